@@ -2,7 +2,6 @@ package gogit
 
 import (
 	"fmt"
-	"os"
 
 	"github.com/TonyGLL/go-git/internal/gogit"
 	"github.com/spf13/cobra"
@@ -14,13 +13,13 @@ var addCmd = &cobra.Command{
 	Long: `Adds the specified file or directory to the staging area (index).
 When a directory is specified, it recursively adds all files within that
 directory, excluding the .gogit directory itself.`,
-	Args: cobra.ExactArgs(1),
+	Args:        cobra.ExactArgs(1),
+	Annotations: worktreeAnnotation,
 	Run: func(cmd *cobra.Command, args []string) {
 		pathToAdd := args[0]
 
 		if err := gogit.Add(pathToAdd); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+			fail(err)
 		}
 
 		fmt.Println("File(s) added successfully.")
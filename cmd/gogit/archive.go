@@ -0,0 +1,52 @@
+package gogit
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/TonyGLL/go-git/internal/gogit"
+	"github.com/spf13/cobra"
+)
+
+var archiveFormat string
+var archivePrefix string
+var archiveOutput string
+var archiveCmd = &cobra.Command{
+	Use:   "archive [--format=tar|tar.gz|zip] [--prefix=<dir>/] [-o <file>] <rev> [<path>...]",
+	Short: "Write the files of a revision as a tar or zip archive",
+	Long: `Writes the files of <rev> (or only those under the given paths) as an
+archive, straight from the object store, to standard output or to the file
+given with -o, whose extension also picks the format when --format is not
+given. Files keep their mode and get the commit date as their time; the
+commit ID is stored in the pax header (tar) or the archive comment (zip).
+Paths with the export-ignore attribute in .gogitattributes are left out.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		format := archiveFormat
+		if format == "" {
+			format = gogit.ArchiveFormatFor(archiveOutput)
+		}
+		out := os.Stdout
+		if archiveOutput != "" {
+			file, err := os.Create(archiveOutput)
+			if err != nil {
+				fail(fmt.Errorf("could not create '%s': %w", archiveOutput, err))
+			}
+			defer file.Close()
+			out = file
+		}
+		if err := gogit.Archive(out, format, archivePrefix, args[0], args[1:]); err != nil {
+			if archiveOutput != "" {
+				os.Remove(archiveOutput)
+			}
+			fail(err)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(archiveCmd)
+	archiveCmd.Flags().StringVar(&archiveFormat, "format", "", "Archive format: tar, tar.gz or zip")
+	archiveCmd.Flags().StringVar(&archivePrefix, "prefix", "", "Directory to put every file under, such as project-1.0/")
+	archiveCmd.Flags().StringVarP(&archiveOutput, "output", "o", "", "Write the archive to this file")
+}
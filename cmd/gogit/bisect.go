@@ -0,0 +1,111 @@
+package gogit
+
+import (
+	"github.com/TonyGLL/go-git/internal/gogit"
+	"github.com/spf13/cobra"
+)
+
+var bisectCmd = &cobra.Command{
+	Use:   "bisect <subcommand>",
+	Short: "Find the commit that introduced a bug by binary search",
+	Long: `Narrows down the commit that introduced a bug. Start with
+"bisect start [<bad> [<good>...]]", then mark commits with "bisect good" and
+"bisect bad"; each time gogit checks out the commit halfway between them
+until the first bad commit is found. "bisect run <cmd>" automates this with
+a script: exit code 0 means good, 125 skip, 1 to 127 bad.
+
+The state lives in .gogit/BISECT_START, .gogit/BISECT_LOG and refs/bisect/.`,
+	Annotations: worktreeAnnotation,
+}
+
+var bisectStartCmd = &cobra.Command{
+	Use:   "start [<bad> [<good>...]]",
+	Short: "Start bisecting",
+	Run: func(cmd *cobra.Command, args []string) {
+		bad, goods := "", []string(nil)
+		if len(args) > 0 {
+			bad, goods = args[0], args[1:]
+		}
+		if err := gogit.BisectStart(bad, goods); err != nil {
+			fail(err)
+		}
+	},
+}
+
+// newBisectMarkCmd builds the good, bad and skip subcommands.
+func newBisectMarkCmd(term, use, short string, args cobra.PositionalArgs) *cobra.Command {
+	return &cobra.Command{
+		Use:   use,
+		Short: short,
+		Args:  args,
+		Run: func(cmd *cobra.Command, args []string) {
+			if _, err := gogit.BisectMark(term, args); err != nil {
+				fail(err)
+			}
+		},
+	}
+}
+
+var bisectResetCmd = &cobra.Command{
+	Use:   "reset [<commit>]",
+	Short: "End the bisection and return to the original branch",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		target := ""
+		if len(args) == 1 {
+			target = args[0]
+		}
+		if err := gogit.BisectReset(target); err != nil {
+			fail(err)
+		}
+	},
+}
+
+var bisectLogCmd = &cobra.Command{
+	Use:   "log",
+	Short: "Show the commands of the current bisection",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := gogit.PrintBisectLog(); err != nil {
+			fail(err)
+		}
+	},
+}
+
+var bisectReplayCmd = &cobra.Command{
+	Use:   "replay <logfile>",
+	Short: "Redo a bisection from a saved log",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := gogit.BisectReplay(args[0]); err != nil {
+			fail(err)
+		}
+	},
+}
+
+var bisectRunCmd = &cobra.Command{
+	Use:   "run <cmd> [<arg>...]",
+	Short: "Bisect automatically with a test command",
+	Args:  cobra.MinimumNArgs(1),
+	// The test command's own flags must not be parsed as ours.
+	DisableFlagParsing: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := gogit.BisectRun(args); err != nil {
+			fail(err)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(bisectCmd)
+	bisectCmd.AddCommand(
+		bisectStartCmd,
+		newBisectMarkCmd("bad", "bad [<rev>]", "Mark a commit (HEAD by default) as bad", cobra.MaximumNArgs(1)),
+		newBisectMarkCmd("good", "good [<rev>...]", "Mark commits (HEAD by default) as good", cobra.ArbitraryArgs),
+		newBisectMarkCmd("skip", "skip [<rev>...]", "Skip commits (HEAD by default) that cannot be tested", cobra.ArbitraryArgs),
+		bisectResetCmd,
+		bisectLogCmd,
+		bisectReplayCmd,
+		bisectRunCmd,
+	)
+}
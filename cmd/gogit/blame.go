@@ -0,0 +1,51 @@
+package gogit
+
+import (
+	"github.com/TonyGLL/go-git/internal/gogit"
+	"github.com/spf13/cobra"
+)
+
+var blameOptions gogit.BlameOptions
+var blamePorcelain bool
+
+var blameCmd = &cobra.Command{
+	Use:   "blame [-L <start>,<end>] [-w] [--ignore-rev <rev>] [--ignore-revs-file <file>] [--porcelain] <file> [<rev>]",
+	Short: "Show which commit last changed each line of a file",
+	Long: `Annotates each line of a file, as of <rev> (HEAD by default), with the
+commit that last changed it, following the first-parent history.
+
+-L limits the output to a range of lines: "<start>,<end>" or
+"<start>,+<count>". -w ignores changes that only touch whitespace.
+Commits given with --ignore-rev, listed in --ignore-revs-file or in the
+files named by the blame.ignoreRevsFile option are looked through: the
+lines they changed are attributed to the commits before them.`,
+	Args:        cobra.RangeArgs(1, 2),
+	Annotations: pagedAnnotation,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 2 {
+			blameOptions.Rev = args[1]
+		}
+		lines, err := gogit.Blame(args[0], &blameOptions)
+		if err != nil {
+			fail(err)
+		}
+
+		switch {
+		case jsonOutput:
+			printJSON(gogit.NewBlameJSON(lines))
+		case blamePorcelain:
+			gogit.PrintBlamePorcelain(args[0], lines)
+		default:
+			gogit.PrintBlame(lines)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(blameCmd)
+	blameCmd.Flags().StringArrayVarP(&blameOptions.Ranges, "lines", "L", nil, "Annotate only the given line range, <start>,<end> or <start>,+<count>")
+	blameCmd.Flags().BoolVarP(&blameOptions.IgnoreWhitespace, "ignore-whitespace", "w", false, "Ignore whitespace-only changes")
+	blameCmd.Flags().StringArrayVar(&blameOptions.IgnoreRevs, "ignore-rev", nil, "Look through the changes of this commit")
+	blameCmd.Flags().StringArrayVar(&blameOptions.IgnoreRevsFiles, "ignore-revs-file", nil, "Look through the commits listed in this file")
+	blameCmd.Flags().BoolVarP(&blamePorcelain, "porcelain", "p", false, "Print a machine-readable format")
+}
@@ -0,0 +1,70 @@
+package gogit
+
+import (
+	"fmt"
+
+	"github.com/TonyGLL/go-git/internal/gogit"
+	"github.com/spf13/cobra"
+)
+
+var branchDelete bool
+var branchForceDelete bool
+var branchRemotes bool
+var branchCmd = &cobra.Command{
+	Use:   "branch [<name> [<start-point>]]",
+	Short: "List, create or delete branches",
+	Long: `With no arguments, lists the local branches and marks the current one
+with '*'. With a name, creates a branch at <start-point> (HEAD by default).
+-d deletes a branch that is merged into HEAD, -D deletes it regardless.
+-r lists the remote-tracking branches instead.`,
+	Args: cobra.MaximumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if branchDelete || branchForceDelete {
+			if len(args) == 0 {
+				fail(fmt.Errorf("branch name required"))
+			}
+			for _, name := range args {
+				if err := gogit.DeleteBranch(name, branchForceDelete); err != nil {
+					fail(err)
+				}
+			}
+			return
+		}
+
+		if len(args) > 0 {
+			startPoint := ""
+			if len(args) == 2 {
+				startPoint = args[1]
+			}
+			if err := gogit.CreateBranch(args[0], startPoint); err != nil {
+				fail(err)
+			}
+			return
+		}
+
+		list := gogit.ListBranches
+		if branchRemotes {
+			list = gogit.ListRemoteBranches
+		}
+		branches, err := list()
+		if err != nil {
+			fail(err)
+		}
+		if jsonOutput {
+			docs := []gogit.BranchJSON{}
+			for _, branch := range branches {
+				docs = append(docs, gogit.BranchJSON{Name: branch.Name, Hash: branch.Hash, Current: branch.Current})
+			}
+			printJSON(docs)
+			return
+		}
+		gogit.PrintBranches(branches)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(branchCmd)
+	branchCmd.Flags().BoolVarP(&branchDelete, "delete", "d", false, "Delete a fully merged branch")
+	branchCmd.Flags().BoolVarP(&branchForceDelete, "force-delete", "D", false, "Delete a branch even if it is not merged")
+	branchCmd.Flags().BoolVarP(&branchRemotes, "remotes", "r", false, "List the remote-tracking branches")
+}
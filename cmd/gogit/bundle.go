@@ -0,0 +1,87 @@
+package gogit
+
+import (
+	"github.com/TonyGLL/go-git/internal/gogit"
+	"github.com/spf13/cobra"
+)
+
+var bundleCmd = &cobra.Command{
+	Use:   "bundle",
+	Short: "Move history through a single file",
+	Long: `Bundles carry branches and tags, and the objects they need, in one file
+for transfers between machines with no network between them. Commits and
+trees are converted to Git's objects on the way out and back on the way
+in, as by export and import, so Git reads the bundles gogit writes and
+the other way round. "gogit clone" accepts a bundle file as its source,
+and "gogit fetch" accepts one as well as a remote whose URL is one.`,
+}
+
+var bundleCreateAll, bundleCreateBranches, bundleCreateTags bool
+var bundleCreateCmd = &cobra.Command{
+	Use:   "create [--all|--branches|--tags] <file> [<rev-list>...]",
+	Short: "Write a bundle of the given history",
+	Long: `Writes the branches and tags named in <rev-list> (or HEAD) to <file>,
+with the objects they need. "^<rev>" or "<rev>..<branch>" leaves out the
+history the receiving repository already has; the bundle then lists the
+commits it builds on as prerequisites. --all, --branches and --tags
+select every branch and tag; --all also records HEAD.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		revs := args[1:]
+		if bundleCreateAll {
+			revs = append(revs, "--all")
+		}
+		if bundleCreateBranches {
+			revs = append(revs, "--branches")
+		}
+		if bundleCreateTags {
+			revs = append(revs, "--tags")
+		}
+		if err := gogit.CreateBundle(args[0], revs); err != nil {
+			fail(err)
+		}
+	},
+}
+
+var bundleVerifyCmd = &cobra.Command{
+	Use:   "verify <file>",
+	Short: "Check that a bundle is valid and applies to this repository",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := gogit.VerifyBundle(args[0]); err != nil {
+			fail(err)
+		}
+	},
+}
+
+var bundleListHeadsCmd = &cobra.Command{
+	Use:   "list-heads <file> [<ref>...]",
+	Short: "List the refs in a bundle",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := gogit.ListBundleHeads(args[0], args[1:]); err != nil {
+			fail(err)
+		}
+	},
+}
+
+var bundleUnbundleCmd = &cobra.Command{
+	Use:   "unbundle <file> [<ref>...]",
+	Short: "Store a bundle's objects and list its refs",
+	Long: `Stores the objects of a bundle in this repository and prints the refs it
+carries, leaving the refs here unchanged.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := gogit.Unbundle(args[0], args[1:]); err != nil {
+			fail(err)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(bundleCmd)
+	bundleCmd.AddCommand(bundleCreateCmd, bundleVerifyCmd, bundleListHeadsCmd, bundleUnbundleCmd)
+	bundleCreateCmd.Flags().BoolVar(&bundleCreateAll, "all", false, "Bundle every branch and tag")
+	bundleCreateCmd.Flags().BoolVar(&bundleCreateBranches, "branches", false, "Bundle every branch")
+	bundleCreateCmd.Flags().BoolVar(&bundleCreateTags, "tags", false, "Bundle every tag")
+}
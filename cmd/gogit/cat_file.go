@@ -0,0 +1,58 @@
+package gogit
+
+import (
+	"fmt"
+
+	"github.com/TonyGLL/go-git/internal/gogit"
+	"github.com/spf13/cobra"
+)
+
+var catFileType bool
+var catFileSize bool
+var catFileExists bool
+var catFilePretty bool
+var catFileCmd = &cobra.Command{
+	Use:   "cat-file (-t | -s | -e | -p) <object>",
+	Short: "Show the type, size or content of an object",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if jsonOutput {
+			hash, err := gogit.ResolveRevision(args[0])
+			if err != nil {
+				fail(err)
+			}
+			doc, err := gogit.NewObjectJSON(hash)
+			if err != nil {
+				fail(err)
+			}
+			printJSON(doc)
+			return
+		}
+
+		var mode string
+		switch {
+		case catFileType:
+			mode = "type"
+		case catFileSize:
+			mode = "size"
+		case catFileExists:
+			mode = "exists"
+		case catFilePretty:
+			mode = "pretty"
+		default:
+			fail(fmt.Errorf("one of -t, -s, -e or -p is required"))
+		}
+
+		if err := gogit.CatFile(args[0], mode); err != nil {
+			fail(err)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(catFileCmd)
+	catFileCmd.Flags().BoolVarP(&catFileType, "type", "t", false, "Show the object type")
+	catFileCmd.Flags().BoolVarP(&catFileSize, "size", "s", false, "Show the object size")
+	catFileCmd.Flags().BoolVarP(&catFileExists, "exists", "e", false, "Exit with an error if the object does not exist")
+	catFileCmd.Flags().BoolVarP(&catFilePretty, "pretty", "p", false, "Print the object content")
+}
@@ -0,0 +1,44 @@
+package gogit
+
+import (
+	"fmt"
+
+	"github.com/TonyGLL/go-git/internal/gogit"
+	"github.com/spf13/cobra"
+)
+
+var checkoutOptions gogit.CheckoutOptions
+var checkoutCmd = &cobra.Command{
+	Use:   "checkout [-b <new-branch>] [<branch>|<commit>]",
+	Short: "Switch branches or check out a commit",
+	Long: `Updates HEAD, the index and the working tree to match a branch or a
+commit. Checking out anything but a branch name leaves HEAD detached.
+
+-b creates a new branch at <commit> (HEAD by default) and switches to it.
+Local changes to files that differ between the two commits stop the
+switch unless -f is given, which discards all local changes.
+
+The post-checkout hook runs afterwards with the old and new commits and
+1; it cannot undo the switch.`,
+	Args:        cobra.MaximumNArgs(1),
+	Annotations: worktreeAnnotation,
+	Run: func(cmd *cobra.Command, args []string) {
+		target := ""
+		if len(args) == 1 {
+			target = args[0]
+		}
+		if target == "" && checkoutOptions.NewBranch == "" {
+			fail(fmt.Errorf("branch or commit required"))
+		}
+		if err := gogit.Checkout(target, &checkoutOptions); err != nil {
+			fail(err)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(checkoutCmd)
+	checkoutCmd.Flags().StringVarP(&checkoutOptions.NewBranch, "branch", "b", "", "Create a new branch and switch to it")
+	checkoutCmd.Flags().BoolVar(&checkoutOptions.Detach, "detach", false, "Detach HEAD at the commit even if it names a branch")
+	checkoutCmd.Flags().BoolVarP(&checkoutOptions.Force, "force", "f", false, "Discard local changes")
+}
@@ -0,0 +1,66 @@
+package gogit
+
+import (
+	"fmt"
+
+	"github.com/TonyGLL/go-git/internal/gogit"
+	"github.com/spf13/cobra"
+)
+
+// sequenceFlags are the --continue/--skip/--abort switches shared by
+// cherry-pick and revert.
+type sequenceFlags struct {
+	Continue bool
+	Skip     bool
+	Abort    bool
+}
+
+func (f *sequenceFlags) register(cmd *cobra.Command, operation string) {
+	cmd.Flags().BoolVar(&f.Continue, "continue", false, fmt.Sprintf("Resume the %s after resolving conflicts", operation))
+	cmd.Flags().BoolVar(&f.Skip, "skip", false, "Skip the current commit and go on with the rest")
+	cmd.Flags().BoolVar(&f.Abort, "abort", false, fmt.Sprintf("Cancel the %s and restore the previous state", operation))
+	cmd.MarkFlagsMutuallyExclusive("continue", "skip", "abort")
+}
+
+// run dispatches to the in-progress actions, or starts a new sequence
+// with start when none of them was given.
+func (f *sequenceFlags) run(operation string, args []string, start func([]string) error) error {
+	if (f.Continue || f.Skip || f.Abort) && len(args) > 0 {
+		return fmt.Errorf("--continue, --skip and --abort take no commits")
+	}
+	switch {
+	case f.Continue:
+		return gogit.ContinueSequence(operation)
+	case f.Skip:
+		return gogit.SkipSequence(operation)
+	case f.Abort:
+		return gogit.AbortSequence(operation)
+	case len(args) == 0:
+		return fmt.Errorf("commit required")
+	}
+	return start(args)
+}
+
+var cherryPickFlags sequenceFlags
+var cherryPickCmd = &cobra.Command{
+	Use:   "cherry-pick (<commit>... | --continue | --skip | --abort)",
+	Short: "Apply the changes introduced by existing commits",
+	Long: `Applies the changes each commit introduced on top of HEAD, recording
+one new commit per pick. The new commits keep the original author, date
+and message, followed by "(cherry picked from commit <hash>)".
+
+When a change conflicts with HEAD, the cherry-pick stops with conflict
+markers in the affected files. Fix them, stage them with "gogit add" and
+run --continue; --skip drops the commit and --abort restores the branch.`,
+	Annotations: worktreeAnnotation,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := cherryPickFlags.run("cherry-pick", args, gogit.CherryPick); err != nil {
+			fail(err)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(cherryPickCmd)
+	cherryPickFlags.register(cherryPickCmd, "cherry-pick")
+}
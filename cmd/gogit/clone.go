@@ -0,0 +1,29 @@
+package gogit
+
+import (
+	"github.com/TonyGLL/go-git/internal/gogit"
+	"github.com/spf13/cobra"
+)
+
+var cloneCmd = &cobra.Command{
+	Use:   "clone <repository> [<directory>]",
+	Short: "Copy a repository into a new directory",
+	Long: `Creates <directory> (named after the repository by default) with a copy
+of the repository's objects, adds the source as the "origin" remote and
+checks out the branch its HEAD points to. The repository may also be a
+bundle file made by "gogit bundle create".`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		dir := ""
+		if len(args) == 2 {
+			dir = args[1]
+		}
+		if err := gogit.Clone(args[0], dir); err != nil {
+			fail(err)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(cloneCmd)
+}
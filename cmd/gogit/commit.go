@@ -2,26 +2,90 @@ package gogit
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"strings"
 
 	"github.com/TonyGLL/go-git/internal/gogit"
 	"github.com/spf13/cobra"
 )
 
-var commitMessage string
+var commitMessages []string
+var commitFile string
+var commitOptions gogit.CommitOptions
+var commitNoEdit bool
 var addCommitCmd = &cobra.Command{
 	Use:   "commit",
 	Short: "Add commit message to gogit repository",
+	Long: `Records the staged changes as a new commit.
+
+The message comes from -m (repeat it to add paragraphs) or from a file
+with -F (use "-F -" to read standard input). Without either, the editor
+from $GOGIT_EDITOR, core.editor, $VISUAL or $EDITOR is opened on a
+template listing the status; lines starting with '#' are removed.
+
+--amend replaces the last commit instead, keeping its parents and, unless
+a new one is given, its message.
+
+A commit whose tree is identical to its parent's is refused unless
+--allow-empty is given. -a stages modified and deleted tracked files
+first.
+
+The pre-commit, prepare-commit-msg, commit-msg and post-commit hooks
+from .gogit/hooks (or core.hooksPath) run around the commit; -n skips
+pre-commit and commit-msg.`,
+	Args:        cobra.NoArgs,
+	Annotations: worktreeAnnotation,
 	Run: func(cmd *cobra.Command, args []string) {
-		if err := gogit.AddCommit(&commitMessage); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+		if len(commitMessages) > 0 && commitFile != "" {
+			fail(fmt.Errorf("options -m and -F cannot be used together"))
+		}
+
+		switch {
+		case len(commitMessages) > 0:
+			commitOptions.Message = strings.Join(commitMessages, "\n\n")
+		case commitFile != "":
+			message, err := readMessageFile(commitFile)
+			if err != nil {
+				fail(err)
+			}
+			commitOptions.Message = message
+		case commitNoEdit && !commitOptions.Amend:
+			fail(fmt.Errorf("--no-edit requires a message from -m, -F or --amend"))
+		case !commitNoEdit:
+			commitOptions.Edit = true
+		}
+
+		if err := gogit.AddCommit(&commitOptions); err != nil {
+			fail(err)
 		}
 	},
 }
 
+// readMessageFile reads a commit message from path, or from stdin for "-".
+func readMessageFile(path string) (string, error) {
+	if path == "-" {
+		content, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return "", fmt.Errorf("error reading commit message from stdin: %w", err)
+		}
+		return string(content), nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("could not read log file '%s': %w", path, err)
+	}
+	return string(content), nil
+}
+
 func init() {
 	RootCmd.AddCommand(addCommitCmd)
-	addCommitCmd.Flags().StringVarP(&commitMessage, "message", "m", "", "Commit message (mandatory)")
-	addCommitCmd.MarkFlagRequired("message")
+	addCommitCmd.Flags().StringArrayVarP(&commitMessages, "message", "m", nil, "Commit message; repeat for multiple paragraphs")
+	addCommitCmd.Flags().StringVarP(&commitFile, "file", "F", "", "Take the commit message from a file, or stdin with '-'")
+	addCommitCmd.Flags().BoolVarP(&commitOptions.Edit, "edit", "e", false, "Edit the message given with -m or -F before committing")
+	addCommitCmd.Flags().BoolVar(&commitNoEdit, "no-edit", false, "Use the message as is, without opening an editor")
+	addCommitCmd.Flags().BoolVar(&commitOptions.Amend, "amend", false, "Replace the last commit with a new one")
+	addCommitCmd.Flags().BoolVar(&commitOptions.AllowEmpty, "allow-empty", false, "Allow a commit that records no changes")
+	addCommitCmd.Flags().BoolVarP(&commitOptions.NoVerify, "no-verify", "n", false, "Skip the pre-commit and commit-msg hooks")
+	addCommitCmd.Flags().BoolVarP(&commitOptions.All, "all", "a", false, "Stage modified and deleted tracked files before committing")
 }
@@ -0,0 +1,70 @@
+package gogit
+
+import (
+	"fmt"
+
+	"github.com/TonyGLL/go-git/internal/gogit"
+	"github.com/spf13/cobra"
+)
+
+var configGlobal bool
+var configUnset bool
+var configList bool
+var configCmd = &cobra.Command{
+	Use:   "config [--global] (--list | --unset <key> | <key> [<value>])",
+	Short: "Get and set repository or global options",
+	Long: `Reads and writes options in .gogit/config, or in ~/.gogitconfig with
+--global. Keys have the form section.name or section.subsection.name,
+for example color.ui or core.pager.`,
+	Args: cobra.MaximumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		switch {
+		case configList:
+			entries, err := gogit.ListConfig()
+			if err != nil {
+				fail(err)
+			}
+			if jsonOutput {
+				docs := []gogit.ConfigEntryJSON{}
+				for _, entry := range entries {
+					docs = append(docs, gogit.ConfigEntryJSON{Key: entry.Key, Value: entry.Value})
+				}
+				printJSON(docs)
+				return
+			}
+			for _, entry := range entries {
+				fmt.Printf("%s=%s\n", entry.Key, entry.Value)
+			}
+		case len(args) == 0:
+			fail(fmt.Errorf("a key is required"))
+		case configUnset:
+			if err := gogit.UnsetConfig(args[0], configGlobal); err != nil {
+				fail(err)
+			}
+		case len(args) == 2:
+			if err := gogit.SetConfig(args[0], args[1], configGlobal); err != nil {
+				fail(err)
+			}
+		default:
+			value, ok, err := gogit.GetConfig(args[0])
+			if err != nil {
+				fail(err)
+			}
+			if !ok {
+				fail(fmt.Errorf("key '%s' is not set", args[0]))
+			}
+			if jsonOutput {
+				printJSON(gogit.ConfigEntryJSON{Key: args[0], Value: value})
+				return
+			}
+			fmt.Println(value)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(configCmd)
+	configCmd.Flags().BoolVar(&configGlobal, "global", false, "Use the per-user config file")
+	configCmd.Flags().BoolVar(&configUnset, "unset", false, "Remove a key")
+	configCmd.Flags().BoolVarP(&configList, "list", "l", false, "List all options")
+}
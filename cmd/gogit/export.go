@@ -0,0 +1,25 @@
+package gogit
+
+import (
+	"github.com/TonyGLL/go-git/internal/gogit"
+	"github.com/spf13/cobra"
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export <dir>",
+	Short: "Write the history as a Git repository",
+	Long: `Writes the branches, tags and HEAD of the current repository as a Git
+repository in <dir>/.git, or directly in <dir> as a bare repository when
+its name ends in ".git". Exporting again into the same place adds the new
+commits and moves the refs.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := gogit.ExportGit(args[0]); err != nil {
+			fail(err)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(exportCmd)
+}
@@ -0,0 +1,28 @@
+package gogit
+
+import (
+	"os"
+
+	"github.com/TonyGLL/go-git/internal/gogit"
+	"github.com/spf13/cobra"
+)
+
+var fastExportAll bool
+var fastExportCmd = &cobra.Command{
+	Use:   "fast-export [--all] [<branch-or-tag>...]",
+	Short: "Write history as a Git fast-import stream",
+	Long: `Writes the history of the named branches and tags (or of all of them
+with --all) to standard output in Git's fast-import format, which
+"gogit fast-import", "git fast-import" and other converters read. Every
+file is written with mode 100644.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := gogit.FastExport(os.Stdout, args, fastExportAll); err != nil {
+			fail(err)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(fastExportCmd)
+	fastExportCmd.Flags().BoolVar(&fastExportAll, "all", false, "Export every branch and tag")
+}
@@ -0,0 +1,32 @@
+package gogit
+
+import (
+	"os"
+
+	"github.com/TonyGLL/go-git/internal/gogit"
+	"github.com/spf13/cobra"
+)
+
+var fastImportForce bool
+var fastImportCmd = &cobra.Command{
+	Use:   "fast-import [--force]",
+	Short: "Read history from a Git fast-import stream",
+	Long: `Reads a Git fast-import stream, as written by "gogit fast-export",
+"git fast-export" or other converters, from standard input. Supported
+commands are blob, commit (with from, merge and the M, D, R, C and
+deleteall file changes), reset, tag, mark, progress, checkpoint, feature
+and done. Tags become lightweight tags. Branches are updated when the
+stream ends; one that would lose commits is left alone unless --force is
+given.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := gogit.FastImport(os.Stdin, fastImportForce); err != nil {
+			fail(err)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(fastImportCmd)
+	fastImportCmd.Flags().BoolVarP(&fastImportForce, "force", "f", false, "Update branches even when they would lose commits")
+}
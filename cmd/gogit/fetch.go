@@ -0,0 +1,33 @@
+package gogit
+
+import (
+	"github.com/TonyGLL/go-git/internal/gogit"
+	"github.com/spf13/cobra"
+)
+
+var fetchPrune bool
+var fetchCmd = &cobra.Command{
+	Use:   "fetch [--prune] [<remote>|<bundle>]",
+	Short: "Download objects and refs from a remote",
+	Long: `Copies the commits of the remote's branches and tags that are missing
+here and moves the remote-tracking branches (refs/remotes/<remote>/*) to
+match. The remote defaults to the current branch's upstream, or origin.
+A remote whose URL is a bundle file fetches from the bundle. A bundle file
+can also be named directly: its tags are created and its branches are
+recorded in FETCH_HEAD, which revisions can name.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := ""
+		if len(args) == 1 {
+			name = args[0]
+		}
+		if err := gogit.Fetch(name, fetchPrune); err != nil {
+			fail(err)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(fetchCmd)
+	fetchCmd.Flags().BoolVarP(&fetchPrune, "prune", "p", false, "Delete tracking branches the remote no longer has")
+}
@@ -0,0 +1,84 @@
+package gogit
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/TonyGLL/go-git/internal/gogit"
+	"github.com/spf13/cobra"
+)
+
+var hashObjectWrite bool
+var hashObjectStdin bool
+var hashObjectNoFilters bool
+var hashObjectCmd = &cobra.Command{
+	Use:   "hash-object [-w] [--no-filters] (--stdin | <file>...)",
+	Short: "Compute the blob hash of files, optionally storing them",
+	Long: `Prints the blob hash of each file, as "gogit add" would store it: after
+the clean filter and line ending conversion its .gogitattributes ask for,
+unless --no-filters is given. Standard input is hashed as it is.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if !hashObjectStdin && len(args) == 0 {
+			fail(fmt.Errorf("no input given; pass files or --stdin"))
+		}
+
+		var docs []gogit.HashObjectJSON
+		report := func(path, hash string) {
+			if jsonOutput {
+				docs = append(docs, gogit.HashObjectJSON{Path: path, Hash: hash})
+				return
+			}
+			fmt.Println(hash)
+		}
+
+		if hashObjectStdin {
+			// The header needs the size up front, so standard input is
+			// read whole; files are streamed.
+			content, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				fail(fmt.Errorf("error reading stdin: %w", err))
+			}
+			hash, err := gogit.HashBlob(bytes.NewReader(content), int64(len(content)), hashObjectWrite)
+			if err != nil {
+				fail(err)
+			}
+			report("-", hash)
+		}
+		for _, path := range args {
+			if !hashObjectNoFilters {
+				hash, err := gogit.HashFile(path, hashObjectWrite)
+				if err != nil {
+					fail(err)
+				}
+				report(path, hash)
+				continue
+			}
+			file, err := os.Open(path)
+			if err != nil {
+				fail(fmt.Errorf("error reading file %s: %w", path, err))
+			}
+			info, err := file.Stat()
+			if err != nil {
+				fail(fmt.Errorf("error reading file %s: %w", path, err))
+			}
+			hash, err := gogit.HashBlob(file, info.Size(), hashObjectWrite)
+			file.Close()
+			if err != nil {
+				fail(err)
+			}
+			report(path, hash)
+		}
+		if jsonOutput {
+			printJSON(docs)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(hashObjectCmd)
+	hashObjectCmd.Flags().BoolVarP(&hashObjectWrite, "write", "w", false, "Write the object into the object database")
+	hashObjectCmd.Flags().BoolVar(&hashObjectStdin, "stdin", false, "Read the content from standard input")
+	hashObjectCmd.Flags().BoolVar(&hashObjectNoFilters, "no-filters", false, "Hash the files as they are, without converting them")
+}
@@ -0,0 +1,30 @@
+package gogit
+
+import (
+	"github.com/TonyGLL/go-git/internal/gogit"
+	"github.com/spf13/cobra"
+)
+
+var importForce bool
+var importCmd = &cobra.Command{
+	Use:   "import [--force] <path-to-git-repository>",
+	Short: "Import the history of a Git repository",
+	Long: `Reads the branches and tags of a Git repository (a working tree, its
+.git directory or a bare repository), with loose and packed objects, and
+recreates their history in the current repository. Commits get new IDs
+since gogit stores trees and commits differently; file contents keep
+theirs. Branches and tags that already exist are left alone unless
+--force is given. In a repository without commits, HEAD then follows the
+Git repository's and its files are checked out.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := gogit.ImportGit(args[0], importForce); err != nil {
+			fail(err)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(importCmd)
+	importCmd.Flags().BoolVarP(&importForce, "force", "f", false, "Overwrite branches and tags that already exist")
+}
@@ -1,30 +1,36 @@
 package gogit
 
 import (
-	"fmt"
-	"os"
-
 	"github.com/TonyGLL/go-git/internal/gogit"
 	"github.com/spf13/cobra"
 )
 
+var initBare bool
 var initCmd = &cobra.Command{
-	Use:   "init [directory]",
+	Use:   "init [--bare] [directory]",
 	Short: "Creates a new gogit repository",
-	Args:  cobra.MaximumNArgs(1),
+	Long: `Creates a repository in a .gogit directory inside the working tree. With
+--bare the repository has no working tree: its objects, refs and HEAD sit
+directly in the directory, which suits a shared repository that others
+push to.`,
+	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		targetDir := "."
 		if len(args) > 0 {
 			targetDir = args[0]
 		}
 
-		if err := gogit.InitRepo(targetDir); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+		initRepo := gogit.InitRepo
+		if initBare {
+			initRepo = gogit.InitBareRepo
+		}
+		if err := initRepo(targetDir); err != nil {
+			fail(err)
 		}
 	},
 }
 
 func init() {
 	RootCmd.AddCommand(initCmd)
+	initCmd.Flags().BoolVar(&initBare, "bare", false, "Create a repository without a working tree")
 }
@@ -0,0 +1,99 @@
+package gogit
+
+import (
+	"github.com/TonyGLL/go-git/internal/gogit"
+	"github.com/spf13/cobra"
+)
+
+var lfsCmd = &cobra.Command{
+	Use:   "lfs",
+	Short: "Keep large files out of the object store",
+	Long: `Files whose path has the filter=lfs attribute in .gogitattributes are
+staged as small pointer blobs, in Git LFS's format, while their content
+goes to a store of its own under .gogit/lfs/objects, named by its
+SHA-256. Checkout puts the content back; fetch and push move the large
+objects the transferred commits point to.`,
+}
+
+var lfsTrackCmd = &cobra.Command{
+	Use:   "track [<pattern>...]",
+	Short: "Store files matching patterns as large files",
+	Long: `Adds "<pattern> filter=lfs" to the top-level .gogitattributes for each
+pattern, or lists the patterns already tracked when none is given. Files
+already staged are converted the next time they are added.`,
+	Annotations: worktreeAnnotation,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := gogit.LFSTrack(args); err != nil {
+			fail(err)
+		}
+	},
+}
+
+var lfsCheckoutCmd = &cobra.Command{
+	Use:   "checkout",
+	Short: "Fill in large files checked out as pointers",
+	Long: `Replaces the large files of the index that were checked out as their
+pointers, because their content was not here at the time, with the
+content now in the large object store. Fetching from a remote that has
+the content brings it in.`,
+	Args:        cobra.NoArgs,
+	Annotations: worktreeAnnotation,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := gogit.LFSCheckout(); err != nil {
+			fail(err)
+		}
+	},
+}
+
+var lfsLsFilesCmd = &cobra.Command{
+	Use:   "ls-files [<rev>]",
+	Short: "List the large files in a commit",
+	Long: `Lists the large files in the tree of <rev> (HEAD by default): the start
+of each one's SHA-256, "*" if its content is here or "-" if only its
+pointer is, and its path.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		rev := "HEAD"
+		if len(args) > 0 {
+			rev = args[0]
+		}
+		if err := gogit.LFSLsFiles(rev); err != nil {
+			fail(err)
+		}
+	},
+}
+
+var lfsPruneDryRun bool
+var lfsPruneCmd = &cobra.Command{
+	Use:   "prune [--dry-run]",
+	Short: "Delete large objects that are no longer needed",
+	Long: `Deletes the large objects that neither the index, nor the tree of any
+branch, tag, remote-tracking branch or HEAD, nor any commit missing from
+the remote-tracking branches points to.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := gogit.LFSPrune(lfsPruneDryRun); err != nil {
+			fail(err)
+		}
+	},
+}
+
+var lfsFsckCmd = &cobra.Command{
+	Use:   "fsck",
+	Short: "Check the large objects",
+	Long: `Checks every large object against its SHA-256, moving corrupt ones to
+.gogit/lfs/bad, and reports the large files of HEAD and the index whose
+content is missing.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := gogit.LFSFsck(); err != nil {
+			fail(err)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(lfsCmd)
+	lfsCmd.AddCommand(lfsTrackCmd, lfsCheckoutCmd, lfsLsFilesCmd, lfsPruneCmd, lfsFsckCmd)
+	lfsPruneCmd.Flags().BoolVar(&lfsPruneDryRun, "dry-run", false, "Only list the objects that would be deleted")
+}
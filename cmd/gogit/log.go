@@ -1,24 +1,78 @@
 package gogit
 
 import (
-	"fmt"
-	"os"
+	"strings"
 
 	"github.com/TonyGLL/go-git/internal/gogit"
 	"github.com/spf13/cobra"
 )
 
+var logOptions gogit.LogOptions
 var logCmd = &cobra.Command{
 	Use:   "log",
 	Short: "Show commits logs",
+	Long: `Shows the commit history of the current branch.
+
+The layout is chosen with --pretty, which accepts the presets oneline,
+short, medium (the default), full and fuller, or a template such as
+--pretty=format:"%h %an %s". --format=<template> is a shorthand for
+--pretty=tformat:<template>. Supported placeholders:
+
+  %H, %h   commit hash (full, abbreviated)
+  %T, %t   tree hash (full, abbreviated)
+  %P, %p   parent hash (full, abbreviated)
+  %an, %ae author name and email
+  %ad      author date, honoring --date
+  %ar, %at, %ai, %aI, %as
+           author date as relative, unix, iso, strict iso and short
+  %s, %b   subject and body of the message
+  %B       raw message
+  %n, %%   newline and a literal '%'
+  %Cred, %Cgreen, %Cblue, %Creset, %C(<color>)
+           switch colors
+
+--date accepts default, relative, iso, iso-strict, rfc, short, unix,
+raw and local.`,
+	Annotations: pagedAnnotation,
 	Run: func(cmd *cobra.Command, args []string) {
-		if err := gogit.LogRepo(); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+		if cmd.Flags().Changed("format") {
+			logOptions.Format = formatFlag(cmd)
+		}
+
+		if jsonOutput {
+			hash, err := gogit.ResolveRevision("HEAD")
+			if err != nil {
+				fail(err)
+			}
+			err = gogit.WalkHistory(hash, func(commit *gogit.Commit) error {
+				printJSON(gogit.NewCommitJSON(commit))
+				return nil
+			})
+			if err != nil {
+				fail(err)
+			}
+			return
+		}
+
+		if err := gogit.LogRepo(&logOptions); err != nil {
+			fail(err)
 		}
 	},
 }
 
+// formatFlag returns --format as a --pretty value: a template, which may
+// itself start with format: or tformat:, is a tformat: template.
+func formatFlag(cmd *cobra.Command) string {
+	format, _ := cmd.Flags().GetString("format")
+	if strings.HasPrefix(format, "format:") || strings.HasPrefix(format, "tformat:") {
+		return format
+	}
+	return "tformat:" + format
+}
+
 func init() {
 	RootCmd.AddCommand(logCmd)
+	logCmd.Flags().StringVar(&logOptions.Format, "pretty", "medium", "Pretty-print format: oneline, short, medium, full, fuller or format:<template>")
+	logCmd.Flags().String("format", "", "Placeholder template, same as --pretty=tformat:<template>")
+	logCmd.Flags().StringVar(&logOptions.DateMode, "date", "default", "Date format: default, relative, iso, iso-strict, rfc, short, unix, raw or local")
 }
@@ -0,0 +1,37 @@
+package gogit
+
+import (
+	"github.com/TonyGLL/go-git/internal/gogit"
+	"github.com/spf13/cobra"
+)
+
+var lsFilesStage bool
+var lsFilesCmd = &cobra.Command{
+	Use:         "ls-files",
+	Short:       "List the files in the index",
+	Args:        cobra.NoArgs,
+	Annotations: worktreeAnnotation,
+	Run: func(cmd *cobra.Command, args []string) {
+		if jsonOutput {
+			indexMap, err := gogit.ReadIndex()
+			if err != nil {
+				fail(err)
+			}
+			docs := []gogit.IndexEntryJSON{}
+			for _, entry := range gogit.NewTreeEntriesJSON(indexMap) {
+				docs = append(docs, gogit.IndexEntryJSON{Mode: entry.Mode, Hash: entry.Hash, Path: entry.Path})
+			}
+			printJSON(docs)
+			return
+		}
+
+		if err := gogit.LsFiles(lsFilesStage); err != nil {
+			fail(err)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(lsFilesCmd)
+	lsFilesCmd.Flags().BoolVarP(&lsFilesStage, "stage", "s", false, "Show mode, object hash and stage number")
+}
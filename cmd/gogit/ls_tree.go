@@ -0,0 +1,36 @@
+package gogit
+
+import (
+	"github.com/TonyGLL/go-git/internal/gogit"
+	"github.com/spf13/cobra"
+)
+
+var lsTreeNameOnly bool
+var lsTreeCmd = &cobra.Command{
+	Use:   "ls-tree <tree-ish>",
+	Short: "List the contents of a tree object",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if jsonOutput {
+			treeHash, err := gogit.ResolveTree(args[0])
+			if err != nil {
+				fail(err)
+			}
+			tree, err := gogit.ReadTree(treeHash)
+			if err != nil {
+				fail(err)
+			}
+			printJSON(gogit.NewTreeEntriesJSON(tree))
+			return
+		}
+
+		if err := gogit.LsTree(args[0], lsTreeNameOnly); err != nil {
+			fail(err)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(lsTreeCmd)
+	lsTreeCmd.Flags().BoolVar(&lsTreeNameOnly, "name-only", false, "List only file names")
+}
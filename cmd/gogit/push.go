@@ -0,0 +1,60 @@
+package gogit
+
+import (
+	"strings"
+
+	"github.com/TonyGLL/go-git/internal/gogit"
+	"github.com/spf13/cobra"
+)
+
+// leaseAll is the --force-with-lease value used when the flag is given
+// without one: every pushed ref is expected to match its tracking ref.
+const leaseAll = "*"
+
+var pushOptions gogit.PushOptions
+var pushLeases []string
+var pushCmd = &cobra.Command{
+	Use:   "push [-f | --force-with-lease[=<ref>[:<expect>]]] [-u] [<remote> [<refspec>...]]",
+	Short: "Update remote refs with local commits",
+	Long: `Copies the commits the remote is missing and moves its refs. Each
+refspec is <src>[:<dst>]; a leading "+" forces that update and ":<dst>"
+deletes <dst>. By default the current branch is pushed to the branch of
+the same name.
+
+Updates that are not fast-forwards are rejected. --force allows them;
+--force-with-lease only does so while the remote ref still has the
+expected value: <expect>, or the ref's remote-tracking branch.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) > 0 {
+			pushOptions.Remote, pushOptions.Refspecs = args[0], args[1:]
+		}
+		if cmd.Flags().Changed("force-with-lease") {
+			pushOptions.ForceWithLease = true
+			pushOptions.Leases = make(map[string]string)
+			for _, lease := range pushLeases {
+				if lease == leaseAll {
+					continue
+				}
+				ref, expect, explicit := strings.Cut(lease, ":")
+				if !explicit {
+					continue
+				}
+				if !strings.HasPrefix(ref, "refs/") {
+					ref = "refs/heads/" + ref
+				}
+				pushOptions.Leases[ref] = expect
+			}
+		}
+		if err := gogit.Push(&pushOptions); err != nil {
+			fail(err)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(pushCmd)
+	pushCmd.Flags().BoolVarP(&pushOptions.Force, "force", "f", false, "Allow updates that are not fast-forwards")
+	pushCmd.Flags().StringArrayVar(&pushLeases, "force-with-lease", nil, "Force only while the remote ref has the expected value")
+	pushCmd.Flags().Lookup("force-with-lease").NoOptDefVal = leaseAll
+	pushCmd.Flags().BoolVarP(&pushOptions.SetUpstream, "set-upstream", "u", false, "Track the pushed branches")
+}
@@ -0,0 +1,56 @@
+package gogit
+
+import (
+	"fmt"
+
+	"github.com/TonyGLL/go-git/internal/gogit"
+	"github.com/spf13/cobra"
+)
+
+var rebaseOptions gogit.RebaseOptions
+var rebaseFlags sequenceFlags
+var rebaseCmd = &cobra.Command{
+	Use:   "rebase [-i] [--onto <newbase>] (<upstream> | --continue | --skip | --abort)",
+	Short: "Replay commits on top of another base",
+	Long: `Replays, one by one, the commits of the current branch that are not on
+<upstream> on top of <upstream> (or of --onto <newbase>), then moves the
+branch to the result.
+
+With -i the list of commits opens in the editor first. Each line starts
+with a command: pick, reword, edit, squash, fixup, drop, or exec followed
+by a shell command. Lines can be reordered or removed.
+
+A conflict, an "edit" line or a failing exec stops the rebase. The state
+is kept in .gogit/rebase-merge; resolve the problem and run --continue,
+or use --skip or --abort. Every step is recorded in the reflog.`,
+	Args:        cobra.MaximumNArgs(1),
+	Annotations: worktreeAnnotation,
+	Run: func(cmd *cobra.Command, args []string) {
+		var err error
+		switch {
+		case (rebaseFlags.Continue || rebaseFlags.Skip || rebaseFlags.Abort) && len(args) > 0:
+			err = fmt.Errorf("--continue, --skip and --abort take no upstream")
+		case rebaseFlags.Continue:
+			err = gogit.ContinueRebase()
+		case rebaseFlags.Skip:
+			err = gogit.SkipRebase()
+		case rebaseFlags.Abort:
+			err = gogit.AbortRebase()
+		case len(args) == 0:
+			err = fmt.Errorf("upstream required")
+		default:
+			rebaseOptions.Upstream = args[0]
+			err = gogit.Rebase(&rebaseOptions)
+		}
+		if err != nil {
+			fail(err)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(rebaseCmd)
+	rebaseCmd.Flags().BoolVarP(&rebaseOptions.Interactive, "interactive", "i", false, "Edit the list of commits before replaying them")
+	rebaseCmd.Flags().StringVar(&rebaseOptions.Onto, "onto", "", "Replay the commits on <newbase> instead of <upstream>")
+	rebaseFlags.register(rebaseCmd, "rebase")
+}
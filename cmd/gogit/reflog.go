@@ -0,0 +1,40 @@
+package gogit
+
+import (
+	"github.com/TonyGLL/go-git/internal/gogit"
+	"github.com/spf13/cobra"
+)
+
+var reflogCmd = &cobra.Command{
+	Use:   "reflog [show] [<ref>]",
+	Short: "Show where a ref has pointed",
+	Long: `Lists the updates recorded for a ref (HEAD by default), newest first.
+Entry n can be used as a revision: HEAD@{n} or <branch>@{n}.`,
+	Args:        cobra.MaximumNArgs(2),
+	Annotations: pagedAnnotation,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) > 0 && args[0] == "show" {
+			args = args[1:]
+		}
+		ref := "HEAD"
+		if len(args) > 0 {
+			ref = args[0]
+		}
+
+		if jsonOutput {
+			entries, err := gogit.ReflogFor(ref)
+			if err != nil {
+				fail(err)
+			}
+			printJSON(gogit.NewReflogJSON(entries))
+			return
+		}
+		if err := gogit.PrintReflog(ref); err != nil {
+			fail(err)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(reflogCmd)
+}
@@ -0,0 +1,66 @@
+package gogit
+
+import (
+	"github.com/TonyGLL/go-git/internal/gogit"
+	"github.com/spf13/cobra"
+)
+
+var remoteVerbose bool
+var remoteCmd = &cobra.Command{
+	Use:   "remote [-v]",
+	Short: "Manage the repositories you fetch from and push to",
+	Long: `Lists the configured remotes; -v adds their URLs. A remote is another
+gogit repository, given by its path. Its branches are tracked under
+refs/remotes/<name>/.`,
+	Args: cobra.NoArgs,
+	Run:  listRemotes,
+}
+
+var remoteListCmd = &cobra.Command{
+	Use:   "list [-v]",
+	Short: "List the remotes, as \"gogit remote\" does",
+	Args:  cobra.NoArgs,
+	Run:   listRemotes,
+}
+
+func listRemotes(cmd *cobra.Command, args []string) {
+	remotes, err := gogit.ListRemotes()
+	if err != nil {
+		fail(err)
+	}
+	if jsonOutput {
+		printJSON(gogit.NewRemotesJSON(remotes))
+		return
+	}
+	gogit.PrintRemotes(remotes, remoteVerbose)
+}
+
+var remoteAddCmd = &cobra.Command{
+	Use:   "add <name> <url>",
+	Short: "Add a remote",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := gogit.AddRemote(args[0], args[1]); err != nil {
+			fail(err)
+		}
+	},
+}
+
+var remoteRemoveCmd = &cobra.Command{
+	Use:     "remove <name>",
+	Aliases: []string{"rm"},
+	Short:   "Remove a remote and its tracking branches",
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := gogit.RemoveRemote(args[0]); err != nil {
+			fail(err)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(remoteCmd)
+	remoteCmd.AddCommand(remoteListCmd, remoteAddCmd, remoteRemoveCmd)
+	remoteCmd.Flags().BoolVarP(&remoteVerbose, "verbose", "v", false, "Show the URL of each remote")
+	remoteListCmd.Flags().BoolVarP(&remoteVerbose, "verbose", "v", false, "Show the URL of each remote")
+}
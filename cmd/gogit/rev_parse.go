@@ -0,0 +1,56 @@
+package gogit
+
+import (
+	"fmt"
+
+	"github.com/TonyGLL/go-git/internal/gogit"
+	"github.com/spf13/cobra"
+)
+
+var revParseShort bool
+var revParseAbbrevRef bool
+var revParseCmd = &cobra.Command{
+	Use:   "rev-parse <rev>...",
+	Short: "Resolve revisions to object hashes",
+	Long: `Prints the object hash of each revision. Revisions can be full or
+abbreviated hashes, branch or tag names, HEAD, and any of those followed
+by ~<n> or ^ to walk back through parents.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		docs := []gogit.RevisionJSON{}
+		for _, rev := range args {
+			var value string
+			var err error
+			if revParseAbbrevRef {
+				value, err = gogit.AbbreviateRef(rev)
+			} else {
+				value, err = gogit.ResolveRevision(rev)
+				if err == nil && revParseShort {
+					value = value[:7]
+				}
+			}
+			if err != nil {
+				fail(err)
+			}
+
+			if jsonOutput && revParseAbbrevRef {
+				docs = append(docs, gogit.RevisionJSON{Rev: rev, Name: value})
+				continue
+			}
+			if jsonOutput {
+				docs = append(docs, gogit.RevisionJSON{Rev: rev, Hash: value})
+				continue
+			}
+			fmt.Println(value)
+		}
+		if jsonOutput {
+			printJSON(docs)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(revParseCmd)
+	revParseCmd.Flags().BoolVar(&revParseShort, "short", false, "Print abbreviated hashes")
+	revParseCmd.Flags().BoolVar(&revParseAbbrevRef, "abbrev-ref", false, "Print short ref names instead of hashes")
+}
@@ -0,0 +1,29 @@
+package gogit
+
+import (
+	"github.com/TonyGLL/go-git/internal/gogit"
+	"github.com/spf13/cobra"
+)
+
+var revertFlags sequenceFlags
+var revertCmd = &cobra.Command{
+	Use:   "revert (<commit>... | --continue | --skip | --abort)",
+	Short: "Record new commits that undo existing ones",
+	Long: `Records, for each commit, a new commit that reverses its changes.
+History is not rewritten. The message reads 'Revert "<subject>"' and
+"This reverts commit <hash>.".
+
+Conflicts stop the revert the same way they stop a cherry-pick: resolve
+them, stage the files and run --continue, or use --skip or --abort.`,
+	Annotations: worktreeAnnotation,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := revertFlags.run("revert", args, gogit.Revert); err != nil {
+			fail(err)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(revertCmd)
+	revertFlags.register(revertCmd, "revert")
+}
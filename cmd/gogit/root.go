@@ -4,20 +4,104 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/TonyGLL/go-git/internal/gogit"
 	"github.com/spf13/cobra"
 )
 
+// jsonOutput is set by the global --json flag.
+var jsonOutput bool
+
+// colorMode and noPager hold the global --color and --no-pager flags.
+var colorMode string
+var noPager bool
+
+// stopPager flushes and closes the pager started for the current command.
+var stopPager = func() {}
+
+// pagedAnnotation marks commands whose output goes through the pager.
+var pagedAnnotation = map[string]string{"pager": "true"}
+
+// worktreeAnnotation marks commands, or groups of subcommands, that need
+// a working tree; bare repositories refuse them.
+var worktreeAnnotation = map[string]string{"worktree": "true"}
+
 var RootCmd = &cobra.Command{
 	Use:   "gogit",
 	Short: "gogit - a simplified Git replica written in Go",
 	Long: `gogit is a minimalist version control system
 created as a learning project to understand the fundamental
 concepts of Git.`,
+	SilenceErrors: true,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		stdoutIsTerminal := gogit.IsTerminal(os.Stdout)
+		mode := ""
+		if cmd.Flags().Changed("color") {
+			mode = colorMode
+		}
+		useColor, err := gogit.UseColor(mode, stdoutIsTerminal)
+		if err != nil {
+			fail(err)
+		}
+		gogit.SetColorEnabled(useColor && !jsonOutput)
+
+		gogit.FindRepo()
+		for c := cmd; c != nil; c = c.Parent() {
+			if c.Annotations["worktree"] == "true" {
+				if err := gogit.RequireWorkTree(); err != nil {
+					fail(err)
+				}
+				break
+			}
+		}
+
+		if cmd.Annotations["pager"] == "true" && stdoutIsTerminal && !jsonOutput && !noPager {
+			stop, err := gogit.StartPager(cmd.Name())
+			if err != nil {
+				fail(err)
+			}
+			stopPager = stop
+		}
+	},
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		stopPager()
+	},
+}
+
+func init() {
+	RootCmd.PersistentFlags().BoolVar(&jsonOutput, "json", false, "Emit JSON documents instead of human-readable text")
+	RootCmd.PersistentFlags().StringVar(&colorMode, "color", "auto", "When to color output: always, never or auto")
+	RootCmd.PersistentFlags().BoolVar(&noPager, "no-pager", false, "Do not pipe output into a pager")
 }
 
 func Execute() {
+	// Flag errors happen before --json is parsed, so look for it up front
+	// to keep usage text out of machine-readable output.
+	for _, arg := range os.Args[1:] {
+		if arg == "--json" || arg == "--json=true" {
+			jsonOutput = true
+			RootCmd.SilenceUsage = true
+		}
+	}
+
 	if err := RootCmd.Execute(); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		fail(err)
+	}
+}
+
+// fail reports err on stderr, as a JSON document in --json mode, and exits.
+func fail(err error) {
+	stopPager()
+	if jsonOutput {
+		_ = gogit.WriteJSON(os.Stderr, gogit.ErrorJSON{Error: err.Error()})
+	} else {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	}
+	os.Exit(1)
+}
+
+// printJSON writes v to stdout as one line of JSON.
+func printJSON(v any) {
+	if err := gogit.WriteJSON(os.Stdout, v); err != nil {
+		fail(err)
 	}
 }
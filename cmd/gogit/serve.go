@@ -0,0 +1,41 @@
+package gogit
+
+import (
+	"github.com/TonyGLL/go-git/internal/gogit"
+	"github.com/spf13/cobra"
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve the repository to other machines",
+}
+
+var serveHTTPAddr string
+var serveHTTPReadOnly bool
+var serveHTTPCmd = &cobra.Command{
+	Use:   "http [--listen <addr>] [--read-only]",
+	Short: "Serve the repository to gogit clients over HTTP",
+	Long: `Serves the current repository, bare or not, over HTTP, so that other
+gogit repositories can clone, fetch from and push to it with an http://
+URL. Any URL path on the server names the repository, for example
+http://host:8080/project.git. Pushes follow the same rules as pushes to a
+local path, including receive.denyCurrentBranch.
+
+The requests follow Git's smart HTTP protocol, but the packfiles hold
+gogit's commits and trees, so Git clients cannot use the server, and
+gogit refuses packs of Git objects. Use bundles, or export and import,
+to exchange history with Git.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := gogit.ServeHTTPRepo(serveHTTPAddr, serveHTTPReadOnly); err != nil {
+			fail(err)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(serveCmd)
+	serveCmd.AddCommand(serveHTTPCmd)
+	serveHTTPCmd.Flags().StringVar(&serveHTTPAddr, "listen", "localhost:8080", "Address to listen on")
+	serveHTTPCmd.Flags().BoolVar(&serveHTTPReadOnly, "read-only", false, "Refuse pushes")
+}
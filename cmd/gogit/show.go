@@ -0,0 +1,46 @@
+package gogit
+
+import (
+	"github.com/TonyGLL/go-git/internal/gogit"
+	"github.com/spf13/cobra"
+)
+
+var showOptions gogit.LogOptions
+var showCmd = &cobra.Command{
+	Use:   "show [<object>]",
+	Short: "Show a commit, tree or blob",
+	Long: `Shows a commit with the list of files it changed, a tree listing or the
+content of a blob. Defaults to HEAD. Accepts the same --pretty and --date
+options as log.`,
+	Args:        cobra.MaximumNArgs(1),
+	Annotations: pagedAnnotation,
+	Run: func(cmd *cobra.Command, args []string) {
+		rev := "HEAD"
+		if len(args) == 1 {
+			rev = args[0]
+		}
+
+		if jsonOutput {
+			doc, err := gogit.NewShowJSON(rev)
+			if err != nil {
+				fail(err)
+			}
+			printJSON(doc)
+			return
+		}
+
+		if cmd.Flags().Changed("format") {
+			showOptions.Format = formatFlag(cmd)
+		}
+		if err := gogit.ShowObject(rev, &showOptions); err != nil {
+			fail(err)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(showCmd)
+	showCmd.Flags().StringVar(&showOptions.Format, "pretty", "medium", "Pretty-print format: oneline, short, medium, full, fuller or format:<template>")
+	showCmd.Flags().String("format", "", "Placeholder template, same as --pretty=tformat:<template>")
+	showCmd.Flags().StringVar(&showOptions.DateMode, "date", "default", "Date format: default, relative, iso, iso-strict, rfc, short, unix, raw or local")
+}
@@ -0,0 +1,142 @@
+package gogit
+
+import (
+	"github.com/TonyGLL/go-git/internal/gogit"
+	"github.com/spf13/cobra"
+)
+
+var stashOpts gogit.StashOptions
+var stashIndex bool
+
+var stashCmd = &cobra.Command{
+	Use:   "stash [push [-m <message>] [-- <path>...]]",
+	Short: "Set aside uncommitted changes",
+	Long: `Saves the changes to tracked files in the index and the working tree
+and resets them to HEAD. Entries are kept under refs/stash, newest first as
+stash@{0}, and can be listed, shown, applied, popped or dropped.
+
+Without a subcommand, stash behaves like "stash push".`,
+	Args:        cobra.ArbitraryArgs,
+	Annotations: worktreeAnnotation,
+	Run: func(cmd *cobra.Command, args []string) {
+		runStashPush(args)
+	},
+}
+
+var stashPushCmd = &cobra.Command{
+	Use:   "push [-m <message>] [-- <path>...]",
+	Short: "Save local changes as a new stash entry",
+	Run: func(cmd *cobra.Command, args []string) {
+		runStashPush(args)
+	},
+}
+
+func runStashPush(args []string) {
+	stashOpts.Paths = args
+	if err := gogit.StashPush(&stashOpts); err != nil {
+		fail(err)
+	}
+}
+
+var stashListCmd = &cobra.Command{
+	Use:         "list",
+	Short:       "List the stash entries",
+	Args:        cobra.NoArgs,
+	Annotations: pagedAnnotation,
+	Run: func(cmd *cobra.Command, args []string) {
+		stashes, err := gogit.ListStashes()
+		if err != nil {
+			fail(err)
+		}
+		if jsonOutput {
+			printJSON(gogit.NewStashesJSON(stashes))
+			return
+		}
+		gogit.PrintStashes(stashes)
+	},
+}
+
+var stashShowCmd = &cobra.Command{
+	Use:   "show [<stash>]",
+	Short: "Show the files a stash entry changed",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		changes, err := gogit.StashChanges(stashArg(args))
+		if err != nil {
+			fail(err)
+		}
+		if jsonOutput {
+			printJSON(gogit.NewChangesJSON(changes))
+			return
+		}
+		gogit.PrintChanges(changes)
+	},
+}
+
+var stashApplyCmd = &cobra.Command{
+	Use:   "apply [--index] [<stash>]",
+	Short: "Apply a stash entry on top of the working tree",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := gogit.StashApply(stashArg(args), stashIndex); err != nil {
+			fail(err)
+		}
+	},
+}
+
+var stashPopCmd = &cobra.Command{
+	Use:   "pop [--index] [<stash>]",
+	Short: "Apply a stash entry and drop it",
+	Long: `Applies a stash entry like "stash apply" and drops it afterwards. When
+applying it conflicts, the entry is kept.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := gogit.StashPop(stashArg(args), stashIndex); err != nil {
+			fail(err)
+		}
+	},
+}
+
+var stashDropCmd = &cobra.Command{
+	Use:   "drop [<stash>]",
+	Short: "Remove a stash entry",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := gogit.StashDrop(stashArg(args)); err != nil {
+			fail(err)
+		}
+	},
+}
+
+var stashBranchCmd = &cobra.Command{
+	Use:   "branch <branch> [<stash>]",
+	Short: "Create a branch from a stash entry",
+	Long: `Creates and checks out a branch at the commit the stash entry was made
+on, then pops the entry there with its index restored.`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := gogit.StashBranch(args[0], stashArg(args[1:])); err != nil {
+			fail(err)
+		}
+	},
+}
+
+// stashArg returns the optional stash selector; "" means stash@{0}.
+func stashArg(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	return args[0]
+}
+
+func init() {
+	RootCmd.AddCommand(stashCmd)
+	stashCmd.AddCommand(stashPushCmd, stashListCmd, stashShowCmd, stashApplyCmd, stashPopCmd, stashDropCmd, stashBranchCmd)
+
+	for _, cmd := range []*cobra.Command{stashCmd, stashPushCmd} {
+		cmd.Flags().StringVarP(&stashOpts.Message, "message", "m", "", "Describe the stash entry")
+	}
+	for _, cmd := range []*cobra.Command{stashApplyCmd, stashPopCmd} {
+		cmd.Flags().BoolVar(&stashIndex, "index", false, "Restore the staged changes as well")
+	}
+}
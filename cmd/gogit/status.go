@@ -2,23 +2,61 @@ package gogit
 
 import (
 	"fmt"
-	"os"
 
 	"github.com/TonyGLL/go-git/internal/gogit"
 	"github.com/spf13/cobra"
 )
 
+var statusOptions gogit.StatusOptions
+var statusShort bool
+var statusPorcelain string
 var statusCmd = &cobra.Command{
 	Use:   "status",
 	Short: "Show commit status",
+	Long: `Shows the state of the index and the working tree.
+
+--short prints one "XY path" line per changed file, where X is the state
+of the index against HEAD and Y the state of the working tree against the
+index. --porcelain (or --porcelain=v1) prints the same format without
+colors and is guaranteed to stay stable for scripts; --porcelain=v2 adds
+file modes and object hashes. -z terminates entries with NUL instead of
+a newline and implies --porcelain=v1 when no format is given.`,
+	Annotations: worktreeAnnotation,
 	Run: func(cmd *cobra.Command, args []string) {
-		if err := gogit.StatusRepo(); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+		switch {
+		case statusPorcelain == "v1" || statusPorcelain == "1":
+			statusOptions.Format = gogit.StatusFormatPorcelainV1
+		case statusPorcelain == "v2" || statusPorcelain == "2":
+			statusOptions.Format = gogit.StatusFormatPorcelainV2
+		case statusPorcelain != "":
+			fail(fmt.Errorf("unsupported porcelain version '%s'", statusPorcelain))
+		case statusShort:
+			statusOptions.Format = gogit.StatusFormatShort
+		case statusOptions.NullTerminated:
+			statusOptions.Format = gogit.StatusFormatPorcelainV1
+		}
+
+		if jsonOutput {
+			statusInfo, err := gogit.GetStatus(statusOptions.Ignored)
+			if err != nil {
+				fail(err)
+			}
+			printJSON(gogit.NewStatusJSON(statusInfo))
+			return
+		}
+
+		if err := gogit.StatusRepo(&statusOptions); err != nil {
+			fail(err)
 		}
 	},
 }
 
 func init() {
 	RootCmd.AddCommand(statusCmd)
+	statusCmd.Flags().BoolVarP(&statusShort, "short", "s", false, "Give the output in the short format")
+	statusCmd.Flags().StringVar(&statusPorcelain, "porcelain", "", "Give the output in a stable, script-friendly format (v1 or v2)")
+	statusCmd.Flags().Lookup("porcelain").NoOptDefVal = "v1"
+	statusCmd.Flags().BoolVarP(&statusOptions.NullTerminated, "null", "z", false, "Terminate entries with NUL")
+	statusCmd.Flags().BoolVarP(&statusOptions.Branch, "branch", "b", false, "Show the branch in the short and porcelain formats")
+	statusCmd.Flags().BoolVar(&statusOptions.Ignored, "ignored", false, "Show ignored files as well")
 }
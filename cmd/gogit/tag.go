@@ -0,0 +1,62 @@
+package gogit
+
+import (
+	"fmt"
+
+	"github.com/TonyGLL/go-git/internal/gogit"
+	"github.com/spf13/cobra"
+)
+
+var tagDelete bool
+var tagForce bool
+var tagCmd = &cobra.Command{
+	Use:   "tag [<name> [<commit>]]",
+	Short: "List, create or delete tags",
+	Long: `With no arguments, lists the tags. With a name, creates a lightweight
+tag pointing at <commit> (HEAD by default). -d deletes tags.`,
+	Args: cobra.MaximumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if tagDelete {
+			if len(args) == 0 {
+				fail(fmt.Errorf("tag name required"))
+			}
+			for _, name := range args {
+				if err := gogit.DeleteTag(name); err != nil {
+					fail(err)
+				}
+			}
+			return
+		}
+
+		if len(args) > 0 {
+			rev := ""
+			if len(args) == 2 {
+				rev = args[1]
+			}
+			if err := gogit.CreateTag(args[0], rev, tagForce); err != nil {
+				fail(err)
+			}
+			return
+		}
+
+		tags, err := gogit.ListTags()
+		if err != nil {
+			fail(err)
+		}
+		if jsonOutput {
+			docs := []gogit.RefJSON{}
+			for _, tag := range tags {
+				docs = append(docs, gogit.RefJSON{Name: tag.Name, Hash: tag.Hash})
+			}
+			printJSON(docs)
+			return
+		}
+		gogit.PrintTags(tags)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(tagCmd)
+	tagCmd.Flags().BoolVarP(&tagDelete, "delete", "d", false, "Delete tags")
+	tagCmd.Flags().BoolVarP(&tagForce, "force", "f", false, "Replace an existing tag")
+}
@@ -13,109 +13,201 @@ func Add(path string) error {
 	if err != nil {
 		return fmt.Errorf("error reading .gogitignore: %w", err)
 	}
+	lock, err := lockIndex()
+	if err != nil {
+		return err
+	}
+	defer lock.rollback()
 	// 1. Read the index file once into memory.
 	indexEntries, err := ReadIndex()
 	if err != nil {
 		return fmt.Errorf("error reading index: %w", err)
 	}
+	c, err := worktreeConverter()
+	if err != nil {
+		return err
+	}
+	defer c.close()
+	// Paths staged here count as resolved if they had conflicts.
+	var added []string
 
 	if path == "." {
-		// Walk the current directory
-		err = filepath.Walk(".", func(filePath string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
+		// Walk the current directory, hashing and storing the files found
+		// on a pool of workers.
+		walk := func(emit func(path string)) error {
+			return filepath.Walk(".", func(filePath string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
 
-			// Ignore the .gogit directory
-			if info.IsDir() && info.Name() == ".gogit" {
-				return filepath.SkipDir
-			}
-			if info.IsDir() && info.Name() == ".git" {
-				return filepath.SkipDir
-			}
+				// Ignore the .gogit directory
+				if info.IsDir() && info.Name() == ".gogit" {
+					return filepath.SkipDir
+				}
+				if info.IsDir() && info.Name() == ".git" {
+					return filepath.SkipDir
+				}
 
-			// Check against .gogitignore patterns
-			ignored, err := isIgnored(filePath, ignorePatterns)
-			if err != nil {
-				return fmt.Errorf("error checking ignore patterns for %s: %w", filePath, err)
-			}
-			if ignored {
+				// Check against .gogitignore patterns
+				ignored, err := isIgnored(filePath, ignorePatterns)
+				if err != nil {
+					return fmt.Errorf("error checking ignore patterns for %s: %w", filePath, err)
+				}
+				if ignored {
+					if info.IsDir() {
+						return filepath.SkipDir // Skip directory and its contents
+					}
+					return nil // Skip file
+				}
+
+				// Ignore other directories (that are not explicitly ignored by .gogitignore)
 				if info.IsDir() {
-					return filepath.SkipDir // Skip directory and its contents
+					return nil
 				}
-				return nil // Skip file
-			}
 
-			// Ignore other directories (that are not explicitly ignored by .gogitignore)
-			if info.IsDir() {
-				return nil
-			}
+				// Normalize path for consistent checks
+				normalizedPath := filepath.ToSlash(filePath)
+				if strings.HasPrefix(normalizedPath, ".gogit/") {
+					return nil
+				}
 
-			// Normalize path for consistent checks
-			normalizedPath := filepath.ToSlash(filePath)
-			if strings.HasPrefix(normalizedPath, ".gogit/") {
+				// 2. Queue each file for hashing
+				fmt.Printf("Adding '%s'\n", filePath)
+				added = append(added, normalizedPath)
+				emit(filePath)
 				return nil
-			}
-
-			// 2. Process each file and update the in-memory map
-			fmt.Printf("Adding '%s'\n", filePath)
-			return processFile(filePath, indexEntries)
-		})
+			})
+		}
+		results, err := hashInParallel(walk, c.storeFile)
 		if err != nil {
 			return err
 		}
+		// Update the in-memory map in walk order
+		for _, result := range results {
+			indexEntries[result.path] = result.hash
+		}
 	} else {
 		// If it's not ".", treat it as a single file or directory
-		info, err := os.Stat(path)
-		if err != nil {
-			return fmt.Errorf("error stating path %s: %w", path, err)
-		}
-		if info.IsDir() {
+		info, statErr := os.Stat(path)
+		switch {
+		case os.IsNotExist(statErr):
+			known, err := isTrackedOrUnmerged(path, indexEntries)
+			if err != nil {
+				return err
+			}
+			if !known {
+				return fmt.Errorf("error stating path %s: %w", path, statErr)
+			}
+			// Adding a tracked or conflicted file that was deleted stages
+			// the deletion.
+			delete(indexEntries, filepath.ToSlash(filepath.Clean(path)))
+		case statErr != nil:
+			return fmt.Errorf("error stating path %s: %w", path, statErr)
+		case info.IsDir():
 			return fmt.Errorf("adding single directories is not supported, use 'add .' instead")
+		default:
+			if err := processFile(c, path, indexEntries); err != nil {
+				return err
+			}
 		}
-
-		if err := processFile(path, indexEntries); err != nil {
-			return err
-		}
+		added = append(added, path)
 	}
 
 	// 3. Write the updated index back to the file once.
-	if err := WriteIndex(indexEntries); err != nil {
+	if err := lock.commitIndex(indexEntries); err != nil {
 		return fmt.Errorf("error writing index file: %w", err)
 	}
 
-	return nil
+	return markResolved(added...)
 }
 
-// processFile handles hashing a single file and adding it to the in-memory index map.
-func processFile(filePath string, indexEntries map[string]string) error {
-	content, err := os.ReadFile(filePath)
+// StageTracked updates the index with the working tree content of every
+// tracked file: modified files are re-hashed and deleted files are
+// removed. Untracked files are left alone.
+func StageTracked() error {
+	lock, err := lockIndex()
 	if err != nil {
-		return fmt.Errorf("error reading file %s: %w", filePath, err)
+		return err
 	}
-
-	blobHash, buffer, err := HashObject(content)
+	defer lock.rollback()
+	indexEntries, err := ReadIndex()
 	if err != nil {
-		return fmt.Errorf("error hashing file: %w", err)
+		return fmt.Errorf("error reading index: %w", err)
 	}
+	workdirMap, err := BuildWorkdirMap()
+	if err != nil {
+		return fmt.Errorf("could not build the working directory map: %w", err)
+	}
+	c, err := worktreeConverter()
+	if err != nil {
+		return err
+	}
+	defer c.close()
 
-	firstTwo := blobHash[:2]
-	rest := blobHash[2:]
-	objectPath := filepath.Join(ObjectsPath, firstTwo, rest)
-
-	// Check if object exists. If not, create it.
-	if _, err := os.Stat(objectPath); os.IsNotExist(err) {
-		if err := os.MkdirAll(filepath.Join(ObjectsPath, firstTwo), 0755); err != nil {
-			return fmt.Errorf("error creating directory %s: %w", filepath.Join(ObjectsPath, firstTwo), err)
+	for _, path := range sortedKeys(indexEntries) {
+		workdirHash, exists := workdirMap[path]
+		if !exists {
+			if _, err := os.Lstat(path); os.IsNotExist(err) {
+				delete(indexEntries, path)
+			}
+			continue
+		}
+		if workdirHash != indexEntries[path] {
+			if err := processFile(c, path, indexEntries); err != nil {
+				return err
+			}
 		}
-		if err := os.WriteFile(objectPath, buffer.Bytes(), 0644); err != nil {
-			return fmt.Errorf("error writing blob object to %s: %w", objectPath, err)
+	}
+
+	if err := lock.commitIndex(indexEntries); err != nil {
+		return fmt.Errorf("error writing index file: %w", err)
+	}
+	return nil
+}
+
+// isTrackedOrUnmerged reports whether path is in the index or has
+// unresolved conflicts.
+func isTrackedOrUnmerged(path string, indexEntries map[string]string) (bool, error) {
+	path = filepath.ToSlash(filepath.Clean(path))
+	if _, ok := indexEntries[path]; ok {
+		return true, nil
+	}
+	unmerged, err := ReadUnmerged()
+	if err != nil {
+		return false, err
+	}
+	for _, u := range unmerged {
+		if u.Path == path {
+			return true, nil
 		}
-	} else if err != nil {
-		return fmt.Errorf("error checking object existence at %s: %w", objectPath, err)
+	}
+	return false, nil
+}
+
+// processFile handles hashing a single file, converted by c, and adding it
+// to the in-memory index map.
+func processFile(c *converter, filePath string, indexEntries map[string]string) error {
+	blobHash, err := c.storeFile(filePath)
+	if err != nil {
+		return err
 	}
 
 	// Update the in-memory map
 	indexEntries[filePath] = blobHash
 	return nil
 }
+
+// openWorkdirFile opens a file of the working tree for reading and returns
+// its size.
+func openWorkdirFile(filePath string) (*os.File, int64, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error reading file %s: %w", filePath, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, 0, fmt.Errorf("error reading file %s: %w", filePath, err)
+	}
+	return file, info.Size(), nil
+}
@@ -0,0 +1,238 @@
+package gogit
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Archive formats.
+const (
+	ArchiveTar   = "tar"
+	ArchiveTarGz = "tar.gz"
+	ArchiveZip   = "zip"
+)
+
+// ArchiveFormatFor guesses the archive format from an output file name,
+// defaulting to tar.
+func ArchiveFormatFor(file string) string {
+	switch {
+	case strings.HasSuffix(file, ".zip"):
+		return ArchiveZip
+	case strings.HasSuffix(file, ".tar.gz"), strings.HasSuffix(file, ".tgz"):
+		return ArchiveTarGz
+	}
+	return ArchiveTar
+}
+
+// archiveEntry is a file or directory to put in an archive.
+type archiveEntry struct {
+	name string
+	path string
+	hash string
+	dir  bool
+}
+
+// Archive writes the tree of rev, or only the files under paths, to w as
+// a tar, gzipped tar or zip archive, with every name under prefix. Files
+// get their tree mode and the commit date as their modification time, the
+// commit ID goes into the pax header or zip comment, and paths with the
+// export-ignore attribute are left out. Blobs are copied from the object
+// store one at a time, converted as a checkout would write them.
+func Archive(w io.Writer, format, prefix, rev string, paths []string) error {
+	hash, err := ResolveRevision(rev)
+	if err != nil {
+		return err
+	}
+	objectType, _, err := ReadObjectContent(hash)
+	if err != nil {
+		return err
+	}
+	// A tree has no date or commit to record; it is archived as of now.
+	treeHash, commitID, mtime := hash, "", time.Now()
+	switch objectType {
+	case ObjectTypeCommit:
+		commit, err := ReadCommit(hash)
+		if err != nil {
+			return err
+		}
+		treeHash, commitID, mtime = commit.Tree, commit.Hash, commit.Date
+	case ObjectTypeTree:
+	default:
+		return fmt.Errorf("not a tree object: %s", rev)
+	}
+	files, err := ReadTree(treeHash)
+	if err != nil {
+		return err
+	}
+
+	entries, err := archiveEntries(files, prefix, paths)
+	if err != nil {
+		return err
+	}
+	c, err := treeConverter(files)
+	if err != nil {
+		return err
+	}
+	defer c.close()
+
+	switch format {
+	case ArchiveTar:
+		return writeTarArchive(w, c, entries, commitID, mtime)
+	case ArchiveTarGz:
+		compressor := gzip.NewWriter(w)
+		if err := writeTarArchive(compressor, c, entries, commitID, mtime); err != nil {
+			return err
+		}
+		return compressor.Close()
+	case ArchiveZip:
+		return writeZipArchive(w, c, entries, commitID, mtime)
+	}
+	return fmt.Errorf("unknown archive format '%s'", format)
+}
+
+// archiveEntries lists what goes into an archive, in order: each file
+// under paths without export-ignore, preceded by the directories leading
+// to it, all under prefix.
+func archiveEntries(files map[string]string, prefix string, paths []string) ([]archiveEntry, error) {
+	rules, err := treeAttributes(files)
+	if err != nil {
+		return nil, err
+	}
+	// export-ignore on a directory leaves out everything below it.
+	ignored := func(file string) bool {
+		for i := range file {
+			if file[i] == '/' && rules.lookup(file[:i])["export-ignore"] == attrSet {
+				return true
+			}
+		}
+		return rules.lookup(file)["export-ignore"] == attrSet
+	}
+
+	matched := make([]bool, len(paths))
+	var entries []archiveEntry
+	if strings.HasSuffix(prefix, "/") {
+		entries = append(entries, archiveEntry{name: prefix, dir: true})
+	}
+	dirs := make(map[string]bool)
+	for _, file := range sortedKeys(files) {
+		selected := len(paths) == 0
+		for i, want := range paths {
+			want = strings.TrimSuffix(want, "/")
+			if want == "." || want == "" || file == want || strings.HasPrefix(file, want+"/") {
+				matched[i], selected = true, true
+			}
+		}
+		if !selected || ignored(file) {
+			continue
+		}
+		for i := range file {
+			if file[i] == '/' && !dirs[file[:i]] {
+				dirs[file[:i]] = true
+				entries = append(entries, archiveEntry{name: prefix + file[:i+1], dir: true})
+			}
+		}
+		entries = append(entries, archiveEntry{name: prefix + file, path: file, hash: files[file]})
+	}
+	for i, want := range paths {
+		if !matched[i] {
+			return nil, fmt.Errorf("pathspec '%s' did not match any files", want)
+		}
+	}
+	return entries, nil
+}
+
+// writeTarArchive writes entries as a tar archive whose pax global header
+// carries the commit ID, as Git's archives do.
+func writeTarArchive(w io.Writer, c *converter, entries []archiveEntry, commitID string, mtime time.Time) error {
+	archive := tar.NewWriter(w)
+	if commitID != "" {
+		header := &tar.Header{
+			Typeflag:   tar.TypeXGlobalHeader,
+			Name:       "pax_global_header",
+			PAXRecords: map[string]string{"comment": commitID},
+			Format:     tar.FormatPAX,
+		}
+		if err := archive.WriteHeader(header); err != nil {
+			return fmt.Errorf("error writing archive: %w", err)
+		}
+	}
+	for _, entry := range entries {
+		header := &tar.Header{
+			Name:    entry.name,
+			ModTime: mtime,
+			Uname:   "root",
+			Gname:   "root",
+		}
+		if entry.dir {
+			header.Typeflag, header.Mode = tar.TypeDir, 0755
+			if err := archive.WriteHeader(header); err != nil {
+				return fmt.Errorf("error writing archive: %w", err)
+			}
+			continue
+		}
+		blob, size, err := c.smudge(entry.path, entry.hash)
+		if err != nil {
+			return err
+		}
+		header.Typeflag, header.Mode, header.Size = tar.TypeReg, 0644, size
+		if err := archive.WriteHeader(header); err != nil {
+			blob.Close()
+			return fmt.Errorf("error writing archive: %w", err)
+		}
+		_, err = io.Copy(archive, blob)
+		blob.Close()
+		if err != nil {
+			return fmt.Errorf("error writing %s to archive: %w", entry.name, err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return fmt.Errorf("error writing archive: %w", err)
+	}
+	return nil
+}
+
+// writeZipArchive writes entries as a deflated zip archive whose comment
+// is the commit ID.
+func writeZipArchive(w io.Writer, c *converter, entries []archiveEntry, commitID string, mtime time.Time) error {
+	archive := zip.NewWriter(w)
+	if commitID != "" {
+		if err := archive.SetComment(commitID); err != nil {
+			return err
+		}
+	}
+	for _, entry := range entries {
+		header := &zip.FileHeader{Name: entry.name, Modified: mtime}
+		if entry.dir {
+			header.SetMode(os.ModeDir | 0755)
+			if _, err := archive.CreateHeader(header); err != nil {
+				return fmt.Errorf("error writing archive: %w", err)
+			}
+			continue
+		}
+		header.Method = zip.Deflate
+		header.SetMode(0644)
+		out, err := archive.CreateHeader(header)
+		if err != nil {
+			return fmt.Errorf("error writing archive: %w", err)
+		}
+		blob, _, err := c.smudge(entry.path, entry.hash)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(out, blob)
+		blob.Close()
+		if err != nil {
+			return fmt.Errorf("error writing %s to archive: %w", entry.name, err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return fmt.Errorf("error writing archive: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package gogit
+
+import (
+	"bufio"
+	"bytes"
+	"path"
+	"sort"
+	"strings"
+)
+
+// attributesFile is the name of the files that assign attributes to
+// paths, one "<pattern> <attr>..." rule per line. A rule sets an
+// attribute ("attr"), unsets it ("-attr"), gives it a value
+// ("attr=value") or returns it to unspecified ("!attr"). Patterns follow
+// .gogitignore: one without a slash matches the file name at any depth
+// below the attributes file, one with a slash matches the path relative
+// to it, and "**" matches any number of directories. Later rules, and
+// files in deeper directories, take precedence. The "binary" macro stands
+// for "-diff -merge -text".
+const attributesFile = ".gogitattributes"
+
+// Attribute states, as check-attr would print them. Values are stored as
+// they are.
+const (
+	attrSet   = "set"
+	attrUnset = "unset"
+)
+
+// attrRule is one line of an attributes file in directory dir ("" for
+// the root).
+type attrRule struct {
+	dir     string
+	pattern string
+	attrs   []string
+}
+
+// attrRules holds the rules of every attributes file in a tree, shallower
+// files first.
+type attrRules []attrRule
+
+// parseAttributes reads the rules of the attributes file in dir.
+func parseAttributes(dir string, data []byte) attrRules {
+	var rules attrRules
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		rules = append(rules, attrRule{dir: dir, pattern: fields[0], attrs: fields[1:]})
+	}
+	return rules
+}
+
+// treeAttributes reads the rules of the attributes files in a tree, given
+// as a path -> blob hash map.
+func treeAttributes(files map[string]string) (attrRules, error) {
+	var paths []string
+	for file := range files {
+		if path.Base(file) == attributesFile {
+			paths = append(paths, file)
+		}
+	}
+	sortByDepth(paths)
+
+	var rules attrRules
+	for _, file := range paths {
+		content, err := ReadBlob(files[file])
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, parseAttributes(attrDir(file), content)...)
+	}
+	return rules, nil
+}
+
+// attrDir is the directory an attributes file applies to.
+func attrDir(file string) string {
+	dir := path.Dir(file)
+	if dir == "." {
+		return ""
+	}
+	return dir
+}
+
+// sortByDepth orders paths by the number of directories above them, then
+// by name.
+func sortByDepth(paths []string) {
+	sort.Slice(paths, func(i, j int) bool {
+		di, dj := strings.Count(paths[i], "/"), strings.Count(paths[j], "/")
+		if di != dj {
+			return di < dj
+		}
+		return paths[i] < paths[j]
+	})
+}
+
+// lookup returns the attributes of file: attrSet, attrUnset or a value
+// for each attribute a rule specifies.
+func (rules attrRules) lookup(file string) map[string]string {
+	attrs := make(map[string]string)
+	for _, rule := range rules {
+		if !rule.matches(file) {
+			continue
+		}
+		for _, attr := range rule.attrs {
+			switch {
+			case strings.HasPrefix(attr, "-"):
+				attrs[attr[1:]] = attrUnset
+			case strings.HasPrefix(attr, "!"):
+				delete(attrs, attr[1:])
+			case strings.Contains(attr, "="):
+				name, value, _ := strings.Cut(attr, "=")
+				attrs[name] = value
+			case attr == "binary":
+				attrs["binary"] = attrSet
+				attrs["diff"], attrs["merge"], attrs["text"] = attrUnset, attrUnset, attrUnset
+			default:
+				attrs[attr] = attrSet
+			}
+		}
+	}
+	return attrs
+}
+
+// isBinaryMerge reports whether file's content, with rules' attributes,
+// is merged as a whole rather than line by line: -merge (which binary
+// implies) says it is, merge or text says it is not, and otherwise the
+// content decides. -diff only concerns diffs and plays no part.
+func (rules attrRules) isBinaryMerge(file string, content []byte) bool {
+	attrs := rules.lookup(file)
+	switch {
+	case attrs["merge"] == attrUnset:
+		return true
+	case attrs["merge"] == attrSet, attrs["text"] == attrSet:
+		return false
+	}
+	return isBinary(content)
+}
+
+// matches reports whether the rule's pattern applies to file.
+func (rule attrRule) matches(file string) bool {
+	rel := file
+	if rule.dir != "" {
+		if !strings.HasPrefix(file, rule.dir+"/") {
+			return false
+		}
+		rel = file[len(rule.dir)+1:]
+	}
+	pattern := strings.TrimSuffix(rule.pattern, "/")
+	if !strings.Contains(pattern, "/") {
+		matched, _ := path.Match(pattern, path.Base(rel))
+		return matched
+	}
+	return matchPathPattern(strings.Split(strings.TrimPrefix(pattern, "/"), "/"), strings.Split(rel, "/"))
+}
+
+// matchPathPattern matches path components against pattern components,
+// where a "**" component stands for any number of directories.
+func matchPathPattern(pattern, parts []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for skip := 0; skip <= len(parts); skip++ {
+				if matchPathPattern(pattern[1:], parts[skip:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		if matched, _ := path.Match(pattern[0], parts[0]); !matched {
+			return false
+		}
+		pattern, parts = pattern[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
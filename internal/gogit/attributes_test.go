@@ -0,0 +1,18 @@
+package gogit
+
+import "testing"
+
+func TestOnlyMergeAttributesStopLineMerging(t *testing.T) {
+	rules := parseAttributes("", []byte("*.nodiff -diff\n*.nomerge -merge\n*.bin binary\n"))
+	content := []byte("text\n")
+	for file, want := range map[string]bool{
+		"a.txt":     false,
+		"a.nodiff":  false,
+		"a.nomerge": true,
+		"a.bin":     true,
+	} {
+		if got := rules.isBinaryMerge(file, content); got != want {
+			t.Errorf("isBinaryMerge(%s) = %v, want %v", file, got, want)
+		}
+	}
+}
@@ -0,0 +1,530 @@
+package gogit
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math/bits"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// A bisection keeps its state next to the other refs, like Git:
+//
+//	BISECT_START       the branch (or detached commit) bisect started from
+//	BISECT_LOG         the commands run so far, replayable with "bisect replay"
+//	refs/bisect/bad    the bad commit
+//	refs/bisect/good-* and refs/bisect/skip-* the good and skipped commits
+
+const bisectRefs = "refs/bisect/"
+
+// bisectSkipCode is the exit code "bisect run" scripts use for commits
+// that cannot be tested.
+const bisectSkipCode = 125
+
+func bisectStartPath() string {
+	return filepath.Join(RepoPath, "BISECT_START")
+}
+
+func bisectLogPath() string {
+	return filepath.Join(RepoPath, "BISECT_LOG")
+}
+
+// BisectInProgress reports whether a bisection has been started.
+func BisectInProgress() bool {
+	_, err := os.Stat(bisectStartPath())
+	return err == nil
+}
+
+// bisectStart returns the branch name or commit the bisection started
+// from.
+func bisectStart() (string, error) {
+	data, err := os.ReadFile(bisectStartPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("you need to start by \"gogit bisect start\"")
+		}
+		return "", fmt.Errorf("error reading bisect state: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// BisectStart begins a bisection, marking bad (if given) as bad and each
+// of goods as good. A bisection already in progress starts over from the
+// same original branch.
+func BisectStart(bad string, goods []string) error {
+	if RebaseInProgress() {
+		return fmt.Errorf("cannot bisect while a rebase is in progress")
+	}
+
+	// Resolve the revisions first so that a typo leaves no state behind.
+	var revs []string
+	for _, rev := range append([]string{bad}, goods...) {
+		if rev == "" {
+			continue
+		}
+		hash, err := ResolveRevision(rev)
+		if err != nil {
+			return err
+		}
+		revs = append(revs, hash)
+	}
+
+	headRef, head, err := ReadHead()
+	if err != nil {
+		return err
+	}
+	if head == "" {
+		return fmt.Errorf("bad HEAD - I need a HEAD")
+	}
+	start := strings.TrimPrefix(headRef, "refs/heads/")
+	if headRef == "" {
+		start = head
+	}
+	if BisectInProgress() {
+		if start, err = bisectStart(); err != nil {
+			return err
+		}
+		if err := clearBisectState(); err != nil {
+			return err
+		}
+	}
+	if err := os.WriteFile(bisectStartPath(), []byte(start+"\n"), 0644); err != nil {
+		return fmt.Errorf("error writing bisect state: %w", err)
+	}
+	if err := os.WriteFile(bisectLogPath(), nil, 0644); err != nil {
+		return fmt.Errorf("error writing bisect log: %w", err)
+	}
+
+	// The marks are logged as comments; replaying the start command
+	// repeats them.
+	command := "gogit bisect start"
+	if len(revs) > 0 {
+		command += " '" + strings.Join(revs, "' '") + "'"
+	}
+	if bad != "" {
+		if err := markBisect("bad", revs[0]); err != nil {
+			return err
+		}
+		revs = revs[1:]
+	}
+	for _, hash := range revs {
+		if err := markBisect("good", hash); err != nil {
+			return err
+		}
+	}
+	if err := appendBisectLog(command); err != nil {
+		return err
+	}
+	_, err = bisectNext()
+	return err
+}
+
+// BisectMark marks revs (HEAD when empty) as "good", "bad" or "skip" and
+// checks out the next commit to test. It reports whether the first bad
+// commit has been found.
+func BisectMark(term string, revs []string) (bool, error) {
+	if _, err := bisectStart(); err != nil {
+		return false, err
+	}
+	if len(revs) == 0 {
+		revs = []string{"HEAD"}
+	}
+	if term == "bad" && len(revs) > 1 {
+		return false, fmt.Errorf("'bisect bad' can take only one argument")
+	}
+
+	var hashes []string
+	for _, rev := range revs {
+		hash, err := ResolveRevision(rev)
+		if err != nil {
+			return false, err
+		}
+		hashes = append(hashes, hash)
+	}
+	for _, hash := range hashes {
+		if err := markBisect(term, hash); err != nil {
+			return false, err
+		}
+		if err := appendBisectLog(fmt.Sprintf("gogit bisect %s %s", term, hash)); err != nil {
+			return false, err
+		}
+	}
+	return bisectNext()
+}
+
+// markBisect records one commit under refs/bisect and notes it in the
+// log.
+func markBisect(term, hash string) error {
+	ref := bisectRefs + "bad"
+	if term != "bad" {
+		ref = bisectRefs + term + "-" + hash
+	}
+	if err := UpdateRef(ref, hash, ""); err != nil {
+		return err
+	}
+	commit, err := ReadCommit(hash)
+	if err != nil {
+		return err
+	}
+	return appendBisectLog(fmt.Sprintf("# %s: [%s] %s", term, hash, subjectOf(commit.Message)))
+}
+
+func appendBisectLog(lines string) error {
+	file, err := os.OpenFile(bisectLogPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("error writing bisect log: %w", err)
+	}
+	defer file.Close()
+	if _, err := fmt.Fprintln(file, lines); err != nil {
+		return fmt.Errorf("error writing bisect log: %w", err)
+	}
+	return nil
+}
+
+// bisectTerms returns the bad commit and the good and skipped ones.
+func bisectTerms() (string, []string, map[string]bool, error) {
+	refs, err := ListRefs(bisectRefs)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	var bad string
+	var goods []string
+	skipped := make(map[string]bool)
+	for _, ref := range refs {
+		name := strings.TrimPrefix(ref.Name, bisectRefs)
+		switch {
+		case name == "bad":
+			bad = ref.Hash
+		case strings.HasPrefix(name, "good-"):
+			goods = append(goods, ref.Hash)
+		case strings.HasPrefix(name, "skip-"):
+			skipped[ref.Hash] = true
+		}
+	}
+	return bad, goods, skipped, nil
+}
+
+// bisectNext checks out the commit that best splits the remaining
+// candidates, or reports the first bad commit once it is known.
+func bisectNext() (bool, error) {
+	bad, goods, skipped, err := bisectTerms()
+	if err != nil {
+		return false, err
+	}
+	switch {
+	case bad == "" && len(goods) == 0:
+		return false, nil
+	case bad == "":
+		fmt.Println("status: waiting for bad commit, good commit known")
+		return false, nil
+	case len(goods) == 0:
+		fmt.Println("status: waiting for good commit(s), bad commit known")
+		return false, nil
+	}
+
+	candidates, err := bisectCandidates(bad, goods)
+	if err != nil {
+		return false, err
+	}
+	if len(candidates) == 0 {
+		return true, reportFirstBad(bad)
+	}
+
+	// Choose the commit whose answer halves the candidates best: if it
+	// is bad, its own ancestors remain; if it is good, the others do.
+	best, bestScore, bestWeight := "", -1, 0
+	for _, hash := range candidates {
+		if skipped[hash] {
+			continue
+		}
+		weight, err := candidateAncestors(hash, candidates)
+		if err != nil {
+			return false, err
+		}
+		if score := min(weight, len(candidates)+1-weight); score > bestScore {
+			best, bestScore, bestWeight = hash, score, weight
+		}
+	}
+	if best == "" {
+		fmt.Println("There are only 'skip'ped commits left to test.")
+		fmt.Println("The first bad commit could be any of:")
+		for _, hash := range append(candidates, bad) {
+			fmt.Println(hash)
+		}
+		return true, fmt.Errorf("we cannot bisect more")
+	}
+
+	left := max(bestWeight-1, len(candidates)-bestWeight)
+	steps := bits.Len(uint(left+1)) - 1
+	fmt.Printf("Bisecting: %d %s left to test after this (roughly %d %s)\n",
+		left, pluralWord(left, "revision"), steps, pluralWord(steps, "step"))
+	return false, bisectCheckout(best)
+}
+
+// pluralWord returns word with an "s" unless n is 1.
+func pluralWord(n int, word string) string {
+	if n == 1 {
+		return word
+	}
+	return word + "s"
+}
+
+// bisectCandidates returns the ancestors of bad, excluding bad itself,
+// that are not ancestors of any good commit, newest first.
+func bisectCandidates(bad string, goods []string) ([]string, error) {
+	excluded := make(map[string]bool)
+	queue := append([]string(nil), goods...)
+	for len(queue) > 0 {
+		hash := queue[0]
+		queue = queue[1:]
+		if excluded[hash] {
+			continue
+		}
+		excluded[hash] = true
+		commit, err := ReadCommit(hash)
+		if err != nil {
+			return nil, err
+		}
+		queue = append(queue, commit.Parents...)
+	}
+
+	var candidates []string
+	seen := map[string]bool{bad: true}
+	commit, err := ReadCommit(bad)
+	if err != nil {
+		return nil, err
+	}
+	queue = append([]string(nil), commit.Parents...)
+	for len(queue) > 0 {
+		hash := queue[0]
+		queue = queue[1:]
+		if seen[hash] || excluded[hash] {
+			continue
+		}
+		seen[hash] = true
+		candidates = append(candidates, hash)
+		commit, err := ReadCommit(hash)
+		if err != nil {
+			return nil, err
+		}
+		queue = append(queue, commit.Parents...)
+	}
+	return candidates, nil
+}
+
+// candidateAncestors counts hash and its ancestors among candidates.
+func candidateAncestors(hash string, candidates []string) (int, error) {
+	inSet := make(map[string]bool, len(candidates))
+	for _, candidate := range candidates {
+		inSet[candidate] = true
+	}
+	seen := make(map[string]bool)
+	queue := []string{hash}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if seen[current] || !inSet[current] {
+			continue
+		}
+		seen[current] = true
+		commit, err := ReadCommit(current)
+		if err != nil {
+			return 0, err
+		}
+		queue = append(queue, commit.Parents...)
+	}
+	return len(seen), nil
+}
+
+// reportFirstBad prints the first bad commit and records it in the log.
+func reportFirstBad(hash string) error {
+	commit, err := ReadCommit(hash)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s is the first bad commit\n", hash)
+	formatted, err := FormatCommit(commit, &LogOptions{Format: "medium", DateMode: "default"})
+	if err != nil {
+		return err
+	}
+	fmt.Print(formatted)
+	changes, err := CommitChanges(commit)
+	if err != nil {
+		return err
+	}
+	PrintChanges(changes)
+	return appendBisectLog(fmt.Sprintf("# first bad commit: [%s] %s", hash, subjectOf(commit.Message)))
+}
+
+// bisectCheckout detaches HEAD at the commit to test next.
+func bisectCheckout(hash string) error {
+	headRef, head, err := ReadHead()
+	if err != nil {
+		return err
+	}
+	oldTree, err := commitTree(head)
+	if err != nil {
+		return err
+	}
+	newTree, err := commitTree(hash)
+	if err != nil {
+		return err
+	}
+	if err := checkoutTree(oldTree, newTree, false, "checkout"); err != nil {
+		return err
+	}
+	from := strings.TrimPrefix(headRef, "refs/heads/")
+	if headRef == "" {
+		from = head
+	}
+	if err := DetachHead(hash, fmt.Sprintf("checkout: moving from %s to %s", from, hash)); err != nil {
+		return err
+	}
+	commit, err := ReadCommit(hash)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("[%s] %s\n", hash, subjectOf(commit.Message))
+	return nil
+}
+
+// BisectReset ends the bisection and checks out target, or the branch
+// the bisection started from when target is empty.
+func BisectReset(target string) error {
+	start, err := bisectStart()
+	if err != nil {
+		fmt.Println("We are not bisecting.")
+		return nil
+	}
+	if target == "" {
+		target = start
+	}
+	if err := Checkout(target, &CheckoutOptions{}); err != nil {
+		return fmt.Errorf("could not check out original HEAD '%s': %w", target, err)
+	}
+	return clearBisectState()
+}
+
+func clearBisectState() error {
+	refs, err := ListRefs(bisectRefs)
+	if err != nil {
+		return err
+	}
+	for _, ref := range refs {
+		if err := DeleteRef(ref.Name); err != nil {
+			return err
+		}
+	}
+	for _, path := range []string{bisectStartPath(), bisectLogPath()} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("error removing bisect state: %w", err)
+		}
+	}
+	return nil
+}
+
+// PrintBisectLog prints the commands of the current bisection.
+func PrintBisectLog() error {
+	if _, err := bisectStart(); err != nil {
+		return err
+	}
+	data, err := os.ReadFile(bisectLogPath())
+	if err != nil {
+		return fmt.Errorf("error reading bisect log: %w", err)
+	}
+	fmt.Print(string(data))
+	return nil
+}
+
+// BisectReplay starts over and runs the commands of a bisect log. Lines
+// of "git bisect" logs are accepted too.
+func BisectReplay(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("cannot read '%s' for replaying: %w", path, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) < 3 || (fields[0] != "gogit" && fields[0] != "git") || fields[1] != "bisect" {
+			return fmt.Errorf("invalid bisect log line: %s", scanner.Text())
+		}
+		args := fields[3:]
+		for i, arg := range args {
+			args[i] = strings.Trim(arg, "'")
+		}
+		switch fields[2] {
+		case "start":
+			bad, goods := "", []string(nil)
+			if len(args) > 0 {
+				bad, goods = args[0], args[1:]
+			}
+			err = BisectStart(bad, goods)
+		case "good", "bad", "skip":
+			_, err = BisectMark(fields[2], args)
+		default:
+			err = fmt.Errorf("invalid bisect command '%s' in log", fields[2])
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// BisectRun tests each commit the bisection checks out with command:
+// exit code 0 marks it good, 125 skips it, 1 to 127 mark it bad and
+// anything else stops the run.
+func BisectRun(command []string) error {
+	if _, err := bisectStart(); err != nil {
+		return err
+	}
+	bad, goods, _, err := bisectTerms()
+	if err != nil {
+		return err
+	}
+	if bad == "" || len(goods) == 0 {
+		return fmt.Errorf("bisect run needs both a good and a bad commit")
+	}
+
+	script := strings.Join(command, " ")
+	for {
+		fmt.Printf("running %s\n", script)
+		cmd := exec.Command("sh", "-c", script)
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+		code := 0
+		if err := cmd.Run(); err != nil {
+			var exitErr *exec.ExitError
+			if !errors.As(err, &exitErr) {
+				return fmt.Errorf("bisect run failed: %w", err)
+			}
+			code = exitErr.ExitCode()
+		}
+
+		term := "good"
+		switch {
+		case code == bisectSkipCode:
+			term = "skip"
+		case code < 0 || code >= 128:
+			return fmt.Errorf("bisect run failed: exit code %d from '%s' is < 0 or >= 128", code, script)
+		case code != 0:
+			term = "bad"
+		}
+		done, err := BisectMark(term, nil)
+		if err != nil {
+			return err
+		}
+		if done {
+			fmt.Println("bisect found first bad commit")
+			return nil
+		}
+	}
+}
@@ -0,0 +1,383 @@
+package gogit
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// BlameOptions controls how Blame attributes lines.
+type BlameOptions struct {
+	// Rev is the commit whose version of the file is blamed; HEAD by
+	// default.
+	Rev string
+	// Ranges are -L values, "<start>,<end>" or "<start>,+<count>", with
+	// 1-based inclusive line numbers. Either end may be omitted.
+	Ranges []string
+	// IgnoreWhitespace treats lines that differ only in whitespace as
+	// unchanged.
+	IgnoreWhitespace bool
+	// IgnoreRevs lists commits whose changes are passed through to the
+	// lines they replaced, such as mass reformatting commits.
+	IgnoreRevs []string
+	// IgnoreRevsFiles are files listing more such commits, one per line.
+	IgnoreRevsFiles []string
+}
+
+// BlameLine is one line of the blamed file and the commit that last
+// changed it.
+type BlameLine struct {
+	Commit *Commit
+	// OrigLine is the line's number in Commit's version of the file and
+	// FinalLine its number in the blamed version. Both start at 1.
+	OrigLine  int
+	FinalLine int
+	Text      string
+	// Boundary marks lines that date back to a root commit.
+	Boundary bool
+}
+
+// blameLine follows one line of the blamed file back through history:
+// current is its index in the version being examined.
+type blameLine struct {
+	current int
+	final   int
+}
+
+// Blame attributes each line of path, as of opts.Rev, to the last commit
+// that changed it, following the first-parent chain.
+func Blame(path string, opts *BlameOptions) ([]BlameLine, error) {
+	path = filepath.ToSlash(filepath.Clean(path))
+	rev := opts.Rev
+	if rev == "" {
+		rev = "HEAD"
+	}
+	hash, err := ResolveRevision(rev)
+	if err != nil {
+		return nil, err
+	}
+	commit, err := ReadCommit(hash)
+	if err != nil {
+		return nil, err
+	}
+	blob, err := blobAt(commit, path)
+	if err != nil {
+		return nil, err
+	}
+	if blob == "" {
+		return nil, fmt.Errorf("no such path '%s' in %s", path, rev)
+	}
+	content, err := ReadBlob(blob)
+	if err != nil {
+		return nil, err
+	}
+	lines := splitLines(content)
+
+	ignored, err := ignoredRevisions(opts)
+	if err != nil {
+		return nil, err
+	}
+	selected, err := parseLineRanges(opts.Ranges, len(lines))
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]BlameLine, len(lines))
+	var pending []blameLine
+	for i := range lines {
+		if selected[i] {
+			pending = append(pending, blameLine{current: i, final: i})
+		}
+	}
+	assign := func(line blameLine, commit *Commit, boundary bool) {
+		result[line.final] = BlameLine{
+			Commit:    commit,
+			OrigLine:  line.current + 1,
+			FinalLine: line.final + 1,
+			Text:      lines[line.final],
+			Boundary:  boundary,
+		}
+	}
+
+	current := lines
+	for len(pending) > 0 {
+		if commit.Parent == "" {
+			for _, line := range pending {
+				assign(line, commit, true)
+			}
+			break
+		}
+		parent, err := ReadCommit(commit.Parent)
+		if err != nil {
+			return nil, err
+		}
+		parentBlob, err := blobAt(parent, path)
+		if err != nil {
+			return nil, err
+		}
+		if parentBlob == "" {
+			for _, line := range pending {
+				assign(line, commit, false)
+			}
+			break
+		}
+		if parentBlob == blob {
+			commit = parent
+			continue
+		}
+		parentContent, err := ReadBlob(parentBlob)
+		if err != nil {
+			return nil, err
+		}
+		parentLines := splitLines(parentContent)
+
+		origin := lineOrigins(parentLines, current, opts.IgnoreWhitespace, ignored[commit.Hash])
+		var next []blameLine
+		for _, line := range pending {
+			if origin[line.current] >= 0 {
+				next = append(next, blameLine{current: origin[line.current], final: line.final})
+			} else {
+				assign(line, commit, false)
+			}
+		}
+		pending = next
+		commit, blob, current = parent, parentBlob, parentLines
+	}
+
+	var blamed []BlameLine
+	for i := range lines {
+		if selected[i] {
+			blamed = append(blamed, result[i])
+		}
+	}
+	return blamed, nil
+}
+
+// blobAt returns the blob of path in commit's tree, or "" if it has none.
+func blobAt(commit *Commit, path string) (string, error) {
+	tree, err := ReadTree(commit.Tree)
+	if err != nil {
+		return "", err
+	}
+	return tree[path], nil
+}
+
+// lineOrigins maps each line of newer to the line of older it came from,
+// or -1 for lines the newer version introduced. When passThrough is set,
+// the lines of a changed hunk map to the lines they replaced, position
+// by position, so that the change itself is never blamed.
+func lineOrigins(older, newer []string, ignoreWhitespace, passThrough bool) []int {
+	if ignoreWhitespace {
+		older, newer = stripWhitespace(older), stripWhitespace(newer)
+	}
+	origin := make([]int, len(newer))
+	for i := range origin {
+		origin[i] = -1
+	}
+	for _, match := range matchLines(older, newer) {
+		origin[match[1]] = match[0]
+	}
+	if passThrough {
+		for _, hunk := range diffLines(older, newer) {
+			for i := 0; hunk.NewStart+i < hunk.NewEnd && hunk.OldStart+i < hunk.OldEnd; i++ {
+				origin[hunk.NewStart+i] = hunk.OldStart + i
+			}
+		}
+	}
+	return origin
+}
+
+// stripWhitespace removes all whitespace from each line.
+func stripWhitespace(lines []string) []string {
+	stripped := make([]string, len(lines))
+	for i, line := range lines {
+		stripped[i] = strings.Map(func(r rune) rune {
+			if unicode.IsSpace(r) {
+				return -1
+			}
+			return r
+		}, line)
+	}
+	return stripped
+}
+
+// ignoredRevisions resolves the commits named by opts.IgnoreRevs, the
+// files in opts.IgnoreRevsFiles and the blame.ignoreRevsFile option.
+func ignoredRevisions(opts *BlameOptions) (map[string]bool, error) {
+	revs := append([]string(nil), opts.IgnoreRevs...)
+	files, err := GetConfigAll("blame.ignoreRevsFile")
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range append(files, opts.IgnoreRevsFiles...) {
+		listed, err := readIgnoreRevsFile(name)
+		if err != nil {
+			return nil, err
+		}
+		revs = append(revs, listed...)
+	}
+
+	ignored := make(map[string]bool)
+	for _, rev := range revs {
+		hash, err := ResolveRevision(rev)
+		if err != nil {
+			return nil, fmt.Errorf("invalid revision to ignore '%s': %w", rev, err)
+		}
+		ignored[hash] = true
+	}
+	return ignored, nil
+}
+
+// readIgnoreRevsFile reads a list of revisions, one per line. Blank
+// lines and "#" comments are skipped.
+func readIgnoreRevsFile(name string) ([]string, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("could not read ignore-revs file: %w", err)
+	}
+	defer file.Close()
+
+	var revs []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		if line = strings.TrimSpace(line); line != "" {
+			revs = append(revs, line)
+		}
+	}
+	return revs, scanner.Err()
+}
+
+// parseLineRanges returns which of total lines the -L ranges select. No
+// ranges select every line.
+func parseLineRanges(ranges []string, total int) ([]bool, error) {
+	selected := make([]bool, total)
+	if len(ranges) == 0 {
+		for i := range selected {
+			selected[i] = true
+		}
+		return selected, nil
+	}
+	for _, value := range ranges {
+		start, end, err := parseLineRange(value, total)
+		if err != nil {
+			return nil, err
+		}
+		for i := start; i <= end; i++ {
+			selected[i-1] = true
+		}
+	}
+	return selected, nil
+}
+
+// parseLineRange parses one -L value into inclusive 1-based bounds.
+func parseLineRange(value string, total int) (int, int, error) {
+	invalid := fmt.Errorf("invalid -L range '%s'", value)
+	startText, endText, _ := strings.Cut(value, ",")
+
+	start := 1
+	if startText != "" {
+		n, err := strconv.Atoi(startText)
+		if err != nil || n < 1 {
+			return 0, 0, invalid
+		}
+		start = n
+	}
+	if start > total {
+		return 0, 0, fmt.Errorf("file has only %d lines", total)
+	}
+
+	end := total
+	switch {
+	case strings.HasPrefix(endText, "+"):
+		n, err := strconv.Atoi(endText[1:])
+		if err != nil || n < 1 {
+			return 0, 0, invalid
+		}
+		end = start + n - 1
+	case endText != "":
+		n, err := strconv.Atoi(endText)
+		if err != nil || n < 1 {
+			return 0, 0, invalid
+		}
+		end = n
+	}
+	if end < start {
+		start, end = end, start
+	}
+	return start, min(end, total), nil
+}
+
+// PrintBlame prints each line prefixed with its commit, author, date and
+// line number. Lines from root commits have a "^" before their hash.
+func PrintBlame(lines []BlameLine) {
+	authorWidth, numberWidth := 0, 0
+	for _, line := range lines {
+		name, _ := splitAuthor(line.Commit.Author)
+		authorWidth = max(authorWidth, len(name))
+		numberWidth = max(numberWidth, len(strconv.Itoa(line.FinalLine)))
+	}
+	for _, line := range lines {
+		hash := line.Commit.Hash[:8]
+		if line.Boundary {
+			hash = "^" + line.Commit.Hash[:7]
+		}
+		name, _ := splitAuthor(line.Commit.Author)
+		date := line.Commit.Date.Local().Format("2006-01-02 15:04:05 -0700")
+		fmt.Printf("%s%s%s (%-*s %s %*d) %s", ColorYellow, hash, ColorReset,
+			authorWidth, name, date, numberWidth, line.FinalLine, terminated(line.Text))
+	}
+}
+
+// PrintBlamePorcelain prints the machine-readable format of
+// `git blame --porcelain`: a header per line, the commit's details the
+// first time it appears, and the line itself after a tab.
+func PrintBlamePorcelain(path string, lines []BlameLine) {
+	path = filepath.ToSlash(filepath.Clean(path))
+	seen := make(map[string]bool)
+	for i, line := range lines {
+		commit := line.Commit
+		header := fmt.Sprintf("%s %d %d", commit.Hash, line.OrigLine, line.FinalLine)
+		// The first line of a run of consecutive lines from one commit
+		// carries the length of the run.
+		if i == 0 || lines[i-1].Commit.Hash != commit.Hash || lines[i-1].FinalLine != line.FinalLine-1 {
+			group := 1
+			for j := i + 1; j < len(lines) && lines[j].Commit.Hash == commit.Hash &&
+				lines[j].FinalLine == lines[j-1].FinalLine+1; j++ {
+				group++
+			}
+			header += fmt.Sprintf(" %d", group)
+		}
+		fmt.Println(header)
+
+		if !seen[commit.Hash] {
+			seen[commit.Hash] = true
+			name, email := splitAuthor(commit.Author)
+			subject, _ := splitMessage(commit.Message)
+			for _, role := range []string{"author", "committer"} {
+				fmt.Printf("%s %s\n", role, name)
+				fmt.Printf("%s-mail <%s>\n", role, email)
+				fmt.Printf("%s-time %d\n", role, commit.Date.Unix())
+				fmt.Printf("%s-tz %s\n", role, commit.Date.Format("-0700"))
+			}
+			fmt.Printf("summary %s\n", subject)
+			if line.Boundary {
+				fmt.Println("boundary")
+			}
+			fmt.Printf("filename %s\n", path)
+		}
+		fmt.Printf("\t%s", terminated(line.Text))
+	}
+}
+
+// terminated returns line with a trailing newline.
+func terminated(line string) string {
+	if strings.HasSuffix(line, "\n") {
+		return line
+	}
+	return line + "\n"
+}
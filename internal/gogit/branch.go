@@ -0,0 +1,127 @@
+package gogit
+
+import (
+	"fmt"
+)
+
+// Branch is a local branch as listed by `branch`.
+type Branch struct {
+	Name    string
+	Hash    string
+	Current bool
+}
+
+// ListBranches returns the local branches sorted by name.
+func ListBranches() ([]Branch, error) {
+	refs, err := ListRefs("refs/heads/")
+	if err != nil {
+		return nil, err
+	}
+	current, err := CurrentBranch()
+	if err != nil {
+		return nil, err
+	}
+
+	branches := make([]Branch, 0, len(refs))
+	for _, ref := range refs {
+		name := ref.Name[len("refs/heads/"):]
+		branches = append(branches, Branch{Name: name, Hash: ref.Hash, Current: name == current})
+	}
+	return branches, nil
+}
+
+// ListRemoteBranches returns the remote-tracking branches, named
+// "<remote>/<branch>", sorted by name.
+func ListRemoteBranches() ([]Branch, error) {
+	refs, err := ListRefs("refs/remotes/")
+	if err != nil {
+		return nil, err
+	}
+	branches := make([]Branch, 0, len(refs))
+	for _, ref := range refs {
+		branches = append(branches, Branch{Name: ref.Name[len("refs/remotes/"):], Hash: ref.Hash})
+	}
+	return branches, nil
+}
+
+// CreateBranch creates a branch at startPoint, or at HEAD when startPoint
+// is empty.
+func CreateBranch(name, startPoint string) error {
+	if err := ValidateRefName(name); err != nil {
+		return err
+	}
+
+	refName := "refs/heads/" + name
+	existing, err := ReadRef(refName)
+	if err != nil {
+		return err
+	}
+	if existing != "" {
+		return fmt.Errorf("a branch named '%s' already exists", name)
+	}
+
+	if startPoint == "" {
+		startPoint = "HEAD"
+	}
+	hash, err := ResolveRevision(startPoint)
+	if err != nil {
+		return err
+	}
+	if _, err := ReadCommit(hash); err != nil {
+		return fmt.Errorf("not a valid commit: '%s'", startPoint)
+	}
+
+	return UpdateRef(refName, hash, "branch: Created from "+startPoint)
+}
+
+// DeleteBranch removes a branch. Unless force is set, the branch must be
+// fully merged into HEAD.
+func DeleteBranch(name string, force bool) error {
+	current, err := CurrentBranch()
+	if err != nil {
+		return err
+	}
+	if name == current {
+		return fmt.Errorf("cannot delete branch '%s' checked out", name)
+	}
+
+	refName := "refs/heads/" + name
+	hash, err := ReadRef(refName)
+	if err != nil {
+		return err
+	}
+	if hash == "" {
+		return fmt.Errorf("branch '%s' not found", name)
+	}
+
+	if !force {
+		headHash, err := GetBranchHash()
+		if err != nil {
+			return err
+		}
+		merged, err := IsAncestor(hash, headHash)
+		if err != nil {
+			return err
+		}
+		if !merged {
+			return fmt.Errorf("the branch '%s' is not fully merged; use -D to delete it anyway", name)
+		}
+	}
+
+	if err := DeleteRef(refName); err != nil {
+		return err
+	}
+	fmt.Printf("Deleted branch %s (was %s).\n", name, abbrevHash(hash))
+	return nil
+}
+
+// PrintBranches lists branches, marking the current one.
+func PrintBranches(branches []Branch) {
+	for _, branch := range branches {
+		if branch.Current {
+			fmt.Printf("* %s%s%s\n", ColorGreen, branch.Name, ColorReset)
+		} else {
+			fmt.Printf("  %s\n", branch.Name)
+		}
+	}
+}
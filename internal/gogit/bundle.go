@@ -0,0 +1,580 @@
+package gogit
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// A bundle is a repository in a single file, for moving history where no
+// network reaches: a header listing the commits the bundle builds on
+// (prerequisites, one "-<hash> <subject>" line each) and the refs it
+// carries ("<hash> <ref>"), a blank line, then a packfile of the objects
+// reachable from those refs but not from the prerequisites.
+//
+// The objects are Git's, so that Git and gogit can read each other's
+// bundles: commits and trees are converted on the way in and out, as by
+// "gogit export" and "gogit import", and the IDs in the header are Git
+// IDs.
+
+const (
+	bundleSignatureV2 = "# v2 git bundle"
+	bundleSignatureV3 = "# v3 git bundle"
+)
+
+// bundle is a bundle file's header.
+type bundle struct {
+	Prerequisites []string
+	Refs          []Ref
+}
+
+// isBundleFile reports whether path is a regular file that starts with a
+// bundle signature.
+func isBundleFile(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	line, _ := bufio.NewReader(file).ReadString('\n')
+	line = strings.TrimSuffix(line, "\n")
+	return line == bundleSignatureV2 || line == bundleSignatureV3
+}
+
+// openBundle opens a bundle file and reads its header, leaving the
+// returned reader at the start of the packfile.
+func openBundle(path string) (*bundle, *bufio.Reader, *os.File, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("could not open bundle '%s': %w", path, err)
+	}
+	r := bufio.NewReader(file)
+	b, err := readBundleHeader(r)
+	if err != nil {
+		file.Close()
+		return nil, nil, nil, fmt.Errorf("'%s': %w", path, err)
+	}
+	return b, r, file, nil
+}
+
+// readBundleHeader reads the header of a v2 or v3 bundle, up to and
+// including the blank line before the packfile.
+func readBundleHeader(r *bufio.Reader) (*bundle, error) {
+	readLine := func() (string, error) {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return "", fmt.Errorf("truncated bundle header")
+		}
+		return strings.TrimSuffix(line, "\n"), nil
+	}
+
+	signature, err := readLine()
+	if err != nil || (signature != bundleSignatureV2 && signature != bundleSignatureV3) {
+		return nil, fmt.Errorf("does not look like a v2 or v3 bundle file")
+	}
+	b := &bundle{}
+	for {
+		line, err := readLine()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case line == "":
+			return b, nil
+		case strings.HasPrefix(line, "@"):
+			// v3 capabilities; only SHA-1 repositories are understood.
+			if signature != bundleSignatureV3 {
+				return nil, fmt.Errorf("unexpected capability line in v2 bundle: %q", line)
+			}
+			key, value, _ := strings.Cut(line[1:], "=")
+			if key == "object-format" && value != "sha1" {
+				return nil, fmt.Errorf("unsupported object format '%s'", value)
+			}
+		case strings.HasPrefix(line, "-"):
+			hash, _, _ := strings.Cut(line[1:], " ")
+			if !fullHashPattern.MatchString(hash) {
+				return nil, fmt.Errorf("malformed prerequisite line: %q", line)
+			}
+			b.Prerequisites = append(b.Prerequisites, hash)
+		default:
+			hash, name, ok := strings.Cut(line, " ")
+			if !ok || !fullHashPattern.MatchString(hash) || name == "" {
+				return nil, fmt.Errorf("malformed ref line: %q", line)
+			}
+			b.Refs = append(b.Refs, Ref{Name: name, Hash: hash})
+		}
+	}
+}
+
+// resolvePrerequisites finds the commits here that the prerequisites of
+// b, which are Git commit IDs, were converted from, by converting the
+// history of every ref. It returns them by Git ID, along with the
+// prerequisites the current repository lacks.
+func (b *bundle) resolvePrerequisites() (map[string]string, []string, error) {
+	resolved := make(map[string]string)
+	if len(b.Prerequisites) == 0 {
+		return resolved, nil, nil
+	}
+	tips, err := ListRefs("refs/")
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, head, err := ReadHead(); err != nil {
+		return nil, nil, err
+	} else if head != "" {
+		tips = append(tips, Ref{Name: "HEAD", Hash: head})
+	}
+	wanted := make(map[string]bool)
+	for _, hash := range b.Prerequisites {
+		wanted[hash] = true
+	}
+	exporter := newGitExporter(newGitObjectSet(true))
+	for _, tip := range tips {
+		if _, err := exporter.commit(tip.Hash); err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", tip.Name, err)
+		}
+	}
+	for hash, converted := range exporter.commits {
+		if wanted[converted] {
+			resolved[converted] = hash
+		}
+	}
+	var missing []string
+	for _, hash := range b.Prerequisites {
+		if resolved[hash] == "" {
+			missing = append(missing, hash)
+		}
+	}
+	return resolved, missing, nil
+}
+
+// importBundle converts the objects of the bundle file into gogit
+// objects and returns its refs with the IDs their commits have here.
+// Annotated tags become lightweight tags of the commits they name.
+func importBundle(file string) ([]Ref, error) {
+	b, r, f, err := openBundle(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	prerequisites, missing, err := b.resolvePrerequisites()
+	if err != nil {
+		return nil, err
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("repository lacks these prerequisite commits: %s", strings.Join(missing, ", "))
+	}
+
+	// Git writes bundles as thin packs, whose deltas may be based on the
+	// objects of the prerequisites, and the trees of new commits may name
+	// their unchanged subtrees.
+	objects := newGitObjectSet(false)
+	exporter := newGitExporter(objects)
+	for _, hash := range prerequisites {
+		commit, err := ReadCommit(hash)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := exporter.tree(commit.Tree); err != nil {
+			return nil, err
+		}
+	}
+	readBase := func(hash string) (string, []byte, error) {
+		if objectType, content, err := objects.readObject(hash); err == nil {
+			return objectType, content, nil
+		}
+		return readStoredBase(hash)
+	}
+	if _, err := unpack(r, objects.writeObject, readBase); err != nil {
+		return nil, fmt.Errorf("'%s': %w", file, err)
+	}
+
+	importer := newGitImporter(objects)
+	for converted, hash := range prerequisites {
+		importer.commits[converted] = hash
+	}
+	var refs []Ref
+	for _, ref := range b.Refs {
+		commit, err := peelGitTag(objects, ref.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", ref.Name, err)
+		}
+		hash, err := importer.commit(commit)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", ref.Name, err)
+		}
+		refs = append(refs, Ref{Name: ref.Name, Hash: hash})
+	}
+	return refs, nil
+}
+
+// CreateBundle writes a bundle of the history described by revs to file.
+// Each rev is a branch, tag or HEAD to include, "^<rev>" to leave out
+// what is reachable from rev, "<a>..<b>" for both at once, or --all,
+// --branches or --tags. The commits the bundle leaves out but builds on
+// become its prerequisites.
+func CreateBundle(file string, revs []string) error {
+	var refs []Ref
+	var include, exclude []string
+	addRef := func(name, hash string) {
+		for _, ref := range refs {
+			if ref.Name == name {
+				return
+			}
+		}
+		refs = append(refs, Ref{Name: name, Hash: hash})
+		include = append(include, hash)
+	}
+	addRefs := func(prefix string) error {
+		list, err := ListRefs(prefix)
+		if err != nil {
+			return err
+		}
+		for _, ref := range list {
+			addRef(ref.Name, ref.Hash)
+		}
+		return nil
+	}
+	addPositive := func(rev string) error {
+		if rev == "HEAD" {
+			_, hash, err := ReadHead()
+			if err != nil {
+				return err
+			}
+			if hash == "" {
+				return fmt.Errorf("HEAD does not point to a commit")
+			}
+			addRef("HEAD", hash)
+			return nil
+		}
+		name, err := localRefName(rev)
+		if err != nil {
+			return err
+		}
+		hash := ""
+		if name != "" {
+			if hash, err = ReadRef(name); err != nil {
+				return err
+			}
+		}
+		if hash == "" {
+			return fmt.Errorf("cannot bundle '%s': not a branch, tag or HEAD", rev)
+		}
+		addRef(name, hash)
+		return nil
+	}
+	addNegative := func(rev string) error {
+		hash, err := ResolveRevision(rev)
+		if err != nil {
+			return err
+		}
+		exclude = append(exclude, hash)
+		return nil
+	}
+
+	for _, rev := range revs {
+		var err error
+		switch {
+		case rev == "--all":
+			if err = addRefs("refs/heads/"); err == nil {
+				err = addRefs("refs/tags/")
+			}
+			// Like Git, --all records HEAD, which clones check out.
+			if _, head, headErr := ReadHead(); err == nil && head != "" {
+				addRef("HEAD", head)
+			} else if err == nil {
+				err = headErr
+			}
+		case rev == "--branches":
+			err = addRefs("refs/heads/")
+		case rev == "--tags":
+			err = addRefs("refs/tags/")
+		case strings.HasPrefix(rev, "^"):
+			err = addNegative(rev[1:])
+		case strings.Contains(rev, ".."):
+			from, to, _ := strings.Cut(rev, "..")
+			if from == "" {
+				from = "HEAD"
+			}
+			if to == "" {
+				to = "HEAD"
+			}
+			if err = addNegative(from); err == nil {
+				err = addPositive(to)
+			}
+		default:
+			err = addPositive(rev)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if len(refs) == 0 {
+		return fmt.Errorf("refusing to create empty bundle")
+	}
+
+	excluded, err := reachableObjects(exclude)
+	if err != nil {
+		return err
+	}
+	objects, err := missingObjects(include, func(hash string) bool { return excluded[hash] })
+	if err != nil {
+		return err
+	}
+	if len(objects) == 0 {
+		return fmt.Errorf("refusing to create empty bundle")
+	}
+
+	// The prerequisites are the boundary: left-out parents of bundled
+	// commits.
+	bundled := make(map[string]bool, len(objects))
+	for _, hash := range objects {
+		bundled[hash] = true
+	}
+	var prerequisites []string
+	seen := make(map[string]bool)
+	for _, hash := range objects {
+		objectType, _, err := ReadObjectContent(hash)
+		if err != nil {
+			return err
+		}
+		if objectType != ObjectTypeCommit {
+			continue
+		}
+		commit, err := ReadCommit(hash)
+		if err != nil {
+			return err
+		}
+		for _, parent := range commit.Parents {
+			if !bundled[parent] && !seen[parent] {
+				seen[parent] = true
+				prerequisites = append(prerequisites, parent)
+			}
+		}
+	}
+
+	// Convert the history the prerequisites end, only for its IDs, then
+	// the bundled commits, whose objects are those not seen before.
+	converted := newGitObjectSet(true)
+	exporter := newGitExporter(converted)
+	var header bundle
+	subjects := make(map[string]string)
+	for _, hash := range prerequisites {
+		gitHash, err := exporter.commit(hash)
+		if err != nil {
+			return err
+		}
+		commit, err := ReadCommit(hash)
+		if err != nil {
+			return err
+		}
+		header.Prerequisites = append(header.Prerequisites, gitHash)
+		subjects[gitHash], _, _ = strings.Cut(commit.Message, "\n")
+	}
+	converted.hashOnly = false
+	known := len(converted.order)
+	for _, ref := range refs {
+		gitHash, err := exporter.commit(ref.Hash)
+		if err != nil {
+			return fmt.Errorf("%s: %w", ref.Name, err)
+		}
+		header.Refs = append(header.Refs, Ref{Name: ref.Name, Hash: gitHash})
+	}
+
+	out, err := os.Create(file)
+	if err != nil {
+		return fmt.Errorf("could not create bundle '%s': %w", file, err)
+	}
+	if err := writeBundle(out, &header, subjects, converted, converted.order[known:]); err != nil {
+		out.Close()
+		os.Remove(file)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(file)
+		return fmt.Errorf("error writing bundle '%s': %w", file, err)
+	}
+	return nil
+}
+
+// writeBundle writes a bundle with the given header, the prerequisites'
+// subjects, and the Git objects hashes out of objects.
+func writeBundle(w io.Writer, header *bundle, subjects map[string]string, objects *gitObjectSet, hashes []string) error {
+	out := bufio.NewWriter(w)
+	fmt.Fprintln(out, bundleSignatureV2)
+	for _, hash := range header.Prerequisites {
+		fmt.Fprintf(out, "-%s %s\n", hash, subjects[hash])
+	}
+	for _, ref := range header.Refs {
+		fmt.Fprintf(out, "%s %s\n", ref.Hash, ref.Name)
+	}
+	fmt.Fprintln(out)
+	if err := writePack(out, hashes, objects.readObject); err != nil {
+		return err
+	}
+	return out.Flush()
+}
+
+// VerifyBundle checks that file is a well-formed bundle whose packfile is
+// intact and whose prerequisites the current repository has, and
+// describes it.
+func VerifyBundle(file string) error {
+	b, r, f, err := openBundle(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, _, err := scanPack(r); err != nil {
+		return fmt.Errorf("'%s': %w", file, err)
+	}
+	if _, missing, err := b.resolvePrerequisites(); err != nil {
+		return err
+	} else if len(missing) > 0 {
+		return fmt.Errorf("repository lacks these prerequisite commits: %s", strings.Join(missing, ", "))
+	}
+
+	fmt.Printf("The bundle contains %s:\n", countNoun(len(b.Refs), "this ref", "these %d refs"))
+	for _, ref := range b.Refs {
+		fmt.Printf("%s %s\n", ref.Hash, ref.Name)
+	}
+	if len(b.Prerequisites) == 0 {
+		fmt.Println("The bundle records a complete history.")
+	} else {
+		fmt.Printf("The bundle requires %s:\n", countNoun(len(b.Prerequisites), "this ref", "these %d refs"))
+		for _, hash := range b.Prerequisites {
+			fmt.Println(hash)
+		}
+	}
+	fmt.Printf("%s is okay\n", file)
+	return nil
+}
+
+// countNoun picks the singular phrase for one and formats the plural one
+// with n otherwise.
+func countNoun(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return fmt.Sprintf(many, n)
+}
+
+// ListBundleHeads prints the refs a bundle carries, only those named by
+// names (full or short) when any are given.
+func ListBundleHeads(file string, names []string) error {
+	b, _, f, err := openBundle(file)
+	if err != nil {
+		return err
+	}
+	f.Close()
+	printBundleRefs(b.Refs, names)
+	return nil
+}
+
+// Unbundle stores the objects of a bundle in the current repository and
+// prints the refs it carries, with the IDs their commits have here,
+// without updating any refs.
+func Unbundle(file string, names []string) error {
+	refs, err := importBundle(file)
+	if err != nil {
+		return err
+	}
+	printBundleRefs(refs, names)
+	return nil
+}
+
+func printBundleRefs(refs []Ref, names []string) {
+	for _, ref := range refs {
+		if len(names) > 0 && !bundleRefMatches(ref.Name, names) {
+			continue
+		}
+		fmt.Printf("%s %s\n", ref.Hash, ref.Name)
+	}
+}
+
+// bundleRefMatches reports whether ref is one of names, given in full or
+// without its refs/heads/ or refs/tags/ prefix.
+func bundleRefMatches(ref string, names []string) bool {
+	for _, name := range names {
+		if ref == name || shortRefName(ref) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// bundleTransport fetches from a bundle file. Bundles are read-only.
+// Their objects are imported when the refs are first asked for, since
+// only then are the IDs of the refs here known.
+type bundleTransport struct {
+	file string
+	refs []Ref
+}
+
+// load imports the bundle's objects, once, and returns its refs.
+func (t *bundleTransport) load() ([]Ref, error) {
+	if t.refs == nil {
+		refs, err := importBundle(t.file)
+		if err != nil {
+			return nil, err
+		}
+		t.refs = refs
+	}
+	return t.refs, nil
+}
+
+func (t *bundleTransport) advertise(push bool) (*advertisement, error) {
+	if push {
+		return nil, fmt.Errorf("cannot push to bundle '%s'", t.file)
+	}
+	refs, err := t.load()
+	if err != nil {
+		return nil, err
+	}
+
+	ad := &advertisement{}
+	for _, ref := range refs {
+		switch {
+		case ref.Name == "HEAD":
+			ad.Head = ref.Hash
+		case strings.HasPrefix(ref.Name, "refs/heads/"), strings.HasPrefix(ref.Name, "refs/tags/"):
+			ad.Refs = append(ad.Refs, ref)
+		}
+	}
+	// A bundle records HEAD's commit but not its branch: take the branch
+	// at that commit, preferring main. Without HEAD, main or the first
+	// branch stands in for it.
+	for _, ref := range ad.Refs {
+		if !strings.HasPrefix(ref.Name, "refs/heads/") || (ad.Head != "" && ref.Hash != ad.Head) {
+			continue
+		}
+		if ad.HeadRef == "" || ref.Name == "refs/heads/main" {
+			ad.HeadRef = ref.Name
+		}
+	}
+	if ad.Head == "" && ad.HeadRef != "" {
+		ad.Head = ad.ref(ad.HeadRef)
+	}
+	return ad, nil
+}
+
+// fetch has nothing left to do: advertise imported the objects.
+func (t *bundleTransport) fetch(wants []string) error {
+	_, err := t.load()
+	return err
+}
+
+// fetchLFS has nothing to copy: bundles carry no large objects, so their
+// large files check out as pointers until fetched from elsewhere.
+func (t *bundleTransport) fetchLFS(pointers []lfsPointer) error {
+	return nil
+}
+
+func (t *bundleTransport) push(updates []refUpdate) (map[string]string, error) {
+	return nil, fmt.Errorf("cannot push to bundle '%s'", t.file)
+}
@@ -0,0 +1,33 @@
+package gogit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBundleFetchIntoFetchHead(t *testing.T) {
+	root := t.TempDir()
+	source := initTestRepo(t, root, "source")
+	chdir(t, source)
+	if err := os.MkdirAll(filepath.Join("src", "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	commitFile(t, filepath.Join("src", "sub", "file.txt"), "one\n", "one")
+	quietly(t, func() error { return CreateBundle(filepath.Join(root, "full.bundle"), []string{"--all"}) })
+
+	chdir(t, root)
+	quietly(t, func() error { return Clone(filepath.Join(root, "full.bundle"), "client") })
+
+	// The second bundle only holds the new commit and names the first as
+	// its prerequisite, whose Git ID the client must work out again.
+	chdir(t, source)
+	head := commitFile(t, "top.txt", "two\n", "two")
+	quietly(t, func() error { return CreateBundle(filepath.Join(root, "next.bundle"), []string{"main~1..main"}) })
+
+	chdir(t, filepath.Join(root, "client"))
+	quietly(t, func() error { return Fetch(filepath.Join(root, "next.bundle"), false) })
+	if got, err := ResolveRevision("FETCH_HEAD"); err != nil || got != head {
+		t.Fatalf("FETCH_HEAD = %q, %v; want %q", got, err, head)
+	}
+}
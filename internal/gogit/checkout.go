@@ -0,0 +1,363 @@
+package gogit
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// zeroHash stands for "no commit" in hook arguments and on the wire.
+var zeroHash = strings.Repeat("0", 40)
+
+// CheckoutOptions controls Checkout.
+type CheckoutOptions struct {
+	// NewBranch creates a branch with this name at the target and
+	// switches to it.
+	NewBranch string
+	// Detach checks out the target commit with a detached HEAD even when
+	// it names a branch.
+	Detach bool
+	// Force discards local changes that would otherwise block the switch.
+	Force bool
+}
+
+// Checkout switches HEAD, the index and the working tree to target, a
+// branch name or any revision. Revisions that are not branch names
+// leave HEAD detached.
+func Checkout(target string, opts *CheckoutOptions) error {
+	unmerged, err := ReadUnmerged()
+	if err != nil {
+		return err
+	}
+	if len(unmerged) > 0 && !opts.Force {
+		return fmt.Errorf("you need to resolve your current index first")
+	}
+
+	oldRef, oldHash, err := ReadHead()
+	if err != nil {
+		return err
+	}
+
+	var newRef, newHash string
+	switch {
+	case opts.NewBranch != "":
+		if err := ValidateRefName(opts.NewBranch); err != nil {
+			return err
+		}
+		newRef = "refs/heads/" + opts.NewBranch
+		existing, err := ReadRef(newRef)
+		if err != nil {
+			return err
+		}
+		if existing != "" {
+			return fmt.Errorf("a branch named '%s' already exists", opts.NewBranch)
+		}
+		if target == "" {
+			target = "HEAD"
+		}
+		if newHash, err = resolveCommit(target); err != nil {
+			return err
+		}
+	case !opts.Detach && !strings.HasPrefix(target, "refs/"):
+		hash, err := ReadRef("refs/heads/" + target)
+		if err != nil {
+			return err
+		}
+		if hash != "" {
+			newRef, newHash = "refs/heads/"+target, hash
+			break
+		}
+		remote, err := trackingRemote(target)
+		if err != nil {
+			return err
+		}
+		if remote != "" {
+			return checkoutTracking(remote, target, opts)
+		}
+		fallthrough
+	default:
+		if newHash, err = resolveCommit(target); err != nil {
+			return err
+		}
+	}
+
+	oldTree, err := commitTree(oldHash)
+	if err != nil {
+		return err
+	}
+	newTree, err := commitTree(newHash)
+	if err != nil {
+		return err
+	}
+	if err := checkoutTree(oldTree, newTree, opts.Force, "checkout"); err != nil {
+		return err
+	}
+
+	if opts.NewBranch != "" {
+		if err := UpdateRef(newRef, newHash, "branch: Created from "+target); err != nil {
+			return err
+		}
+	}
+	if err := writeUnmerged(nil); err != nil {
+		return err
+	}
+	from := strings.TrimPrefix(oldRef, "refs/heads/")
+	if oldRef == "" {
+		from = oldHash
+	}
+	to := target
+	if opts.NewBranch != "" {
+		to = opts.NewBranch
+	}
+	reflogMessage := fmt.Sprintf("checkout: moving from %s to %s", from, to)
+	if newRef != "" {
+		if err := AttachHead(newRef, reflogMessage); err != nil {
+			return err
+		}
+	} else if err := DetachHead(newHash, reflogMessage); err != nil {
+		return err
+	}
+
+	branchName := strings.TrimPrefix(newRef, "refs/heads/")
+	switch {
+	case opts.NewBranch != "":
+		fmt.Printf("Switched to a new branch '%s'\n", branchName)
+	case newRef != "" && newRef == oldRef:
+		fmt.Printf("Already on '%s'\n", branchName)
+	case newRef != "":
+		fmt.Printf("Switched to branch '%s'\n", branchName)
+	default:
+		commit, err := ReadCommit(newHash)
+		if err != nil {
+			return err
+		}
+		subject, _ := splitMessage(commit.Message)
+		if oldRef != "" {
+			fmt.Printf("Note: switching to '%s'.\n\n", target)
+			fmt.Println("You are in 'detached HEAD' state. You can look around and make commits;")
+			fmt.Println("switch back to a branch with \"gogit checkout <branch>\", or keep your")
+			fmt.Println("commits by creating a branch with \"gogit checkout -b <new-branch>\".")
+			fmt.Println()
+		}
+		fmt.Printf("HEAD is now at %s %s\n", abbrevHash(newHash), subject)
+	}
+
+	// post-checkout can only report the switch, which has happened; its
+	// exit status is ignored.
+	if err := RunHook("post-checkout", hashOrZero(oldHash), newHash, "1"); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+	}
+	return nil
+}
+
+// trackingRemote returns the only remote with a tracking branch called
+// branch, or "" when there is none or several.
+func trackingRemote(branch string) (string, error) {
+	remotes, err := ListRemotes()
+	if err != nil {
+		return "", err
+	}
+	found := ""
+	for _, remote := range remotes {
+		hash, err := ReadRef(remoteRefs(remote.Name) + branch)
+		if err != nil {
+			return "", err
+		}
+		if hash != "" {
+			if found != "" {
+				return "", nil
+			}
+			found = remote.Name
+		}
+	}
+	return found, nil
+}
+
+// checkoutTracking creates and checks out a local branch from the
+// tracking branch of the same name on remote, with it as upstream.
+func checkoutTracking(remote, branch string, opts *CheckoutOptions) error {
+	created := *opts
+	created.NewBranch = branch
+	if err := Checkout(remote+"/"+branch, &created); err != nil {
+		return err
+	}
+	if err := setUpstream(branch, remote, "refs/heads/"+branch); err != nil {
+		return err
+	}
+	fmt.Printf("branch '%s' set up to track '%s/%s'.\n", branch, remote, branch)
+	return nil
+}
+
+// resolveCommit resolves rev and checks that it names a commit.
+func resolveCommit(rev string) (string, error) {
+	hash, err := ResolveRevision(rev)
+	if err != nil {
+		return "", err
+	}
+	if _, err := ReadCommit(hash); err != nil {
+		return "", fmt.Errorf("'%s' is not a commit", rev)
+	}
+	return hash, nil
+}
+
+// commitTree returns the path -> blob hash map of a commit's tree, or an
+// empty map for an empty hash.
+func commitTree(hash string) (map[string]string, error) {
+	if hash == "" {
+		return make(map[string]string), nil
+	}
+	commit, err := ReadCommit(hash)
+	if err != nil {
+		return nil, err
+	}
+	return ReadTree(commit.Tree)
+}
+
+func hashOrZero(hash string) string {
+	if hash == "" {
+		return zeroHash
+	}
+	return hash
+}
+
+// checkoutTree moves the index and the working tree from oldTree to
+// newTree. Files that are the same in both trees keep any local changes.
+// Unless force is set, nothing is touched when a file that differs
+// between the trees has local changes, or when an untracked file is in
+// the way; operation names the command in that error. With force, every
+// file is reset to newTree.
+func checkoutTree(oldTree, newTree map[string]string, force bool, operation string) error {
+	lock, err := lockIndex()
+	if err != nil {
+		return err
+	}
+	defer lock.rollback()
+	indexMap, err := ReadIndex()
+	if err != nil {
+		return err
+	}
+	if err := checkoutIndex(indexMap, oldTree, newTree, force, operation); err != nil {
+		return err
+	}
+	return lock.commitIndex(indexMap)
+}
+
+// checkoutIndex is checkoutTree for callers that hold the index lock: it
+// updates indexMap in place and leaves writing it to them.
+func checkoutIndex(indexMap, oldTree, newTree map[string]string, force bool, operation string) error {
+	workdirMap, err := BuildWorkdirMap()
+	if err != nil {
+		return fmt.Errorf("could not build the working directory map: %w", err)
+	}
+
+	paths := make(map[string]bool)
+	for path := range oldTree {
+		paths[path] = true
+	}
+	for path := range newTree {
+		paths[path] = true
+	}
+	if force {
+		for path := range indexMap {
+			paths[path] = true
+		}
+	}
+
+	var dirty, untracked []string
+	var toWrite, toRemove []string
+	for path := range paths {
+		oldHash, newHash := oldTree[path], newTree[path]
+		indexHash, workdirHash := indexMap[path], workdirMap[path]
+
+		if !force && oldHash == newHash {
+			continue
+		}
+		if indexHash == newHash && workdirHash == newHash {
+			continue
+		}
+
+		if !force {
+			switch {
+			case oldHash == "" && indexHash == "" && workdirHash != "":
+				untracked = append(untracked, path)
+				continue
+			case indexHash != oldHash || workdirHash != indexHash:
+				dirty = append(dirty, path)
+				continue
+			}
+		}
+
+		switch {
+		case newHash != "":
+			toWrite = append(toWrite, path)
+		case oldHash != "" || indexHash != "":
+			toRemove = append(toRemove, path)
+		}
+	}
+
+	if len(dirty) > 0 || len(untracked) > 0 {
+		var sb strings.Builder
+		if len(dirty) > 0 {
+			sort.Strings(dirty)
+			fmt.Fprintf(&sb, "your local changes to the following files would be overwritten by %s:\n", operation)
+			for _, path := range dirty {
+				fmt.Fprintf(&sb, "\t%s\n", path)
+			}
+			sb.WriteString("Please commit your changes or stash them before you continue.")
+		}
+		if len(untracked) > 0 {
+			sort.Strings(untracked)
+			if sb.Len() > 0 {
+				sb.WriteString("\n")
+			}
+			fmt.Fprintf(&sb, "the following untracked working tree files would be overwritten by %s:\n", operation)
+			for _, path := range untracked {
+				fmt.Fprintf(&sb, "\t%s\n", path)
+			}
+			sb.WriteString("Please move or remove them before you continue.")
+		}
+		return fmt.Errorf("%s", sb.String())
+	}
+
+	c, err := treeConverter(newTree)
+	if err != nil {
+		return err
+	}
+	defer c.close()
+	sort.Strings(toWrite)
+	for _, path := range toWrite {
+		if err := c.writeFile(path, newTree[path]); err != nil {
+			return err
+		}
+		indexMap[path] = newTree[path]
+	}
+	sort.Strings(toRemove)
+	for _, path := range toRemove {
+		if oldTree[path] == "" {
+			// Staged files that no commit knows about become untracked.
+			delete(indexMap, path)
+			continue
+		}
+		if err := removeWorkdirFile(path); err != nil {
+			return err
+		}
+		delete(indexMap, path)
+	}
+	return nil
+}
+
+// removeWorkdirFile deletes path from the working tree along with any
+// parent directories left empty.
+func removeWorkdirFile(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error removing %s: %w", path, err)
+	}
+	for dir := filepath.Dir(path); dir != "." && dir != "/"; dir = filepath.Dir(dir) {
+		if err := os.Remove(dir); err != nil {
+			break
+		}
+	}
+	return nil
+}
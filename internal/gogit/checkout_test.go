@@ -0,0 +1,32 @@
+package gogit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPostCheckoutHookCannotAbort(t *testing.T) {
+	dir := initTestRepo(t, t.TempDir(), "repo")
+	chdir(t, dir)
+	first := commitFile(t, "file.txt", "one\n", "first")
+	second := commitFile(t, "file.txt", "two\n", "second")
+
+	// The hook records its arguments and fails.
+	hook := "#!/bin/sh\necho \"$@\" > ../hook-args\nexit 1\n"
+	if err := os.WriteFile(filepath.Join(RepoPath, "hooks", "post-checkout"), []byte(hook), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	quietly(t, func() error { return Checkout(first, &CheckoutOptions{}) })
+	if _, head, err := ReadHead(); err != nil || head != first {
+		t.Fatalf("HEAD = %q, %v; want %q", head, err, first)
+	}
+	args, err := os.ReadFile(filepath.Join(dir, "..", "hook-args"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := second + " " + first + " 1\n"; string(args) != want {
+		t.Fatalf("post-checkout arguments = %q, want %q", args, want)
+	}
+}
@@ -1,9 +1,82 @@
 package gogit
 
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
 const (
-	ColorReset  = "\033[0m"
-	ColorRed    = "\033[31m"
-	ColorGreen  = "\033[32m"
-	ColorYellow = "\033[33m"
-	ColorBlue   = "\033[34m"
+	ansiReset   = "\033[0m"
+	ansiRed     = "\033[31m"
+	ansiGreen   = "\033[32m"
+	ansiYellow  = "\033[33m"
+	ansiBlue    = "\033[34m"
+	ansiMagenta = "\033[35m"
+	ansiCyan    = "\033[36m"
+	ansiBold    = "\033[1m"
+)
+
+// The color variables hold escape sequences while colors are enabled and
+// are empty strings otherwise, so callers can interpolate them freely.
+var (
+	ColorReset   = ansiReset
+	ColorRed     = ansiRed
+	ColorGreen   = ansiGreen
+	ColorYellow  = ansiYellow
+	ColorBlue    = ansiBlue
+	ColorMagenta = ansiMagenta
+	ColorCyan    = ansiCyan
+	ColorBold    = ansiBold
 )
+
+// SetColorEnabled turns colored output on or off.
+func SetColorEnabled(enabled bool) {
+	if !enabled {
+		ColorReset, ColorRed, ColorGreen, ColorYellow = "", "", "", ""
+		ColorBlue, ColorMagenta, ColorCyan, ColorBold = "", "", "", ""
+		return
+	}
+	ColorReset, ColorRed, ColorGreen, ColorYellow = ansiReset, ansiRed, ansiGreen, ansiYellow
+	ColorBlue, ColorMagenta, ColorCyan, ColorBold = ansiBlue, ansiMagenta, ansiCyan, ansiBold
+}
+
+// UseColor decides whether output should be colored. flagMode is the
+// value of --color, empty when the flag was not given; it takes priority
+// over the NO_COLOR environment variable, which in turn overrides the
+// color.ui setting. "auto" colors only when stdout is a terminal.
+func UseColor(flagMode string, stdoutIsTerminal bool) (bool, error) {
+	mode := flagMode
+	if mode == "" {
+		if os.Getenv("NO_COLOR") != "" {
+			return false, nil
+		}
+		value, ok, err := GetConfig("color.ui")
+		if err != nil {
+			return false, err
+		}
+		mode = "auto"
+		if ok {
+			mode = value
+		}
+	}
+
+	switch strings.ToLower(mode) {
+	case "always":
+		return true, nil
+	case "never", "false", "no", "off":
+		return false, nil
+	case "auto", "true", "yes", "on":
+		return stdoutIsTerminal && os.Getenv("TERM") != "dumb", nil
+	}
+	return false, fmt.Errorf("invalid color mode '%s' (expected always, never or auto)", mode)
+}
+
+// IsTerminal reports whether f is connected to a terminal.
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
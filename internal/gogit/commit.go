@@ -2,6 +2,8 @@ package gogit
 
 import (
 	"bufio"
+	"bytes"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -10,29 +12,35 @@ import (
 	"time"
 )
 
+// ErrNothingToCommit is returned when a commit would record the same tree
+// as its parent and empty commits were not allowed.
+var ErrNothingToCommit = errors.New("nothing to commit")
+
 // ReadCommit reads a commit object from the repository and returns a Commit struct.
 func ReadCommit(hash string) (*Commit, error) {
-	firstTwo := hash[:2]
-	rest := hash[2:]
-
-	currentObjectPath := fmt.Sprintf("%s/%s/%s", ObjectsPath, firstTwo, rest)
-
-	file, err := os.Open(currentObjectPath)
+	data, err := readObjectFile(hash)
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
+	if objectType, _ := parseObjectData(data); objectType != ObjectTypeCommit {
+		return nil, fmt.Errorf("object %s is a %s, not a commit", hash, objectType)
+	}
+	return parseCommit(hash, data)
+}
 
+// parseCommit decodes the stored content of a commit object.
+func parseCommit(hash string, data []byte) (*Commit, error) {
 	var commit Commit
 	commit.Hash = hash
 
-	scanner := bufio.NewScanner(file)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
 	for scanner.Scan() {
 		line := scanner.Text()
 		if strings.HasPrefix(line, "tree ") {
 			commit.Tree = strings.TrimSpace(strings.TrimPrefix(line, "tree "))
 		} else if strings.HasPrefix(line, "parent ") {
-			commit.Parent = strings.TrimSpace(strings.TrimPrefix(line, "parent "))
+			commit.Parents = append(commit.Parents, strings.TrimSpace(strings.TrimPrefix(line, "parent ")))
+			commit.Parent = commit.Parents[0]
 		} else if strings.HasPrefix(line, "author ") {
 			commit.Author = strings.TrimSpace(strings.TrimPrefix(line, "author "))
 		} else if strings.HasPrefix(line, "date ") {
@@ -55,7 +63,37 @@ func ReadCommit(hash string) (*Commit, error) {
 	return &commit, nil
 }
 
-func AddCommit(message *string) error {
+// AddCommit records the index as a new commit on the current branch.
+func AddCommit(opts *CommitOptions) error {
+	if opts.All {
+		if err := StageTracked(); err != nil {
+			return err
+		}
+	}
+
+	if !opts.NoVerify {
+		if err := RunHook("pre-commit"); err != nil {
+			return err
+		}
+	}
+
+	// Hold the index while its tree is recorded. The lock is released
+	// before the message hooks and the editor run; the branch is then only
+	// moved if it still points where it did.
+	lock, err := lockIndex()
+	if err != nil {
+		return err
+	}
+	defer lock.rollback()
+
+	unmerged, err := ReadUnmerged()
+	if err != nil {
+		return err
+	}
+	if len(unmerged) > 0 {
+		return errUnmerged("committing")
+	}
+
 	indexMap, err := ReadIndex()
 	if err != nil {
 		return err
@@ -66,86 +104,270 @@ func AddCommit(message *string) error {
 		return nil
 	}
 
+	headRef, headHash, err := ReadHead()
+	if err != nil {
+		return fmt.Errorf("error reading HEAD: %w", err)
+	}
+	parentCommitHash := headHash
+
+	var parents []string
+	if parentCommitHash != "" {
+		parents = []string{parentCommitHash}
+	}
+	// When amending, the new commit replaces HEAD and takes over its
+	// parents, all of them for a merge.
+	var amended *Commit
+	if opts.Amend {
+		if parentCommitHash == "" {
+			return fmt.Errorf("you have nothing to amend")
+		}
+		if amended, err = ReadCommit(parentCommitHash); err != nil {
+			return err
+		}
+		parentCommitHash, parents = amended.Parent, amended.Parents
+	}
+
 	// --- Generate and save the Tree object ---
 	treeHash, treeContent, err := HashTree(indexMap)
 	if err != nil {
 		return fmt.Errorf("error hashing tree: %w", err)
 	}
 
-	treeFirstTwo := treeHash[:2]
-	treeRest := treeHash[2:]
-
-	// Create necessary directories for the tree object
-	if err := os.MkdirAll(filepath.Join(ObjectsPath, treeFirstTwo), 0755); err != nil {
-		return fmt.Errorf("error creating directory for tree object %s: %w", treeFirstTwo, err)
+	// Refuse to record a commit that changes nothing. Like Git, amending a
+	// merge is allowed even when its tree is its first parent's.
+	if parentCommitHash != "" && !opts.AllowEmpty && len(parents) < 2 {
+		parent, err := ReadCommit(parentCommitHash)
+		if err != nil {
+			return err
+		}
+		if parent.Tree == treeHash {
+			return nothingToCommitError(amended != nil)
+		}
 	}
 
-	// Write the tree object content
-	treeObjectPath := filepath.Join(ObjectsPath, treeFirstTwo, treeRest)
-	if err := os.WriteFile(treeObjectPath, treeContent, 0644); err != nil {
-		return fmt.Errorf("error writing tree object to %s: %w", treeObjectPath, err)
+	if err := writeObject(treeHash, treeContent); err != nil {
+		return fmt.Errorf("error writing tree object: %w", err)
 	}
 	// --- End Tree object generation ---
+	lock.rollback()
 
-	headRef, err := GetHeadRef()
+	message, err := commitMessage(opts, amended)
 	if err != nil {
 		return err
 	}
 
-	var parentCommitHash string
-	branchRefPath := fmt.Sprintf("%s/%s", RepoPath, headRef["ref:"])
-	branchRef, err := os.Open(branchRefPath)
+	commitHash, commitContent, err := hashCommitAt(treeHash, parents, authorName, time.Now(), message)
 	if err != nil {
-		// If the branch ref file doesn't exist, it's likely the first commit.
-		// In this case, parentCommitHash remains empty.
-		if os.IsNotExist(err) {
-			parentCommitHash = ""
-		} else {
-			return err
+		return fmt.Errorf("error hashing commit: %w", err)
+	}
+
+	if err := writeObject(commitHash, commitContent); err != nil {
+		return fmt.Errorf("error creating commit object file: %w", err)
+	}
+
+	// Update branch reference (e.g., refs/heads/main)
+	reflogMessage := "commit: "
+	switch {
+	case amended != nil:
+		reflogMessage = "commit (amend): "
+	case parentCommitHash == "":
+		reflogMessage = "commit (initial): "
+	}
+	subject, _ := splitMessage(message)
+	if err := updateHeadVerified(headRef, headHash, commitHash, reflogMessage+subject); err != nil {
+		return fmt.Errorf("error updating branch reference file: %w", err)
+	}
+
+	// A commit made by hand concludes a stopped cherry-pick or revert.
+	if err := clearPickState(); err != nil {
+		return err
+	}
+
+	// post-commit is purely a notification; its exit status is ignored.
+	if err := RunHook("post-commit"); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+	}
+
+	return nil
+}
+
+// recordCommit stores indexMap as a commit on top of parent with the
+// given author, date and message, and moves HEAD to it, logging
+// reflogMessage. Unlike AddCommit it runs no hooks; commands that replay
+// existing commits use it.
+func recordCommit(indexMap map[string]string, parent, author string, date time.Time, message, reflogMessage string) (string, error) {
+	var parents []string
+	if parent != "" {
+		parents = append(parents, parent)
+	}
+	commitHash, err := storeCommit(indexMap, parents, author, date, message)
+	if err != nil {
+		return "", err
+	}
+	if err := UpdateHead(commitHash, reflogMessage); err != nil {
+		return "", fmt.Errorf("error updating branch reference file: %w", err)
+	}
+	return commitHash, nil
+}
+
+// storeCommit writes the tree of files and a commit of it to the object
+// store, without moving any ref.
+func storeCommit(files map[string]string, parents []string, author string, date time.Time, message string) (string, error) {
+	treeHash, treeContent, err := HashTree(files)
+	if err != nil {
+		return "", fmt.Errorf("error hashing tree: %w", err)
+	}
+	if err := writeObject(treeHash, treeContent); err != nil {
+		return "", fmt.Errorf("error writing tree object: %w", err)
+	}
+
+	commitHash, commitContent, err := hashCommitAt(treeHash, parents, author, date, message)
+	if err != nil {
+		return "", fmt.Errorf("error hashing commit: %w", err)
+	}
+	if err := writeObject(commitHash, commitContent); err != nil {
+		return "", fmt.Errorf("error creating commit object file: %w", err)
+	}
+	return commitHash, nil
+}
+
+// nothingToCommitError explains why a commit would be empty.
+func nothingToCommitError(amending bool) error {
+	if amending {
+		return fmt.Errorf("%w: amending the last commit would make it empty; use --allow-empty to amend it anyway", ErrNothingToCommit)
+	}
+
+	statusInfo, err := GetStatus(false)
+	if err != nil {
+		return err
+	}
+	for _, entry := range statusInfo.Entries {
+		if entry.Worktree != StatusUnmodified && entry.Worktree != StatusUntracked {
+			return fmt.Errorf("%w (use \"gogit add\" and/or \"gogit commit -a\" to stage changes)", ErrNothingToCommit)
 		}
-	} else {
-		defer branchRef.Close()
-		scannerBranchRef := bufio.NewScanner(branchRef)
-		for scannerBranchRef.Scan() {
-			parentCommitHash = scannerBranchRef.Text()
+	}
+	return fmt.Errorf("%w, working tree clean", ErrNothingToCommit)
+}
+
+// commitMessage returns the cleaned-up message for a new commit. The
+// message is staged in .gogit/COMMIT_EDITMSG, where the
+// prepare-commit-msg hook, the editor (when opts asks for it) and the
+// commit-msg hook can change it in turn. amended is the commit being
+// replaced by --amend, if any; its message is the default.
+func commitMessage(opts *CommitOptions, amended *Commit) (string, error) {
+	message := opts.Message
+	if message == "" && amended != nil {
+		message = amended.Message
+	}
+	// A stopped cherry-pick or revert has prepared a message.
+	if message == "" {
+		prepared, err := os.ReadFile(mergeMsgPath())
+		if err != nil && !os.IsNotExist(err) {
+			return "", fmt.Errorf("error reading %s: %w", mergeMsgPath(), err)
 		}
-		if err := scannerBranchRef.Err(); err != nil {
-			return fmt.Errorf("error scanning branch ref file: %w", err)
+		message = string(prepared)
+	}
+
+	content := message
+	if opts.Edit {
+		template, err := commitTemplate(message)
+		if err != nil {
+			return "", err
 		}
+		content = template
 	}
 
-	authorName := "TonyGLL"
-	// Call HashCommit with the treeHash
-	commitHash, commitContent, err := HashCommit(treeHash, parentCommitHash, authorName, *message)
-	if err != nil {
-		return fmt.Errorf("error hashing commit: %w", err)
+	messagePath := filepath.Join(RepoPath, "COMMIT_EDITMSG")
+	if err := os.WriteFile(messagePath, []byte(content), 0644); err != nil {
+		return "", fmt.Errorf("error writing %s: %w", messagePath, err)
 	}
 
-	firstTwo := commitHash[:2]
-	rest := commitHash[2:]
+	var source []string
+	switch {
+	case opts.Message != "":
+		source = []string{"message"}
+	case amended != nil:
+		source = []string{"commit", amended.Hash}
+	}
+	if err := RunHook("prepare-commit-msg", append([]string{messagePath}, source...)...); err != nil {
+		return "", err
+	}
 
-	// Create necessary directories for the commit object
-	if err := os.MkdirAll(filepath.Join(ObjectsPath, firstTwo), 0755); err != nil {
-		return fmt.Errorf("error creating directory for commit object %s: %w", firstTwo, err)
+	if opts.Edit {
+		if err := LaunchEditor(messagePath); err != nil {
+			return "", err
+		}
 	}
 
-	// Create commit object file
-	newCommitObjectPath := filepath.Join(ObjectsPath, firstTwo, rest)
-	if err := os.WriteFile(newCommitObjectPath, commitContent, 0644); err != nil {
-		return fmt.Errorf("error creating commit object file: %w", err)
+	if !opts.NoVerify {
+		if err := RunHook("commit-msg", messagePath); err != nil {
+			return "", err
+		}
 	}
 
-	// Update branch reference (e.g., refs/heads/main)
-	newRefHeadPath := filepath.Join(RepoPath, headRef["ref:"])
-	if err := os.WriteFile(newRefHeadPath, []byte(commitHash+"\n"), 0644); err != nil {
-		return fmt.Errorf("error updating branch reference file: %w", err)
+	edited, err := os.ReadFile(messagePath)
+	if err != nil {
+		return "", fmt.Errorf("error reading %s: %w", messagePath, err)
+	}
+	message = CleanupMessage(string(edited), opts.Edit)
+	if message == "" {
+		return "", fmt.Errorf("aborting commit due to empty commit message")
+	}
+	return message, nil
+}
+
+// commitTemplate builds the text shown in the editor: the initial message
+// followed by commented-out instructions and the repository status.
+func commitTemplate(initial string) (string, error) {
+	statusInfo, err := GetStatus(false)
+	if err != nil {
+		return "", err
 	}
 
-	// Clear the index after a successful commit
-	// err = os.WriteFile(IndexPath, []byte(""), 0644)
-	// if err != nil {
-	// 	return fmt.Errorf("error clearing index file: %w", err)
-	// }
+	var sb strings.Builder
+	if initial != "" {
+		sb.WriteString(initial + "\n")
+	}
+	sb.WriteString("\n")
+	sb.WriteString("# Please enter the commit message for your changes. Lines starting\n")
+	sb.WriteString("# with '#' will be ignored, and an empty message aborts the commit.\n")
+	sb.WriteString("#\n")
+	fmt.Fprintf(&sb, "# On branch %s\n", statusInfo.Branch)
 
-	return nil
+	groups := groupStatus(statusInfo)
+	for _, section := range []struct {
+		title string
+		lines []string
+	}{
+		{"Changes to be committed:", groups.staged},
+		{"Unmerged paths:", groups.unmerged},
+		{"Changes not staged for commit:", groups.unstaged},
+		{"Untracked files:", groups.untracked},
+	} {
+		if len(section.lines) == 0 {
+			continue
+		}
+		fmt.Fprintf(&sb, "# %s\n", section.title)
+		for _, line := range section.lines {
+			fmt.Fprintf(&sb, "#\t%s\n", line)
+		}
+		sb.WriteString("#\n")
+	}
+	return sb.String(), nil
+}
+
+// IsAncestor reports whether ancestor is reachable from descendant by
+// following parent links. A commit counts as its own ancestor.
+func IsAncestor(ancestor, descendant string) (bool, error) {
+	for hash := descendant; hash != ""; {
+		if hash == ancestor {
+			return true, nil
+		}
+		commit, err := ReadCommit(hash)
+		if err != nil {
+			return false, err
+		}
+		hash = commit.Parent
+	}
+	return false, nil
 }
@@ -0,0 +1,68 @@
+package gogit
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestAmendKeepsMergeParents(t *testing.T) {
+	dir := initTestRepo(t, t.TempDir(), "repo")
+	chdir(t, dir)
+	first := commitFile(t, "file.txt", "one\n", "first")
+	second := commitFile(t, "file.txt", "two\n", "second")
+
+	index, err := ReadIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	merge, err := storeCommit(index, []string{second, first}, "a <a@b>", time.Now(), "merge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := UpdateHead(merge, "merge"); err != nil {
+		t.Fatal(err)
+	}
+
+	quietly(t, func() error { return AddCommit(&CommitOptions{Amend: true, Message: "amended merge"}) })
+	_, head, err := ReadHead()
+	if err != nil {
+		t.Fatal(err)
+	}
+	amended, err := ReadCommit(head)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{second, first}; !slices.Equal(amended.Parents, want) {
+		t.Fatalf("amended parents = %v, want %v", amended.Parents, want)
+	}
+}
+
+func TestCommitReleasesIndexForMessageHooks(t *testing.T) {
+	dir := initTestRepo(t, t.TempDir(), "repo")
+	chdir(t, dir)
+	first := commitFile(t, "file.txt", "one\n", "first")
+	second := commitFile(t, "file.txt", "two\n", "second")
+
+	// The hook fails if the index is still locked, and otherwise moves the
+	// branch back the way a concurrent command could.
+	hook := "#!/bin/sh\ntest -e .gogit/index.lock && exit 1\necho " + first + " > .gogit/refs/heads/main\n"
+	if err := os.WriteFile(filepath.Join(RepoPath, "hooks", "commit-msg"), []byte(hook), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile("file.txt", []byte("three\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	quietly(t, func() error { return Add("file.txt") })
+
+	err := AddCommit(&CommitOptions{Message: "third"})
+	if !errors.Is(err, ErrRefMoved) {
+		t.Fatalf("commit after the branch moved from %s: err = %v, want ErrRefMoved", second, err)
+	}
+	if got, err := ReadRef("refs/heads/main"); err != nil || got != first {
+		t.Fatalf("main = %q, %v; want the hook's %q", got, err, first)
+	}
+}
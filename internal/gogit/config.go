@@ -0,0 +1,375 @@
+package gogit
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ConfigEntry is one key/value pair from a config file. Key is in its
+// canonical "section.subsection.name" form.
+type ConfigEntry struct {
+	Key   string
+	Value string
+}
+
+// configLine is a parsed line of a config file. Lines that are not
+// key/value pairs (comments, blank lines, section headers) have no key.
+type configLine struct {
+	raw     string
+	section string
+	key     string
+	value   string
+}
+
+// RepoConfigPath returns the path of the repository's config file.
+func RepoConfigPath() string {
+	return filepath.Join(RepoPath, "config")
+}
+
+// GlobalConfigPath returns the path of the per-user config file.
+func GlobalConfigPath() string {
+	if path := os.Getenv("GOGIT_CONFIG_GLOBAL"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".gogitconfig")
+}
+
+// ListConfig returns the entries of the global config followed by the
+// repository config, in file order.
+func ListConfig() ([]ConfigEntry, error) {
+	var entries []ConfigEntry
+	for _, path := range []string{GlobalConfigPath(), RepoConfigPath()} {
+		if path == "" {
+			continue
+		}
+		lines, err := readConfigFile(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range lines {
+			if line.key != "" {
+				entries = append(entries, ConfigEntry{Key: line.key, Value: line.value})
+			}
+		}
+	}
+	return entries, nil
+}
+
+// GetConfigAll returns every value of key, global values first.
+func GetConfigAll(key string) ([]string, error) {
+	canonical, err := canonicalConfigKey(key)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := ListConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	var values []string
+	for _, entry := range entries {
+		if entry.Key == canonical {
+			values = append(values, entry.Value)
+		}
+	}
+	return values, nil
+}
+
+// GetConfig returns the last value of key; the repository config
+// overrides the global one. ok is false when the key is not set.
+func GetConfig(key string) (value string, ok bool, err error) {
+	values, err := GetConfigAll(key)
+	if err != nil || len(values) == 0 {
+		return "", false, err
+	}
+	return values[len(values)-1], true, nil
+}
+
+// GetConfigBool reads a boolean key, returning def when it is not set.
+func GetConfigBool(key string, def bool) (bool, error) {
+	value, ok, err := GetConfig(key)
+	if err != nil || !ok {
+		return def, err
+	}
+	return parseConfigBool(key, value)
+}
+
+// GetConfigInt reads an integer key, returning def when it is not set.
+func GetConfigInt(key string, def int) (int, error) {
+	value, ok, err := GetConfig(key)
+	if err != nil || !ok {
+		return def, err
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return def, fmt.Errorf("bad numeric config value '%s' for '%s'", value, key)
+	}
+	return n, nil
+}
+
+func parseConfigBool(key, value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "true", "yes", "on", "1":
+		return true, nil
+	case "false", "no", "off", "0", "":
+		return false, nil
+	}
+	return false, fmt.Errorf("bad boolean config value '%s' for '%s'", value, key)
+}
+
+// SetConfig sets key to value in the repository config, or in the
+// global config when global is set. An existing value is replaced.
+func SetConfig(key, value string, global bool) error {
+	return editConfig(key, global, func(lines []configLine, section, name string) []configLine {
+		newLine := configLine{raw: "\t" + name + " = " + quoteConfigValue(value), section: section, key: section + "." + strings.ToLower(name), value: value}
+
+		lastInSection := -1
+		replaced := false
+		for i, line := range lines {
+			if line.section != section {
+				continue
+			}
+			lastInSection = i
+			if line.key == newLine.key {
+				lines[i] = newLine
+				replaced = true
+			}
+		}
+
+		switch {
+		case replaced:
+			return lines
+		case lastInSection >= 0:
+			lines = append(lines[:lastInSection+1], append([]configLine{newLine}, lines[lastInSection+1:]...)...)
+			return lines
+		}
+		return append(lines, configLine{raw: sectionHeader(section), section: section}, newLine)
+	})
+}
+
+// AddConfig appends another value for a multi-valued key.
+func AddConfig(key, value string, global bool) error {
+	return editConfig(key, global, func(lines []configLine, section, name string) []configLine {
+		newLine := configLine{raw: "\t" + name + " = " + quoteConfigValue(value), section: section, key: section + "." + strings.ToLower(name), value: value}
+		for i := len(lines) - 1; i >= 0; i-- {
+			if lines[i].section == section {
+				return append(lines[:i+1], append([]configLine{newLine}, lines[i+1:]...)...)
+			}
+		}
+		return append(lines, configLine{raw: sectionHeader(section), section: section}, newLine)
+	})
+}
+
+// UnsetConfig removes every value of key.
+func UnsetConfig(key string, global bool) error {
+	canonical, err := canonicalConfigKey(key)
+	if err != nil {
+		return err
+	}
+	found := false
+	err = editConfig(key, global, func(lines []configLine, section, name string) []configLine {
+		kept := lines[:0]
+		for _, line := range lines {
+			if line.key == canonical {
+				found = true
+				continue
+			}
+			kept = append(kept, line)
+		}
+		return kept
+	})
+	if err == nil && !found {
+		return fmt.Errorf("key '%s' is not set", key)
+	}
+	return err
+}
+
+// RemoveConfigSection deletes a whole section such as "remote.origin".
+func RemoveConfigSection(section string, global bool) error {
+	section = canonicalSection(section)
+	path := configPath(global)
+	lines, err := readConfigFile(path)
+	if err != nil {
+		return err
+	}
+
+	kept := lines[:0]
+	found := false
+	for _, line := range lines {
+		if line.section == section {
+			found = true
+			continue
+		}
+		kept = append(kept, line)
+	}
+	if !found {
+		return fmt.Errorf("no such section: %s", section)
+	}
+	return writeConfigFile(path, kept)
+}
+
+func editConfig(key string, global bool, edit func(lines []configLine, section, name string) []configLine) error {
+	canonical, err := canonicalConfigKey(key)
+	if err != nil {
+		return err
+	}
+	dot := strings.LastIndexByte(canonical, '.')
+	section := canonical[:dot]
+	name := key[strings.LastIndexByte(key, '.')+1:]
+
+	path := configPath(global)
+	lines, err := readConfigFile(path)
+	if err != nil {
+		return err
+	}
+	return writeConfigFile(path, edit(lines, section, name))
+}
+
+func configPath(global bool) string {
+	if global {
+		return GlobalConfigPath()
+	}
+	return RepoConfigPath()
+}
+
+// canonicalConfigKey lowercases the section and variable name of a key,
+// keeping the subsection as written: "Remote.Origin.URL" becomes
+// "remote.Origin.url".
+func canonicalConfigKey(key string) (string, error) {
+	first := strings.IndexByte(key, '.')
+	last := strings.LastIndexByte(key, '.')
+	if first <= 0 || last == len(key)-1 {
+		return "", fmt.Errorf("key does not contain a section: %s", key)
+	}
+	return canonicalSection(key[:last]) + "." + strings.ToLower(key[last+1:]), nil
+}
+
+func canonicalSection(section string) string {
+	name, subsection, found := strings.Cut(section, ".")
+	if !found {
+		return strings.ToLower(name)
+	}
+	return strings.ToLower(name) + "." + subsection
+}
+
+func sectionHeader(section string) string {
+	name, subsection, found := strings.Cut(section, ".")
+	if !found {
+		return "[" + name + "]"
+	}
+	return fmt.Sprintf("[%s %s]", name, strconv.Quote(subsection))
+}
+
+func quoteConfigValue(value string) string {
+	if value == "" || strings.TrimSpace(value) != value || strings.ContainsAny(value, "#;\"\\") {
+		return strconv.Quote(value)
+	}
+	return value
+}
+
+func readConfigFile(path string) ([]configLine, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error opening config file %s: %w", path, err)
+	}
+	defer file.Close()
+
+	var lines []configLine
+	section := ""
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		raw := scanner.Text()
+		trimmed := strings.TrimSpace(raw)
+		line := configLine{raw: raw, section: section}
+
+		switch {
+		case trimmed == "" || trimmed[0] == '#' || trimmed[0] == ';':
+		case trimmed[0] == '[':
+			end := strings.IndexByte(trimmed, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("bad config line %d in %s", lineNumber, path)
+			}
+			header := strings.TrimSpace(trimmed[1:end])
+			if name, subsection, found := strings.Cut(header, " "); found {
+				unquoted, err := strconv.Unquote(strings.TrimSpace(subsection))
+				if err != nil {
+					return nil, fmt.Errorf("bad config line %d in %s", lineNumber, path)
+				}
+				section = strings.ToLower(name) + "." + unquoted
+			} else {
+				section = strings.ToLower(header)
+			}
+			line.section = section
+		default:
+			if section == "" {
+				return nil, fmt.Errorf("bad config line %d in %s", lineNumber, path)
+			}
+			name, value, hasValue := strings.Cut(trimmed, "=")
+			line.key = section + "." + strings.ToLower(strings.TrimSpace(name))
+			line.value = "true"
+			if hasValue {
+				line.value = parseConfigValue(value)
+			}
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading config file %s: %w", path, err)
+	}
+	return lines, nil
+}
+
+// parseConfigValue strips comments, surrounding whitespace and quotes
+// from the right-hand side of a "name = value" line.
+func parseConfigValue(raw string) string {
+	var sb strings.Builder
+	inQuotes := false
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+		switch {
+		case c == '\\' && i+1 < len(raw):
+			i++
+			switch raw[i] {
+			case 'n':
+				sb.WriteByte('\n')
+			case 't':
+				sb.WriteByte('\t')
+			default:
+				sb.WriteByte(raw[i])
+			}
+		case c == '"':
+			inQuotes = !inQuotes
+		case (c == '#' || c == ';') && !inQuotes:
+			return strings.TrimSpace(sb.String())
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+func writeConfigFile(path string, lines []configLine) error {
+	if path == "" {
+		return fmt.Errorf("could not determine the config file location")
+	}
+	var sb strings.Builder
+	for _, line := range lines {
+		sb.WriteString(line.raw)
+		sb.WriteByte('\n')
+	}
+	if err := os.WriteFile(path, []byte(sb.String()), 0644); err != nil {
+		return fmt.Errorf("error writing config file %s: %w", path, err)
+	}
+	return nil
+}
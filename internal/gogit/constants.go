@@ -2,6 +2,9 @@ package gogit
 
 import "path/filepath"
 
+// authorName is recorded as the author of every new commit.
+const authorName = "TonyGLL"
+
 var (
 	RepoPath     = filepath.Join(".", ".gogit")
 	ObjectsPath  = filepath.Join(RepoPath, "objects")
@@ -9,3 +12,21 @@ var (
 	HeadPath     = filepath.Join(RepoPath, "HEAD")
 	RefHeadsPath = filepath.Join(RepoPath, "refs/heads")
 )
+
+// setRepoPath points the paths above at the repository directory dir.
+func setRepoPath(dir string) {
+	RepoPath = dir
+	ObjectsPath = filepath.Join(dir, "objects")
+	IndexPath = filepath.Join(dir, "index")
+	HeadPath = filepath.Join(dir, "HEAD")
+	RefHeadsPath = filepath.Join(dir, "refs/heads")
+}
+
+// withRepo runs fn with the repository directory dir, such as a remote's,
+// in place of the current one.
+func withRepo(dir string, fn func() error) error {
+	saved := RepoPath
+	setRepoPath(dir)
+	defer setRepoPath(saved)
+	return fn()
+}
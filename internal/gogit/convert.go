@@ -0,0 +1,314 @@
+package gogit
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+)
+
+// A file's content in the working tree need not be what the object store
+// holds for it. The attributes of its path decide how it is converted on
+// the way in ("clean", when it is staged or hashed for status) and on the
+// way out ("smudge", when it is checked out):
+//
+//   - filter=lfs stores the file as a pointer to a large object kept
+//     outside the object store.
+//   - filter=<name> runs the content through the clean and smudge
+//     commands the config gives for it (see filter.go).
+//   - text marks the file as text: its lines are stored ending in LF and
+//     checked out ending as eol=lf|crlf, or the core.eol option, says.
+//     text=auto does the same unless the content looks binary, eol alone
+//     implies text, and -text (or binary) leaves the content as it is.
+
+// converter converts content according to a set of attribute rules.
+type converter struct {
+	rules attrRules
+	// eol is the line ending text files get in the working tree when
+	// their eol attribute does not say: "lf" or "crlf".
+	eol string
+
+	// mu guards the filter drivers and processes, loaded on first use.
+	mu        sync.Mutex
+	filters   map[string]*filterDriver
+	processes map[string]*filterProcess
+}
+
+// newConverter returns the converter for rules, with the line ending
+// core.eol sets: lf (the default), crlf, or native for the platform's.
+// Close it to stop the filter processes it starts.
+func newConverter(rules attrRules) (*converter, error) {
+	eol, _, err := GetConfig("core.eol")
+	if err != nil {
+		return nil, err
+	}
+	switch eol {
+	case "", "lf":
+		eol = "lf"
+	case "crlf":
+	case "native":
+		eol = "lf"
+		if runtime.GOOS == "windows" {
+			eol = "crlf"
+		}
+	default:
+		return nil, fmt.Errorf("core.eol must be lf, crlf or native, got '%s'", eol)
+	}
+	return &converter{
+		rules:     rules,
+		eol:       eol,
+		filters:   make(map[string]*filterDriver),
+		processes: make(map[string]*filterProcess),
+	}, nil
+}
+
+// worktreeConverter returns the converter for staging files: the rules of
+// the .gogitattributes file at the top of the working tree and of those
+// the index tracks below it, as they are in the working tree.
+func worktreeConverter() (*converter, error) {
+	indexEntries, err := ReadIndex()
+	if err != nil {
+		return nil, err
+	}
+	paths := []string{attributesFile}
+	for path := range indexEntries {
+		if path != attributesFile && filepath.Base(path) == attributesFile {
+			paths = append(paths, path)
+		}
+	}
+	sortByDepth(paths)
+
+	var rules attrRules
+	for _, path := range paths {
+		content, err := os.ReadFile(filepath.FromSlash(path))
+		if os.IsNotExist(err) {
+			// A deleted attributes file still applies as staged.
+			hash, tracked := indexEntries[path]
+			if !tracked {
+				continue
+			}
+			if content, err = ReadBlob(hash); err != nil {
+				return nil, err
+			}
+		} else if err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", path, err)
+		}
+		rules = append(rules, parseAttributes(attrDir(path), content)...)
+	}
+	return newConverter(rules)
+}
+
+// treeConverter returns the converter for checking out files of a tree,
+// following the attributes files in that tree.
+func treeConverter(files map[string]string) (*converter, error) {
+	rules, err := treeAttributes(files)
+	if err != nil {
+		return nil, err
+	}
+	return newConverter(rules)
+}
+
+// attrs returns the attributes of path.
+func (c *converter) attrs(path string) map[string]string {
+	return c.rules.lookup(filepath.ToSlash(filepath.Clean(path)))
+}
+
+// isText reports whether the line endings of content, a file with attrs,
+// are converted.
+func isText(attrs map[string]string, content []byte) bool {
+	switch attrs["text"] {
+	case attrSet:
+		return true
+	case "auto":
+		return !isBinary(content)
+	case "":
+		// eol implies text unless text is unset.
+		return attrs["eol"] == "lf" || attrs["eol"] == "crlf"
+	}
+	return false
+}
+
+// hasTextAttrs reports whether a file with attrs may need its line
+// endings converted, before its content is known.
+func hasTextAttrs(attrs map[string]string) bool {
+	return attrs["text"] == attrSet || attrs["text"] == "auto" || (attrs["text"] == "" && attrs["eol"] != "")
+}
+
+// clean opens the file at path for storing, converted to what the object
+// store holds for it, and returns the content's size. With store set,
+// content kept outside the object store, such as large files, is stored
+// as a side effect; otherwise it is only hashed. The clean filter runs
+// first, then line endings are normalized. Filtered and text files are
+// read into memory; other files are streamed.
+func (c *converter) clean(path string, store bool) (io.ReadCloser, int64, error) {
+	file, size, err := openWorkdirFile(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	attrs := c.attrs(path)
+	if attrs["filter"] == "lfs" {
+		defer file.Close()
+		pointer, err := cleanLFS(file, size, store)
+		if err != nil {
+			return nil, 0, fmt.Errorf("error storing large file %s: %w", path, err)
+		}
+		return io.NopCloser(bytes.NewReader(pointer)), int64(len(pointer)), nil
+	}
+	driver, err := c.filterFor(attrs)
+	if err != nil {
+		file.Close()
+		return nil, 0, err
+	}
+
+	var content []byte
+	filtered := false
+	if driver != nil {
+		if content, filtered, err = c.applyFilter(driver, "clean", path, file); err != nil {
+			file.Close()
+			return nil, 0, err
+		}
+	}
+	if !filtered {
+		// The filter may have read some of the file before failing.
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			file.Close()
+			return nil, 0, fmt.Errorf("error reading file %s: %w", path, err)
+		}
+		if !hasTextAttrs(attrs) {
+			return file, size, nil
+		}
+		if content, err = io.ReadAll(file); err != nil {
+			file.Close()
+			return nil, 0, fmt.Errorf("error reading file %s: %w", path, err)
+		}
+	}
+	file.Close()
+	if isText(attrs, content) {
+		content = bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+	}
+	return io.NopCloser(bytes.NewReader(content)), int64(len(content)), nil
+}
+
+// smudge opens the blob hash for writing to path in the working tree,
+// converted from what the object store holds, and returns the size of the
+// converted content. Line endings are converted first, then the smudge
+// filter runs.
+func (c *converter) smudge(path, hash string) (io.ReadCloser, int64, error) {
+	blob, size, err := openBlob(hash)
+	if err != nil {
+		return nil, 0, err
+	}
+	attrs := c.attrs(path)
+	eol := attrs["eol"]
+	if eol != "lf" && eol != "crlf" {
+		eol = c.eol
+	}
+	driver, err := c.filterFor(attrs)
+	if err != nil {
+		blob.Close()
+		return nil, 0, err
+	}
+	switch {
+	case attrs["filter"] == "lfs" && size <= lfsPointerMaxSize:
+	case attrs["filter"] == "lfs":
+		return blob, size, nil
+	case driver == nil && (eol == "lf" || !hasTextAttrs(attrs)):
+		return blob, size, nil
+	}
+
+	content, err := io.ReadAll(blob)
+	blob.Close()
+	if err != nil {
+		return nil, 0, fmt.Errorf("error reading object %s: %w", hash, err)
+	}
+	if attrs["filter"] == "lfs" {
+		return smudgeLFS(path, content)
+	}
+	if eol == "crlf" && isText(attrs, content) {
+		content = toCRLF(content)
+	}
+	if driver != nil {
+		out, filtered, err := c.applyFilter(driver, "smudge", path, bytes.NewReader(content))
+		if err != nil {
+			return nil, 0, err
+		}
+		if filtered {
+			content = out
+		}
+	}
+	return io.NopCloser(bytes.NewReader(content)), int64(len(content)), nil
+}
+
+// toCRLF ends every line of content in CRLF, leaving those that already
+// do alone.
+func toCRLF(content []byte) []byte {
+	var out bytes.Buffer
+	out.Grow(len(content) + bytes.Count(content, []byte("\n")))
+	for i, b := range content {
+		if b == '\n' && (i == 0 || content[i-1] != '\r') {
+			out.WriteByte('\r')
+		}
+		out.WriteByte(b)
+	}
+	return out.Bytes()
+}
+
+// storeFile streams a file's content, cleaned, into the object store as a
+// blob and returns its hash. It is safe to call from several goroutines.
+func (c *converter) storeFile(filePath string) (string, error) {
+	content, size, err := c.clean(filePath, true)
+	if err != nil {
+		return "", err
+	}
+	defer content.Close()
+
+	blobHash, err := writeBlob(content, size)
+	if err != nil {
+		return "", fmt.Errorf("error writing blob object for %s: %w", filePath, err)
+	}
+	return blobHash, nil
+}
+
+// hashFile returns the blob hash of a file's cleaned content without
+// storing anything.
+func (c *converter) hashFile(path string) (string, error) {
+	content, size, err := c.clean(path, false)
+	if err != nil {
+		return "", err
+	}
+	defer content.Close()
+
+	hash, err := HashObject(content, size)
+	if err != nil {
+		return "", fmt.Errorf("could not hash the file %s: %w", path, err)
+	}
+	return hash, nil
+}
+
+// writeFile writes the content of a blob, smudged, to path in the working
+// tree, creating parent directories as needed.
+func (c *converter) writeFile(path, hash string) error {
+	content, _, err := c.smudge(path, hash)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("error creating directory for %s: %w", path, err)
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("error writing %s: %w", path, err)
+	}
+	if _, err := io.Copy(file, content); err != nil {
+		file.Close()
+		return fmt.Errorf("error writing %s: %w", path, err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("error writing %s: %w", path, err)
+	}
+	return nil
+}
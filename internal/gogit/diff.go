@@ -0,0 +1,157 @@
+package gogit
+
+import (
+	"bytes"
+	"strings"
+)
+
+// diffHunk is a run of lines that differ between two texts: lines
+// [OldStart, OldEnd) of the old text were replaced by lines
+// [NewStart, NewEnd) of the new one. Either range may be empty.
+type diffHunk struct {
+	OldStart, OldEnd int
+	NewStart, NewEnd int
+}
+
+// splitLines splits content into lines, each keeping its trailing newline.
+// A final line without a newline is kept as is.
+func splitLines(content []byte) []string {
+	if len(content) == 0 {
+		return nil
+	}
+	lines := strings.SplitAfter(string(content), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// isBinary reports whether content looks binary, using the same rule as
+// Git: a NUL byte in the first 8000 bytes.
+func isBinary(content []byte) bool {
+	if len(content) > 8000 {
+		content = content[:8000]
+	}
+	return bytes.IndexByte(content, 0) >= 0
+}
+
+// diffLines returns the hunks that turn a into b, in order.
+func diffLines(a, b []string) []diffHunk {
+	var hunks []diffHunk
+	oldPos, newPos := 0, 0
+	for _, match := range matchLines(a, b) {
+		if match[0] > oldPos || match[1] > newPos {
+			hunks = append(hunks, diffHunk{oldPos, match[0], newPos, match[1]})
+		}
+		oldPos, newPos = match[0]+1, match[1]+1
+	}
+	if oldPos < len(a) || newPos < len(b) {
+		hunks = append(hunks, diffHunk{oldPos, len(a), newPos, len(b)})
+	}
+	return hunks
+}
+
+// matchLines returns the index pairs of a longest common subsequence of a
+// and b, in increasing order.
+func matchLines(a, b []string) [][2]int {
+	// Common prefixes and suffixes match trivially; only the middle needs
+	// the full search.
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix &&
+		a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var matches [][2]int
+	for i := 0; i < prefix; i++ {
+		matches = append(matches, [2]int{i, i})
+	}
+	for _, match := range myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]) {
+		matches = append(matches, [2]int{match[0] + prefix, match[1] + prefix})
+	}
+	for i := suffix; i > 0; i-- {
+		matches = append(matches, [2]int{len(a) - i, len(b) - i})
+	}
+	return matches
+}
+
+// myers finds a shortest edit script between a and b with Myers' O(ND)
+// algorithm and returns the matching lines it keeps.
+func myers(a, b []string) [][2]int {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return nil
+	}
+
+	// trace[d] holds the furthest x reached on each diagonal k in
+	// [-d, d] after d edits, stored at index k+d.
+	var trace [][]int
+	previous := []int{0}
+search:
+	for d := 0; d <= n+m; d++ {
+		v := make([]int, 2*d+1)
+		for k := -d; k <= d; k += 2 {
+			var x int
+			switch {
+			case d == 0:
+				x = 0
+			case k == -d || (k != d && previous[k-1+d-1] < previous[k+1+d-1]):
+				x = previous[k+1+d-1]
+			default:
+				x = previous[k-1+d-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[k+d] = x
+			if x >= n && y >= m {
+				trace = append(trace, v)
+				break search
+			}
+		}
+		trace = append(trace, v)
+		previous = v
+	}
+
+	// Walk the trace backwards, collecting the diagonal (matching) moves.
+	var reversed [][2]int
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		// (startX, startY) is where the snake ending at (x, y) began.
+		startX, startY, prevX, prevY := 0, 0, 0, 0
+		if d > 0 {
+			prev := trace[d-1]
+			at := func(k int) int { return prev[k+d-1] }
+			k := x - y
+			prevK := k - 1
+			if k == -d || (k != d && at(k-1) < at(k+1)) {
+				prevK = k + 1
+			}
+			prevX = at(prevK)
+			prevY = prevX - prevK
+			if prevK == k+1 {
+				startX, startY = prevX, prevY+1
+			} else {
+				startX, startY = prevX+1, prevY
+			}
+		}
+		for x > startX && y > startY {
+			x--
+			y--
+			reversed = append(reversed, [2]int{x, y})
+		}
+		x, y = prevX, prevY
+	}
+
+	matches := make([][2]int, len(reversed))
+	for i, match := range reversed {
+		matches[len(reversed)-1-i] = match
+	}
+	return matches
+}
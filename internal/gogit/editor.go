@@ -0,0 +1,89 @@
+package gogit
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// editorCommand returns the editor to launch: $GOGIT_EDITOR, then the
+// core.editor option, then $VISUAL and $EDITOR, falling back to vi.
+func editorCommand() (string, error) {
+	if editor := os.Getenv("GOGIT_EDITOR"); editor != "" {
+		return editor, nil
+	}
+	value, ok, err := GetConfig("core.editor")
+	if err != nil {
+		return "", err
+	}
+	if ok && value != "" {
+		return value, nil
+	}
+	for _, name := range []string{"VISUAL", "EDITOR"} {
+		if editor := os.Getenv(name); editor != "" {
+			return editor, nil
+		}
+	}
+	return "vi", nil
+}
+
+// LaunchEditor opens path in the user's editor and waits for it to exit.
+func LaunchEditor(path string) error {
+	editor, err := editorCommand()
+	if err != nil {
+		return err
+	}
+	// ":" is the conventional "do not edit" editor.
+	if editor == ":" {
+		return nil
+	}
+
+	// Run through the shell so editors configured with arguments work.
+	cmd := exec.Command("sh", "-c", editor+` "$@"`, editor, path)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("there was a problem with the editor '%s': %w", editor, err)
+	}
+	return nil
+}
+
+// EditFile writes initial to path, opens it in the editor and returns
+// the edited content.
+func EditFile(path, initial string) (string, error) {
+	if err := os.WriteFile(path, []byte(initial), 0644); err != nil {
+		return "", fmt.Errorf("error writing %s: %w", path, err)
+	}
+	if err := LaunchEditor(path); err != nil {
+		return "", err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("error reading %s: %w", path, err)
+	}
+	return string(content), nil
+}
+
+// CleanupMessage normalizes a commit message the way Git does: trailing
+// whitespace is removed from every line, runs of blank lines collapse to
+// one and leading and trailing blank lines are dropped. With
+// stripComments, lines starting with '#' are removed first.
+func CleanupMessage(message string, stripComments bool) string {
+	var lines []string
+	previousBlank := true
+	for _, line := range strings.Split(message, "\n") {
+		if stripComments && strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimRight(line, " \t\r")
+		blank := line == ""
+		if blank && previousBlank {
+			continue
+		}
+		lines = append(lines, line)
+		previousBlank = blank
+	}
+	return strings.TrimRight(strings.Join(lines, "\n"), "\n")
+}
@@ -0,0 +1,191 @@
+package gogit
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// FastExport writes the history of refs (branches or tags; every branch
+// and tag with all) to w as a Git fast-import stream: each new blob once,
+// then each commit, parents first, with its changes against its first
+// parent.
+func FastExport(w io.Writer, names []string, all bool) error {
+	var refs []Ref
+	if all {
+		var err error
+		if refs, err = listAdvertisedRefs(); err != nil {
+			return err
+		}
+	}
+	for _, name := range names {
+		ref, err := fastExportRef(name)
+		if err != nil {
+			return err
+		}
+		refs = append(refs, ref)
+	}
+	if len(refs) == 0 {
+		return fmt.Errorf("nothing to export; name a branch or tag, or use --all")
+	}
+
+	out := bufio.NewWriter(w)
+	exporter := &fastExporter{out: out, marks: make(map[string]int), emittedAs: make(map[string]string)}
+	for _, ref := range refs {
+		if err := exporter.history(ref.Name, ref.Hash); err != nil {
+			return err
+		}
+		// A ref whose tip went out under another name is pointed at it.
+		if exporter.emittedAs[ref.Hash] != ref.Name {
+			fmt.Fprintf(out, "reset %s\nfrom :%d\n\n", ref.Name, exporter.marks[ref.Hash])
+		}
+	}
+	return out.Flush()
+}
+
+// fastExportRef resolves a branch or tag name, or HEAD, to its full ref.
+func fastExportRef(name string) (Ref, error) {
+	full := name
+	if name == "HEAD" {
+		headRef, _, err := ReadHead()
+		if err != nil {
+			return Ref{}, err
+		}
+		if headRef == "" {
+			return Ref{}, fmt.Errorf("HEAD is detached; name a branch or tag to export")
+		}
+		full = headRef
+	} else {
+		var err error
+		if full, err = localRefName(name); err != nil {
+			return Ref{}, err
+		}
+	}
+	if full == "" {
+		return Ref{}, fmt.Errorf("'%s' is not a branch or tag", name)
+	}
+	hash, err := ReadRef(full)
+	if err != nil {
+		return Ref{}, err
+	}
+	if hash == "" {
+		return Ref{}, fmt.Errorf("'%s' is not a branch or tag", name)
+	}
+	return Ref{Name: full, Hash: hash}, nil
+}
+
+// fastExporter writes commits and blobs once each, numbering them with
+// marks.
+type fastExporter struct {
+	out   *bufio.Writer
+	marks map[string]int
+	// emittedAs records the ref each commit was written on.
+	emittedAs map[string]string
+}
+
+func (exp *fastExporter) mark(hash string) int {
+	mark := len(exp.marks) + 1
+	exp.marks[hash] = mark
+	return mark
+}
+
+// history writes the commits leading to tip that are not out yet, on ref.
+func (exp *fastExporter) history(ref, tip string) error {
+	stack := []string{tip}
+	for len(stack) > 0 {
+		current := stack[len(stack)-1]
+		if _, done := exp.marks[current]; done {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		commit, err := ReadCommit(current)
+		if err != nil {
+			return err
+		}
+		waiting := false
+		for _, parent := range commit.Parents {
+			if _, done := exp.marks[parent]; !done {
+				stack = append(stack, parent)
+				waiting = true
+			}
+		}
+		if waiting {
+			continue
+		}
+		stack = stack[:len(stack)-1]
+		if err := exp.commit(ref, commit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// commit writes the blobs a commit adds or changes, then the commit.
+func (exp *fastExporter) commit(ref string, commit *Commit) error {
+	parentFiles, err := commitTree(commit.Parent)
+	if err != nil {
+		return err
+	}
+	files, err := ReadTree(commit.Tree)
+	if err != nil {
+		return err
+	}
+
+	var changes []string
+	for _, path := range sortedKeys(files) {
+		hash := files[path]
+		if parentFiles[path] == hash {
+			continue
+		}
+		if _, done := exp.marks[hash]; !done {
+			content, err := ReadBlob(hash)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(exp.out, "blob\nmark :%d\ndata %d\n", exp.mark(hash), len(content))
+			exp.out.Write(content)
+			exp.out.WriteString("\n")
+		}
+		changes = append(changes, fmt.Sprintf("M %s :%d %s", gitModeFile, exp.marks[hash], fastImportPath(path)))
+	}
+	for _, path := range sortedKeys(parentFiles) {
+		if _, ok := files[path]; !ok {
+			changes = append(changes, "D "+fastImportPath(path))
+		}
+	}
+
+	// Without a from line a commit would continue whatever the branch
+	// held before, so root commits reset it first.
+	if len(commit.Parents) == 0 {
+		fmt.Fprintf(exp.out, "reset %s\n", ref)
+	}
+	message := commit.Message + "\n"
+	ident := gitIdent(commit.Author, commit.Date)
+	fmt.Fprintf(exp.out, "commit %s\nmark :%d\n", ref, exp.mark(commit.Hash))
+	fmt.Fprintf(exp.out, "author %s\ncommitter %s\n", ident, ident)
+	fmt.Fprintf(exp.out, "data %d\n%s", len(message), message)
+	for i, parent := range commit.Parents {
+		keyword := "merge"
+		if i == 0 {
+			keyword = "from"
+		}
+		fmt.Fprintf(exp.out, "%s :%d\n", keyword, exp.marks[parent])
+	}
+	for _, change := range changes {
+		exp.out.WriteString(change + "\n")
+	}
+	exp.out.WriteString("\n")
+	exp.emittedAs[commit.Hash] = ref
+	return nil
+}
+
+// fastImportPath quotes a path that would otherwise be misread: one that
+// starts with a quote or holds a newline.
+func fastImportPath(path string) string {
+	if strings.HasPrefix(path, `"`) || strings.Contains(path, "\n") {
+		return strconv.Quote(path)
+	}
+	return path
+}
@@ -0,0 +1,605 @@
+package gogit
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FastImport reads a Git fast-import stream from r and records its blobs
+// and commits. Branches and tags are updated once the stream ends; a
+// branch that would lose commits is left alone unless force is set. When
+// the current branch had no commits before, its new files are checked
+// out.
+func FastImport(r io.Reader, force bool) error {
+	if !isRepoDir(RepoPath) {
+		return fmt.Errorf("not a gogit repository; run 'gogit init' first")
+	}
+	_, headBefore, err := ReadHead()
+	if err != nil {
+		return err
+	}
+
+	importer := &fastImporter{
+		r:     bufio.NewReader(r),
+		marks: make(map[string]string),
+		refs:  make(map[string]string),
+	}
+	if err := importer.run(); err != nil {
+		return err
+	}
+	failed, err := importer.updateRefs(force)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "gogit fast-import: %d blobs, %d commits, %d refs\n",
+		importer.blobs, importer.commits, len(importer.order))
+
+	if headBefore == "" {
+		if _, head, err := ReadHead(); err != nil {
+			return err
+		} else if head != "" {
+			if err := checkoutImportedHead(); err != nil {
+				return err
+			}
+		}
+	}
+	if failed {
+		return fmt.Errorf("some refs were not updated; use --force to overwrite them")
+	}
+	return nil
+}
+
+// fastImporter holds the state of a fast-import stream being read.
+type fastImporter struct {
+	r *bufio.Reader
+	// pending is a line read ahead and not yet handled.
+	pending    *string
+	lineNumber int
+
+	// marks maps ":<n>" to the object it names.
+	marks map[string]string
+	// refs holds the branch and tag tips set by the stream, in the order
+	// in which they were first touched.
+	refs  map[string]string
+	order []string
+
+	blobs, commits int
+	requireDone    bool
+}
+
+// readLine returns the next line that is not a comment, without its
+// newline, and io.EOF at the end of the stream.
+func (imp *fastImporter) readLine() (string, error) {
+	if imp.pending != nil {
+		line := *imp.pending
+		imp.pending = nil
+		return line, nil
+	}
+	for {
+		line, err := imp.r.ReadString('\n')
+		if err == io.EOF && line != "" {
+			err = nil
+		}
+		if err != nil {
+			return "", err
+		}
+		imp.lineNumber++
+		line = strings.TrimSuffix(line, "\n")
+		if !strings.HasPrefix(line, "#") {
+			return line, nil
+		}
+	}
+}
+
+// unreadLine hands line back to the next readLine.
+func (imp *fastImporter) unreadLine(line string) {
+	imp.pending = &line
+}
+
+// optional returns the argument of the next line if it starts with
+// keyword, and otherwise leaves the line for later.
+func (imp *fastImporter) optional(keyword string) (string, bool, error) {
+	line, err := imp.readLine()
+	if err == io.EOF {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	if value, ok := strings.CutPrefix(line, keyword+" "); ok {
+		return value, true, nil
+	}
+	imp.unreadLine(line)
+	return "", false, nil
+}
+
+func (imp *fastImporter) errorf(format string, args ...any) error {
+	return fmt.Errorf("fast-import: line %d: %s", imp.lineNumber, fmt.Sprintf(format, args...))
+}
+
+// run handles the commands of the stream until it ends.
+func (imp *fastImporter) run() error {
+	for {
+		line, err := imp.readLine()
+		if err == io.EOF {
+			if imp.requireDone {
+				return fmt.Errorf("fast-import: stream ends without 'done'")
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		command, argument, _ := strings.Cut(line, " ")
+		switch command {
+		case "":
+		case "blob":
+			err = imp.blob()
+		case "commit":
+			err = imp.commit(qualifyRemoteRef(argument, "refs/heads/"))
+		case "reset":
+			err = imp.reset(qualifyRemoteRef(argument, "refs/heads/"))
+		case "tag":
+			err = imp.tag(argument)
+		case "checkpoint":
+		case "progress":
+			fmt.Println(line)
+		case "done":
+			return nil
+		case "feature":
+			err = imp.feature(argument)
+		case "option":
+			// Options tune other importers; gogit has none to honor.
+		default:
+			err = imp.errorf("unsupported command '%s'", command)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (imp *fastImporter) feature(name string) error {
+	switch name {
+	case "done":
+		imp.requireDone = true
+	case "date-format=raw", "date-format=raw-permissive", "force":
+	default:
+		return imp.errorf("unsupported feature '%s'", name)
+	}
+	return nil
+}
+
+// data reads a data command: "data <count>" followed by exactly that
+// many bytes, or "data <<<delimiter>" followed by lines up to the
+// delimiter.
+func (imp *fastImporter) data() ([]byte, error) {
+	line, err := imp.readLine()
+	if err != nil {
+		return nil, imp.errorf("expected data: %v", err)
+	}
+	argument, ok := strings.CutPrefix(line, "data ")
+	if !ok {
+		return nil, imp.errorf("expected data, got '%s'", line)
+	}
+
+	if delimiter, ok := strings.CutPrefix(argument, "<<"); ok {
+		var content strings.Builder
+		for {
+			line, err := imp.r.ReadString('\n')
+			if err != nil {
+				return nil, imp.errorf("data ends without '%s'", delimiter)
+			}
+			imp.lineNumber++
+			if strings.TrimSuffix(line, "\n") == delimiter {
+				return []byte(content.String()), nil
+			}
+			content.WriteString(line)
+		}
+	}
+
+	size, err := strconv.Atoi(argument)
+	if err != nil || size < 0 {
+		return nil, imp.errorf("invalid data length '%s'", argument)
+	}
+	content := make([]byte, size)
+	if _, err := io.ReadFull(imp.r, content); err != nil {
+		return nil, imp.errorf("data ends early: %v", err)
+	}
+	imp.lineNumber += strings.Count(string(content), "\n")
+	// The data may be followed by an optional newline.
+	if next, err := imp.r.Peek(1); err == nil && next[0] == '\n' {
+		imp.r.ReadByte()
+		imp.lineNumber++
+	}
+	return content, nil
+}
+
+// markAndOID reads the optional mark and original-oid lines of a blob,
+// commit or tag.
+func (imp *fastImporter) markAndOID() (string, error) {
+	mark, _, err := imp.optional("mark")
+	if err != nil {
+		return "", err
+	}
+	if _, _, err := imp.optional("original-oid"); err != nil {
+		return "", err
+	}
+	return mark, nil
+}
+
+func (imp *fastImporter) blob() error {
+	mark, err := imp.markAndOID()
+	if err != nil {
+		return err
+	}
+	content, err := imp.data()
+	if err != nil {
+		return err
+	}
+	hash, err := storeObject(ObjectTypeBlob, content)
+	if err != nil {
+		return err
+	}
+	if mark != "" {
+		imp.marks[mark] = hash
+	}
+	imp.blobs++
+	return nil
+}
+
+// setRef records a new tip for ref.
+func (imp *fastImporter) setRef(ref, hash string) {
+	if _, seen := imp.refs[ref]; !seen {
+		imp.order = append(imp.order, ref)
+	}
+	imp.refs[ref] = hash
+}
+
+// tip returns the commit ref currently points to: the last one the
+// stream set, or the ref's value before the import.
+func (imp *fastImporter) tip(ref string) (string, error) {
+	if hash, ok := imp.refs[ref]; ok {
+		return hash, nil
+	}
+	return ReadRef(ref)
+}
+
+// commitish resolves the argument of from and merge: a mark, a ref set
+// by the stream, or any revision of the repository.
+func (imp *fastImporter) commitish(value string) (string, error) {
+	if strings.HasPrefix(value, ":") {
+		hash, ok := imp.marks[value]
+		if !ok {
+			return "", imp.errorf("mark %s not declared", value)
+		}
+		return hash, nil
+	}
+	if value == zeroHash {
+		return "", nil
+	}
+	for _, ref := range []string{value, "refs/heads/" + value} {
+		if hash, ok := imp.refs[ref]; ok {
+			return hash, nil
+		}
+	}
+	hash, err := ResolveRevision(value)
+	if err != nil {
+		return "", imp.errorf("%v", err)
+	}
+	return hash, nil
+}
+
+func (imp *fastImporter) commit(ref string) error {
+	mark, err := imp.markAndOID()
+	if err != nil {
+		return err
+	}
+	authorLine, hasAuthor, err := imp.optional("author")
+	if err != nil {
+		return err
+	}
+	committerLine, hasCommitter, err := imp.optional("committer")
+	if err != nil {
+		return err
+	}
+	if !hasCommitter {
+		return imp.errorf("commit to %s has no committer", ref)
+	}
+	if !hasAuthor {
+		authorLine = committerLine
+	}
+	author, date, err := parseGitIdent(authorLine)
+	if err != nil {
+		return imp.errorf("%v", err)
+	}
+	if date.IsZero() {
+		date = time.Now()
+	}
+	if _, _, err := imp.optional("encoding"); err != nil {
+		return err
+	}
+	message, err := imp.data()
+	if err != nil {
+		return err
+	}
+
+	// The commit continues the branch unless from says otherwise.
+	var parents []string
+	from, hasFrom, err := imp.optional("from")
+	if err != nil {
+		return err
+	}
+	parent := ""
+	if hasFrom {
+		parent, err = imp.commitish(from)
+	} else {
+		parent, err = imp.tip(ref)
+	}
+	if err != nil {
+		return err
+	}
+	if parent != "" {
+		parents = append(parents, parent)
+	}
+	for {
+		merge, ok, err := imp.optional("merge")
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+		hash, err := imp.commitish(merge)
+		if err != nil {
+			return err
+		}
+		parents = append(parents, hash)
+	}
+
+	files, err := commitTree(parent)
+	if err != nil {
+		return err
+	}
+	if err := imp.fileChanges(files); err != nil {
+		return err
+	}
+
+	hash, err := storeCommit(files, parents, author, date, strings.TrimSpace(string(message)))
+	if err != nil {
+		return err
+	}
+	if mark != "" {
+		imp.marks[mark] = hash
+	}
+	imp.setRef(ref, hash)
+	imp.commits++
+	return nil
+}
+
+// fileChanges applies the M, D, R, C and deleteall lines of a commit to
+// files.
+func (imp *fastImporter) fileChanges(files map[string]string) error {
+	for {
+		line, err := imp.readLine()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		command, argument, _ := strings.Cut(line, " ")
+		switch command {
+		case "M":
+			err = imp.modify(files, argument)
+		case "D":
+			var path string
+			if path, _, err = imp.path(argument, false); err == nil {
+				removeTreePath(files, path)
+			}
+		case "R", "C":
+			var source, rest, destination string
+			if source, rest, err = imp.path(argument, true); err != nil {
+				break
+			}
+			if destination, _, err = imp.path(rest, false); err != nil {
+				break
+			}
+			moved := make(map[string]string)
+			for path, hash := range files {
+				if path == source || strings.HasPrefix(path, source+"/") {
+					moved[destination+strings.TrimPrefix(path, source)] = hash
+				}
+			}
+			if len(moved) == 0 {
+				return imp.errorf("path %s not in branch", source)
+			}
+			if command == "R" {
+				removeTreePath(files, source)
+			}
+			for path, hash := range moved {
+				files[path] = hash
+			}
+		case "deleteall":
+			clear(files)
+		case "":
+			return nil
+		default:
+			imp.unreadLine(line)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// removeTreePath removes a file, or every file of a directory, from files.
+func removeTreePath(files map[string]string, path string) {
+	for file := range files {
+		if file == path || strings.HasPrefix(file, path+"/") {
+			delete(files, file)
+		}
+	}
+}
+
+// modify applies "M <mode> <dataref> <path>", where the data is a mark,
+// an object ID, or "inline" with the data following.
+func (imp *fastImporter) modify(files map[string]string, argument string) error {
+	fields := strings.SplitN(argument, " ", 3)
+	if len(fields) != 3 {
+		return imp.errorf("invalid file change 'M %s'", argument)
+	}
+	mode, dataref := fields[0], fields[1]
+	path, _, err := imp.path(fields[2], false)
+	if err != nil {
+		return err
+	}
+	switch mode {
+	case "100644", "644", "100755", "755", "120000":
+	case gitModeSubmodule:
+		fmt.Fprintf(os.Stderr, "warning: skipping submodule '%s'\n", path)
+		return nil
+	default:
+		return imp.errorf("unsupported file mode %s for %s", mode, path)
+	}
+
+	var hash string
+	switch {
+	case dataref == "inline":
+		content, err := imp.data()
+		if err != nil {
+			return err
+		}
+		if hash, err = storeObject(ObjectTypeBlob, content); err != nil {
+			return err
+		}
+		imp.blobs++
+	case strings.HasPrefix(dataref, ":"):
+		var ok bool
+		if hash, ok = imp.marks[dataref]; !ok {
+			return imp.errorf("mark %s not declared", dataref)
+		}
+	default:
+		hash = dataref
+	}
+	if objectType, _, err := ReadObjectContent(hash); err != nil || objectType != ObjectTypeBlob {
+		return imp.errorf("%s is not a blob", dataref)
+	}
+	files[path] = hash
+	return nil
+}
+
+// path reads a path at the start of s: C-style quoted, or else the rest
+// of the line (up to the first space when more follows). It returns the
+// path and what comes after it.
+func (imp *fastImporter) path(s string, more bool) (string, string, error) {
+	if strings.HasPrefix(s, `"`) {
+		for end := 1; end < len(s); end++ {
+			if s[end] == '\\' {
+				end++
+				continue
+			}
+			if s[end] == '"' {
+				path, err := strconv.Unquote(s[:end+1])
+				if err != nil {
+					return "", "", imp.errorf("invalid quoted path %s", s[:end+1])
+				}
+				return path, strings.TrimPrefix(s[end+1:], " "), nil
+			}
+		}
+		return "", "", imp.errorf("unterminated quoted path %s", s)
+	}
+	if more {
+		path, rest, ok := strings.Cut(s, " ")
+		if !ok {
+			return "", "", imp.errorf("missing destination path after %s", s)
+		}
+		return path, rest, nil
+	}
+	return s, "", nil
+}
+
+func (imp *fastImporter) reset(ref string) error {
+	from, ok, err := imp.optional("from")
+	if err != nil {
+		return err
+	}
+	hash := ""
+	if ok {
+		if hash, err = imp.commitish(from); err != nil {
+			return err
+		}
+	}
+	imp.setRef(ref, hash)
+	return nil
+}
+
+// tag records a tag. gogit tags are lightweight, so the tagger and
+// message are read and dropped.
+func (imp *fastImporter) tag(name string) error {
+	if _, err := imp.markAndOID(); err != nil {
+		return err
+	}
+	from, ok, err := imp.optional("from")
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return imp.errorf("tag %s has no from", name)
+	}
+	hash, err := imp.commitish(from)
+	if err != nil {
+		return err
+	}
+	if _, _, err := imp.optional("original-oid"); err != nil {
+		return err
+	}
+	if _, _, err := imp.optional("tagger"); err != nil {
+		return err
+	}
+	if _, err := imp.data(); err != nil {
+		return err
+	}
+	imp.setRef("refs/tags/"+name, hash)
+	return nil
+}
+
+// updateRefs moves the refs the stream set. It reports whether any was
+// left alone because the update would lose commits.
+func (imp *fastImporter) updateRefs(force bool) (bool, error) {
+	failed := false
+	for _, ref := range imp.order {
+		hash := imp.refs[ref]
+		if hash == "" {
+			continue
+		}
+		old, err := ReadRef(ref)
+		if err != nil {
+			return false, err
+		}
+		if old == hash {
+			continue
+		}
+		if old != "" && !force {
+			fastForward, err := IsAncestor(old, hash)
+			if err != nil {
+				return false, err
+			}
+			if !fastForward {
+				fmt.Fprintf(os.Stderr, "warning: not updating %s (new tip %s does not contain %s)\n", ref, hash, old)
+				failed = true
+				continue
+			}
+		}
+		if err := UpdateRef(ref, hash, "fast-import"); err != nil {
+			return false, err
+		}
+	}
+	return failed, nil
+}
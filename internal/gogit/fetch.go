@@ -0,0 +1,386 @@
+package gogit
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// refUpdate is a planned change of one ref during fetch or push: Ref
+// moves from Old to New. An empty New deletes it.
+type refUpdate struct {
+	Source string
+	Ref    string
+	Old    string
+	New    string
+	Force  bool
+}
+
+// Fetch copies the branches and tags of a remote (the current branch's
+// upstream remote, or origin, when name is empty) that are missing here,
+// and updates its tracking refs. With prune, tracking refs of branches
+// the remote no longer has are deleted. A bundle file can be named
+// instead of a remote: its branches are then recorded in FETCH_HEAD.
+func Fetch(name string, prune bool) error {
+	if name == "" {
+		var err error
+		if name, err = defaultRemote(); err != nil {
+			return err
+		}
+	}
+	remote, err := GetRemote(name)
+	if err != nil && isBundleFile(name) {
+		remote, err = &Remote{URL: name}, nil
+	}
+	if err != nil {
+		return err
+	}
+	_, err = fetchRemote(remote, prune, false)
+	return err
+}
+
+// fetchHeadPath returns the file recording the branches fetched from a
+// bundle that is not a remote.
+func fetchHeadPath() string {
+	return filepath.Join(RepoPath, "FETCH_HEAD")
+}
+
+// fetchRemote fetches from remote and returns what the remote advertised.
+// A remote without a name has no tracking refs: its branches go to
+// FETCH_HEAD.
+func fetchRemote(remote *Remote, prune, quiet bool) (*advertisement, error) {
+	t, err := openTransport(remote.URL)
+	if err != nil {
+		return nil, err
+	}
+	var specs []string
+	if remote.Name != "" {
+		if specs, err = GetConfigAll("remote." + remote.Name + ".fetch"); err != nil {
+			return nil, err
+		}
+		if len(specs) == 0 {
+			specs = []string{defaultFetchRefspec(remote.Name)}
+		}
+	}
+	ad, err := t.advertise(false)
+	if err != nil {
+		return nil, err
+	}
+
+	// Map the remote branches through the refspecs; tags keep their
+	// names and are only created, never moved.
+	var updates []refUpdate
+	var heads []Ref
+	fetched := make(map[string]bool)
+	for _, ref := range ad.Refs {
+		if strings.HasPrefix(ref.Name, "refs/tags/") {
+			existing, err := ReadRef(ref.Name)
+			if err != nil {
+				return nil, err
+			}
+			if existing == "" {
+				updates = append(updates, refUpdate{Source: ref.Name, Ref: ref.Name, New: ref.Hash})
+			}
+			continue
+		}
+		if remote.Name == "" {
+			heads = append(heads, ref)
+			continue
+		}
+		for _, value := range specs {
+			spec := parseRefspec(value)
+			if local, ok := spec.match(ref.Name); ok && local != "" {
+				updates = append(updates, refUpdate{Source: ref.Name, Ref: local, New: ref.Hash, Force: spec.Force})
+				fetched[local] = true
+			}
+		}
+	}
+
+	var tips []string
+	for _, update := range updates {
+		tips = append(tips, update.New)
+	}
+	for _, head := range heads {
+		tips = append(tips, head.Hash)
+	}
+	var wants []string
+	for _, tip := range tips {
+		if !ObjectExists(tip) {
+			wants = append(wants, tip)
+		}
+	}
+	if len(wants) > 0 {
+		if err := t.fetch(wants); err != nil {
+			return nil, err
+		}
+	}
+	// Fetch the large objects the fetched trees point to.
+	pointers, err := lfsPointersAt(tips)
+	if err != nil {
+		return nil, err
+	}
+	if missing := missingLFSObjects(RepoPath, pointers); len(missing) > 0 {
+		if err := t.fetchLFS(missing); err != nil {
+			return nil, err
+		}
+	}
+
+	header := false
+	report := func(flag, summary, from, to, reason string) {
+		if quiet {
+			return
+		}
+		if !header {
+			fmt.Printf("From %s\n", remote.URL)
+			header = true
+		}
+		printRefUpdate(flag, summary, from, to, reason)
+	}
+
+	rejected := false
+	for _, update := range updates {
+		old, err := ReadRef(update.Ref)
+		if err != nil {
+			return nil, err
+		}
+		from, to := shortRefName(update.Source), shortRefName(update.Ref)
+		message := "fetch: fast-forward"
+		switch {
+		case old == update.New:
+			continue
+		case old == "" && strings.HasPrefix(update.Ref, "refs/tags/"):
+			report("*", "[new tag]", from, to, "")
+			message = "fetch: storing head"
+		case old == "":
+			report("*", "[new branch]", from, to, "")
+			message = "fetch: storing head"
+		default:
+			fastForward, err := IsAncestor(old, update.New)
+			if err != nil {
+				return nil, err
+			}
+			switch {
+			case fastForward:
+				report(" ", abbrevHash(old)+".."+abbrevHash(update.New), from, to, "")
+			case update.Force:
+				report("+", abbrevHash(old)+"..."+abbrevHash(update.New), from, to, "forced update")
+				message = "fetch: forced-update"
+			default:
+				report("!", "[rejected]", from, to, "non-fast-forward")
+				rejected = true
+				continue
+			}
+		}
+		if err := UpdateRef(update.Ref, update.New, message); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(heads) > 0 {
+		if err := writeFetchHead(remote.URL, heads, ad.HeadRef); err != nil {
+			return nil, err
+		}
+		for _, head := range heads {
+			report("*", "branch", shortRefName(head.Name), "FETCH_HEAD", "")
+		}
+	}
+
+	if prune && remote.Name != "" {
+		tracking, err := ListRefs(remoteRefs(remote.Name))
+		if err != nil {
+			return nil, err
+		}
+		for _, ref := range tracking {
+			if fetched[ref.Name] {
+				continue
+			}
+			if err := DeleteRef(ref.Name); err != nil {
+				return nil, err
+			}
+			report("-", "[deleted]", "(none)", shortRefName(ref.Name), "")
+		}
+	}
+
+	if rejected {
+		return nil, fmt.Errorf("some local refs could not be updated")
+	}
+	return ad, nil
+}
+
+// writeFetchHead records the branches fetched from url in FETCH_HEAD, as
+// Git does: the branch HEAD points to comes first and the others are
+// marked not-for-merge.
+func writeFetchHead(url string, heads []Ref, headRef string) error {
+	var merge, others strings.Builder
+	for _, head := range heads {
+		name := strings.TrimPrefix(head.Name, "refs/heads/")
+		if head.Name == headRef {
+			fmt.Fprintf(&merge, "%s\t\tbranch '%s' of %s\n", head.Hash, name, url)
+		} else {
+			fmt.Fprintf(&others, "%s\tnot-for-merge\tbranch '%s' of %s\n", head.Hash, name, url)
+		}
+	}
+	return writeFileLocked(fetchHeadPath(), []byte(merge.String()+others.String()))
+}
+
+// readFetchHead returns the first commit recorded in FETCH_HEAD, or ""
+// if nothing was fetched.
+func readFetchHead() (string, error) {
+	data, err := os.ReadFile(fetchHeadPath())
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	hash, _, _ := strings.Cut(string(data), "\t")
+	return hash, nil
+}
+
+// Clone copies the repository or bundle file at url into a new directory
+// dir (named after the repository when empty), with url as its origin
+// remote, and checks out the branch the source's HEAD points to. A clone
+// that fails removes what it created.
+func Clone(url, dir string) (err error) {
+	remoteURL := isRemoteURL(url)
+	if !remoteURL && !isBundleFile(strings.TrimPrefix(url, "file://")) {
+		if _, err := remoteRepoDir(url); err != nil {
+			return err
+		}
+	}
+	if dir == "" {
+		dir = cloneDirName(url)
+	}
+	if entries, err := os.ReadDir(dir); err == nil && len(entries) > 0 {
+		return fmt.Errorf("destination path '%s' already exists and is not an empty directory", dir)
+	}
+	// A local remote is recorded by absolute path so that it still
+	// resolves from inside the clone.
+	if !remoteURL && !strings.HasPrefix(url, "file://") {
+		abs, err := filepath.Abs(url)
+		if err != nil {
+			return fmt.Errorf("could not resolve '%s': %w", url, err)
+		}
+		url = abs
+	}
+
+	_, statErr := os.Stat(dir)
+	created := os.IsNotExist(statErr)
+	fmt.Printf("Cloning into '%s'...\n", dir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("could not create directory '%s': %w", dir, err)
+	}
+	// Runs after the deferred return to cwd below, so dir still resolves.
+	defer func() {
+		if err != nil {
+			removeFailedClone(dir, created)
+		}
+	}()
+	if err := createRepo(filepath.Join(dir, ".gogit"), false); err != nil {
+		return err
+	}
+
+	// Everything else works relative to the new working tree.
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	if err := os.Chdir(dir); err != nil {
+		return err
+	}
+	defer os.Chdir(cwd)
+
+	if err := AddRemote("origin", url); err != nil {
+		return err
+	}
+	remote := &Remote{Name: "origin", URL: url}
+	ad, err := fetchRemote(remote, false, true)
+	if err != nil {
+		return err
+	}
+	headRef, head := ad.HeadRef, ad.Head
+	if head == "" {
+		fmt.Println("warning: You appear to have cloned an empty repository.")
+		return nil
+	}
+
+	message := "clone: from " + url
+	if headRef == "" {
+		if err := DetachHead(head, message); err != nil {
+			return err
+		}
+	} else {
+		branch := strings.TrimPrefix(headRef, "refs/heads/")
+		if err := UpdateRef(headRef, head, message); err != nil {
+			return err
+		}
+		if headRef != "refs/heads/main" {
+			// Drop the unborn main branch the new repository started on.
+			if err := os.Remove(filepath.Join(RepoPath, "refs/heads/main")); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		if err := AttachHead(headRef, message); err != nil {
+			return err
+		}
+		if err := setUpstream(branch, "origin", headRef); err != nil {
+			return err
+		}
+	}
+
+	tree, err := commitTree(head)
+	if err != nil {
+		return err
+	}
+	if err := checkoutTree(map[string]string{}, tree, false, "clone"); err != nil {
+		return err
+	}
+	fmt.Println("done.")
+	return nil
+}
+
+// removeFailedClone deletes the directory of a failed clone, or only its
+// contents when it was an existing empty directory.
+func removeFailedClone(dir string, created bool) {
+	if created {
+		os.RemoveAll(dir)
+		return
+	}
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		os.RemoveAll(filepath.Join(dir, entry.Name()))
+	}
+}
+
+// isRemoteURL reports whether url names a repository reached over the
+// network rather than a local path.
+func isRemoteURL(url string) bool {
+	return strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://")
+}
+
+// cloneDirName derives the directory a clone of url goes into from the
+// last component of its path.
+func cloneDirName(url string) string {
+	path := strings.TrimPrefix(url, "file://")
+	if isRemoteURL(url) {
+		_, path, _ = strings.Cut(url, "://")
+		path = strings.TrimRight(path, "/")
+		if i := strings.IndexAny(path, "?#"); i >= 0 {
+			path = path[:i]
+		}
+	}
+	name := filepath.Base(filepath.Clean(path))
+	for _, suffix := range []string{".gogit", ".git", ".bundle"} {
+		name = strings.TrimSuffix(name, suffix)
+	}
+	return name
+}
+
+// setUpstream makes ref of remote the upstream of a local branch.
+func setUpstream(branch, remote, ref string) error {
+	if err := SetConfig("branch."+branch+".remote", remote, false); err != nil {
+		return err
+	}
+	return SetConfig("branch."+branch+".merge", ref, false)
+}
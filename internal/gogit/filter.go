@@ -0,0 +1,349 @@
+package gogit
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// A path with filter=<name> has its content run through commands from the
+// config on the way into and out of the object store, as in Git:
+//
+//	[filter "<name>"]
+//		clean = <command>    # working tree -> object store
+//		smudge = <command>   # object store -> working tree
+//		process = <command>  # a long-running filter for both
+//		required = true      # fail instead of passing content through
+//
+// clean and smudge commands run through "sh -c" once per file, with the
+// content on stdin, the result read from stdout and "%f" replaced by the
+// path. A process command is started once per command and converts every
+// file over Git's long-running filter protocol; it takes precedence over
+// clean and smudge. When a filter that is not required fails, or has no
+// command for the direction, the content goes through unchanged. The lfs
+// filter is built in.
+
+// filterDriver is the filter.<name> section of the config.
+type filterDriver struct {
+	name     string
+	clean    string
+	smudge   string
+	process  string
+	required bool
+}
+
+// loadFilterDriver reads the filter.<name> options.
+func loadFilterDriver(name string) (*filterDriver, error) {
+	driver := &filterDriver{name: name}
+	for key, value := range map[string]*string{"clean": &driver.clean, "smudge": &driver.smudge, "process": &driver.process} {
+		var err error
+		if *value, _, err = GetConfig("filter." + name + "." + key); err != nil {
+			return nil, err
+		}
+	}
+	required, err := GetConfigBool("filter."+name+".required", false)
+	if err != nil {
+		return nil, err
+	}
+	driver.required = required
+	return driver, nil
+}
+
+// filterFor returns the driver of the filter attribute in attrs, or nil
+// when there is none to run.
+func (c *converter) filterFor(attrs map[string]string) (*filterDriver, error) {
+	name := attrs["filter"]
+	if name == "" || name == attrSet || name == attrUnset || name == "lfs" {
+		return nil, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if driver, ok := c.filters[name]; ok {
+		return driver, nil
+	}
+	driver, err := loadFilterDriver(name)
+	if err != nil {
+		return nil, err
+	}
+	if driver.clean == "" && driver.smudge == "" && driver.process == "" && !driver.required {
+		driver = nil
+	}
+	c.filters[name] = driver
+	return driver, nil
+}
+
+// runFilter runs content of path through driver's command ("clean" or
+// "smudge"). It reports false when the driver has no command for that
+// direction, leaving the content as it is.
+func (c *converter) runFilter(driver *filterDriver, command, path string, content io.Reader) ([]byte, bool, error) {
+	path = filepath.ToSlash(filepath.Clean(path))
+	if driver.process != "" {
+		process, err := c.filterProcess(driver)
+		if err != nil {
+			return nil, false, err
+		}
+		if !process.supports(command) {
+			return nil, false, nil
+		}
+		out, err := process.run(command, path, content)
+		if err != nil {
+			return nil, false, fmt.Errorf("%s filter '%s' failed on %s: %w", command, driver.name, path, err)
+		}
+		return out, true, nil
+	}
+
+	commandLine := driver.clean
+	if command == "smudge" {
+		commandLine = driver.smudge
+	}
+	if commandLine == "" {
+		return nil, false, nil
+	}
+	var out bytes.Buffer
+	cmd := exec.Command("sh", "-c", strings.ReplaceAll(commandLine, "%f", shellQuote(path)))
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = content, &out, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, false, fmt.Errorf("%s filter '%s' failed on %s: %w", command, driver.name, path, err)
+	}
+	return out.Bytes(), true, nil
+}
+
+// applyFilter runs runFilter, settling failures by the driver's required
+// option: a required filter that fails, or has no command for the
+// direction, is an error; otherwise the content is kept, with a warning
+// for a failure.
+func (c *converter) applyFilter(driver *filterDriver, command, path string, content io.Reader) ([]byte, bool, error) {
+	out, ok, err := c.runFilter(driver, command, path, content)
+	switch {
+	case err != nil && driver.required:
+		return nil, false, err
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "warning: %v; using the content unfiltered\n", err)
+		return nil, false, nil
+	case !ok && driver.required:
+		return nil, false, fmt.Errorf("%s: %s filter '%s' is required but has no command", path, command, driver.name)
+	}
+	return out, ok, nil
+}
+
+// shellQuote quotes s as a single sh word.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// filterProcess is a running filter.<name>.process command. Files go
+// through it one at a time:
+//
+//	gogit> command=clean, pathname=<path>, flush, content..., flush
+//	filter> status=success, flush, content..., flush, [status=...], flush
+//
+// after a handshake in which both sides agree on version 2 and the filter
+// names the commands it supports. A status of "error" fails the file;
+// "abort" also stops using the filter for that command.
+type filterProcess struct {
+	mu           sync.Mutex
+	cmd          *exec.Cmd
+	stdin        io.WriteCloser
+	stdout       *bufio.Reader
+	capabilities map[string]bool
+	// err is set once the process has failed; it is not used again.
+	err error
+}
+
+// filterProcess returns driver's running process, starting it on first
+// use.
+func (c *converter) filterProcess(driver *filterDriver) (*filterProcess, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if process, ok := c.processes[driver.name]; ok {
+		return process, process.err
+	}
+	process, err := startFilterProcess(driver.process)
+	if err != nil {
+		err = fmt.Errorf("error starting filter '%s': %w", driver.name, err)
+		process = &filterProcess{err: err}
+	}
+	c.processes[driver.name] = process
+	return process, err
+}
+
+// startFilterProcess runs command and performs the handshake.
+func startFilterProcess(command string) (*filterProcess, error) {
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	process := &filterProcess{cmd: cmd, stdin: stdin, stdout: bufio.NewReader(stdout), capabilities: make(map[string]bool)}
+	if err := process.handshake(); err != nil {
+		process.stop()
+		return nil, err
+	}
+	return process, nil
+}
+
+func (p *filterProcess) handshake() error {
+	w := bufio.NewWriter(p.stdin)
+	writePktLine(w, "git-filter-client\n")
+	writePktLine(w, "version=2\n")
+	writeFlushPkt(w)
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	lines, err := readPktLines(p.stdout)
+	if err != nil {
+		return err
+	}
+	if len(lines) < 2 || lines[0] != "git-filter-server" || lines[1] != "version=2" {
+		return fmt.Errorf("unexpected filter handshake %q", lines)
+	}
+
+	writePktLine(w, "capability=clean\n")
+	writePktLine(w, "capability=smudge\n")
+	writeFlushPkt(w)
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if lines, err = readPktLines(p.stdout); err != nil {
+		return err
+	}
+	for _, line := range lines {
+		if capability, ok := strings.CutPrefix(line, "capability="); ok {
+			p.capabilities[capability] = true
+		}
+	}
+	return nil
+}
+
+// supports reports whether the process converts files for command.
+func (p *filterProcess) supports(command string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err == nil && p.capabilities[command]
+}
+
+// run converts one file's content with the process.
+func (p *filterProcess) run(command, path string, content io.Reader) ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return nil, p.err
+	}
+	out, status, err := p.exchange(command, path, content)
+	if err != nil {
+		// The conversation is out of step; nothing more can go through.
+		p.err = fmt.Errorf("filter process stopped: %w", err)
+		p.stop()
+		return nil, err
+	}
+	switch status {
+	case "success":
+		return out, nil
+	case "abort":
+		p.capabilities[command] = false
+		return nil, fmt.Errorf("filter aborted %s", command)
+	}
+	return nil, fmt.Errorf("filter reported status '%s'", status)
+}
+
+// exchange sends one file and reads back its converted content and final
+// status.
+func (p *filterProcess) exchange(command, path string, content io.Reader) ([]byte, string, error) {
+	w := bufio.NewWriter(p.stdin)
+	writePktLine(w, "command="+command+"\n")
+	writePktLine(w, "pathname="+path+"\n")
+	writeFlushPkt(w)
+	buf := make([]byte, maxPktPayload)
+	for {
+		n, err := io.ReadFull(content, buf)
+		if n > 0 {
+			writePktLine(w, string(buf[:n]))
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	writeFlushPkt(w)
+	if err := w.Flush(); err != nil {
+		return nil, "", err
+	}
+
+	status, err := p.readStatus("")
+	if err != nil || status != "success" {
+		return nil, status, err
+	}
+	var out bytes.Buffer
+	for {
+		payload, err := readPktLine(p.stdout)
+		if err != nil {
+			return nil, "", err
+		}
+		if payload == nil {
+			break
+		}
+		out.Write(payload)
+	}
+	// An empty list after the content keeps the status.
+	if status, err = p.readStatus(status); err != nil {
+		return nil, "", err
+	}
+	return out.Bytes(), status, nil
+}
+
+// readStatus reads a list of key=value lines up to a flush-pkt and
+// returns its status, or current if it has none.
+func (p *filterProcess) readStatus(current string) (string, error) {
+	lines, err := readPktLines(p.stdout)
+	if err != nil {
+		return "", err
+	}
+	for _, line := range lines {
+		if status, ok := strings.CutPrefix(line, "status="); ok {
+			current = status
+		}
+	}
+	if current == "" {
+		return "", fmt.Errorf("filter sent no status")
+	}
+	return current, nil
+}
+
+// stop closes the process's input, which tells it to exit, and waits for
+// it.
+func (p *filterProcess) stop() {
+	if p.cmd == nil {
+		return
+	}
+	p.stdin.Close()
+	p.cmd.Wait()
+	p.cmd = nil
+}
+
+// close stops the filter processes the converter started.
+func (c *converter) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, process := range c.processes {
+		process.mu.Lock()
+		process.stop()
+		process.mu.Unlock()
+	}
+	c.processes = make(map[string]*filterProcess)
+}
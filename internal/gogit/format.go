@@ -0,0 +1,347 @@
+package gogit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// prettyPresets are the named layouts accepted by --pretty.
+var prettyPresets = map[string]bool{
+	"oneline": true,
+	"short":   true,
+	"medium":  true,
+	"full":    true,
+	"fuller":  true,
+}
+
+// prettyFormat is a parsed --pretty/--format value. Either preset or
+// template is set; separator is true for "format:" templates, which are
+// printed between commits instead of after each one.
+type prettyFormat struct {
+	preset    string
+	template  string
+	separator bool
+}
+
+// parsePretty turns a --pretty value into a prettyFormat. It accepts the
+// preset names, "format:<template>", "tformat:<template>" and, like Git, a
+// bare string containing a '%' placeholder as a tformat template.
+func parsePretty(value string) (*prettyFormat, error) {
+	switch {
+	case value == "":
+		return &prettyFormat{preset: "medium"}, nil
+	case strings.HasPrefix(value, "format:"):
+		return &prettyFormat{template: strings.TrimPrefix(value, "format:"), separator: true}, nil
+	case strings.HasPrefix(value, "tformat:"):
+		return &prettyFormat{template: strings.TrimPrefix(value, "tformat:")}, nil
+	case prettyPresets[value]:
+		return &prettyFormat{preset: value}, nil
+	case strings.Contains(value, "%"):
+		return &prettyFormat{template: value}, nil
+	}
+	return nil, fmt.Errorf("invalid --pretty format: %s", value)
+}
+
+// FormatCommit renders a commit using the format and date mode in opts.
+// Preset layouts include their trailing newlines; templates do not.
+func FormatCommit(commit *Commit, opts *LogOptions) (string, error) {
+	pf, err := parsePretty(opts.Format)
+	if err != nil {
+		return "", err
+	}
+	if _, err := FormatDate(commit.Date, opts.DateMode); err != nil {
+		return "", err
+	}
+	return pf.render(commit, opts.DateMode), nil
+}
+
+func (pf *prettyFormat) render(commit *Commit, dateMode string) string {
+	if pf.preset == "" {
+		return expandPlaceholders(pf.template, commit, dateMode)
+	}
+
+	date, _ := FormatDate(commit.Date, dateMode)
+	subject, _ := splitMessage(commit.Message)
+
+	var sb strings.Builder
+	switch pf.preset {
+	case "oneline":
+		fmt.Fprintf(&sb, "%s%s%s %s\n", ColorYellow, commit.Hash, ColorReset, subject)
+	case "short":
+		fmt.Fprintf(&sb, "%scommit %s%s\n", ColorYellow, commit.Hash, ColorReset)
+		fmt.Fprintf(&sb, "Author: %s\n", commit.Author)
+		fmt.Fprintf(&sb, "\n%s\n\n", indentMessage(subject, "    "))
+	case "full":
+		fmt.Fprintf(&sb, "%scommit %s%s\n", ColorYellow, commit.Hash, ColorReset)
+		fmt.Fprintf(&sb, "Author: %s\n", commit.Author)
+		fmt.Fprintf(&sb, "Commit: %s\n", commit.Author)
+		fmt.Fprintf(&sb, "\n%s\n\n", indentMessage(commit.Message, "    "))
+	case "fuller":
+		fmt.Fprintf(&sb, "%scommit %s%s\n", ColorYellow, commit.Hash, ColorReset)
+		fmt.Fprintf(&sb, "Author:     %s\n", commit.Author)
+		fmt.Fprintf(&sb, "AuthorDate: %s\n", date)
+		fmt.Fprintf(&sb, "Commit:     %s\n", commit.Author)
+		fmt.Fprintf(&sb, "CommitDate: %s\n", date)
+		fmt.Fprintf(&sb, "\n%s\n\n", indentMessage(commit.Message, "    "))
+	default: // medium
+		fmt.Fprintf(&sb, "%scommit %s%s\n", ColorYellow, commit.Hash, ColorReset)
+		fmt.Fprintf(&sb, "Tree: %s\n", commit.Tree)
+		if len(commit.Parents) > 1 {
+			fmt.Fprintf(&sb, "%sMerge: %s%s\n", ColorRed, abbrevHashes(commit.Parents), ColorReset)
+		} else if commit.Parent != "" {
+			fmt.Fprintf(&sb, "%sParent: %s%s\n", ColorRed, commit.Parent, ColorReset)
+		}
+		fmt.Fprintf(&sb, "%sAuthor: %s%s\n", ColorGreen, commit.Author, ColorReset)
+		fmt.Fprintf(&sb, "%sDate: %s%s\n", ColorBlue, date, ColorReset)
+		fmt.Fprintf(&sb, "\n%s\n\n", indentMessage(commit.Message, "\t"))
+	}
+	return sb.String()
+}
+
+// expandPlaceholders replaces Git-style %-placeholders in template with the
+// commit's fields. Unknown placeholders are copied through unchanged.
+func expandPlaceholders(template string, commit *Commit, dateMode string) string {
+	name, email := splitAuthor(commit.Author)
+	subject, body := splitMessage(commit.Message)
+
+	var sb strings.Builder
+	for i := 0; i < len(template); i++ {
+		if template[i] != '%' || i+1 >= len(template) {
+			sb.WriteByte(template[i])
+			continue
+		}
+
+		rest := template[i+1:]
+		consumed := 1
+		switch {
+		case strings.HasPrefix(rest, "%"):
+			sb.WriteByte('%')
+		case strings.HasPrefix(rest, "n"):
+			sb.WriteByte('\n')
+		case strings.HasPrefix(rest, "H"):
+			sb.WriteString(commit.Hash)
+		case strings.HasPrefix(rest, "h"):
+			sb.WriteString(abbrevHash(commit.Hash))
+		case strings.HasPrefix(rest, "T"):
+			sb.WriteString(commit.Tree)
+		case strings.HasPrefix(rest, "t"):
+			sb.WriteString(abbrevHash(commit.Tree))
+		case strings.HasPrefix(rest, "P"):
+			sb.WriteString(strings.Join(commit.Parents, " "))
+		case strings.HasPrefix(rest, "p"):
+			sb.WriteString(abbrevHashes(commit.Parents))
+		case strings.HasPrefix(rest, "s"):
+			sb.WriteString(subject)
+		case strings.HasPrefix(rest, "b"):
+			if body != "" {
+				sb.WriteString(body + "\n")
+			}
+		case strings.HasPrefix(rest, "B"):
+			sb.WriteString(commit.Message + "\n")
+		case len(rest) >= 2 && (rest[0] == 'a' || rest[0] == 'c'):
+			// Commits only record an author, so committer placeholders
+			// report the same identity and date.
+			value, ok := identityPlaceholder(rest[1], name, email, commit.Date, dateMode)
+			if !ok {
+				sb.WriteString("%" + rest[:2])
+			} else {
+				sb.WriteString(value)
+			}
+			consumed = 2
+		case strings.HasPrefix(rest, "C("):
+			end := strings.IndexByte(rest, ')')
+			if end < 0 {
+				sb.WriteString("%C(")
+				consumed = 2
+				break
+			}
+			if code, ok := colorCode(rest[2:end]); ok {
+				sb.WriteString(code)
+			} else {
+				sb.WriteString("%" + rest[:end+1])
+			}
+			consumed = end + 1
+		case strings.HasPrefix(rest, "Cred"):
+			sb.WriteString(ColorRed)
+			consumed = 4
+		case strings.HasPrefix(rest, "Cgreen"):
+			sb.WriteString(ColorGreen)
+			consumed = 6
+		case strings.HasPrefix(rest, "Cblue"):
+			sb.WriteString(ColorBlue)
+			consumed = 5
+		case strings.HasPrefix(rest, "Creset"):
+			sb.WriteString(ColorReset)
+			consumed = 6
+		default:
+			sb.WriteByte('%')
+			consumed = 0
+		}
+		i += consumed
+	}
+	return sb.String()
+}
+
+// identityPlaceholder expands the second letter of an %a?/%c? placeholder.
+func identityPlaceholder(field byte, name, email string, date time.Time, dateMode string) (string, bool) {
+	switch field {
+	case 'n':
+		return name, true
+	case 'e':
+		return email, true
+	case 'd':
+		value, err := FormatDate(date, dateMode)
+		return value, err == nil
+	case 'r':
+		return relativeDate(date, time.Now()), true
+	case 't':
+		return strconv.FormatInt(date.Unix(), 10), true
+	case 'i':
+		value, _ := FormatDate(date, "iso")
+		return value, true
+	case 'I':
+		value, _ := FormatDate(date, "iso-strict")
+		return value, true
+	case 's':
+		value, _ := FormatDate(date, "short")
+		return value, true
+	}
+	return "", false
+}
+
+// colorCode maps a %C(...) color name to its escape sequence.
+func colorCode(name string) (string, bool) {
+	switch strings.TrimSpace(name) {
+	case "reset", "normal":
+		return ColorReset, true
+	case "red":
+		return ColorRed, true
+	case "green":
+		return ColorGreen, true
+	case "yellow":
+		return ColorYellow, true
+	case "blue":
+		return ColorBlue, true
+	case "magenta":
+		return ColorMagenta, true
+	case "cyan":
+		return ColorCyan, true
+	case "bold":
+		return ColorBold, true
+	}
+	return "", false
+}
+
+// FormatDate renders t according to a --date mode.
+func FormatDate(t time.Time, mode string) (string, error) {
+	switch mode {
+	case "", "default":
+		return t.Format("Mon Jan 2 15:04:05 2006 -0700"), nil
+	case "local":
+		return t.Local().Format("Mon Jan 2 15:04:05 2006"), nil
+	case "iso", "iso8601":
+		return t.Format("2006-01-02 15:04:05 -0700"), nil
+	case "iso-strict", "iso8601-strict":
+		return t.Format(time.RFC3339), nil
+	case "rfc", "rfc2822":
+		return t.Format("Mon, 2 Jan 2006 15:04:05 -0700"), nil
+	case "short":
+		return t.Format("2006-01-02"), nil
+	case "unix":
+		return strconv.FormatInt(t.Unix(), 10), nil
+	case "raw":
+		return fmt.Sprintf("%d %s", t.Unix(), t.Format("-0700")), nil
+	case "relative":
+		return relativeDate(t, time.Now()), nil
+	}
+	return "", fmt.Errorf("unknown date format: %s", mode)
+}
+
+// relativeDate describes t relative to now, using the same thresholds as Git.
+func relativeDate(t, now time.Time) string {
+	if t.After(now) {
+		return "in the future"
+	}
+	seconds := int64(now.Sub(t).Seconds())
+	plural := func(n int64, unit string) string {
+		if n == 1 {
+			return fmt.Sprintf("%d %s", n, unit)
+		}
+		return fmt.Sprintf("%d %ss", n, unit)
+	}
+
+	switch {
+	case seconds < 90:
+		return plural(seconds, "second") + " ago"
+	case seconds < 90*60:
+		return plural((seconds+30)/60, "minute") + " ago"
+	case seconds < 36*3600:
+		return plural((seconds+1800)/3600, "hour") + " ago"
+	}
+
+	days := (seconds + 43200) / 86400
+	switch {
+	case days < 14:
+		return plural(days, "day") + " ago"
+	case days < 70:
+		return plural((days+3)/7, "week") + " ago"
+	case days < 365:
+		return plural((days+15)/30, "month") + " ago"
+	case days < 1825:
+		totalMonths := (days*12*2 + 365) / (365 * 2)
+		years := totalMonths / 12
+		months := totalMonths % 12
+		if months == 0 {
+			return plural(years, "year") + " ago"
+		}
+		return plural(years, "year") + ", " + plural(months, "month") + " ago"
+	}
+	return plural((days+183)/365, "year") + " ago"
+}
+
+// splitAuthor splits an "Name <email>" identity. Identities recorded
+// without an email return an empty email.
+func splitAuthor(author string) (string, string) {
+	start := strings.LastIndexByte(author, '<')
+	end := strings.LastIndexByte(author, '>')
+	if start < 0 || end < start {
+		return strings.TrimSpace(author), ""
+	}
+	return strings.TrimSpace(author[:start]), author[start+1 : end]
+}
+
+// splitMessage returns the subject line and the body of a commit message.
+func splitMessage(message string) (string, string) {
+	subject, body, _ := strings.Cut(message, "\n")
+	return strings.TrimSpace(subject), strings.TrimSpace(body)
+}
+
+// indentMessage prefixes every non-empty line of message with indent.
+func indentMessage(message, indent string) string {
+	lines := strings.Split(message, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = indent + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// abbrevHash shortens a hash to the length Git uses by default.
+func abbrevHash(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
+}
+
+// abbrevHashes abbreviates each hash and joins them with spaces.
+func abbrevHashes(hashes []string) string {
+	short := make([]string, len(hashes))
+	for i, hash := range hashes {
+		short[i] = abbrevHash(hash)
+	}
+	return strings.Join(short, " ")
+}
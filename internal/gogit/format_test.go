@@ -0,0 +1,16 @@
+package gogit
+
+import "testing"
+
+func TestEmptyTemplateRendersEmptyRecord(t *testing.T) {
+	commit := &Commit{Hash: "0123456789abcdef0123456789abcdef01234567", Author: "a <a@b>", Message: "subject"}
+	for _, value := range []string{"format:", "tformat:"} {
+		pf, err := parsePretty(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out := pf.render(commit, "default"); out != "" {
+			t.Fatalf("--pretty=%s rendered %q, want an empty record", value, out)
+		}
+	}
+}
@@ -0,0 +1,486 @@
+package gogit
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Modes of Git tree entries.
+const (
+	gitModeFile       = "100644"
+	gitModeExecutable = "100755"
+	gitModeSymlink    = "120000"
+	gitModeTree       = "40000"
+	gitModeSubmodule  = "160000"
+)
+
+// gitRepo reads the objects and refs of a Git repository directory (a
+// .git directory, or a bare repository).
+type gitRepo struct {
+	dir   string
+	packs []*gitPack
+}
+
+// gitObjectReader is where Git objects are imported from: a Git
+// repository, or the objects of a bundle.
+type gitObjectReader interface {
+	readObject(hash string) (string, []byte, error)
+}
+
+// gitObjectWriter is where Git objects are exported to: a Git repository
+// directory, or the objects of a bundle being written.
+type gitObjectWriter interface {
+	writeObject(objectType string, content []byte) (string, error)
+	// copyBlob adds the gogit blob hash, which has the same ID in Git,
+	// unless it is there already.
+	copyBlob(hash string) error
+}
+
+// gitObjectDir writes loose objects into a Git repository directory.
+type gitObjectDir string
+
+func (dir gitObjectDir) writeObject(objectType string, content []byte) (string, error) {
+	return writeGitObject(string(dir), objectType, content)
+}
+
+func (dir gitObjectDir) copyBlob(hash string) error {
+	if _, err := os.Stat(filepath.Join(string(dir), "objects", hash[:2], hash[2:])); err == nil {
+		return nil
+	}
+	content, err := ReadBlob(hash)
+	if err != nil {
+		return err
+	}
+	_, err = writeGitObject(string(dir), ObjectTypeBlob, content)
+	return err
+}
+
+// gitObjectSet holds Git objects in memory, in the order they were
+// added. Exported blobs are only named, since they have the same IDs in
+// gogit and are read from the repository when needed. While hashOnly is
+// set, trees and commits are only named too, which is enough to learn
+// the Git IDs of gogit commits.
+type gitObjectSet struct {
+	objects  map[string]gitObject
+	order    []string
+	hashOnly bool
+}
+
+// gitObject is an object of a gitObjectSet. A nil content was not kept.
+type gitObject struct {
+	objectType string
+	content    []byte
+}
+
+func newGitObjectSet(hashOnly bool) *gitObjectSet {
+	return &gitObjectSet{objects: make(map[string]gitObject), hashOnly: hashOnly}
+}
+
+func (set *gitObjectSet) add(hash, objectType string, content []byte) {
+	if _, ok := set.objects[hash]; ok {
+		return
+	}
+	set.objects[hash] = gitObject{objectType: objectType, content: content}
+	set.order = append(set.order, hash)
+}
+
+func (set *gitObjectSet) writeObject(objectType string, content []byte) (string, error) {
+	hash := hashTypedObject(objectType, content)
+	switch {
+	case set.hashOnly:
+		content = nil
+	case content == nil:
+		content = []byte{}
+	}
+	set.add(hash, objectType, content)
+	return hash, nil
+}
+
+func (set *gitObjectSet) copyBlob(hash string) error {
+	set.add(hash, ObjectTypeBlob, nil)
+	return nil
+}
+
+func (set *gitObjectSet) readObject(hash string) (string, []byte, error) {
+	object, ok := set.objects[hash]
+	switch {
+	case ok && object.content != nil:
+		return object.objectType, object.content, nil
+	case ok && object.objectType == ObjectTypeBlob:
+		content, err := ReadBlob(hash)
+		return ObjectTypeBlob, content, err
+	}
+	return "", nil, fmt.Errorf("object %s not found", hash)
+}
+
+// gitPack is a packfile of a Git repository with the offsets its index
+// gives for each object.
+type gitPack struct {
+	file    *os.File
+	offsets map[string]int64
+}
+
+// openGitRepo opens the Git repository in dir, loading its pack indexes.
+func openGitRepo(dir string) (*gitRepo, error) {
+	for _, name := range []string{"HEAD", "objects", "refs"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			return nil, fmt.Errorf("'%s' is not a Git repository", dir)
+		}
+	}
+	repo := &gitRepo{dir: dir}
+	indexes, err := filepath.Glob(filepath.Join(dir, "objects", "pack", "*.idx"))
+	if err != nil {
+		return nil, err
+	}
+	for _, index := range indexes {
+		pack, err := openGitPack(index)
+		if err != nil {
+			repo.Close()
+			return nil, err
+		}
+		repo.packs = append(repo.packs, pack)
+	}
+	return repo, nil
+}
+
+// Close releases the repository's packfiles.
+func (repo *gitRepo) Close() {
+	for _, pack := range repo.packs {
+		pack.file.Close()
+	}
+}
+
+// openGitPack reads a version 2 pack index: a 256-entry fan-out table,
+// the sorted object names, their CRCs, and their offsets in the pack, with
+// offsets past 2 GiB kept in a separate table of 8-byte values.
+func openGitPack(indexPath string) (*gitPack, error) {
+	index, err := os.ReadFile(indexPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading pack index: %w", err)
+	}
+	errCorrupt := fmt.Errorf("corrupt pack index %s", indexPath)
+	if len(index) < 8+256*4 || !bytes.Equal(index[:4], []byte{0xff, 't', 'O', 'c'}) {
+		return nil, fmt.Errorf("unsupported pack index %s", indexPath)
+	}
+	if version := binary.BigEndian.Uint32(index[4:]); version != 2 {
+		return nil, fmt.Errorf("unsupported pack index version %d", version)
+	}
+	count := int(binary.BigEndian.Uint32(index[8+255*4:]))
+	names := 8 + 256*4
+	offsets := names + count*(20+4)
+	large := offsets + count*4
+	if len(index) < large {
+		return nil, errCorrupt
+	}
+
+	pack := &gitPack{offsets: make(map[string]int64, count)}
+	for i := 0; i < count; i++ {
+		name := hex.EncodeToString(index[names+i*20 : names+i*20+20])
+		offset := int64(binary.BigEndian.Uint32(index[offsets+i*4:]))
+		if offset&0x80000000 != 0 {
+			at := large + int(offset&0x7fffffff)*8
+			if len(index) < at+8 {
+				return nil, errCorrupt
+			}
+			offset = int64(binary.BigEndian.Uint64(index[at:]))
+		}
+		pack.offsets[name] = offset
+	}
+	if pack.file, err = os.Open(strings.TrimSuffix(indexPath, ".idx") + ".pack"); err != nil {
+		return nil, fmt.Errorf("error opening pack: %w", err)
+	}
+	return pack, nil
+}
+
+// readObject returns the type and content of an object, loose or packed.
+func (repo *gitRepo) readObject(hash string) (string, []byte, error) {
+	if !fullHashPattern.MatchString(hash) {
+		return "", nil, fmt.Errorf("invalid object name %s", hash)
+	}
+	data, err := os.ReadFile(filepath.Join(repo.dir, "objects", hash[:2], hash[2:]))
+	if err == nil {
+		return parseLooseObject(hash, data)
+	}
+	if !os.IsNotExist(err) {
+		return "", nil, fmt.Errorf("error reading object %s: %w", hash, err)
+	}
+	for _, pack := range repo.packs {
+		if offset, ok := pack.offsets[hash]; ok {
+			code, content, err := repo.readPacked(pack, offset)
+			if err != nil {
+				return "", nil, fmt.Errorf("error reading object %s: %w", hash, err)
+			}
+			return packTypeNames[code], content, nil
+		}
+	}
+	return "", nil, fmt.Errorf("object %s not found in Git repository", hash)
+}
+
+// parseLooseObject decompresses a loose object: zlib over
+// "<type> <size>\0<content>".
+func parseLooseObject(hash string, data []byte) (string, []byte, error) {
+	decompressor, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return "", nil, fmt.Errorf("error decompressing object %s: %w", hash, err)
+	}
+	object, err := io.ReadAll(decompressor)
+	if err != nil {
+		return "", nil, fmt.Errorf("error decompressing object %s: %w", hash, err)
+	}
+	header, content, ok := bytes.Cut(object, []byte{0})
+	objectType, size, _ := strings.Cut(string(header), " ")
+	if length, err := strconv.Atoi(size); !ok || err != nil || length != len(content) {
+		return "", nil, fmt.Errorf("corrupt object %s", hash)
+	}
+	return objectType, content, nil
+}
+
+// readPacked reads the object at offset in pack, applying deltas.
+func (repo *gitRepo) readPacked(pack *gitPack, offset int64) (int, []byte, error) {
+	entry, err := readPackEntry(bufio.NewReader(io.NewSectionReader(pack.file, offset, 1<<62)))
+	if err != nil {
+		return 0, nil, err
+	}
+	var baseCode int
+	var base []byte
+	switch entry.code {
+	case packOfsDelta:
+		if baseCode, base, err = repo.readPacked(pack, offset-entry.baseOffset); err != nil {
+			return 0, nil, err
+		}
+	case packRefDelta:
+		var baseType string
+		if baseType, base, err = repo.readObject(entry.baseHash); err != nil {
+			return 0, nil, err
+		}
+		for code, name := range packTypeNames {
+			if name == baseType {
+				baseCode = code
+			}
+		}
+	default:
+		return entry.code, entry.content, nil
+	}
+	content, err := applyDelta(base, entry.content)
+	return baseCode, content, err
+}
+
+// refs returns the branches and tags of the repository, loose and
+// packed, and what its HEAD points to.
+func (repo *gitRepo) refs() (refs []Ref, headRef, head string, err error) {
+	values := make(map[string]string)
+	if data, err := os.ReadFile(filepath.Join(repo.dir, "packed-refs")); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			hash, name, ok := strings.Cut(line, " ")
+			if ok && !strings.HasPrefix(line, "#") && !strings.HasPrefix(line, "^") {
+				values[name] = hash
+			}
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, "", "", fmt.Errorf("error reading packed-refs: %w", err)
+	}
+	for _, namespace := range []string{"refs/heads", "refs/tags"} {
+		root := filepath.Join(repo.dir, namespace)
+		err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) && path == root {
+					return filepath.SkipDir
+				}
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			relative, err := filepath.Rel(repo.dir, path)
+			if err != nil {
+				return err
+			}
+			values[filepath.ToSlash(relative)] = strings.TrimSpace(string(data))
+			return nil
+		})
+		if err != nil {
+			return nil, "", "", fmt.Errorf("error reading Git refs: %w", err)
+		}
+	}
+	for _, name := range sortedKeys(values) {
+		if strings.HasPrefix(name, "refs/heads/") || strings.HasPrefix(name, "refs/tags/") {
+			refs = append(refs, Ref{Name: name, Hash: values[name]})
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(repo.dir, "HEAD"))
+	if err != nil {
+		return nil, "", "", fmt.Errorf("error reading Git HEAD: %w", err)
+	}
+	head = strings.TrimSpace(string(data))
+	if target, ok := strings.CutPrefix(head, "ref: "); ok {
+		headRef, head = target, values[target]
+	}
+	return refs, headRef, head, nil
+}
+
+// peelGitTag follows tag objects to the commit they name.
+func peelGitTag(objects gitObjectReader, hash string) (string, error) {
+	for {
+		objectType, content, err := objects.readObject(hash)
+		if err != nil {
+			return "", err
+		}
+		switch objectType {
+		case ObjectTypeCommit:
+			return hash, nil
+		case "tag":
+			object, _, _ := strings.Cut(string(content), "\n")
+			target, ok := strings.CutPrefix(object, "object ")
+			if !ok {
+				return "", fmt.Errorf("corrupt tag %s", hash)
+			}
+			hash = target
+		default:
+			return "", fmt.Errorf("object %s is a %s, not a commit", hash, objectType)
+		}
+	}
+}
+
+// parseGitTree decodes a tree in Git's format.
+func parseGitTree(content []byte) ([]gitTreeEntry, error) {
+	var entries []gitTreeEntry
+	for len(content) > 0 {
+		header, rest, ok := bytes.Cut(content, []byte{0})
+		mode, name, hasName := strings.Cut(string(header), " ")
+		if !ok || !hasName || len(rest) < 20 {
+			return nil, fmt.Errorf("corrupt Git tree")
+		}
+		entries = append(entries, gitTreeEntry{Mode: mode, Name: name, Hash: hex.EncodeToString(rest[:20])})
+		content = rest[20:]
+	}
+	return entries, nil
+}
+
+// gitCommit is the part of a Git commit gogit keeps.
+type gitCommit struct {
+	Tree    string
+	Parents []string
+	Author  string
+	Date    time.Time
+	Message string
+}
+
+// parseGitCommit decodes a commit in Git's format. Headers gogit has no
+// place for, such as the committer or a signature, are dropped.
+func parseGitCommit(content []byte) (*gitCommit, error) {
+	headers, message, _ := strings.Cut(string(content), "\n\n")
+	commit := &gitCommit{Message: strings.TrimSpace(message)}
+	for _, line := range strings.Split(headers, "\n") {
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "tree":
+			commit.Tree = value
+		case "parent":
+			commit.Parents = append(commit.Parents, value)
+		case "author":
+			var err error
+			if commit.Author, commit.Date, err = parseGitIdent(value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if commit.Tree == "" {
+		return nil, fmt.Errorf("corrupt Git commit: no tree")
+	}
+	return commit, nil
+}
+
+// parseGitIdent splits "Name <email> <unix time> <zone>" into a gogit
+// author and a UTC date.
+func parseGitIdent(value string) (string, time.Time, error) {
+	end := strings.LastIndexByte(value, '>')
+	if end < 0 {
+		return "", time.Time{}, fmt.Errorf("invalid identity %q", value)
+	}
+	// gogit authors without an email are exported with "<>".
+	author := strings.TrimSuffix(value[:end+1], " <>")
+	fields := strings.Fields(value[end+1:])
+	if len(fields) == 0 {
+		return author, time.Time{}, nil
+	}
+	seconds, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("invalid date in identity %q", value)
+	}
+	return author, time.Unix(seconds, 0).UTC(), nil
+}
+
+// writeGitObject stores an object in a Git repository directory as a
+// loose, zlib-compressed file, and returns its hash.
+func writeGitObject(dir, objectType string, content []byte) (string, error) {
+	hash := hashTypedObject(objectType, content)
+	path := filepath.Join(dir, "objects", hash[:2], hash[2:])
+	if _, err := os.Stat(path); err == nil {
+		return hash, nil
+	}
+	var data bytes.Buffer
+	compressor := zlib.NewWriter(&data)
+	fmt.Fprintf(compressor, "%s %d\000", objectType, len(content))
+	compressor.Write(content)
+	if err := compressor.Close(); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("error creating directory %s: %w", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, data.Bytes(), 0444); err != nil {
+		return "", fmt.Errorf("error writing object %s: %w", hash, err)
+	}
+	return hash, nil
+}
+
+// gitTreeFromFiles writes a flat gogit file map as nested Git trees to
+// objects and returns the hash of the root tree.
+func gitTreeFromFiles(objects gitObjectWriter, files map[string]string) (string, error) {
+	var entries []gitTreeEntry
+	subdirs := make(map[string]map[string]string)
+	for path, hash := range files {
+		name, rest, nested := strings.Cut(path, "/")
+		if !nested {
+			entries = append(entries, gitTreeEntry{Mode: gitModeFile, Name: name, Hash: hash})
+			continue
+		}
+		if subdirs[name] == nil {
+			subdirs[name] = make(map[string]string)
+		}
+		subdirs[name][rest] = hash
+	}
+	names := make([]string, 0, len(subdirs))
+	for name := range subdirs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		hash, err := gitTreeFromFiles(objects, subdirs[name])
+		if err != nil {
+			return "", err
+		}
+		entries = append(entries, gitTreeEntry{Mode: gitModeTree, Name: name, Hash: hash})
+	}
+	content, err := gitTreeContent(entries)
+	if err != nil {
+		return "", err
+	}
+	return objects.writeObject(ObjectTypeTree, content)
+}
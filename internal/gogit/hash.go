@@ -3,26 +3,43 @@ package gogit
 import (
 	"bytes"
 	"crypto/sha1"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"sort"
 	"time"
 )
 
-func HashObject(content []byte) (string, bytes.Buffer, error) {
-	// 1. Create a buffer to build the Git blob object.
-	var buffer bytes.Buffer
-	// 2. Write the blob header, including the type ("blob"), a space and the length of the content.
-	// The `len()` function in Go returns the number of bytes in the slice.
-	buffer.WriteString(fmt.Sprintf("blob %d", len(content)))
-	// 3. Write the null byte ('\0'), which separates the header from the content.
-	buffer.WriteByte(0)
-	// 4. Write the actual content (the `[]byte`).
-	buffer.Write(content)
-	// 5. Compute the SHA-1 hash of the entire byte sequence.
-	hash := sha1.Sum(buffer.Bytes())
-	// 6. Format the resulting hash as a hexadecimal string.
-	blobHash := fmt.Sprintf("%x", hash)
-	return blobHash, buffer, nil
+// HashObject computes the blob hash of the size bytes read from r,
+// streaming them through the hash so that memory use does not depend on
+// the size.
+func HashObject(r io.Reader, size int64) (string, error) {
+	return hashBlobTo(io.Discard, r, size)
+}
+
+// hashBlobTo copies a blob's stored bytes, the "blob <size>\0" header and
+// then the size bytes read from r, to w, and returns the blob's hash. It
+// fails if r does not hold exactly size bytes, as when a file changes
+// while it is read.
+func hashBlobTo(w io.Writer, r io.Reader, size int64) (string, error) {
+	// 1. Everything written goes through the hash as well.
+	digest := sha1.New()
+	out := io.MultiWriter(w, digest)
+	// 2. Write the blob header: the type ("blob"), a space, the length of
+	// the content and the null byte that separates it from the content.
+	if _, err := fmt.Fprintf(out, "blob %d\000", size); err != nil {
+		return "", err
+	}
+	// 3. Stream the content.
+	n, err := io.Copy(out, r)
+	if err != nil {
+		return "", err
+	}
+	if n != size {
+		return "", fmt.Errorf("expected %d bytes, read %d; did the file change while it was read?", size, n)
+	}
+	// 4. Format the resulting hash as a hexadecimal string.
+	return hex.EncodeToString(digest.Sum(nil)), nil
 }
 
 func HashTree(files map[string]string) (string, []byte, error) {
@@ -56,18 +73,30 @@ func HashTree(files map[string]string) (string, []byte, error) {
 }
 
 func HashCommit(treeHash, parentHash, author, message string) (string, []byte, error) {
+	var parents []string
+	if parentHash != "" {
+		parents = append(parents, parentHash)
+	}
+	return hashCommitAt(treeHash, parents, author, time.Now(), message)
+}
+
+// hashCommitAt is HashCommit with any number of parents and an explicit
+// date, used for merge-like commits and when a commit is re-created from
+// an existing one.
+func hashCommitAt(treeHash string, parents []string, author string, date time.Time, message string) (string, []byte, error) {
 	// 1. Use a buffer to efficiently build the commit content.
 	var contentBuffer bytes.Buffer
 
 	// 2. Write the commit metadata.
 	// Fprintf is ideal for writing formatted text to an io.Writer like a buffer.
 	fmt.Fprintf(&contentBuffer, "tree %s\n", treeHash) // New: points to the tree object
-	if parentHash != "" {                              // Only add parent if it exists
+	// A root commit has no parents, a merge-like commit several.
+	for _, parentHash := range parents {
 		fmt.Fprintf(&contentBuffer, "parent %s\n", parentHash)
 	}
 	fmt.Fprintf(&contentBuffer, "author %s\n", author)
 	// We use the ISO 8601 format (RFC3339 in Go) and UTC for consistency.
-	fmt.Fprintf(&contentBuffer, "date %s\n", time.Now().UTC().Format(time.RFC3339))
+	fmt.Fprintf(&contentBuffer, "date %s\n", date.UTC().Format(time.RFC3339))
 
 	// 3. Write the commit message, separated by a blank line.
 	fmt.Fprintf(&contentBuffer, "\n%s\n", message)
@@ -92,17 +121,68 @@ func HashCommit(treeHash, parentHash, author, message string) (string, []byte, e
 	return commitHash, commitContent, nil
 }
 
-func ReadObject(hash string) error {
-	commit, err := ReadCommit(hash)
-	if err != nil {
-		return err
+// hashTypedObject hashes content as an object of the given type, the way
+// Git names every object.
+func hashTypedObject(objectType string, content []byte) string {
+	digest := sha1.New()
+	fmt.Fprintf(digest, "%s %d\000", objectType, len(content))
+	digest.Write(content)
+	return fmt.Sprintf("%x", digest.Sum(nil))
+}
+
+// gitTreeEntry is one entry of a tree in Git's format: a file, or a
+// directory holding another tree.
+type gitTreeEntry struct {
+	Mode string
+	Name string
+	Hash string
+}
+
+// gitTreeContent encodes entries the way Git stores a tree: for each,
+// "<mode> <name>\0" and the raw 20-byte hash, with directories sorted as
+// if their names ended in "/".
+func gitTreeContent(entries []gitTreeEntry) ([]byte, error) {
+	sorted := append([]gitTreeEntry(nil), entries...)
+	sortKey := func(entry gitTreeEntry) string {
+		if entry.Mode == gitModeTree {
+			return entry.Name + "/"
+		}
+		return entry.Name
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sortKey(sorted[i]) < sortKey(sorted[j]) })
+
+	var content bytes.Buffer
+	for _, entry := range sorted {
+		raw, err := hex.DecodeString(entry.Hash)
+		if err != nil || len(raw) != sha1.Size {
+			return nil, fmt.Errorf("invalid object name %s", entry.Hash)
+		}
+		fmt.Fprintf(&content, "%s %s\000", entry.Mode, entry.Name)
+		content.Write(raw)
 	}
+	return content.Bytes(), nil
+}
 
-	PrintCommit(commit)
+// gitCommitContent encodes a commit the way Git stores it. Git records an
+// author and a committer, each as "Name <email> <unix time> <zone>"; the
+// gogit author and date fill both.
+func gitCommitContent(tree string, parents []string, author string, date time.Time, message string) []byte {
+	ident := gitIdent(author, date)
 
-	if commit.Parent != "" {
-		return ReadObject(commit.Parent)
+	var content bytes.Buffer
+	fmt.Fprintf(&content, "tree %s\n", tree)
+	for _, parent := range parents {
+		fmt.Fprintf(&content, "parent %s\n", parent)
 	}
+	fmt.Fprintf(&content, "author %s\n", ident)
+	fmt.Fprintf(&content, "committer %s\n", ident)
+	fmt.Fprintf(&content, "\n%s\n", message)
+	return content.Bytes()
+}
 
-	return nil
+// gitIdent formats an author and date as Git records them:
+// "Name <email> <unix time> <zone>".
+func gitIdent(author string, date time.Time) string {
+	name, email := splitAuthor(author)
+	return fmt.Sprintf("%s <%s> %d +0000", name, email, date.Unix())
 }
@@ -0,0 +1,159 @@
+package gogit
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// sampleHooks are installed by InitRepo with a ".sample" suffix. Removing
+// the suffix (and keeping the file executable) activates a hook.
+var sampleHooks = map[string]string{
+	"pre-commit": `#!/bin/sh
+#
+# Called by "gogit commit" before the commit message is asked for. A
+# non-zero exit aborts the commit. Bypass with "gogit commit --no-verify".
+#
+# This sample refuses commits that add trailing whitespace to staged files.
+
+gogit ls-files | while read -r file; do
+	if [ -f "$file" ] && grep -q '[[:space:]]$' "$file"; then
+		echo "pre-commit: trailing whitespace in $file" >&2
+		exit 1
+	fi
+done
+`,
+	"prepare-commit-msg": `#!/bin/sh
+#
+# Called by "gogit commit" after the message file is prepared and before
+# the editor is opened. Arguments: the message file, the message source
+# ("message" for -m/-F, "commit" for --amend) and, for --amend, the hash
+# of the amended commit. A non-zero exit aborts the commit.
+#
+# This sample adds the branch name as a comment.
+
+branch=$(gogit rev-parse --abbrev-ref HEAD)
+echo "# Branch: $branch" >> "$1"
+`,
+	"commit-msg": `#!/bin/sh
+#
+# Called by "gogit commit" with the file holding the final message. The
+# hook may edit the file; a non-zero exit aborts the commit. Bypass with
+# "gogit commit --no-verify".
+#
+# This sample rejects subjects longer than 72 characters.
+
+subject=$(grep -v '^#' "$1" | head -n 1)
+if [ ${#subject} -gt 72 ]; then
+	echo "commit-msg: subject is longer than 72 characters" >&2
+	exit 1
+fi
+`,
+	"post-commit": `#!/bin/sh
+#
+# Called by "gogit commit" after the commit is recorded. Its exit status
+# is ignored.
+
+gogit log --pretty=oneline --no-pager | head -n 1
+`,
+	"post-checkout": `#!/bin/sh
+#
+# Called by "gogit checkout" after HEAD, the index and the working tree
+# have been switched. Arguments: the previous HEAD, the new HEAD and 1 for
+# a branch checkout (0 otherwise). Its exit status is ignored.
+#
+# This sample reminds of stashed changes left behind.
+
+if [ -n "$(gogit stash list)" ]; then
+	echo "post-checkout: you have stashed changes (gogit stash list)" >&2
+fi
+`,
+}
+
+// installSampleHooks writes the sample hooks into repoPath/hooks.
+func installSampleHooks(repoPath string) error {
+	hooksPath := filepath.Join(repoPath, "hooks")
+	if err := os.MkdirAll(hooksPath, 0755); err != nil {
+		return fmt.Errorf("error creating directory hooks: %w", err)
+	}
+	for name, content := range sampleHooks {
+		samplePath := filepath.Join(hooksPath, name+".sample")
+		if err := os.WriteFile(samplePath, []byte(content), 0755); err != nil {
+			return fmt.Errorf("error creating sample hook %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// hooksDir returns the directory hooks are run from: core.hooksPath when
+// set, .gogit/hooks otherwise.
+func hooksDir() (string, error) {
+	value, ok, err := GetConfig("core.hooksPath")
+	if err != nil {
+		return "", err
+	}
+	if ok && value != "" {
+		return value, nil
+	}
+	return filepath.Join(RepoPath, "hooks"), nil
+}
+
+// RunHook runs the hook name with args if it exists. Missing hooks are
+// skipped and hooks without the executable bit are skipped with a hint.
+// The hook's output goes to stderr; a non-zero exit is returned as an
+// error.
+func RunHook(name string, args ...string) error {
+	dir, err := hooksDir()
+	if err != nil {
+		return err
+	}
+	hookPath, err := filepath.Abs(filepath.Join(dir, name))
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(hookPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("error checking hook %s: %w", name, err)
+	}
+	if info.IsDir() {
+		return nil
+	}
+	if info.Mode()&0111 == 0 {
+		fmt.Fprintf(os.Stderr, "hint: The '%s' hook was ignored because it's not set as executable.\n", hookPath)
+		return nil
+	}
+
+	gitDir, err := filepath.Abs(RepoPath)
+	if err != nil {
+		return err
+	}
+	indexFile, err := filepath.Abs(IndexPath)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(hookPath, args...)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(),
+		"GIT_DIR="+gitDir,
+		"GOGIT_DIR="+gitDir,
+		"GIT_INDEX_FILE="+indexFile,
+		"GIT_AUTHOR_NAME="+authorName,
+		"GIT_EDITOR=:",
+	)
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return fmt.Errorf("%s hook exited with status %d", name, exitErr.ExitCode())
+		}
+		return fmt.Errorf("error running %s hook: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,643 @@
+package gogit
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The smart HTTP protocol has two rounds. GET <repo>/info/refs?service=S
+// advertises the refs, one pkt-line each, with the capabilities after a
+// NUL on the first line. POST <repo>/S then runs the service:
+// git-upload-pack takes "want"/"have" lines and answers with a packfile,
+// and git-receive-pack takes "<old> <new> <ref>" commands followed by a
+// packfile and answers with a status report.
+//
+// Only gogit clients can use the server. The packfiles carry gogit
+// objects, whose trees Git cannot read, and negotiation is cut short:
+// upload-pack answers NAK once the client is done, without acknowledging
+// common commits as it goes.
+//
+// Large objects are moved outside the protocol, one plain request each to
+// <repo>/lfs/objects/<sha256>: GET downloads one, HEAD checks for it and
+// PUT uploads it.
+
+const (
+	uploadPackService  = "git-upload-pack"
+	receivePackService = "git-receive-pack"
+	httpAgent          = "agent=gogit/1.0"
+)
+
+// HTTPServer serves the repository in Dir over the smart HTTP protocol.
+// The repository is addressed by any URL path; only its last component,
+// such as /info/refs or /git-upload-pack, selects the service, or the
+// last two and a SHA-256 for a large object.
+type HTTPServer struct {
+	Dir string
+	// ReadOnly refuses pushes.
+	ReadOnly bool
+}
+
+// serveMu serializes requests: each one switches the package to the
+// served repository while it runs. Requests are read in full before the
+// switch and answered in full after it, so a client in the same process
+// never sees the server's repository.
+var serveMu sync.Mutex
+
+// ServeHTTPRepo serves the current repository at addr until it fails.
+func ServeHTTPRepo(addr string, readOnly bool) error {
+	if !isRepoDir(RepoPath) {
+		return fmt.Errorf("not a gogit repository")
+	}
+	dir, err := filepath.Abs(RepoPath)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Serving %s on http://%s/\n", dir, addr)
+	return http.ListenAndServe(addr, &HTTPServer{Dir: dir, ReadOnly: readOnly})
+}
+
+func (s *HTTPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if dir, oid := path.Split(r.URL.Path); strings.HasSuffix(dir, "/lfs/objects/") && lfsOIDPattern.MatchString(oid) {
+		s.serveLFSObject(w, r, oid)
+		return
+	}
+	var service string
+	switch {
+	case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/info/refs"):
+		service = r.URL.Query().Get("service")
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/"+uploadPackService):
+		service = uploadPackService
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/"+receivePackService):
+		service = receivePackService
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	switch {
+	case service != uploadPackService && service != receivePackService:
+		http.Error(w, "only the smart HTTP protocol is supported", http.StatusForbidden)
+		return
+	case service == receivePackService && s.ReadOnly:
+		http.Error(w, "pushing is disabled", http.StatusForbidden)
+		return
+	}
+
+	var request []byte
+	if r.Method == http.MethodPost {
+		body := io.Reader(r.Body)
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			decompressor, err := gzip.NewReader(r.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			body = decompressor
+		}
+		var err error
+		if request, err = io.ReadAll(body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	var response bytes.Buffer
+	serveMu.Lock()
+	err := withRepo(s.Dir, func() error {
+		switch {
+		case r.Method == http.MethodGet:
+			return advertiseRefs(&response, service)
+		case service == uploadPackService:
+			return uploadPack(&response, bytes.NewReader(request))
+		default:
+			return receivePack(&response, bytes.NewReader(request))
+		}
+	})
+	serveMu.Unlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		w.Header().Set("Content-Type", "application/x-"+service+"-advertisement")
+	} else {
+		w.Header().Set("Content-Type", "application/x-"+service+"-result")
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(response.Bytes())
+}
+
+// serveLFSObject answers a request for a large object. The large object
+// store is only ever added to, and objects are renamed into it complete,
+// so requests need not hold serveMu.
+func (s *HTTPServer) serveLFSObject(w http.ResponseWriter, r *http.Request, oid string) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		file, err := os.Open(lfsObjectFile(s.Dir, oid))
+		if os.IsNotExist(err) {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer file.Close()
+		info, err := file.Stat()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+		if r.Method == http.MethodGet {
+			io.Copy(w, file)
+		}
+	case http.MethodPut:
+		if s.ReadOnly {
+			http.Error(w, "pushing is disabled", http.StatusForbidden)
+			return
+		}
+		pointer, err := storeLFSObject(s.Dir, r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if pointer.OID != oid {
+			http.Error(w, fmt.Sprintf("content does not match %s", oid), http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// advertiseRefs writes the ref advertisement of the current repository.
+func advertiseRefs(w io.Writer, service string) error {
+	refs, err := listAdvertisedRefs()
+	if err != nil {
+		return err
+	}
+	capabilities := httpAgent
+	if service == receivePackService {
+		capabilities = "report-status delete-refs " + httpAgent
+	} else {
+		headRef, head, err := ReadHead()
+		if err != nil {
+			return err
+		}
+		if head != "" {
+			refs = append([]Ref{{Name: "HEAD", Hash: head}}, refs...)
+			if headRef != "" {
+				capabilities = "symref=HEAD:" + headRef + " " + capabilities
+			}
+		}
+	}
+
+	writePktLine(w, "# service="+service+"\n")
+	writeFlushPkt(w)
+	if len(refs) == 0 {
+		writePktLine(w, zeroHash+" capabilities^{}\000"+capabilities+"\n")
+	}
+	for i, ref := range refs {
+		line := ref.Hash + " " + ref.Name
+		if i == 0 {
+			line += "\000" + capabilities
+		}
+		if err := writePktLine(w, line+"\n"); err != nil {
+			return err
+		}
+	}
+	return writeFlushPkt(w)
+}
+
+// uploadPack answers a fetch request with a packfile of the objects
+// reachable from the wanted commits and not from the common ones.
+func uploadPack(w io.Writer, r io.Reader) error {
+	var wants, haves []string
+	for {
+		payload, err := readPktLine(r)
+		if err != nil {
+			return err
+		}
+		if payload == nil {
+			// A flush ends the wants, or a batch of haves.
+			continue
+		}
+		line := strings.TrimSuffix(string(payload), "\n")
+		if line == "done" {
+			break
+		}
+		command, rest, _ := strings.Cut(line, " ")
+		hash, _, _ := strings.Cut(rest, " ")
+		switch command {
+		case "want":
+			if !ObjectExists(hash) {
+				return fmt.Errorf("not our ref %s", hash)
+			}
+			wants = append(wants, hash)
+		case "have":
+			if ObjectExists(hash) {
+				haves = append(haves, hash)
+			}
+		default:
+			return fmt.Errorf("unexpected line in upload-pack request: %q", line)
+		}
+	}
+
+	has, err := reachableObjects(haves)
+	if err != nil {
+		return err
+	}
+	missing, err := missingObjects(wants, func(hash string) bool { return has[hash] })
+	if err != nil {
+		return err
+	}
+	if err := writePktLine(w, "NAK\n"); err != nil {
+		return err
+	}
+	return writePack(w, missing, ReadObjectContent)
+}
+
+// reachableObjects returns the set of objects reachable from tips.
+func reachableObjects(tips []string) (map[string]bool, error) {
+	objects, err := missingObjects(tips, func(string) bool { return false })
+	if err != nil {
+		return nil, err
+	}
+	set := make(map[string]bool, len(objects))
+	for _, hash := range objects {
+		set[hash] = true
+	}
+	return set, nil
+}
+
+// receivePack stores a pushed packfile, applies the ref updates sent with
+// it and writes the status report.
+func receivePack(w io.Writer, r io.Reader) error {
+	lines, err := readPktLines(r)
+	if err != nil {
+		return err
+	}
+	var updates []refUpdate
+	needPack := false
+	for _, line := range lines {
+		line, _, _ = strings.Cut(line, "\000")
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return fmt.Errorf("invalid receive-pack command: %q", line)
+		}
+		update := refUpdate{Ref: fields[2], Old: fields[0], New: fields[1]}
+		if update.Old == zeroHash {
+			update.Old = ""
+		}
+		if update.New == zeroHash {
+			update.New = ""
+		} else {
+			needPack = true
+		}
+		updates = append(updates, update)
+	}
+
+	unpack := "ok"
+	if needPack {
+		if _, err := readPack(r); err != nil {
+			unpack = err.Error()
+		}
+	}
+	var refused map[string]string
+	if unpack == "ok" {
+		if refused, err = receiveUpdates(updates); err != nil {
+			return err
+		}
+	}
+
+	writePktLine(w, "unpack "+unpack+"\n")
+	for _, update := range updates {
+		reason, ok := refused[update.Ref]
+		switch {
+		case unpack != "ok":
+			writePktLine(w, "ng "+update.Ref+" unpacker error\n")
+		case ok:
+			writePktLine(w, "ng "+update.Ref+" "+reason+"\n")
+		default:
+			writePktLine(w, "ok "+update.Ref+"\n")
+		}
+	}
+	return writeFlushPkt(w)
+}
+
+// httpTransport reaches a repository served over smart HTTP.
+type httpTransport struct {
+	url    string
+	client *http.Client
+}
+
+func newHTTPTransport(url string) *httpTransport {
+	return &httpTransport{url: strings.TrimSuffix(url, "/"), client: http.DefaultClient}
+}
+
+// request runs one round of service: the ref advertisement for GET, the
+// service itself for POST. It checks that the answer has the content type
+// the round calls for.
+func (t *httpTransport) request(method, service string, body []byte) ([]byte, error) {
+	url := t.url + "/" + service
+	contentType := "application/x-" + service + "-result"
+	if method == http.MethodGet {
+		url = t.url + "/info/refs?service=" + service
+		contentType = "application/x-" + service + "-advertisement"
+	}
+	request, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if method == http.MethodPost {
+		request.Header.Set("Content-Type", "application/x-"+service+"-request")
+	}
+	response, err := t.client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("unable to access '%s': %w", t.url, err)
+	}
+	defer response.Body.Close()
+	data, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to access '%s': %w", t.url, err)
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to access '%s': %s: %s", t.url, response.Status, strings.TrimSpace(string(data)))
+	}
+	if got := response.Header.Get("Content-Type"); got != contentType {
+		return nil, fmt.Errorf("'%s' is not a smart HTTP repository (content type %q)", t.url, got)
+	}
+	return data, nil
+}
+
+func (t *httpTransport) advertise(push bool) (*advertisement, error) {
+	service := uploadPackService
+	if push {
+		service = receivePackService
+	}
+	data, err := t.request(http.MethodGet, service, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	r := bytes.NewReader(data)
+	header, err := readPktLines(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(header) != 1 || header[0] != "# service="+service {
+		return nil, fmt.Errorf("invalid ref advertisement from '%s'", t.url)
+	}
+	lines, err := readPktLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Ref names end up as file names here, so a server sending invalid
+	// ones fails the whole transfer.
+	ad := &advertisement{}
+	for i, line := range lines {
+		line, capabilities, _ := strings.Cut(line, "\000")
+		if i == 0 {
+			for _, capability := range strings.Fields(capabilities) {
+				if target, ok := strings.CutPrefix(capability, "symref=HEAD:"); ok {
+					if err := checkTransferRef(target); err != nil {
+						return nil, fmt.Errorf("invalid HEAD advertised by '%s': %w", t.url, err)
+					}
+					ad.HeadRef = target
+				}
+			}
+		}
+		hash, name, ok := strings.Cut(line, " ")
+		if !ok {
+			return nil, fmt.Errorf("invalid ref advertisement from '%s'", t.url)
+		}
+		switch {
+		case name == "capabilities^{}":
+		case name == "HEAD":
+			ad.Head = hash
+		case strings.HasPrefix(name, "refs/heads/") || strings.HasPrefix(name, "refs/tags/"):
+			if strings.HasSuffix(name, "^{}") {
+				continue
+			}
+			if err := checkTransferRef(name); err != nil {
+				return nil, fmt.Errorf("invalid ref advertised by '%s': %w", t.url, err)
+			}
+			if !fullHashPattern.MatchString(hash) {
+				return nil, fmt.Errorf("invalid ref advertisement from '%s'", t.url)
+			}
+			ad.Refs = append(ad.Refs, Ref{Name: name, Hash: hash})
+		}
+	}
+	return ad, nil
+}
+
+func (t *httpTransport) fetch(wants []string) error {
+	var body bytes.Buffer
+	for i, hash := range wants {
+		line := "want " + hash
+		if i == 0 {
+			line += " " + httpAgent
+		}
+		writePktLine(&body, line+"\n")
+	}
+	writeFlushPkt(&body)
+	// Offer every local ref so that the server leaves out what is already
+	// here.
+	refs, err := ListRefs("refs/")
+	if err != nil {
+		return err
+	}
+	for _, ref := range refs {
+		writePktLine(&body, "have "+ref.Hash+"\n")
+	}
+	writePktLine(&body, "done\n")
+
+	data, err := t.request(http.MethodPost, uploadPackService, body.Bytes())
+	if err != nil {
+		return err
+	}
+	r := bufio.NewReader(bytes.NewReader(data))
+	for {
+		payload, err := readPktLine(r)
+		if err != nil {
+			return err
+		}
+		line := strings.TrimSuffix(string(payload), "\n")
+		if msg, ok := strings.CutPrefix(line, "ERR "); ok {
+			return fmt.Errorf("remote error: %s", msg)
+		}
+		if line == "NAK" {
+			break
+		}
+		if !strings.HasPrefix(line, "ACK ") {
+			return fmt.Errorf("unexpected upload-pack response: %q", line)
+		}
+	}
+	_, err = readPack(r)
+	return err
+}
+
+func (t *httpTransport) push(updates []refUpdate) (map[string]string, error) {
+	ad, err := t.advertise(true)
+	if err != nil {
+		return nil, err
+	}
+	// The remote has everything reachable from its refs; only send what
+	// those refs, as far as they are known here, do not already cover.
+	var known []string
+	for _, ref := range ad.Refs {
+		if ObjectExists(ref.Hash) {
+			known = append(known, ref.Hash)
+		}
+	}
+	has, err := reachableObjects(known)
+	if err != nil {
+		return nil, err
+	}
+
+	var body bytes.Buffer
+	var tips []string
+	var pointers []lfsPointer
+	for i, update := range updates {
+		oldHash, newHash := update.Old, update.New
+		if oldHash == "" {
+			oldHash = zeroHash
+		}
+		if newHash == "" {
+			newHash = zeroHash
+		} else {
+			tips = append(tips, newHash)
+		}
+		line := oldHash + " " + newHash + " " + update.Ref
+		if i == 0 {
+			line += "\000report-status delete-refs " + httpAgent
+		}
+		writePktLine(&body, line+"\n")
+	}
+	writeFlushPkt(&body)
+	if len(tips) > 0 {
+		missing, err := missingObjects(tips, func(hash string) bool { return has[hash] })
+		if err != nil {
+			return nil, err
+		}
+		if err := writePack(&body, missing, ReadObjectContent); err != nil {
+			return nil, err
+		}
+		if pointers, err = lfsPointersIn(missing); err != nil {
+			return nil, err
+		}
+	}
+	// Large objects go over before any ref points to them.
+	if err := t.uploadLFS(pointers); err != nil {
+		return nil, err
+	}
+
+	data, err := t.request(http.MethodPost, receivePackService, body.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	lines, err := readPktLines(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("empty status report from '%s'", t.url)
+	}
+	if unpack := strings.TrimPrefix(lines[0], "unpack "); unpack != "ok" {
+		return nil, fmt.Errorf("remote unpack failed: %s", unpack)
+	}
+	refused := make(map[string]string)
+	for _, line := range lines[1:] {
+		if rest, ok := strings.CutPrefix(line, "ng "); ok {
+			ref, reason, _ := strings.Cut(rest, " ")
+			refused[ref] = reason
+		}
+	}
+	return refused, nil
+}
+
+// lfsObjectURL returns where the remote serves the large object oid.
+func (t *httpTransport) lfsObjectURL(oid string) string {
+	return t.url + "/lfs/objects/" + oid
+}
+
+func (t *httpTransport) fetchLFS(pointers []lfsPointer) error {
+	for _, pointer := range pointers {
+		response, err := t.client.Get(t.lfsObjectURL(pointer.OID))
+		if err != nil {
+			return fmt.Errorf("unable to access '%s': %w", t.url, err)
+		}
+		if response.StatusCode == http.StatusNotFound {
+			response.Body.Close()
+			fmt.Fprintf(os.Stderr, "warning: large object %s is missing from '%s'\n", pointer.OID, t.url)
+			continue
+		}
+		if response.StatusCode != http.StatusOK {
+			response.Body.Close()
+			return fmt.Errorf("unable to fetch large object %s from '%s': %s", pointer.OID, t.url, response.Status)
+		}
+		stored, err := storeLFSObject(RepoPath, response.Body)
+		response.Body.Close()
+		if err != nil {
+			return fmt.Errorf("error fetching large object %s: %w", pointer.OID, err)
+		}
+		if stored.OID != pointer.OID {
+			return fmt.Errorf("large object %s from '%s' is corrupt", pointer.OID, t.url)
+		}
+	}
+	return nil
+}
+
+// uploadLFS sends the remote the large objects pointers name that it does
+// not have yet.
+func (t *httpTransport) uploadLFS(pointers []lfsPointer) error {
+	for _, pointer := range pointers {
+		response, err := t.client.Head(t.lfsObjectURL(pointer.OID))
+		if err != nil {
+			return fmt.Errorf("unable to access '%s': %w", t.url, err)
+		}
+		response.Body.Close()
+		if response.StatusCode == http.StatusOK {
+			continue
+		}
+
+		file, err := os.Open(lfsObjectFile(RepoPath, pointer.OID))
+		if os.IsNotExist(err) {
+			fmt.Fprintf(os.Stderr, "warning: large object %s is missing here, not pushing it\n", pointer.OID)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		request, err := http.NewRequest(http.MethodPut, t.lfsObjectURL(pointer.OID), file)
+		if err != nil {
+			file.Close()
+			return err
+		}
+		request.ContentLength = pointer.Size
+		request.Header.Set("Content-Type", "application/octet-stream")
+		response, err = t.client.Do(request)
+		if err != nil {
+			return fmt.Errorf("unable to access '%s': %w", t.url, err)
+		}
+		message, _ := io.ReadAll(response.Body)
+		response.Body.Close()
+		if response.StatusCode != http.StatusOK {
+			return fmt.Errorf("unable to push large object %s to '%s': %s: %s", pointer.OID, t.url, response.Status, strings.TrimSpace(string(message)))
+		}
+	}
+	return nil
+}
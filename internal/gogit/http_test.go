@@ -0,0 +1,213 @@
+package gogit
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// chdir moves the test into dir until it ends.
+func chdir(t testing.TB, dir string) {
+	t.Helper()
+	old, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(old) })
+}
+
+// initTestRepo creates a repository in a new directory under root, with
+// the user's global config out of the way.
+func initTestRepo(t testing.TB, root, name string) string {
+	t.Helper()
+	t.Setenv("HOME", root)
+	dir := filepath.Join(root, name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := InitRepo(dir); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// commitFile writes and commits one file in the current repository and
+// returns the new HEAD.
+func commitFile(t testing.TB, name, content, message string) string {
+	t.Helper()
+	if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Add(name); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddCommit(&CommitOptions{Message: message}); err != nil {
+		t.Fatal(err)
+	}
+	_, head, err := ReadHead()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return head
+}
+
+// refIn reads a ref of the repository whose .gogit directory is repo.
+func refIn(t testing.TB, repo, name string) string {
+	t.Helper()
+	var hash string
+	err := withRepo(repo, func() error {
+		var err error
+		hash, err = ReadRef(name)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+// startTestServer serves a new repository with one commit and returns
+// its working tree and URL.
+func startTestServer(t *testing.T, root string, readOnly bool) (string, string) {
+	t.Helper()
+	dir := initTestRepo(t, root, "server")
+	chdir(t, dir)
+	commitFile(t, "README", "hello\n", "first")
+	server := httptest.NewServer(&HTTPServer{Dir: filepath.Join(dir, ".gogit"), ReadOnly: readOnly})
+	t.Cleanup(server.Close)
+	return dir, server.URL
+}
+
+func TestHTTPCloneFetchPush(t *testing.T) {
+	root := t.TempDir()
+	serverDir, url := startTestServer(t, root, false)
+	serverRepo := filepath.Join(serverDir, ".gogit")
+
+	chdir(t, root)
+	if err := Clone(url+"/project.git", "client"); err != nil {
+		t.Fatalf("clone: %v", err)
+	}
+	clientDir := filepath.Join(root, "client")
+	if content, err := os.ReadFile(filepath.Join(clientDir, "README")); err != nil || string(content) != "hello\n" {
+		t.Fatalf("cloned README = %q, %v", content, err)
+	}
+
+	chdir(t, clientDir)
+	pushed := commitFile(t, "feature.txt", "feature\n", "feature")
+	if err := Push(&PushOptions{Remote: "origin", Refspecs: []string{"main:refs/heads/feature"}}); err != nil {
+		t.Fatalf("push: %v", err)
+	}
+	if got := refIn(t, serverRepo, "refs/heads/feature"); got != pushed {
+		t.Fatalf("server feature = %q, want %q", got, pushed)
+	}
+
+	chdir(t, serverDir)
+	moved := commitFile(t, "second.txt", "second\n", "second")
+	chdir(t, clientDir)
+	if err := Fetch("origin", false); err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	if got, err := ReadRef("refs/remotes/origin/main"); err != nil || got != moved {
+		t.Fatalf("origin/main = %q, %v; want %q", got, err, moved)
+	}
+	if !ObjectExists(moved) {
+		t.Fatalf("fetched commit %s is missing", moved)
+	}
+}
+
+func TestHTTPReadOnlyRefusesPush(t *testing.T) {
+	root := t.TempDir()
+	serverDir, url := startTestServer(t, root, true)
+
+	chdir(t, root)
+	if err := Clone(url, "client"); err != nil {
+		t.Fatalf("clone: %v", err)
+	}
+	chdir(t, filepath.Join(root, "client"))
+	commitFile(t, "feature.txt", "feature\n", "feature")
+	if err := Push(&PushOptions{Remote: "origin", Refspecs: []string{"main:refs/heads/feature"}}); err == nil {
+		t.Fatal("push to a read-only server succeeded")
+	}
+	if got := refIn(t, filepath.Join(serverDir, ".gogit"), "refs/heads/feature"); got != "" {
+		t.Fatalf("server feature = %q after a refused push", got)
+	}
+}
+
+func TestHTTPPushRejectsInvalidRefs(t *testing.T) {
+	root := t.TempDir()
+	serverDir, url := startTestServer(t, root, false)
+	victim := filepath.Join(serverDir, "victim")
+	if err := os.WriteFile(victim, []byte("keep me\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	head := refIn(t, filepath.Join(serverDir, ".gogit"), "refs/heads/main")
+
+	var body bytes.Buffer
+	writePktLine(&body, head+" "+zeroHash+" ../victim\000report-status delete-refs\n")
+	writePktLine(&body, head+" "+zeroHash+" refs/heads/../../victim\n")
+	writeFlushPkt(&body)
+	response, err := http.Post(url+"/"+receivePackService, "application/x-git-receive-pack-request", &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	lines, err := readPktLines(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"unpack ok", "ng ../victim invalid ref name", "ng refs/heads/../../victim invalid ref name"}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("status report = %q, want %q", lines, want)
+	}
+	if _, err := os.Stat(victim); err != nil {
+		t.Fatalf("file outside the refs was touched: %v", err)
+	}
+
+	// A local push goes through the same checks.
+	var refused map[string]string
+	err = withRepo(filepath.Join(serverDir, ".gogit"), func() error {
+		var err error
+		refused, err = receiveUpdates([]refUpdate{{Ref: "refs/tags/../../victim", Old: head}})
+		return err
+	})
+	if err != nil || refused["refs/tags/../../victim"] != "invalid ref name" {
+		t.Fatalf("local update refused = %v, %v", refused, err)
+	}
+	if _, err := os.Stat(victim); err != nil {
+		t.Fatalf("file outside the refs was touched: %v", err)
+	}
+}
+
+func TestHTTPFetchRejectsInvalidAdvertisedRefs(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("HOME", root)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body bytes.Buffer
+		writePktLine(&body, "# service="+uploadPackService+"\n")
+		writeFlushPkt(&body)
+		writePktLine(&body, strings.Repeat("1", 40)+" refs/tags/../../../escaped\000"+httpAgent+"\n")
+		writeFlushPkt(&body)
+		w.Header().Set("Content-Type", "application/x-"+uploadPackService+"-advertisement")
+		w.Write(body.Bytes())
+	}))
+	defer server.Close()
+
+	chdir(t, root)
+	err := Clone(server.URL, "client")
+	if err == nil || !strings.Contains(err.Error(), "invalid ref") {
+		t.Fatalf("clone from a server advertising an invalid ref: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "escaped")); !os.IsNotExist(err) {
+		t.Fatalf("a ref was written outside the repository: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "client")); !os.IsNotExist(err) {
+		t.Fatalf("the failed clone left its directory behind: %v", err)
+	}
+}
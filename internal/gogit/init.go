@@ -4,8 +4,43 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 )
 
+// FindRepo selects the repository of the current directory: the .gogit
+// directory of a working tree, or the directory itself when it is a bare
+// repository.
+func FindRepo() {
+	if _, err := os.Stat(".gogit"); err == nil {
+		return
+	}
+	if !isRepoDir(".") {
+		return
+	}
+	saved := RepoPath
+	setRepoPath(".")
+	if bare, err := IsBareRepo(); err != nil || !bare {
+		setRepoPath(saved)
+	}
+}
+
+// IsBareRepo reports whether the current repository has no working tree.
+func IsBareRepo() (bool, error) {
+	return GetConfigBool("core.bare", false)
+}
+
+// RequireWorkTree fails in a bare repository.
+func RequireWorkTree() error {
+	bare, err := IsBareRepo()
+	if err != nil {
+		return err
+	}
+	if bare {
+		return fmt.Errorf("this operation must be run in a work tree")
+	}
+	return nil
+}
+
 // InitRepo contains the logic to initialize the repository directory structure.
 // It receives the path where the repository will be created.
 func InitRepo(path string) error {
@@ -19,6 +54,40 @@ func InitRepo(path string) error {
 
 	fmt.Printf("Initializing empty gogit repository in %s\n", repoPath)
 
+	if err := createRepo(repoPath, false); err != nil {
+		return err
+	}
+
+	// Create .gogitignore file
+	gogitignorePath := filepath.Join(path, ".gogitignore")
+	gogitignoreContent := []byte("")
+	if err := os.WriteFile(gogitignorePath, gogitignoreContent, 0644); err != nil {
+		return fmt.Errorf("error creating .gogitignore file: %w", err)
+	}
+
+	fmt.Println("Repository initialized successfully!")
+	return nil
+}
+
+// InitBareRepo creates a repository without a working tree: the objects,
+// refs and HEAD sit directly in path. Bare repositories are meant to be
+// pushed to and fetched from.
+func InitBareRepo(path string) error {
+	if isRepoDir(path) {
+		return fmt.Errorf("gogit repository already exists in %s", path)
+	}
+
+	fmt.Printf("Initializing empty bare gogit repository in %s\n", path)
+	if err := createRepo(path, true); err != nil {
+		return err
+	}
+	fmt.Println("Repository initialized successfully!")
+	return nil
+}
+
+// createRepo lays out the files of an empty repository in repoPath. A
+// bare repository has no index.
+func createRepo(repoPath string, bare bool) error {
 	// Create necessary directories
 	dirs := []string{"objects", "refs/heads"}
 	for _, dir := range dirs {
@@ -28,10 +97,20 @@ func InitRepo(path string) error {
 	}
 
 	// Create initial files like index
-	indexPath := filepath.Join(repoPath, "index")
-	indexContent := []byte("")
-	if err := os.WriteFile(indexPath, indexContent, 0644); err != nil {
-		return fmt.Errorf("error creating HEAD file: %w", err)
+	if !bare {
+		indexPath := filepath.Join(repoPath, "index")
+		indexContent := []byte("")
+		if err := os.WriteFile(indexPath, indexContent, 0644); err != nil {
+			return fmt.Errorf("error creating HEAD file: %w", err)
+		}
+	}
+
+	// Record whether the repository has a working tree
+	err := withRepo(repoPath, func() error {
+		return SetConfig("core.bare", strconv.FormatBool(bare), false)
+	})
+	if err != nil {
+		return err
 	}
 
 	// Create initial files like HEAD
@@ -50,13 +129,6 @@ func InitRepo(path string) error {
 		return fmt.Errorf("error creating main file: %w", err)
 	}
 
-	// Create .gogitignore file
-	gogitignorePath := filepath.Join(path, ".gogitignore")
-	gogitignoreContent := []byte("")
-	if err := os.WriteFile(gogitignorePath, gogitignoreContent, 0644); err != nil {
-		return fmt.Errorf("error creating .gogitignore file: %w", err)
-	}
-
-	fmt.Println("Repository initialized successfully!")
-	return nil
+	// Install sample hooks; they stay inactive until renamed
+	return installSampleHooks(repoPath)
 }
@@ -0,0 +1,423 @@
+package gogit
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Git and gogit name blobs identically, but their trees and commits
+// differ: Git nests trees per directory and records file modes, a
+// committer and a time zone, while a gogit tree lists every path of the
+// snapshot and a commit has one author and a UTC date. Import and export
+// therefore rewrite every commit, and the same history gets different
+// commit IDs on either side. Both conversions are deterministic, so
+// repeating one only adds what is new.
+
+// ImportGit copies the branches and tags of the Git repository at path
+// (a working tree, its .git directory or a bare repository) into the
+// current repository. Existing refs are only moved with force. When the
+// current branch has no commits yet, HEAD follows the Git repository's
+// and its files are checked out.
+func ImportGit(path string, force bool) error {
+	if !isRepoDir(RepoPath) {
+		return fmt.Errorf("not a gogit repository; run 'gogit init' first")
+	}
+	dir := path
+	if info, err := os.Stat(filepath.Join(path, ".git")); err == nil && info.IsDir() {
+		dir = filepath.Join(path, ".git")
+	}
+	repo, err := openGitRepo(dir)
+	if err != nil {
+		return err
+	}
+	defer repo.Close()
+
+	refs, gitHeadRef, _, err := repo.refs()
+	if err != nil {
+		return err
+	}
+	// Whether HEAD follows Git's is settled before the import creates the
+	// branch it may point to.
+	headRef, head, err := ReadHead()
+	if err != nil {
+		return err
+	}
+	importer := newGitImporter(repo)
+
+	fmt.Printf("From %s\n", path)
+	rejected := false
+	imported := make(map[string]bool)
+	for _, ref := range refs {
+		commit, err := peelGitTag(repo, ref.Hash)
+		if err != nil {
+			return fmt.Errorf("%s: %w", ref.Name, err)
+		}
+		hash, err := importer.commit(commit)
+		if err != nil {
+			return fmt.Errorf("%s: %w", ref.Name, err)
+		}
+		existing, err := ReadRef(ref.Name)
+		if err != nil {
+			return err
+		}
+		name := shortRefName(ref.Name)
+		switch {
+		case existing == hash:
+			imported[ref.Name] = true
+			continue
+		case existing == "" && strings.HasPrefix(ref.Name, "refs/tags/"):
+			printRefUpdate("*", "[new tag]", name, name, "")
+		case existing == "":
+			printRefUpdate("*", "[new branch]", name, name, "")
+		case force:
+			printRefUpdate("+", abbrevHash(existing)+"..."+abbrevHash(hash), name, name, "forced update")
+		default:
+			printRefUpdate("!", "[rejected]", name, name, "already exists")
+			rejected = true
+			continue
+		}
+		if err := UpdateRef(ref.Name, hash, "import: from "+path); err != nil {
+			return err
+		}
+		imported[ref.Name] = true
+	}
+	fmt.Printf("Imported %d commits.\n", len(importer.commits))
+
+	if err := followGitHead(headRef, head == "", gitHeadRef, imported, path); err != nil {
+		return err
+	}
+	if rejected {
+		return fmt.Errorf("some refs already exist; use --force to overwrite them")
+	}
+	return nil
+}
+
+// followGitHead moves HEAD, when it was on the unborn branch headRef
+// before the import, to the imported branch Git's HEAD pointed to, and
+// checks it out.
+func followGitHead(headRef string, unborn bool, gitHeadRef string, imported map[string]bool, path string) error {
+	if !unborn || !imported[gitHeadRef] {
+		return nil
+	}
+	if headRef != gitHeadRef {
+		// Drop the unborn branch the repository started on, unless the
+		// import brought a branch of that name.
+		if !imported[headRef] {
+			if err := os.Remove(filepath.Join(RepoPath, headRef)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		if err := AttachHead(gitHeadRef, "import: from "+path); err != nil {
+			return err
+		}
+	}
+	return checkoutImportedHead()
+}
+
+// checkoutImportedHead checks out the commit HEAD points to in a working
+// tree whose branch had no commits before an import.
+func checkoutImportedHead() error {
+	if bare, err := IsBareRepo(); err != nil || bare {
+		return err
+	}
+	commit, err := ResolveRevision("HEAD")
+	if err != nil {
+		return err
+	}
+	tree, err := commitTree(commit)
+	if err != nil {
+		return err
+	}
+	return checkoutTree(map[string]string{}, tree, false, "import")
+}
+
+// gitImporter converts Git commits, read from a Git repository or a
+// bundle, into gogit commits, remembering what it already converted.
+type gitImporter struct {
+	repo gitObjectReader
+	// commits maps Git commit IDs to gogit ones.
+	commits map[string]string
+	parsed  map[string]*gitCommit
+	trees   map[string][]gitTreeEntry
+	// warned records paths already warned about.
+	warned map[string]bool
+}
+
+func newGitImporter(repo gitObjectReader) *gitImporter {
+	return &gitImporter{
+		repo:    repo,
+		commits: make(map[string]string),
+		parsed:  make(map[string]*gitCommit),
+		trees:   make(map[string][]gitTreeEntry),
+	}
+}
+
+// commit imports a Git commit and its ancestry, parents first, and
+// returns the gogit commit ID. It walks with an explicit stack since
+// histories can be far deeper than the call stack should grow.
+func (imp *gitImporter) commit(hash string) (string, error) {
+	stack := []string{hash}
+	for len(stack) > 0 {
+		current := stack[len(stack)-1]
+		if _, done := imp.commits[current]; done {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		commit, err := imp.parse(current)
+		if err != nil {
+			return "", err
+		}
+		waiting := false
+		for _, parent := range commit.Parents {
+			if _, done := imp.commits[parent]; !done {
+				stack = append(stack, parent)
+				waiting = true
+			}
+		}
+		if waiting {
+			continue
+		}
+		stack = stack[:len(stack)-1]
+
+		files := make(map[string]string)
+		if err := imp.tree(commit.Tree, "", files); err != nil {
+			return "", err
+		}
+		var parents []string
+		for _, parent := range commit.Parents {
+			parents = append(parents, imp.commits[parent])
+		}
+		converted, err := storeCommit(files, parents, commit.Author, commit.Date, commit.Message)
+		if err != nil {
+			return "", err
+		}
+		imp.commits[current] = converted
+		delete(imp.parsed, current)
+	}
+	return imp.commits[hash], nil
+}
+
+func (imp *gitImporter) parse(hash string) (*gitCommit, error) {
+	if commit, ok := imp.parsed[hash]; ok {
+		return commit, nil
+	}
+	objectType, content, err := imp.repo.readObject(hash)
+	if err != nil {
+		return nil, err
+	}
+	if objectType != ObjectTypeCommit {
+		return nil, fmt.Errorf("object %s is a %s, not a commit", hash, objectType)
+	}
+	commit, err := parseGitCommit(content)
+	if err != nil {
+		return nil, fmt.Errorf("commit %s: %w", hash, err)
+	}
+	imp.parsed[hash] = commit
+	return commit, nil
+}
+
+// tree flattens a Git tree into files, copying the blobs gogit lacks.
+// gogit has no file modes, so executables and symbolic links come in as
+// plain files, with a warning; submodules are skipped.
+func (imp *gitImporter) tree(hash, prefix string, files map[string]string) error {
+	entries, ok := imp.trees[hash]
+	if !ok {
+		objectType, content, err := imp.repo.readObject(hash)
+		if err != nil {
+			return err
+		}
+		if objectType != ObjectTypeTree {
+			return fmt.Errorf("object %s is a %s, not a tree", hash, objectType)
+		}
+		if entries, err = parseGitTree(content); err != nil {
+			return fmt.Errorf("tree %s: %w", hash, err)
+		}
+		imp.trees[hash] = entries
+	}
+
+	for _, entry := range entries {
+		path := prefix + entry.Name
+		switch entry.Mode {
+		case gitModeTree:
+			if err := imp.tree(entry.Hash, path+"/", files); err != nil {
+				return err
+			}
+			continue
+		case gitModeSubmodule:
+			imp.warn(path, "warning: skipping submodule '%s'\n")
+			continue
+		case gitModeExecutable:
+			imp.warn(path, "warning: '%s' is executable in Git; it is imported as a plain file\n")
+		case gitModeSymlink:
+			imp.warn(path, "warning: '%s' is a symbolic link in Git; it is imported as a file holding its target\n")
+		}
+		if !ObjectExists(entry.Hash) {
+			_, content, err := imp.repo.readObject(entry.Hash)
+			if err != nil {
+				return err
+			}
+			if _, err := storeObject(ObjectTypeBlob, content); err != nil {
+				return err
+			}
+		}
+		files[path] = entry.Hash
+	}
+	return nil
+}
+
+// warn prints format with path once per import.
+func (imp *gitImporter) warn(path, format string) {
+	if imp.warned == nil {
+		imp.warned = make(map[string]bool)
+	}
+	if !imp.warned[path] {
+		fmt.Printf(format, path)
+		imp.warned[path] = true
+	}
+}
+
+// ExportGit writes the branches, tags and HEAD of the current repository
+// as a Git repository: into dir/.git, or into dir itself as a bare
+// repository when its name ends in ".git". Exporting again into the same
+// place adds the new commits and moves the refs.
+func ExportGit(dir string) error {
+	gitDir := filepath.Join(dir, ".git")
+	bare := strings.HasSuffix(filepath.Clean(dir), ".git")
+	if bare {
+		gitDir = dir
+	}
+	for _, sub := range []string{"objects", "refs/heads", "refs/tags"} {
+		if err := os.MkdirAll(filepath.Join(gitDir, sub), 0755); err != nil {
+			return fmt.Errorf("error creating directory %s: %w", sub, err)
+		}
+	}
+	configPath := filepath.Join(gitDir, "config")
+	if _, err := os.Stat(configPath); os.IsNotExist(err) {
+		config := fmt.Sprintf("[core]\n\trepositoryformatversion = 0\n\tfilemode = true\n\tbare = %t\n", bare)
+		if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+			return fmt.Errorf("error writing Git config: %w", err)
+		}
+	}
+
+	refs, err := listAdvertisedRefs()
+	if err != nil {
+		return err
+	}
+	exporter := newGitExporter(gitObjectDir(gitDir))
+	for _, ref := range refs {
+		hash, err := exporter.commit(ref.Hash)
+		if err != nil {
+			return fmt.Errorf("%s: %w", ref.Name, err)
+		}
+		path := filepath.Join(gitDir, filepath.FromSlash(ref.Name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("error creating directory %s: %w", filepath.Dir(path), err)
+		}
+		if err := os.WriteFile(path, []byte(hash+"\n"), 0644); err != nil {
+			return fmt.Errorf("error writing Git ref %s: %w", ref.Name, err)
+		}
+	}
+
+	headRef, head, err := ReadHead()
+	if err != nil {
+		return err
+	}
+	headContent := "ref: " + headRef + "\n"
+	if headRef == "" {
+		detached, err := exporter.commit(head)
+		if err != nil {
+			return err
+		}
+		headContent = detached + "\n"
+	}
+	if err := os.WriteFile(filepath.Join(gitDir, "HEAD"), []byte(headContent), 0644); err != nil {
+		return fmt.Errorf("error writing Git HEAD: %w", err)
+	}
+
+	fmt.Printf("Exported %d commits and %d refs to %s\n", len(exporter.commits), len(refs), gitDir)
+	if !bare {
+		fmt.Printf("hint: Git has no index for the working tree yet; run 'git reset' in %s\n", dir)
+		fmt.Println("hint: to build it, or 'git reset --hard' to also check out the files.")
+	}
+	return nil
+}
+
+// gitExporter converts gogit commits into Git commits, written to a Git
+// repository directory or a bundle.
+type gitExporter struct {
+	objects gitObjectWriter
+	// commits and trees map gogit IDs to Git ones.
+	commits map[string]string
+	trees   map[string]string
+}
+
+func newGitExporter(objects gitObjectWriter) *gitExporter {
+	return &gitExporter{objects: objects, commits: make(map[string]string), trees: make(map[string]string)}
+}
+
+// commit exports a gogit commit and its ancestry, parents first, and
+// returns the Git commit ID.
+func (exp *gitExporter) commit(hash string) (string, error) {
+	stack := []string{hash}
+	for len(stack) > 0 {
+		current := stack[len(stack)-1]
+		if _, done := exp.commits[current]; done {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		commit, err := ReadCommit(current)
+		if err != nil {
+			return "", err
+		}
+		waiting := false
+		for _, parent := range commit.Parents {
+			if _, done := exp.commits[parent]; !done {
+				stack = append(stack, parent)
+				waiting = true
+			}
+		}
+		if waiting {
+			continue
+		}
+		stack = stack[:len(stack)-1]
+
+		tree, err := exp.tree(commit.Tree)
+		if err != nil {
+			return "", err
+		}
+		var parents []string
+		for _, parent := range commit.Parents {
+			parents = append(parents, exp.commits[parent])
+		}
+		content := gitCommitContent(tree, parents, commit.Author, commit.Date, commit.Message)
+		if exp.commits[current], err = exp.objects.writeObject(ObjectTypeCommit, content); err != nil {
+			return "", err
+		}
+	}
+	return exp.commits[hash], nil
+}
+
+// tree exports a gogit tree and the blobs it lists, and returns the Git
+// root tree ID.
+func (exp *gitExporter) tree(hash string) (string, error) {
+	if converted, ok := exp.trees[hash]; ok {
+		return converted, nil
+	}
+	files, err := ReadTree(hash)
+	if err != nil {
+		return "", err
+	}
+	for _, blob := range files {
+		if err := exp.objects.copyBlob(blob); err != nil {
+			return "", err
+		}
+	}
+	converted, err := gitTreeFromFiles(exp.objects, files)
+	if err != nil {
+		return "", err
+	}
+	exp.trees[hash] = converted
+	return converted, nil
+}
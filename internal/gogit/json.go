@@ -0,0 +1,386 @@
+package gogit
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// The types below are the documents written in --json mode. Their field
+// names are a public interface described in README.md: fields may be
+// added, but existing ones must not change meaning or disappear.
+
+// IdentityJSON is an author or committer.
+type IdentityJSON struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// CommitJSON describes a commit, as emitted by `log` (one per line) and
+// embedded in `show`.
+type CommitJSON struct {
+	Hash    string       `json:"hash"`
+	Tree    string       `json:"tree"`
+	Parents []string     `json:"parents"`
+	Author  IdentityJSON `json:"author"`
+	Date    string       `json:"date"`
+	Subject string       `json:"subject"`
+	Body    string       `json:"body"`
+	Message string       `json:"message"`
+}
+
+// ChangeJSON is a file changed by a commit.
+type ChangeJSON struct {
+	Status   string `json:"status"`
+	Path     string `json:"path"`
+	OrigPath string `json:"origPath,omitempty"`
+	OldHash  string `json:"oldHash,omitempty"`
+	NewHash  string `json:"newHash,omitempty"`
+}
+
+// ShowJSON is the document written by `show` for a commit.
+type ShowJSON struct {
+	Type    string       `json:"type"`
+	Commit  CommitJSON   `json:"commit"`
+	Changes []ChangeJSON `json:"changes"`
+}
+
+// StatusEntryJSON is one path reported by `status`.
+type StatusEntryJSON struct {
+	Path      string `json:"path"`
+	OrigPath  string `json:"origPath,omitempty"`
+	Index     string `json:"index"`
+	Worktree  string `json:"worktree"`
+	HeadHash  string `json:"headHash,omitempty"`
+	IndexHash string `json:"indexHash,omitempty"`
+	Conflict  string `json:"conflict,omitempty"`
+}
+
+// StatusJSON is the document written by `status`.
+type StatusJSON struct {
+	Branch    string            `json:"branch"`
+	Head      string            `json:"head"`
+	Clean     bool              `json:"clean"`
+	Operation string            `json:"operation,omitempty"`
+	Entries   []StatusEntryJSON `json:"entries"`
+}
+
+// BranchJSON is one entry of `branch`.
+type BranchJSON struct {
+	Name    string `json:"name"`
+	Hash    string `json:"hash"`
+	Current bool   `json:"current"`
+}
+
+// RefJSON is a named ref, used by `tag`.
+type RefJSON struct {
+	Name string `json:"name"`
+	Hash string `json:"hash"`
+}
+
+// TreeEntryJSON is one entry of a tree object.
+type TreeEntryJSON struct {
+	Mode string `json:"mode"`
+	Type string `json:"type"`
+	Hash string `json:"hash"`
+	Path string `json:"path"`
+}
+
+// ObjectJSON describes an object for `cat-file` and for `show` of trees
+// and blobs. Blob content is returned as text when it is valid UTF-8 and
+// as contentBase64 otherwise.
+type ObjectJSON struct {
+	Type          string          `json:"type"`
+	Hash          string          `json:"hash"`
+	Size          int             `json:"size"`
+	Content       *string         `json:"content,omitempty"`
+	ContentBase64 string          `json:"contentBase64,omitempty"`
+	Entries       []TreeEntryJSON `json:"entries,omitempty"`
+	Commit        *CommitJSON     `json:"commit,omitempty"`
+}
+
+// IndexEntryJSON is one entry of `ls-files`.
+type IndexEntryJSON struct {
+	Mode string `json:"mode"`
+	Hash string `json:"hash"`
+	Path string `json:"path"`
+}
+
+// RevisionJSON is one resolved revision from `rev-parse`. Name is set
+// instead of Hash with --abbrev-ref.
+type RevisionJSON struct {
+	Rev  string `json:"rev"`
+	Hash string `json:"hash,omitempty"`
+	Name string `json:"name,omitempty"`
+}
+
+// HashObjectJSON is one result of `hash-object`.
+type HashObjectJSON struct {
+	Path string `json:"path"`
+	Hash string `json:"hash"`
+}
+
+// ConfigEntryJSON is one option from `config`.
+type ConfigEntryJSON struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// ReflogEntryJSON is one entry of `reflog`; entry n of the list is
+// <ref>@{n}.
+type ReflogEntryJSON struct {
+	Old     string `json:"old,omitempty"`
+	New     string `json:"new"`
+	Date    string `json:"date"`
+	Message string `json:"message"`
+}
+
+// RemoteJSON is one entry of `remote`.
+type RemoteJSON struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// StashJSON is one entry of `stash list`.
+type StashJSON struct {
+	Name    string `json:"name"`
+	Hash    string `json:"hash"`
+	Message string `json:"message"`
+}
+
+// BlameLineJSON is one line of `blame`.
+type BlameLineJSON struct {
+	Commit    string       `json:"commit"`
+	Author    IdentityJSON `json:"author"`
+	Date      string       `json:"date"`
+	Summary   string       `json:"summary"`
+	OrigLine  int          `json:"origLine"`
+	FinalLine int          `json:"finalLine"`
+	Content   string       `json:"content"`
+	Boundary  bool         `json:"boundary,omitempty"`
+}
+
+// ErrorJSON is written to stderr when a command fails in --json mode.
+type ErrorJSON struct {
+	Error string `json:"error"`
+}
+
+// WriteJSON writes v as a single line of JSON.
+func WriteJSON(w io.Writer, v any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return fmt.Errorf("error encoding JSON: %w", err)
+	}
+	return nil
+}
+
+// Name returns the JSON spelling of a status code.
+func (c StatusCode) Name() string {
+	switch c {
+	case StatusModified:
+		return "modified"
+	case StatusAdded:
+		return "added"
+	case StatusDeleted:
+		return "deleted"
+	case StatusRenamed:
+		return "renamed"
+	case StatusUnmerged:
+		return "unmerged"
+	case StatusUntracked:
+		return "untracked"
+	case StatusIgnored:
+		return "ignored"
+	}
+	return "unmodified"
+}
+
+// NewCommitJSON converts a commit to its JSON document.
+func NewCommitJSON(commit *Commit) CommitJSON {
+	name, email := splitAuthor(commit.Author)
+	subject, body := splitMessage(commit.Message)
+	parents := append([]string{}, commit.Parents...)
+	return CommitJSON{
+		Hash:    commit.Hash,
+		Tree:    commit.Tree,
+		Parents: parents,
+		Author:  IdentityJSON{Name: name, Email: email},
+		Date:    commit.Date.Format(time.RFC3339),
+		Subject: subject,
+		Body:    body,
+		Message: commit.Message,
+	}
+}
+
+// NewChangesJSON converts tree changes to their JSON documents.
+func NewChangesJSON(changes []TreeChange) []ChangeJSON {
+	result := make([]ChangeJSON, 0, len(changes))
+	for _, change := range changes {
+		result = append(result, ChangeJSON{
+			Status:   change.Status.Name(),
+			Path:     change.Path,
+			OrigPath: change.OrigPath,
+			OldHash:  change.OldHash,
+			NewHash:  change.NewHash,
+		})
+	}
+	return result
+}
+
+// NewStatusJSON converts a StatusInfo to its JSON document.
+func NewStatusJSON(statusInfo *StatusInfo) StatusJSON {
+	doc := StatusJSON{
+		Branch:    statusInfo.Branch,
+		Head:      statusInfo.Head,
+		Clean:     true,
+		Operation: statusInfo.Operation,
+		Entries:   []StatusEntryJSON{},
+	}
+	for _, entry := range statusInfo.Entries {
+		if entry.Index != StatusIgnored {
+			doc.Clean = false
+		}
+		entryDoc := StatusEntryJSON{
+			Path:      entry.Path,
+			OrigPath:  entry.OrigPath,
+			Index:     entry.Index.Name(),
+			Worktree:  entry.Worktree.Name(),
+			HeadHash:  entry.HeadHash,
+			IndexHash: entry.IndexHash,
+		}
+		if entry.Conflict != nil {
+			entryDoc.Conflict = entry.Conflict.Describe()
+		}
+		doc.Entries = append(doc.Entries, entryDoc)
+	}
+	return doc
+}
+
+// NewObjectJSON describes the object hash.
+func NewObjectJSON(hash string) (any, error) {
+	objectType, content, err := ReadObjectContent(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := ObjectJSON{Type: objectType, Hash: hash, Size: len(content)}
+	switch objectType {
+	case ObjectTypeBlob:
+		if utf8.Valid(content) {
+			text := string(content)
+			doc.Content = &text
+		} else {
+			doc.ContentBase64 = base64.StdEncoding.EncodeToString(content)
+		}
+	case ObjectTypeTree:
+		tree, err := parseTree(content)
+		if err != nil {
+			return nil, err
+		}
+		doc.Entries = NewTreeEntriesJSON(tree)
+	case ObjectTypeCommit:
+		commit, err := parseCommit(hash, content)
+		if err != nil {
+			return nil, err
+		}
+		commitDoc := NewCommitJSON(commit)
+		doc.Commit = &commitDoc
+	}
+	return doc, nil
+}
+
+// NewShowJSON describes the object named by rev the way `show` does:
+// commits with their changes, other objects like `cat-file`.
+func NewShowJSON(rev string) (any, error) {
+	hash, err := ResolveRevision(rev)
+	if err != nil {
+		return nil, err
+	}
+	objectType, content, err := ReadObjectContent(hash)
+	if err != nil {
+		return nil, err
+	}
+	if objectType != ObjectTypeCommit {
+		return NewObjectJSON(hash)
+	}
+
+	commit, err := parseCommit(hash, content)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := CommitChanges(commit)
+	if err != nil {
+		return nil, err
+	}
+	return ShowJSON{
+		Type:    ObjectTypeCommit,
+		Commit:  NewCommitJSON(commit),
+		Changes: NewChangesJSON(changes),
+	}, nil
+}
+
+// NewTreeEntriesJSON converts a path -> blob hash map to sorted entries.
+func NewTreeEntriesJSON(tree map[string]string) []TreeEntryJSON {
+	entries := make([]TreeEntryJSON, 0, len(tree))
+	for _, path := range sortedKeys(tree) {
+		entries = append(entries, TreeEntryJSON{Mode: "100644", Type: ObjectTypeBlob, Hash: tree[path], Path: path})
+	}
+	return entries
+}
+
+// NewReflogJSON converts reflog entries to their JSON documents.
+func NewReflogJSON(entries []ReflogEntry) []ReflogEntryJSON {
+	docs := []ReflogEntryJSON{}
+	for _, entry := range entries {
+		docs = append(docs, ReflogEntryJSON{
+			Old:     entry.Old,
+			New:     entry.New,
+			Date:    entry.Time.Format(time.RFC3339),
+			Message: entry.Message,
+		})
+	}
+	return docs
+}
+
+// NewStashesJSON converts stash entries to their JSON documents.
+func NewStashesJSON(stashes []StashEntry) []StashJSON {
+	docs := []StashJSON{}
+	for _, stash := range stashes {
+		docs = append(docs, StashJSON{Name: stash.Name, Hash: stash.Hash, Message: stash.Message})
+	}
+	return docs
+}
+
+// NewBlameJSON converts blamed lines to their JSON documents.
+func NewBlameJSON(lines []BlameLine) []BlameLineJSON {
+	docs := []BlameLineJSON{}
+	for _, line := range lines {
+		name, email := splitAuthor(line.Commit.Author)
+		subject, _ := splitMessage(line.Commit.Message)
+		docs = append(docs, BlameLineJSON{
+			Commit:    line.Commit.Hash,
+			Author:    IdentityJSON{Name: name, Email: email},
+			Date:      line.Commit.Date.Format(time.RFC3339),
+			Summary:   subject,
+			OrigLine:  line.OrigLine,
+			FinalLine: line.FinalLine,
+			Content:   strings.TrimSuffix(line.Text, "\n"),
+			Boundary:  line.Boundary,
+		})
+	}
+	return docs
+}
+
+// NewRemotesJSON converts remotes to their JSON documents.
+func NewRemotesJSON(remotes []Remote) []RemoteJSON {
+	docs := []RemoteJSON{}
+	for _, remote := range remotes {
+		docs = append(docs, RemoteJSON{Name: remote.Name, URL: remote.URL})
+	}
+	return docs
+}
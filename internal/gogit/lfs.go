@@ -0,0 +1,587 @@
+package gogit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Large files, those whose path has the filter=lfs attribute, are kept
+// out of the object store. Staging one stores its content under
+// .gogit/lfs/objects, named by its SHA-256, and stages a small pointer
+// blob in Git LFS's format instead:
+//
+//	version https://git-lfs.github.com/spec/v1
+//	oid sha256:<hex>
+//	size <bytes>
+//
+// Checking the file out puts the content back. Fetch and push move the
+// large objects that the commits they transfer point to.
+
+const lfsPointerVersion = "https://git-lfs.github.com/spec/v1"
+
+// lfsPointerMaxSize bounds the size of a pointer; larger blobs are never
+// taken for one.
+const lfsPointerMaxSize = 1024
+
+var lfsOIDPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// lfsPointer names a large object by its SHA-256 and size.
+type lfsPointer struct {
+	OID  string
+	Size int64
+}
+
+// bytes returns the pointer as it is stored in a blob.
+func (p lfsPointer) bytes() []byte {
+	return []byte(fmt.Sprintf("version %s\noid sha256:%s\nsize %d\n", lfsPointerVersion, p.OID, p.Size))
+}
+
+// parseLFSPointer reads a pointer blob, reporting whether data is one.
+func parseLFSPointer(data []byte) (lfsPointer, bool) {
+	if len(data) > lfsPointerMaxSize || !bytes.HasPrefix(data, []byte("version "+lfsPointerVersion+"\n")) {
+		return lfsPointer{}, false
+	}
+	var pointer lfsPointer
+	sizeSeen := false
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		key, value, _ := strings.Cut(scanner.Text(), " ")
+		switch key {
+		case "oid":
+			pointer.OID = strings.TrimPrefix(value, "sha256:")
+		case "size":
+			size, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || size < 0 {
+				return lfsPointer{}, false
+			}
+			pointer.Size, sizeSeen = size, true
+		}
+	}
+	if !lfsOIDPattern.MatchString(pointer.OID) || !sizeSeen {
+		return lfsPointer{}, false
+	}
+	return pointer, true
+}
+
+// lfsObjectFile returns where repository dir keeps the large object oid.
+func lfsObjectFile(dir, oid string) string {
+	return filepath.Join(dir, "lfs", "objects", oid[:2], oid[2:4], oid)
+}
+
+// hasLFSObject reports whether repository dir has the large object oid.
+func hasLFSObject(dir, oid string) bool {
+	_, err := os.Stat(lfsObjectFile(dir, oid))
+	return err == nil
+}
+
+// storeLFSObject streams r into the large object store of repository dir
+// and returns its pointer. The content goes through a temporary file that
+// is renamed into place once its SHA-256 is known.
+func storeLFSObject(dir string, r io.Reader) (lfsPointer, error) {
+	tempDir := filepath.Join(dir, "lfs", "tmp")
+	if err := os.MkdirAll(tempDir, 0755); err != nil {
+		return lfsPointer{}, fmt.Errorf("error creating directory %s: %w", tempDir, err)
+	}
+	temp, err := os.CreateTemp(tempDir, "object_")
+	if err != nil {
+		return lfsPointer{}, err
+	}
+	defer os.Remove(temp.Name())
+
+	digest := sha256.New()
+	size, err := io.Copy(io.MultiWriter(temp, digest), r)
+	if err == nil {
+		err = temp.Sync()
+	}
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return lfsPointer{}, err
+	}
+
+	pointer := lfsPointer{OID: hex.EncodeToString(digest.Sum(nil)), Size: size}
+	path := lfsObjectFile(dir, pointer.OID)
+	if hasLFSObject(dir, pointer.OID) {
+		return pointer, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return lfsPointer{}, fmt.Errorf("error creating directory %s: %w", filepath.Dir(path), err)
+	}
+	if err := os.Chmod(temp.Name(), 0644); err != nil {
+		return lfsPointer{}, err
+	}
+	if err := os.Rename(temp.Name(), path); err != nil {
+		return lfsPointer{}, err
+	}
+	return pointer, nil
+}
+
+// cleanLFS turns the size bytes of a large file into the pointer to
+// stage, storing the content when store is set. A file that already holds
+// a pointer, as when its object was never fetched, is staged as it is.
+func cleanLFS(r io.Reader, size int64, store bool) ([]byte, error) {
+	if size <= lfsPointerMaxSize {
+		content, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := parseLFSPointer(content); ok {
+			return content, nil
+		}
+		r = bytes.NewReader(content)
+	}
+
+	var pointer lfsPointer
+	if store {
+		var err error
+		if pointer, err = storeLFSObject(RepoPath, r); err != nil {
+			return nil, err
+		}
+	} else {
+		digest := sha256.New()
+		n, err := io.Copy(digest, r)
+		if err != nil {
+			return nil, err
+		}
+		pointer = lfsPointer{OID: hex.EncodeToString(digest.Sum(nil)), Size: n}
+	}
+	if pointer.Size != size {
+		return nil, fmt.Errorf("expected %d bytes, read %d; did the file change while it was read?", size, pointer.Size)
+	}
+	return pointer.bytes(), nil
+}
+
+// smudgeLFS opens the large object a pointer blob names, for writing to
+// path. Without the object the pointer itself is checked out, with a
+// warning.
+func smudgeLFS(path string, content []byte) (io.ReadCloser, int64, error) {
+	pointer, ok := parseLFSPointer(content)
+	if !ok {
+		return io.NopCloser(bytes.NewReader(content)), int64(len(content)), nil
+	}
+	file, err := os.Open(lfsObjectFile(RepoPath, pointer.OID))
+	if os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "warning: large file %s (%s) is not available here; checking out its pointer\n"+
+			"hint: fetch it from a remote that has it, then run 'gogit lfs checkout'\n", path, abbrevHash(pointer.OID))
+		return io.NopCloser(bytes.NewReader(content)), int64(len(content)), nil
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("error reading large object %s: %w", pointer.OID, err)
+	}
+	return file, pointer.Size, nil
+}
+
+// lfsPointersIn returns the large objects that the pointer blobs among
+// hashes name, once each.
+func lfsPointersIn(hashes []string) ([]lfsPointer, error) {
+	var pointers []lfsPointer
+	seen := make(map[string]bool)
+	for _, hash := range hashes {
+		pointer, ok, err := readLFSPointer(hash)
+		if err != nil {
+			return nil, err
+		}
+		if ok && !seen[pointer.OID] {
+			seen[pointer.OID] = true
+			pointers = append(pointers, pointer)
+		}
+	}
+	return pointers, nil
+}
+
+// readLFSPointer reads the object hash as a pointer blob, if it is one.
+func readLFSPointer(hash string) (lfsPointer, bool, error) {
+	object, err := openObject(hash)
+	if err != nil {
+		return lfsPointer{}, false, err
+	}
+	defer object.Close()
+	r := bufio.NewReader(object)
+	if prefix, _ := r.Peek(len("blob ")); string(prefix) != "blob " {
+		return lfsPointer{}, false, nil
+	}
+	header, err := r.ReadString(0)
+	if err != nil {
+		return lfsPointer{}, false, fmt.Errorf("error reading object %s: %w", hash, err)
+	}
+	size, err := strconv.ParseInt(strings.TrimSuffix(header[len("blob "):], "\x00"), 10, 64)
+	if err != nil || size > lfsPointerMaxSize {
+		return lfsPointer{}, false, nil
+	}
+	content, err := io.ReadAll(io.LimitReader(r, size))
+	if err != nil {
+		return lfsPointer{}, false, fmt.Errorf("error reading object %s: %w", hash, err)
+	}
+	pointer, ok := parseLFSPointer(content)
+	return pointer, ok, nil
+}
+
+// lfsPointersAt returns the large objects the trees of commits point to.
+func lfsPointersAt(commits []string) ([]lfsPointer, error) {
+	var blobs []string
+	for _, commit := range commits {
+		files, err := commitTree(commit)
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range sortedKeys(files) {
+			blobs = append(blobs, files[path])
+		}
+	}
+	return lfsPointersIn(blobs)
+}
+
+// missingLFSObjects returns the pointers whose objects repository dir
+// lacks.
+func missingLFSObjects(dir string, pointers []lfsPointer) []lfsPointer {
+	var missing []lfsPointer
+	for _, pointer := range pointers {
+		if !hasLFSObject(dir, pointer.OID) {
+			missing = append(missing, pointer)
+		}
+	}
+	return missing
+}
+
+// copyLFSObjects copies large objects from repository from to repository
+// to, checking their content on the way. Objects from lacks are left out
+// with a warning.
+func copyLFSObjects(from, to string, pointers []lfsPointer) error {
+	for _, pointer := range missingLFSObjects(to, pointers) {
+		file, err := os.Open(lfsObjectFile(from, pointer.OID))
+		if os.IsNotExist(err) {
+			fmt.Fprintf(os.Stderr, "warning: large object %s is missing from %s\n", pointer.OID, from)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		stored, err := storeLFSObject(to, file)
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("error copying large object %s: %w", pointer.OID, err)
+		}
+		if stored.OID != pointer.OID {
+			return fmt.Errorf("large object %s is corrupt in %s", pointer.OID, from)
+		}
+	}
+	return nil
+}
+
+// LFSTrack marks paths matching patterns as large files in the top-level
+// .gogitattributes, or lists the patterns already marked when there are
+// none.
+func LFSTrack(patterns []string) error {
+	content, err := os.ReadFile(attributesFile)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error reading %s: %w", attributesFile, err)
+	}
+	tracked := make(map[string]bool)
+	var order []string
+	for _, rule := range parseAttributes("", content) {
+		for _, attr := range rule.attrs {
+			if attr == "filter=lfs" && !tracked[rule.pattern] {
+				tracked[rule.pattern] = true
+				order = append(order, rule.pattern)
+			}
+		}
+	}
+
+	if len(patterns) == 0 {
+		fmt.Println("Listing tracked patterns")
+		for _, pattern := range order {
+			fmt.Printf("    %s (%s)\n", pattern, attributesFile)
+		}
+		return nil
+	}
+
+	var added strings.Builder
+	for _, pattern := range patterns {
+		if tracked[pattern] {
+			fmt.Printf("\"%s\" already supported\n", pattern)
+			continue
+		}
+		tracked[pattern] = true
+		fmt.Fprintf(&added, "%s filter=lfs\n", pattern)
+		fmt.Printf("Tracking \"%s\"\n", pattern)
+	}
+	if added.Len() == 0 {
+		return nil
+	}
+	if len(content) > 0 && !bytes.HasSuffix(content, []byte("\n")) {
+		content = append(content, '\n')
+	}
+	content = append(content, added.String()...)
+	if err := os.WriteFile(attributesFile, content, 0644); err != nil {
+		return fmt.Errorf("error writing %s: %w", attributesFile, err)
+	}
+	return nil
+}
+
+// LFSLsFiles lists the large files in the tree of rev: the start of each
+// object's SHA-256, "*" if the object is here or "-" if only its pointer
+// is, and the path.
+func LFSLsFiles(rev string) error {
+	hash, err := ResolveRevision(rev)
+	if err != nil {
+		return err
+	}
+	files, err := commitTree(hash)
+	if err != nil {
+		return err
+	}
+	for _, path := range sortedKeys(files) {
+		pointer, ok, err := readLFSPointer(files[path])
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		marker := "-"
+		if hasLFSObject(RepoPath, pointer.OID) {
+			marker = "*"
+		}
+		fmt.Printf("%s %s %s\n", pointer.OID[:10], marker, quotePath(path))
+	}
+	return nil
+}
+
+// LFSCheckout replaces the large files of the index that are checked out
+// as their pointers with their content, once it is here.
+func LFSCheckout() error {
+	indexEntries, err := ReadIndex()
+	if err != nil {
+		return err
+	}
+	c, err := worktreeConverter()
+	if err != nil {
+		return err
+	}
+	defer c.close()
+	checkedOut := 0
+	for _, path := range sortedKeys(indexEntries) {
+		pointer, ok, err := readLFSPointer(indexEntries[path])
+		if err != nil {
+			return err
+		}
+		if !ok || !hasLFSObject(RepoPath, pointer.OID) {
+			continue
+		}
+		content, err := os.ReadFile(filepath.FromSlash(path))
+		if err != nil || len(content) > lfsPointerMaxSize {
+			continue
+		}
+		if current, ok := parseLFSPointer(content); !ok || current != pointer {
+			continue
+		}
+		if err := c.writeFile(filepath.FromSlash(path), indexEntries[path]); err != nil {
+			return err
+		}
+		checkedOut++
+	}
+	fmt.Printf("Checked out %d large file(s)\n", checkedOut)
+	return nil
+}
+
+// listLFSObjects returns the SHA-256 of every large object stored here.
+func listLFSObjects() ([]string, error) {
+	root := filepath.Join(RepoPath, "lfs", "objects")
+	var oids []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == root {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if !d.IsDir() && lfsOIDPattern.MatchString(d.Name()) {
+			oids = append(oids, d.Name())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing large objects: %w", err)
+	}
+	return oids, nil
+}
+
+// LFSPrune deletes the large objects nothing here needs any more. Objects
+// are kept if the index, the tree of any ref or HEAD, or any commit not
+// yet on a remote-tracking branch points to them, so that nothing is
+// deleted that cannot be fetched again.
+func LFSPrune(dryRun bool) error {
+	oids, err := listLFSObjects()
+	if err != nil {
+		return err
+	}
+
+	var blobs []string
+	if bare, err := IsBareRepo(); err != nil {
+		return err
+	} else if !bare {
+		indexEntries, err := ReadIndex()
+		if err != nil {
+			return err
+		}
+		for _, path := range sortedKeys(indexEntries) {
+			blobs = append(blobs, indexEntries[path])
+		}
+	}
+	refs, err := ListRefs("refs/")
+	if err != nil {
+		return err
+	}
+	var tips, pushed []string
+	for _, ref := range refs {
+		tips = append(tips, ref.Hash)
+		if strings.HasPrefix(ref.Name, "refs/remotes/") {
+			pushed = append(pushed, ref.Hash)
+		}
+	}
+	if _, head, err := ReadHead(); err != nil {
+		return err
+	} else if head != "" {
+		tips = append(tips, head)
+	}
+	for _, tip := range tips {
+		files, err := commitTree(tip)
+		if err != nil {
+			return err
+		}
+		for _, path := range sortedKeys(files) {
+			blobs = append(blobs, files[path])
+		}
+	}
+	onRemote, err := reachableObjects(pushed)
+	if err != nil {
+		return err
+	}
+	unpushed, err := missingObjects(tips, func(hash string) bool { return onRemote[hash] })
+	if err != nil {
+		return err
+	}
+	pointers, err := lfsPointersIn(append(blobs, unpushed...))
+	if err != nil {
+		return err
+	}
+	retained := make(map[string]bool, len(pointers))
+	for _, pointer := range pointers {
+		retained[pointer.OID] = true
+	}
+
+	var doomed []string
+	var size int64
+	for _, oid := range oids {
+		if retained[oid] {
+			continue
+		}
+		doomed = append(doomed, oid)
+		if info, err := os.Stat(lfsObjectFile(RepoPath, oid)); err == nil {
+			size += info.Size()
+		}
+	}
+	fmt.Printf("prune: %d local object(s), %d retained\n", len(oids), len(oids)-len(doomed))
+	if len(doomed) == 0 {
+		return nil
+	}
+	if dryRun {
+		fmt.Printf("prune: %d object(s) would be deleted (%d bytes)\n", len(doomed), size)
+		for _, oid := range doomed {
+			fmt.Printf(" * %s\n", oid)
+		}
+		return nil
+	}
+	for _, oid := range doomed {
+		if err := os.Remove(lfsObjectFile(RepoPath, oid)); err != nil {
+			return fmt.Errorf("error deleting large object %s: %w", oid, err)
+		}
+	}
+	fmt.Printf("prune: deleted %d object(s) (%d bytes)\n", len(doomed), size)
+	return nil
+}
+
+// LFSFsck checks every large object stored here against its SHA-256,
+// moving corrupt ones to .gogit/lfs/bad, and checks that the large files
+// of HEAD and the index have their objects.
+func LFSFsck() error {
+	oids, err := listLFSObjects()
+	if err != nil {
+		return err
+	}
+	problems := 0
+	for _, oid := range oids {
+		path := lfsObjectFile(RepoPath, oid)
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		digest := sha256.New()
+		_, err = io.Copy(digest, file)
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("error reading large object %s: %w", oid, err)
+		}
+		if hex.EncodeToString(digest.Sum(nil)) == oid {
+			continue
+		}
+		problems++
+		bad := filepath.Join(RepoPath, "lfs", "bad", oid)
+		if err := os.MkdirAll(filepath.Dir(bad), 0755); err != nil {
+			return err
+		}
+		if err := os.Rename(path, bad); err != nil {
+			return err
+		}
+		fmt.Printf("corrupt object: %s, moved to %s\n", oid, bad)
+	}
+
+	files := make(map[string]string)
+	if _, head, err := ReadHead(); err != nil {
+		return err
+	} else if head != "" {
+		if files, err = commitTree(head); err != nil {
+			return err
+		}
+	}
+	if bare, err := IsBareRepo(); err != nil {
+		return err
+	} else if !bare {
+		indexEntries, err := ReadIndex()
+		if err != nil {
+			return err
+		}
+		for path, hash := range indexEntries {
+			if files[path] != hash {
+				files[path+" (staged)"] = hash
+			}
+		}
+	}
+	for _, path := range sortedKeys(files) {
+		pointer, ok, err := readLFSPointer(files[path])
+		if err != nil {
+			return err
+		}
+		if ok && !hasLFSObject(RepoPath, pointer.OID) {
+			problems++
+			fmt.Printf("missing object: %s (%s)\n", path, pointer.OID)
+		}
+	}
+
+	if problems > 0 {
+		return fmt.Errorf("lfs fsck found %d problem(s)", problems)
+	}
+	fmt.Println("lfs fsck OK")
+	return nil
+}
@@ -0,0 +1,190 @@
+package gogit
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// Files other processes may be reading, such as the index and refs, are
+// never written in place. The new content goes to "<file>.lock", created
+// exclusively so that only one process can be changing the file at a
+// time, and is synced to disk and renamed over the file once complete. A
+// crash leaves the old file intact; a second gogit process finds the lock
+// and stops instead of racing the first.
+
+// lockFile is a held lock on path, open for writing its new content.
+type lockFile struct {
+	path string
+	file *os.File
+}
+
+var (
+	heldLocksMu   sync.Mutex
+	heldLocks     = make(map[*lockFile]bool)
+	lockSignalsOn sync.Once
+)
+
+// lockPath takes the lock on path, failing if another process holds it.
+func lockPath(path string) (*lockFile, error) {
+	lockName := path + ".lock"
+	if err := createLockDir(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(lockName, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		if os.IsExist(err) {
+			return nil, fmt.Errorf("unable to create '%s': File exists.\n\n"+
+				"Another gogit process seems to be running in this repository. Please make\n"+
+				"sure all processes are terminated, then try again. If it still fails, a\n"+
+				"gogit process may have crashed in this repository earlier: remove the\n"+
+				"file manually to continue", lockName)
+		}
+		return nil, fmt.Errorf("unable to create '%s': %w", lockName, err)
+	}
+
+	lock := &lockFile{path: path, file: file}
+	lockSignalsOn.Do(removeLocksOnSignal)
+	heldLocksMu.Lock()
+	heldLocks[lock] = true
+	heldLocksMu.Unlock()
+	return lock, nil
+}
+
+// createLockDir creates dir, such as the directory of a new ref
+// namespace, when it is missing. Only directories inside the repository
+// are created, so that a command run outside one fails instead of
+// leaving a partial repository behind.
+func createLockDir(dir string) error {
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		return nil
+	}
+	if !isRepoDir(RepoPath) {
+		return fmt.Errorf("not a gogit repository")
+	}
+	repo, err := filepath.Abs(RepoPath)
+	if err != nil {
+		return err
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	if rel, err := filepath.Rel(repo, abs); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("%s is outside the repository", dir)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("error creating directory %s: %w", dir, err)
+	}
+	return nil
+}
+
+func (l *lockFile) Write(data []byte) (int, error) {
+	return l.file.Write(data)
+}
+
+// commit syncs the new content to disk and moves it over the locked file,
+// releasing the lock.
+func (l *lockFile) commit() error {
+	defer l.release()
+	lockName := l.file.Name()
+	if err := l.file.Sync(); err != nil {
+		l.file.Close()
+		os.Remove(lockName)
+		return fmt.Errorf("error writing %s: %w", l.path, err)
+	}
+	if err := l.file.Close(); err != nil {
+		os.Remove(lockName)
+		return fmt.Errorf("error writing %s: %w", l.path, err)
+	}
+	if err := os.Rename(lockName, l.path); err != nil {
+		os.Remove(lockName)
+		return fmt.Errorf("error renaming %s into place: %w", lockName, err)
+	}
+	return nil
+}
+
+// rollback drops the lock and the content written so far, leaving the
+// locked file as it was. It does nothing once the lock is committed, so
+// it can be deferred.
+func (l *lockFile) rollback() {
+	heldLocksMu.Lock()
+	held := heldLocks[l]
+	heldLocksMu.Unlock()
+	if !held {
+		return
+	}
+	l.file.Close()
+	os.Remove(l.file.Name())
+	l.release()
+}
+
+func (l *lockFile) release() {
+	heldLocksMu.Lock()
+	delete(heldLocks, l)
+	heldLocksMu.Unlock()
+}
+
+// removeLocksOnSignal makes an interrupted gogit remove the locks it
+// holds, so that the next command does not find them.
+func removeLocksOnSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		heldLocksMu.Lock()
+		for lock := range heldLocks {
+			lock.file.Close()
+			os.Remove(lock.file.Name())
+		}
+		os.Exit(130)
+	}()
+}
+
+// writeFileLocked replaces path with data under its lock.
+func writeFileLocked(path string, data []byte) error {
+	lock, err := lockPath(path)
+	if err != nil {
+		return err
+	}
+	defer lock.rollback()
+	if _, err := lock.Write(data); err != nil {
+		return fmt.Errorf("error writing %s: %w", path, err)
+	}
+	return lock.commit()
+}
+
+// writeFileSynced writes data to a new file at path through a temporary
+// file in the same directory, so that readers see either no file or all
+// of it. Objects are written this way: two processes writing the same
+// object write the same bytes, so they need no lock.
+func writeFileSynced(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("error creating directory %s: %w", dir, err)
+	}
+	temp, err := os.CreateTemp(dir, "tmp_obj_")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Sync(); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(temp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), path)
+}
@@ -1,15 +1,58 @@
 package gogit
 
-func LogRepo() error {
-	currentHash, err := GetBranchHash()
+import (
+	"fmt"
+	"time"
+)
+
+// LogRepo prints the history of the current branch, newest commit first.
+func LogRepo(opts *LogOptions) error {
+	pf, err := parsePretty(opts.Format)
 	if err != nil {
 		return err
 	}
+	if _, err := FormatDate(time.Time{}, opts.DateMode); err != nil {
+		return err
+	}
 
-	err = ReadObject(currentHash)
+	currentHash, err := GetBranchHash()
 	if err != nil {
 		return err
 	}
+	if currentHash == "" {
+		return fmt.Errorf("your current branch does not have any commits yet")
+	}
+
+	first := true
+	return WalkHistory(currentHash, func(commit *Commit) error {
+		out := pf.render(commit, opts.DateMode)
+		switch {
+		case pf.preset != "":
+			fmt.Print(out)
+		case pf.separator && !first:
+			fmt.Print("\n" + out)
+		case pf.separator:
+			fmt.Print(out)
+		default:
+			fmt.Println(out)
+		}
+		first = false
+		return nil
+	})
+}
 
+// WalkHistory calls fn for each commit reachable from hash by following
+// first parents, newest first.
+func WalkHistory(hash string, fn func(*Commit) error) error {
+	for hash != "" {
+		commit, err := ReadCommit(hash)
+		if err != nil {
+			return err
+		}
+		if err := fn(commit); err != nil {
+			return err
+		}
+		hash = commit.Parent
+	}
 	return nil
 }
@@ -0,0 +1,363 @@
+package gogit
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// UnmergedPath is a file left with conflicts by a merge. The hashes are
+// the file's blob in the common base, in HEAD ("ours") and in the commit
+// being applied ("theirs"); each is empty when the file is missing there.
+type UnmergedPath struct {
+	Path   string
+	Base   string
+	Ours   string
+	Theirs string
+}
+
+// Code returns the two-letter status of the conflict as `status --short`
+// shows it: UU, AA, DU, UD, AU or UA.
+func (u UnmergedPath) Code() (StatusCode, StatusCode) {
+	switch {
+	case u.Base == "" && u.Ours == "":
+		return StatusUnmerged, StatusAdded
+	case u.Base == "" && u.Theirs == "":
+		return StatusAdded, StatusUnmerged
+	case u.Base == "":
+		return StatusAdded, StatusAdded
+	case u.Ours == "":
+		return StatusDeleted, StatusUnmerged
+	case u.Theirs == "":
+		return StatusUnmerged, StatusDeleted
+	}
+	return StatusUnmerged, StatusUnmerged
+}
+
+// Describe returns the label of the conflict in the long status format.
+func (u UnmergedPath) Describe() string {
+	switch {
+	case u.Base == "" && u.Ours == "":
+		return "added by them"
+	case u.Base == "" && u.Theirs == "":
+		return "added by us"
+	case u.Base == "":
+		return "both added"
+	case u.Ours == "":
+		return "deleted by us"
+	case u.Theirs == "":
+		return "deleted by them"
+	}
+	return "both modified"
+}
+
+// unmergedPath is the file listing the paths that still have conflicts.
+// Each line is "<base> <ours> <theirs>\t<path>", with zero hashes for
+// missing sides.
+func unmergedPath() string {
+	return filepath.Join(RepoPath, "UNMERGED")
+}
+
+// ReadUnmerged returns the paths that still have conflicts, sorted by path.
+func ReadUnmerged() ([]UnmergedPath, error) {
+	file, err := os.Open(unmergedPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading unmerged paths: %w", err)
+	}
+	defer file.Close()
+
+	var unmerged []UnmergedPath
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		hashes, path, ok := strings.Cut(scanner.Text(), "\t")
+		fields := strings.Fields(hashes)
+		if !ok || len(fields) != 3 {
+			continue
+		}
+		unmerged = append(unmerged, UnmergedPath{
+			Path:   path,
+			Base:   strings.TrimPrefix(fields[0], zeroHash),
+			Ours:   strings.TrimPrefix(fields[1], zeroHash),
+			Theirs: strings.TrimPrefix(fields[2], zeroHash),
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading unmerged paths: %w", err)
+	}
+	sort.Slice(unmerged, func(i, j int) bool { return unmerged[i].Path < unmerged[j].Path })
+	return unmerged, nil
+}
+
+// writeUnmerged replaces the list of conflicted paths. An empty list
+// removes the file.
+func writeUnmerged(unmerged []UnmergedPath) error {
+	if len(unmerged) == 0 {
+		if err := os.Remove(unmergedPath()); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("error removing unmerged paths: %w", err)
+		}
+		return nil
+	}
+
+	var sb strings.Builder
+	for _, u := range unmerged {
+		fmt.Fprintf(&sb, "%s %s %s\t%s\n", hashOrZero(u.Base), hashOrZero(u.Ours), hashOrZero(u.Theirs), u.Path)
+	}
+	if err := os.WriteFile(unmergedPath(), []byte(sb.String()), 0644); err != nil {
+		return fmt.Errorf("error writing unmerged paths: %w", err)
+	}
+	return nil
+}
+
+// markResolved drops paths from the list of conflicts, as `add` does.
+func markResolved(paths ...string) error {
+	unmerged, err := ReadUnmerged()
+	if err != nil || len(unmerged) == 0 {
+		return err
+	}
+	resolved := make(map[string]bool)
+	for _, path := range paths {
+		resolved[filepath.ToSlash(filepath.Clean(path))] = true
+	}
+	var remaining []UnmergedPath
+	for _, u := range unmerged {
+		if !resolved[u.Path] {
+			remaining = append(remaining, u)
+		}
+	}
+	return writeUnmerged(remaining)
+}
+
+// errUnmerged reports that a command cannot run while conflicts remain.
+func errUnmerged(action string) error {
+	return fmt.Errorf("%s is not possible because you have unmerged files\n"+
+		"hint: fix them up in the work tree, and then use 'gogit add <file>'\n"+
+		"hint: as appropriate to mark resolution", action)
+}
+
+// mergeTrees combines the changes from base to ours and from base to
+// theirs. It returns the blob each path changed relative to ours should
+// have in the working tree (empty for deletions) and the conflicts. A
+// conflicted path gets the file with conflict markers, or the side that
+// was not deleted. Attributes come from the attributes files in ours.
+func mergeTrees(base, ours, theirs map[string]string, oursLabel, theirsLabel string) (map[string]string, []UnmergedPath, error) {
+	worktree := make(map[string]string)
+	var conflicts []UnmergedPath
+	rules, err := treeAttributes(ours)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	paths := make(map[string]bool)
+	for _, tree := range []map[string]string{base, ours, theirs} {
+		for path := range tree {
+			paths[path] = true
+		}
+	}
+
+	for _, path := range sortedKeys(paths) {
+		baseHash, oursHash, theirsHash := base[path], ours[path], theirs[path]
+		switch {
+		case oursHash == theirsHash, theirsHash == baseHash:
+			continue
+		case oursHash == baseHash:
+			worktree[path] = theirsHash
+			continue
+		}
+
+		conflict := UnmergedPath{Path: path, Base: baseHash, Ours: oursHash, Theirs: theirsHash}
+		if oursHash == "" || theirsHash == "" {
+			// Modified on one side and deleted on the other: keep the
+			// modified file for the user to decide.
+			worktree[path] = oursHash + theirsHash
+			conflicts = append(conflicts, conflict)
+			continue
+		}
+
+		merged, clean, err := mergeBlobs(rules, path, baseHash, oursHash, theirsHash, oursLabel, theirsLabel)
+		if err != nil {
+			return nil, nil, err
+		}
+		mergedHash, err := storeBlob(merged)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error writing blob object: %w", err)
+		}
+		worktree[path] = mergedHash
+		if !clean {
+			conflicts = append(conflicts, conflict)
+		}
+	}
+	return worktree, conflicts, nil
+}
+
+// mergeBlobs merges the content of three blobs of path line by line.
+// Binary files, and paths with -merge, cannot be merged: the result is
+// our version, reported as a conflict.
+func mergeBlobs(rules attrRules, path, baseHash, oursHash, theirsHash, oursLabel, theirsLabel string) ([]byte, bool, error) {
+	var contents [3][]byte
+	for i, hash := range []string{baseHash, oursHash, theirsHash} {
+		if hash == "" {
+			continue
+		}
+		content, err := ReadBlob(hash)
+		if err != nil {
+			return nil, false, err
+		}
+		contents[i] = content
+	}
+	if rules.isBinaryMerge(path, contents[0]) || rules.isBinaryMerge(path, contents[1]) || rules.isBinaryMerge(path, contents[2]) {
+		return contents[1], false, nil
+	}
+	merged, clean := merge3(contents[0], contents[1], contents[2], oursLabel, theirsLabel)
+	return merged, clean, nil
+}
+
+// merge3 merges the changes from base to ours and from base to theirs.
+// Changes to separate parts of the file are combined; changes to the same
+// or adjacent lines that differ are written between conflict markers and
+// the result is reported as not clean.
+func merge3(base, ours, theirs []byte, oursLabel, theirsLabel string) ([]byte, bool) {
+	baseLines := splitLines(base)
+	oursLines := splitLines(ours)
+	theirsLines := splitLines(theirs)
+	oursHunks := diffLines(baseLines, oursLines)
+	theirsHunks := diffLines(baseLines, theirsLines)
+
+	var out []string
+	clean := true
+	position := 0
+	i, j := 0, 0
+	for i < len(oursHunks) || j < len(theirsHunks) {
+		// Start a group with the hunk that begins first, then pull in
+		// every hunk from either side that overlaps or touches it.
+		start := 0
+		if j >= len(theirsHunks) || (i < len(oursHunks) && oursHunks[i].OldStart <= theirsHunks[j].OldStart) {
+			start = oursHunks[i].OldStart
+		} else {
+			start = theirsHunks[j].OldStart
+		}
+		end := start
+		firstOurs, firstTheirs := i, j
+		for {
+			if i < len(oursHunks) && oursHunks[i].OldStart <= end {
+				end = max(end, oursHunks[i].OldEnd)
+				i++
+			} else if j < len(theirsHunks) && theirsHunks[j].OldStart <= end {
+				end = max(end, theirsHunks[j].OldEnd)
+				j++
+			} else {
+				break
+			}
+		}
+
+		out = append(out, baseLines[position:start]...)
+		position = end
+
+		oursText := sideLines(baseLines, oursLines, oursHunks[firstOurs:i], start, end)
+		theirsText := sideLines(baseLines, theirsLines, theirsHunks[firstTheirs:j], start, end)
+		switch {
+		case firstTheirs == j:
+			out = append(out, oursText...)
+		case firstOurs == i, equalLines(oursText, theirsText):
+			out = append(out, theirsText...)
+		default:
+			clean = false
+			out = append(out, "<<<<<<< "+oursLabel+"\n")
+			out = appendTerminated(out, oursText)
+			out = append(out, "=======\n")
+			out = appendTerminated(out, theirsText)
+			out = append(out, ">>>>>>> "+theirsLabel+"\n")
+		}
+	}
+	out = append(out, baseLines[position:]...)
+	return []byte(strings.Join(out, "")), clean
+}
+
+// sideLines returns one side's replacement for base lines [start, end),
+// given the side's hunks that fall inside that range.
+func sideLines(baseLines, lines []string, hunks []diffHunk, start, end int) []string {
+	if len(hunks) == 0 {
+		return baseLines[start:end]
+	}
+	first, last := hunks[0], hunks[len(hunks)-1]
+	return lines[first.NewStart-(first.OldStart-start) : last.NewEnd+(end-last.OldEnd)]
+}
+
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// appendTerminated appends lines, adding a newline to the last one if it
+// lacks it so that a conflict marker can follow.
+func appendTerminated(out, lines []string) []string {
+	out = append(out, lines...)
+	if len(lines) > 0 && !strings.HasSuffix(lines[len(lines)-1], "\n") {
+		out[len(out)-1] += "\n"
+	}
+	return out
+}
+
+// applyMerge moves the index and working tree from headTree to the result
+// of mergeTrees and records the conflicts. Local changes to any affected
+// file abort the operation before anything is written.
+func applyMerge(headTree, worktree map[string]string, conflicts []UnmergedPath, operation string) error {
+	lock, err := lockIndex()
+	if err != nil {
+		return err
+	}
+	defer lock.rollback()
+	indexMap, err := ReadIndex()
+	if err != nil {
+		return err
+	}
+	if err := mergeIntoIndex(indexMap, headTree, worktree, conflicts, operation); err != nil {
+		return err
+	}
+	if err := lock.commitIndex(indexMap); err != nil {
+		return err
+	}
+	return writeUnmerged(conflicts)
+}
+
+// mergeIntoIndex is applyMerge for callers that hold the index lock: it
+// updates indexMap in place and leaves writing it and the conflicts to
+// them.
+func mergeIntoIndex(indexMap, headTree, worktree map[string]string, conflicts []UnmergedPath, operation string) error {
+	target := make(map[string]string)
+	for path, hash := range headTree {
+		target[path] = hash
+	}
+	for path, hash := range worktree {
+		if hash == "" {
+			delete(target, path)
+		} else {
+			target[path] = hash
+		}
+	}
+	if err := checkoutIndex(indexMap, headTree, target, false, operation); err != nil {
+		return err
+	}
+	// checkoutIndex staged the working tree content; conflicted paths keep
+	// our version in the index instead.
+	for _, conflict := range conflicts {
+		if conflict.Ours == "" {
+			delete(indexMap, conflict.Path)
+		} else {
+			indexMap[conflict.Path] = conflict.Ours
+		}
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+package gogit
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Object types as reported by `cat-file -t`.
+const (
+	ObjectTypeBlob   = "blob"
+	ObjectTypeTree   = "tree"
+	ObjectTypeCommit = "commit"
+)
+
+var fullHashPattern = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// objectPath returns the location of the object file for hash.
+func objectPath(hash string) string {
+	return filepath.Join(ObjectsPath, hash[:2], hash[2:])
+}
+
+// Objects are stored zlib-compressed. Repositories written before that
+// hold uncompressed objects, which are still read as they are: a zlib
+// stream starts with 0x78, while an uncompressed object starts with
+// "blob ", "tree " (a commit) or a tree line's mode.
+const zlibMagic = 0x78
+
+// objectReader reads an object file, closing the decompressor and the
+// file together.
+type objectReader struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (r *objectReader) Close() error {
+	var err error
+	for _, closer := range r.closers {
+		if closeErr := closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// openObject opens the stored bytes of an object for reading,
+// decompressing them if needed.
+func openObject(hash string) (*objectReader, error) {
+	if !fullHashPattern.MatchString(hash) {
+		return nil, fmt.Errorf("invalid object name %s", hash)
+	}
+	file, err := os.Open(objectPath(hash))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("object %s not found", hash)
+		}
+		return nil, fmt.Errorf("error reading object %s: %w", hash, err)
+	}
+	r := bufio.NewReader(file)
+	if first, err := r.Peek(1); err != nil || first[0] != zlibMagic {
+		return &objectReader{Reader: r, closers: []io.Closer{file}}, nil
+	}
+	decompressor, err := zlib.NewReader(r)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("error decompressing object %s: %w", hash, err)
+	}
+	return &objectReader{Reader: decompressor, closers: []io.Closer{decompressor, file}}, nil
+}
+
+// readObjectFile returns the stored bytes of an object. Blobs keep their
+// "blob <size>\0" header on disk; trees and commits are stored bare.
+func readObjectFile(hash string) ([]byte, error) {
+	r, err := openObject(hash)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("error reading object %s: %w", hash, err)
+	}
+	return data, nil
+}
+
+// writeObject stores data as the object hash unless it already exists.
+func writeObject(hash string, data []byte) error {
+	if ObjectExists(hash) {
+		return nil
+	}
+	_, err := writeCompressed(func(w io.Writer) (string, error) {
+		_, err := w.Write(data)
+		return hash, err
+	})
+	return err
+}
+
+// writeBlob stores the size bytes read from r as a blob, hashing them as
+// they are compressed, and returns the blob's hash. Memory use does not
+// depend on the size.
+func writeBlob(r io.Reader, size int64) (string, error) {
+	return writeCompressed(func(w io.Writer) (string, error) {
+		return hashBlobTo(w, r, size)
+	})
+}
+
+// storeBlob stores content as a blob and returns its hash.
+func storeBlob(content []byte) (string, error) {
+	return writeBlob(bytes.NewReader(content), int64(len(content)))
+}
+
+// writeCompressed stores the object whose stored bytes write produces. The
+// bytes are compressed into a temporary file, which is synced and moved
+// into place under the hash write returns, or dropped if that object
+// already exists.
+func writeCompressed(write func(w io.Writer) (string, error)) (string, error) {
+	if err := os.MkdirAll(ObjectsPath, 0755); err != nil {
+		return "", fmt.Errorf("error creating directory %s: %w", ObjectsPath, err)
+	}
+	temp, err := os.CreateTemp(ObjectsPath, "tmp_obj_")
+	if err != nil {
+		return "", fmt.Errorf("error creating object file: %w", err)
+	}
+	defer os.Remove(temp.Name())
+
+	buffered := bufio.NewWriter(temp)
+	compressor := zlib.NewWriter(buffered)
+	hash, err := write(compressor)
+	if err == nil {
+		err = compressor.Close()
+	}
+	if err == nil {
+		err = buffered.Flush()
+	}
+	if err == nil {
+		err = temp.Sync()
+	}
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("error writing object: %w", err)
+	}
+
+	if ObjectExists(hash) {
+		return hash, nil
+	}
+	path := objectPath(hash)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("error creating directory %s: %w", filepath.Dir(path), err)
+	}
+	if err := os.Rename(temp.Name(), path); err != nil {
+		return "", fmt.Errorf("error writing object to %s: %w", path, err)
+	}
+	return hash, nil
+}
+
+// ObjectExists reports whether the object hash is present in the store.
+func ObjectExists(hash string) bool {
+	if !fullHashPattern.MatchString(hash) {
+		return false
+	}
+	_, err := os.Stat(objectPath(hash))
+	return err == nil
+}
+
+// ReadObjectContent returns the type and content of an object, with the
+// blob header stripped.
+func ReadObjectContent(hash string) (string, []byte, error) {
+	data, err := readObjectFile(hash)
+	if err != nil {
+		return "", nil, err
+	}
+	objectType, content := parseObjectData(data)
+	return objectType, content, nil
+}
+
+// ReadBlob returns the content of a blob object.
+func ReadBlob(hash string) ([]byte, error) {
+	objectType, content, err := ReadObjectContent(hash)
+	if err != nil {
+		return nil, err
+	}
+	if objectType != ObjectTypeBlob {
+		return nil, fmt.Errorf("object %s is a %s, not a blob", hash, objectType)
+	}
+	return content, nil
+}
+
+// openBlob opens a blob for reading without loading it into memory, and
+// returns its size.
+func openBlob(hash string) (io.ReadCloser, int64, error) {
+	object, err := openObject(hash)
+	if err != nil {
+		return nil, 0, err
+	}
+	r := bufio.NewReader(object)
+	header, err := r.ReadString(0)
+	size, convErr := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(header, "blob "), "\x00"), 10, 64)
+	if err != nil || !strings.HasPrefix(header, "blob ") || convErr != nil {
+		object.Close()
+		return nil, 0, fmt.Errorf("object %s is not a blob", hash)
+	}
+	return &objectReader{Reader: io.LimitReader(r, size), closers: []io.Closer{object}}, size, nil
+}
+
+// parseObjectData tells the object type apart from its stored bytes.
+func parseObjectData(data []byte) (string, []byte) {
+	if bytes.HasPrefix(data, []byte("blob ")) {
+		if nul := bytes.IndexByte(data, 0); nul > 0 {
+			if _, err := strconv.Atoi(string(data[len("blob "):nul])); err == nil {
+				return ObjectTypeBlob, data[nul+1:]
+			}
+		}
+	}
+	if bytes.HasPrefix(data, []byte("tree ")) {
+		return ObjectTypeCommit, data
+	}
+	return ObjectTypeTree, data
+}
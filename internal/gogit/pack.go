@@ -0,0 +1,490 @@
+package gogit
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+)
+
+// A packfile carries many objects in one stream: the signature "PACK", a
+// version and an object count, then each object as a type-and-size header
+// followed by its zlib-compressed content, and finally the SHA-1 of
+// everything before it. Objects may also be stored as deltas against
+// another object, named by hash (ref-delta) or by its position earlier in
+// the pack (ofs-delta).
+
+// Object type codes used in packfiles.
+const (
+	packCommit   = 1
+	packTree     = 2
+	packBlob     = 3
+	packTag      = 4
+	packOfsDelta = 6
+	packRefDelta = 7
+)
+
+var packTypeNames = map[int]string{
+	packCommit: ObjectTypeCommit,
+	packTree:   ObjectTypeTree,
+	packBlob:   ObjectTypeBlob,
+	packTag:    "tag",
+}
+
+// storeObject writes an object given its type and content, hashing it the
+// way Git does, and returns its hash. Blobs are stored with their header;
+// trees and commits are stored bare.
+func storeObject(objectType string, content []byte) (string, error) {
+	hash := hashTypedObject(objectType, content)
+	switch objectType {
+	case ObjectTypeBlob:
+		return storeBlob(content)
+	case ObjectTypeTree, ObjectTypeCommit:
+		return hash, writeObject(hash, content)
+	}
+	return "", fmt.Errorf("unsupported object type %s", objectType)
+}
+
+// writePack writes the objects hashes, read with read, as an undeltified
+// packfile.
+func writePack(w io.Writer, hashes []string, read func(hash string) (string, []byte, error)) error {
+	digest := sha1.New()
+	out := io.MultiWriter(w, digest)
+
+	header := make([]byte, 12)
+	copy(header, "PACK")
+	binary.BigEndian.PutUint32(header[4:], 2)
+	binary.BigEndian.PutUint32(header[8:], uint32(len(hashes)))
+	if _, err := out.Write(header); err != nil {
+		return err
+	}
+
+	for _, hash := range hashes {
+		objectType, content, err := read(hash)
+		if err != nil {
+			return err
+		}
+		code := packBlob
+		switch objectType {
+		case ObjectTypeCommit:
+			code = packCommit
+		case ObjectTypeTree:
+			code = packTree
+		}
+		if _, err := out.Write(packObjectHeader(code, len(content))); err != nil {
+			return err
+		}
+		compressor := zlib.NewWriter(out)
+		if _, err := compressor.Write(content); err != nil {
+			return err
+		}
+		if err := compressor.Close(); err != nil {
+			return err
+		}
+	}
+
+	_, err := w.Write(digest.Sum(nil))
+	return err
+}
+
+// packObjectHeader encodes an object's type and size: the type in bits
+// 4-6 of the first byte, the size in its low four bits and seven bits of
+// each following byte, least significant first.
+func packObjectHeader(code, size int) []byte {
+	first := byte(code<<4) | byte(size&0x0f)
+	size >>= 4
+	var header []byte
+	for size > 0 {
+		header = append(header, first|0x80)
+		first = byte(size & 0x7f)
+		size >>= 7
+	}
+	return append(header, first)
+}
+
+// packReader reads a packfile byte by byte, so that zlib stops exactly at
+// the end of each object, and hashes what it reads for the trailer check.
+type packReader struct {
+	r      *bufio.Reader
+	digest hash.Hash
+	offset int64
+}
+
+func (p *packReader) Read(buf []byte) (int, error) {
+	n, err := p.r.Read(buf)
+	p.digest.Write(buf[:n])
+	p.offset += int64(n)
+	return n, err
+}
+
+func (p *packReader) ReadByte() (byte, error) {
+	b, err := p.r.ReadByte()
+	if err == nil {
+		p.digest.Write([]byte{b})
+		p.offset++
+	}
+	return b, err
+}
+
+// packEntry is an object read from a pack, before deltas are resolved.
+type packEntry struct {
+	code    int
+	content []byte
+	// base names the delta base: a hash for ref-deltas, an offset for
+	// ofs-deltas.
+	baseHash   string
+	baseOffset int64
+}
+
+// readPack stores every object of a packfile and returns their hashes.
+// Delta bases may be earlier objects of the pack or objects already in
+// the repository. A pack of Git's commits, trees or tags, as Git sends,
+// is refused before anything is stored.
+func readPack(r io.Reader) ([]string, error) {
+	type packObject struct {
+		objectType string
+		content    []byte
+	}
+	var objects []packObject
+	hashes, err := unpack(r, func(objectType string, content []byte) (string, error) {
+		if err := checkGogitObject(objectType, content); err != nil {
+			return "", err
+		}
+		objects = append(objects, packObject{objectType, content})
+		return hashTypedObject(objectType, content), nil
+	}, readStoredBase)
+	if err != nil {
+		return nil, err
+	}
+	for _, object := range objects {
+		if _, err := storeObject(object.objectType, object.content); err != nil {
+			return nil, err
+		}
+	}
+	return hashes, nil
+}
+
+// checkGogitObject returns an error for an object gogit could not have
+// written: a tag, a tree in Git's binary layout, or a commit with headers
+// other than tree, parent, author and date (Git adds a committer).
+func checkGogitObject(objectType string, content []byte) error {
+	gitFormat := fmt.Errorf("pack holds a %s in Git's format; gogit only exchanges packs with gogit", objectType)
+	switch objectType {
+	case ObjectTypeBlob:
+		return nil
+	case ObjectTypeTree:
+		if len(content) == 0 {
+			return nil
+		}
+		for _, line := range strings.Split(strings.TrimSuffix(string(content), "\n"), "\n") {
+			header, path, ok := strings.Cut(line, "\t")
+			fields := strings.Fields(header)
+			if !ok || path == "" || len(fields) != 3 || fields[1] != ObjectTypeBlob || !fullHashPattern.MatchString(fields[2]) {
+				return gitFormat
+			}
+		}
+		return nil
+	case ObjectTypeCommit:
+		headers, _, _ := strings.Cut(string(content), "\n\n")
+		dated := false
+		for _, line := range strings.Split(headers, "\n") {
+			name, _, _ := strings.Cut(line, " ")
+			switch name {
+			case "tree", "parent", "author":
+			case "date":
+				dated = true
+			default:
+				return gitFormat
+			}
+		}
+		if !dated {
+			return gitFormat
+		}
+		return nil
+	}
+	return gitFormat
+}
+
+// readStoredBase reads a delta base from the repository.
+func readStoredBase(hash string) (string, []byte, error) {
+	if !ObjectExists(hash) {
+		return "", nil, errMissingBase
+	}
+	return ReadObjectContent(hash)
+}
+
+// unpack resolves every object of a packfile and passes it to store,
+// returning their hashes. Ref-delta bases the pack does not hold are read
+// with base, which returns errMissingBase for those it does not have
+// either.
+func unpack(r io.Reader, store func(objectType string, content []byte) (string, error), base func(hash string) (string, []byte, error)) ([]string, error) {
+	entries, offsets, err := scanPack(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Resolve deltas until no more progress is made; a ref-delta may name
+	// a base that appears later in the pack.
+	byHash := make(map[string]*packEntry)
+	var hashes []string
+	pending := offsets
+	for len(pending) > 0 {
+		var next []int64
+		for _, offset := range pending {
+			entry := entries[offset]
+			if err := resolvePackEntry(entry, entries, byHash, base); err != nil {
+				if errors.Is(err, errMissingBase) {
+					next = append(next, offset)
+					continue
+				}
+				return nil, err
+			}
+			hash, err := store(packTypeNames[entry.code], entry.content)
+			if err != nil {
+				return nil, err
+			}
+			byHash[hash] = entry
+			hashes = append(hashes, hash)
+		}
+		if len(next) == len(pending) {
+			return nil, fmt.Errorf("pack has %d deltas with missing bases", len(next))
+		}
+		pending = next
+	}
+	return hashes, nil
+}
+
+// scanPack reads every entry of a packfile, in the order of offsets, and
+// checks the trailer, without storing anything.
+func scanPack(r io.Reader) (map[int64]*packEntry, []int64, error) {
+	pack := &packReader{r: bufio.NewReader(r), digest: sha1.New()}
+
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(pack, header); err != nil {
+		return nil, nil, fmt.Errorf("error reading pack header: %w", err)
+	}
+	if string(header[:4]) != "PACK" {
+		return nil, nil, fmt.Errorf("not a packfile")
+	}
+	if version := binary.BigEndian.Uint32(header[4:]); version != 2 && version != 3 {
+		return nil, nil, fmt.Errorf("unsupported pack version %d", version)
+	}
+	count := binary.BigEndian.Uint32(header[8:])
+
+	entries := make(map[int64]*packEntry, count)
+	var offsets []int64
+	for range count {
+		offset := pack.offset
+		entry, err := readPackEntry(pack)
+		if err != nil {
+			return nil, nil, err
+		}
+		if entry.code == packOfsDelta {
+			entry.baseOffset = offset - entry.baseOffset
+		}
+		entries[offset] = entry
+		offsets = append(offsets, offset)
+	}
+
+	expected := pack.digest.Sum(nil)
+	trailer := make([]byte, sha1.Size)
+	if _, err := io.ReadFull(pack.r, trailer); err != nil {
+		return nil, nil, fmt.Errorf("error reading pack trailer: %w", err)
+	}
+	if !bytes.Equal(trailer, expected) {
+		return nil, nil, fmt.Errorf("pack checksum mismatch")
+	}
+	return entries, offsets, nil
+}
+
+// packSource is what readPackEntry reads from: zlib must be able to read
+// byte by byte so that it stops where each object ends.
+type packSource interface {
+	io.Reader
+	io.ByteReader
+}
+
+// readPackEntry reads one object header and its compressed data. An
+// ofs-delta's baseOffset is left relative to the entry's own offset.
+func readPackEntry(pack packSource) (*packEntry, error) {
+	b, err := pack.ReadByte()
+	if err != nil {
+		return nil, fmt.Errorf("error reading pack object: %w", err)
+	}
+	entry := &packEntry{code: int(b>>4) & 0x07}
+	size := int(b & 0x0f)
+	for shift := 4; b&0x80 != 0; shift += 7 {
+		if b, err = pack.ReadByte(); err != nil {
+			return nil, fmt.Errorf("error reading pack object: %w", err)
+		}
+		size |= int(b&0x7f) << shift
+	}
+
+	switch entry.code {
+	case packCommit, packTree, packBlob, packTag:
+	case packRefDelta:
+		base := make([]byte, 20)
+		if _, err := io.ReadFull(pack, base); err != nil {
+			return nil, fmt.Errorf("error reading delta base: %w", err)
+		}
+		entry.baseHash = fmt.Sprintf("%x", base)
+	case packOfsDelta:
+		if b, err = pack.ReadByte(); err != nil {
+			return nil, fmt.Errorf("error reading delta base: %w", err)
+		}
+		offset := int64(b & 0x7f)
+		for b&0x80 != 0 {
+			if b, err = pack.ReadByte(); err != nil {
+				return nil, fmt.Errorf("error reading delta base: %w", err)
+			}
+			offset = (offset+1)<<7 | int64(b&0x7f)
+		}
+		entry.baseOffset = offset
+	default:
+		return nil, fmt.Errorf("unknown pack object type %d", entry.code)
+	}
+
+	decompressor, err := zlib.NewReader(pack)
+	if err != nil {
+		return nil, fmt.Errorf("error decompressing pack object: %w", err)
+	}
+	entry.content, err = io.ReadAll(decompressor)
+	if err != nil {
+		return nil, fmt.Errorf("error decompressing pack object: %w", err)
+	}
+	if len(entry.content) != size {
+		return nil, fmt.Errorf("pack object size mismatch: expected %d bytes, got %d", size, len(entry.content))
+	}
+	return entry, nil
+}
+
+var errMissingBase = errors.New("delta base not available yet")
+
+// resolvePackEntry turns a delta entry into a full object in place.
+func resolvePackEntry(entry *packEntry, entries map[int64]*packEntry, byHash map[string]*packEntry, readBase func(hash string) (string, []byte, error)) error {
+	var baseCode int
+	var base []byte
+	switch entry.code {
+	case packOfsDelta:
+		baseEntry, ok := entries[entry.baseOffset]
+		if !ok {
+			return fmt.Errorf("delta base at offset %d not found", entry.baseOffset)
+		}
+		if err := resolvePackEntry(baseEntry, entries, byHash, readBase); err != nil {
+			return err
+		}
+		baseCode, base = baseEntry.code, baseEntry.content
+	case packRefDelta:
+		if baseEntry, ok := byHash[entry.baseHash]; ok {
+			baseCode, base = baseEntry.code, baseEntry.content
+			break
+		}
+		objectType, content, err := readBase(entry.baseHash)
+		if err != nil {
+			return err
+		}
+		for code, name := range packTypeNames {
+			if name == objectType {
+				baseCode = code
+			}
+		}
+		base = content
+	default:
+		return nil
+	}
+
+	content, err := applyDelta(base, entry.content)
+	if err != nil {
+		return err
+	}
+	entry.code, entry.content = baseCode, content
+	return nil
+}
+
+// applyDelta rebuilds an object from its delta base: after the base and
+// result sizes, each instruction either copies a range of the base or
+// inserts literal bytes.
+func applyDelta(base, delta []byte) ([]byte, error) {
+	errCorrupt := fmt.Errorf("corrupt delta")
+	pos := 0
+	readSize := func() (int, error) {
+		size, shift := 0, 0
+		for {
+			if pos >= len(delta) {
+				return 0, errCorrupt
+			}
+			b := delta[pos]
+			pos++
+			size |= int(b&0x7f) << shift
+			shift += 7
+			if b&0x80 == 0 {
+				return size, nil
+			}
+		}
+	}
+
+	baseSize, err := readSize()
+	if err != nil {
+		return nil, err
+	}
+	if baseSize != len(base) {
+		return nil, fmt.Errorf("delta base size mismatch")
+	}
+	resultSize, err := readSize()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]byte, 0, resultSize)
+	for pos < len(delta) {
+		op := delta[pos]
+		pos++
+		switch {
+		case op&0x80 != 0:
+			var offset, size int
+			for i := 0; i < 4; i++ {
+				if op&(1<<i) != 0 {
+					if pos >= len(delta) {
+						return nil, errCorrupt
+					}
+					offset |= int(delta[pos]) << (8 * i)
+					pos++
+				}
+			}
+			for i := 0; i < 3; i++ {
+				if op&(0x10<<i) != 0 {
+					if pos >= len(delta) {
+						return nil, errCorrupt
+					}
+					size |= int(delta[pos]) << (8 * i)
+					pos++
+				}
+			}
+			if size == 0 {
+				size = 0x10000
+			}
+			if offset+size > len(base) {
+				return nil, errCorrupt
+			}
+			result = append(result, base[offset:offset+size]...)
+		case op != 0:
+			if pos+int(op) > len(delta) {
+				return nil, errCorrupt
+			}
+			result = append(result, delta[pos:pos+int(op)]...)
+			pos += int(op)
+		default:
+			return nil, errCorrupt
+		}
+	}
+	if len(result) != resultSize {
+		return nil, fmt.Errorf("delta result size mismatch")
+	}
+	return result, nil
+}
@@ -0,0 +1,33 @@
+package gogit
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestReadPackRefusesGitObjects(t *testing.T) {
+	dir := initTestRepo(t, t.TempDir(), "repo")
+	chdir(t, dir)
+	head := commitFile(t, "file.txt", "hello\n", "first")
+
+	// The same history as Git stores it: a Git commit and tree, and the
+	// blob, which is the same in both.
+	objects := newGitObjectSet(false)
+	if _, err := newGitExporter(objects).commit(head); err != nil {
+		t.Fatal(err)
+	}
+	var pack bytes.Buffer
+	if err := writePack(&pack, objects.order, objects.readObject); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := readPack(&pack); err == nil || !strings.Contains(err.Error(), "Git's format") {
+		t.Fatalf("readPack of Git objects: err = %v, want a refusal", err)
+	}
+	for _, hash := range objects.order {
+		if objectType := objects.objects[hash].objectType; objectType != ObjectTypeBlob && ObjectExists(hash) {
+			t.Fatalf("Git %s %s was stored", objectType, hash)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package gogit
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// pagerCommand returns the pager to use for command, or "" when output
+// should not be paged. $GOGIT_PAGER wins over core.pager, which wins over
+// $PAGER; the default is less. pager.<command> = false disables paging
+// for a single command.
+func pagerCommand(command string) (string, error) {
+	enabled, err := GetConfigBool("pager."+command, true)
+	if err != nil || !enabled {
+		return "", err
+	}
+
+	pager, ok := os.LookupEnv("GOGIT_PAGER")
+	if !ok {
+		value, set, err := GetConfig("core.pager")
+		if err != nil {
+			return "", err
+		}
+		if set {
+			pager, ok = value, true
+		}
+	}
+	if !ok {
+		pager, ok = os.LookupEnv("PAGER")
+	}
+	if !ok {
+		pager = "less"
+	}
+
+	pager = strings.TrimSpace(pager)
+	if pager == "cat" {
+		return "", nil
+	}
+	return pager, nil
+}
+
+// StartPager pipes everything written to os.Stdout from now on through
+// the configured pager. The returned function closes the pipe and waits
+// for the pager to exit; it must be called before the program exits.
+func StartPager(command string) (func(), error) {
+	pager, err := pagerCommand(command)
+	if err != nil || pager == "" {
+		return func() {}, err
+	}
+
+	cmd := exec.Command("sh", "-c", pager)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = os.Environ()
+	if _, ok := os.LookupEnv("LESS"); !ok {
+		cmd.Env = append(cmd.Env, "LESS=FRX")
+	}
+	if _, ok := os.LookupEnv("LV"); !ok {
+		cmd.Env = append(cmd.Env, "LV=-c")
+	}
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("error creating pager pipe: %w", err)
+	}
+	cmd.Stdin = reader
+	if err := cmd.Start(); err != nil {
+		reader.Close()
+		writer.Close()
+		return nil, fmt.Errorf("error starting pager '%s': %w", pager, err)
+	}
+	reader.Close()
+
+	stdout := os.Stdout
+	os.Stdout = writer
+	return func() {
+		os.Stdout = stdout
+		writer.Close()
+		_ = cmd.Wait()
+	}, nil
+}
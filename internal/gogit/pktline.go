@@ -0,0 +1,69 @@
+package gogit
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Git's wire protocol frames data as pkt-lines: four hex digits giving
+// the length of the line including those digits, then the payload. The
+// special line "0000" (a flush-pkt) ends a section.
+
+// maxPktPayload is the largest payload a single pkt-line can carry.
+const maxPktPayload = 65516
+
+// writePktLine writes payload as one pkt-line.
+func writePktLine(w io.Writer, payload string) error {
+	if len(payload) > maxPktPayload {
+		return fmt.Errorf("pkt-line too long: %d bytes", len(payload))
+	}
+	_, err := fmt.Fprintf(w, "%04x%s", len(payload)+4, payload)
+	return err
+}
+
+// writeFlushPkt writes a flush-pkt.
+func writeFlushPkt(w io.Writer) error {
+	_, err := io.WriteString(w, "0000")
+	return err
+}
+
+// readPktLine reads one pkt-line. It returns nil for a flush-pkt.
+func readPktLine(r io.Reader) ([]byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, fmt.Errorf("error reading pkt-line: %w", err)
+	}
+	length, err := strconv.ParseUint(string(header[:]), 16, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pkt-line length %q", header[:])
+	}
+	if length == 0 {
+		return nil, nil
+	}
+	if length < 4 {
+		return nil, fmt.Errorf("invalid pkt-line length %q", header[:])
+	}
+	payload := make([]byte, length-4)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, fmt.Errorf("error reading pkt-line: %w", err)
+	}
+	return payload, nil
+}
+
+// readPktLines reads pkt-lines up to the next flush-pkt, without their
+// trailing newlines.
+func readPktLines(r io.Reader) ([]string, error) {
+	var lines []string
+	for {
+		payload, err := readPktLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if payload == nil {
+			return lines, nil
+		}
+		lines = append(lines, string(bytes.TrimSuffix(payload, []byte("\n"))))
+	}
+}
@@ -0,0 +1,177 @@
+package gogit
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// CatFile prints information about the object named by rev. mode is
+// "type", "size", "exists" or "pretty".
+func CatFile(rev, mode string) error {
+	hash, err := ResolveRevision(rev)
+	if err != nil {
+		return err
+	}
+	// Blobs are streamed rather than loaded whole.
+	if blob, size, err := openBlob(hash); err == nil {
+		defer blob.Close()
+		switch mode {
+		case "type":
+			fmt.Println(ObjectTypeBlob)
+		case "size":
+			fmt.Println(size)
+		case "exists":
+		case "pretty":
+			_, err = io.Copy(os.Stdout, blob)
+		default:
+			return fmt.Errorf("unknown cat-file mode: %s", mode)
+		}
+		return err
+	}
+	objectType, content, err := ReadObjectContent(hash)
+	if err != nil {
+		return err
+	}
+
+	switch mode {
+	case "type":
+		fmt.Println(objectType)
+	case "size":
+		fmt.Println(len(content))
+	case "exists":
+	case "pretty":
+		_, err = os.Stdout.Write(content)
+	default:
+		return fmt.Errorf("unknown cat-file mode: %s", mode)
+	}
+	return err
+}
+
+// HashBlob computes the blob hash of the size bytes read from r and, when
+// write is set, stores it in the object database.
+func HashBlob(r io.Reader, size int64, write bool) (string, error) {
+	hash := HashObject
+	if write {
+		hash = writeBlob
+	}
+	blobHash, err := hash(r, size)
+	if err != nil {
+		return "", fmt.Errorf("error hashing content: %w", err)
+	}
+	return blobHash, nil
+}
+
+// HashFile computes the blob hash of a working tree file as add stores it,
+// after the clean filter and line ending conversion its attributes ask
+// for, and stores the blob when write is set.
+func HashFile(path string, write bool) (string, error) {
+	c, err := worktreeConverter()
+	if err != nil {
+		return "", err
+	}
+	defer c.close()
+	if write {
+		return c.storeFile(path)
+	}
+	return c.hashFile(path)
+}
+
+// LsFiles prints the paths in the index. With stage set, each line also
+// shows the mode, blob hash and stage number.
+func LsFiles(stage bool) error {
+	indexMap, err := ReadIndex()
+	if err != nil {
+		return err
+	}
+	for _, path := range sortedKeys(indexMap) {
+		if stage {
+			fmt.Printf("100644 %s 0\t%s\n", indexMap[path], quotePath(path))
+		} else {
+			fmt.Println(quotePath(path))
+		}
+	}
+	return nil
+}
+
+// ResolveTree returns the tree hash named by rev, peeling commits to
+// their tree.
+func ResolveTree(rev string) (string, error) {
+	hash, err := ResolveRevision(rev)
+	if err != nil {
+		return "", err
+	}
+	objectType, content, err := ReadObjectContent(hash)
+	if err != nil {
+		return "", err
+	}
+
+	switch objectType {
+	case ObjectTypeTree:
+		return hash, nil
+	case ObjectTypeCommit:
+		commit, err := parseCommit(hash, content)
+		if err != nil {
+			return "", err
+		}
+		return commit.Tree, nil
+	}
+	return "", fmt.Errorf("not a tree object: %s", rev)
+}
+
+// LsTree prints the entries of the tree named by rev.
+func LsTree(rev string, nameOnly bool) error {
+	treeHash, err := ResolveTree(rev)
+	if err != nil {
+		return err
+	}
+	tree, err := ReadTree(treeHash)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range sortedKeys(tree) {
+		if nameOnly {
+			fmt.Println(quotePath(path))
+		} else {
+			fmt.Printf("100644 blob %s\t%s\n", tree[path], quotePath(path))
+		}
+	}
+	return nil
+}
+
+// sortedKeys returns the keys of a path map in sorted order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// AbbreviateRef returns the short name of a ref ("main" for
+// "refs/heads/main"), or of the current branch for "HEAD".
+func AbbreviateRef(rev string) (string, error) {
+	if rev == "HEAD" || rev == "@" {
+		branch, err := CurrentBranch()
+		if err != nil {
+			return "", err
+		}
+		if branch == "" {
+			return "HEAD", nil
+		}
+		return branch, nil
+	}
+	for _, prefix := range []string{"refs/heads/", "refs/tags/", "refs/"} {
+		if strings.HasPrefix(rev, prefix) {
+			return strings.TrimPrefix(rev, prefix), nil
+		}
+	}
+	if _, err := ResolveRevision(rev); err != nil {
+		return "", err
+	}
+	return rev, nil
+}
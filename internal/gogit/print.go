@@ -1,52 +1,116 @@
 package gogit
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
-// PrintCommit prints a commit object with a stylized format.
-func PrintCommit(commit *Commit) {
-	fmt.Printf("%scommit %s%s\n", ColorYellow, commit.Hash, ColorReset)
-	fmt.Printf("Tree: %s\n", commit.Tree)
-	if commit.Parent != "" {
-		fmt.Printf("%sParent: %s%s\n", ColorRed, commit.Parent, ColorReset)
+// PrintCommit prints a commit object using the layout and date mode in opts.
+func PrintCommit(commit *Commit, opts *LogOptions) error {
+	out, err := FormatCommit(commit, opts)
+	if err != nil {
+		return err
 	}
-	fmt.Printf("%sAuthor: %s%s\n", ColorGreen, commit.Author, ColorReset)
-	fmt.Printf("%sDate: %s%s\n", ColorBlue, commit.Date.Format("Mon Jan 2 15:04:05 2006 -0700"), ColorReset)
-	fmt.Printf("\n\t%s\n\n", commit.Message)
+	fmt.Print(out)
+	return nil
 }
 
+// statusGroups is a status split into the sections of its long format,
+// one described line per path.
+type statusGroups struct {
+	staged, unmerged, unstaged, untracked, ignored []string
+}
+
+// groupStatus sorts the entries of a status into the sections of its long
+// format, as shown by status and in the commit message template.
+func groupStatus(statusInfo *StatusInfo) statusGroups {
+	var groups statusGroups
+	for _, entry := range statusInfo.Entries {
+		if entry.Conflict != nil {
+			groups.unmerged = append(groups.unmerged, fmt.Sprintf("%-17s%s", entry.Conflict.Describe()+":", entry.Path))
+			continue
+		}
+		switch entry.Index {
+		case StatusUntracked:
+			groups.untracked = append(groups.untracked, entry.Path)
+			continue
+		case StatusIgnored:
+			groups.ignored = append(groups.ignored, entry.Path)
+			continue
+		case StatusRenamed:
+			groups.staged = append(groups.staged, fmt.Sprintf("renamed:    %s -> %s", entry.OrigPath, entry.Path))
+		case StatusAdded, StatusModified, StatusDeleted:
+			groups.staged = append(groups.staged, describeChange(entry.Index, entry.Path))
+		}
+		if entry.Worktree != StatusUnmodified {
+			groups.unstaged = append(groups.unstaged, describeChange(entry.Worktree, entry.Path))
+		}
+	}
+	return groups
+}
+
+// PrintStatus prints the human-readable, colored status.
 func PrintStatus(statusInfo *StatusInfo) {
 	// Print the current branch
-	fmt.Printf("On branch %s\n", statusInfo.Branch)
+	if statusInfo.Branch == "" {
+		fmt.Printf("%sHEAD detached at %s%s\n", ColorRed, abbrevHash(statusInfo.Head), ColorReset)
+	} else {
+		fmt.Printf("On branch %s\n", statusInfo.Branch)
+	}
+
+	groups := groupStatus(statusInfo)
+
+	if statusInfo.Operation != "" {
+		printOperation(statusInfo, len(groups.unmerged) > 0)
+	}
 
 	// Variable to know if the repository is clean
 	isClean := true
 
 	// Show files ready for commit (Staged)
-	if len(statusInfo.Staged) > 0 {
+	if len(groups.staged) > 0 {
 		isClean = false
 		fmt.Println("\nChanges to be committed:")
 		fmt.Println("  (use \"gogit reset <file>...\" to unstage)")
-		for _, file := range statusInfo.Staged {
+		for _, file := range groups.staged {
 			fmt.Printf("%s\t%s%s\n", ColorGreen, file, ColorReset)
 		}
 	}
 
+	// Show files with unresolved conflicts
+	if len(groups.unmerged) > 0 {
+		isClean = false
+		fmt.Println("\nUnmerged paths:")
+		fmt.Println("  (use \"gogit add <file>...\" to mark resolution)")
+		for _, file := range groups.unmerged {
+			fmt.Printf("%s\t%s%s\n", ColorRed, file, ColorReset)
+		}
+	}
+
 	// Show files with changes not staged for commit (Unstaged)
-	if len(statusInfo.Unstaged) > 0 {
+	if len(groups.unstaged) > 0 {
 		isClean = false
 		fmt.Println("\nChanges not staged for commit:")
 		fmt.Println("  (use \"gogit add <file>...\" to update what will be committed)")
-		for _, file := range statusInfo.Unstaged {
+		for _, file := range groups.unstaged {
 			fmt.Printf("%s\t%s%s\n", ColorRed, file, ColorReset)
 		}
 	}
 
 	// Show untracked files
-	if len(statusInfo.Untracked) > 0 {
+	if len(groups.untracked) > 0 {
 		isClean = false
 		fmt.Println("\nUntracked files:")
 		fmt.Println("  (use \"gogit add <file>...\" to include in what will be committed)")
-		for _, file := range statusInfo.Untracked {
+		for _, file := range groups.untracked {
+			fmt.Printf("%s        %s%s\n", ColorRed, file, ColorReset)
+		}
+	}
+
+	// Show ignored files, only present when they were requested
+	if len(groups.ignored) > 0 {
+		fmt.Println("\nIgnored files:")
+		for _, file := range groups.ignored {
 			fmt.Printf("%s        %s%s\n", ColorRed, file, ColorReset)
 		}
 	}
@@ -56,3 +120,232 @@ func PrintStatus(statusInfo *StatusInfo) {
 		fmt.Println("\nnothing to commit, working tree clean")
 	}
 }
+
+// printOperation explains how to go on with an interrupted cherry-pick,
+// revert or rebase, or the bisection in progress.
+func printOperation(statusInfo *StatusInfo, conflicts bool) {
+	switch statusInfo.Operation {
+	case "rebase":
+		printRebase(statusInfo, conflicts)
+		return
+	case "bisect":
+		fmt.Printf("\nYou are currently bisecting, started from branch '%s'.\n", statusInfo.OperationBranch)
+		fmt.Println("  (use \"gogit bisect reset\" to get back to the original branch)")
+		return
+	}
+	verb := map[string]string{"cherry-pick": "cherry-picking", "revert": "reverting"}[statusInfo.Operation]
+	if statusInfo.OperationHead == "" {
+		fmt.Printf("\n%s is in progress.\n", statusInfo.Operation)
+	} else {
+		fmt.Printf("\nYou are currently %s commit %s.\n", verb, abbrevHash(statusInfo.OperationHead))
+	}
+	if conflicts {
+		fmt.Printf("  (fix conflicts and run \"gogit %s --continue\")\n", statusInfo.Operation)
+	} else {
+		fmt.Printf("  (all conflicts fixed: run \"gogit %s --continue\")\n", statusInfo.Operation)
+	}
+	fmt.Printf("  (use \"gogit %s --skip\" to skip this patch)\n", statusInfo.Operation)
+	fmt.Printf("  (use \"gogit %s --abort\" to cancel the %s operation)\n", statusInfo.Operation, statusInfo.Operation)
+}
+
+func printRebase(statusInfo *StatusInfo, conflicts bool) {
+	onto := abbrevHash(statusInfo.OperationOnto)
+	switch {
+	case statusInfo.OperationHead == "":
+		fmt.Printf("\nYou are currently editing a commit while rebasing branch '%s' on '%s'.\n", statusInfo.OperationBranch, onto)
+		fmt.Println("  (use \"gogit commit --amend\" to amend the current commit)")
+		fmt.Println("  (use \"gogit rebase --continue\" once you are satisfied with your changes)")
+		return
+	case conflicts:
+		fmt.Printf("\nYou are currently rebasing branch '%s' on '%s'.\n", statusInfo.OperationBranch, onto)
+		fmt.Println("  (fix conflicts and then run \"gogit rebase --continue\")")
+	default:
+		fmt.Printf("\nYou are currently rebasing branch '%s' on '%s'.\n", statusInfo.OperationBranch, onto)
+		fmt.Println("  (all conflicts fixed: run \"gogit rebase --continue\")")
+	}
+	fmt.Println("  (use \"gogit rebase --skip\" to skip this patch)")
+	fmt.Println("  (use \"gogit rebase --abort\" to check out the original branch)")
+}
+
+// describeChange renders a status code the way the long format lists it.
+func describeChange(code StatusCode, path string) string {
+	switch code {
+	case StatusAdded:
+		return fmt.Sprintf("new file:   %s", path)
+	case StatusDeleted:
+		return fmt.Sprintf("deleted:    %s", path)
+	}
+	return fmt.Sprintf("modified:   %s", path)
+}
+
+// PrintStatusShort prints one colored "XY path" line per entry.
+func PrintStatusShort(statusInfo *StatusInfo, opts *StatusOptions) {
+	if opts.Branch {
+		fmt.Printf("## %s%s%s\n", ColorGreen, branchHeader(statusInfo), ColorReset)
+	}
+	for _, entry := range statusInfo.Entries {
+		path := quotePath(entry.Path)
+		if entry.Index == StatusRenamed {
+			path = quotePath(entry.OrigPath) + " -> " + path
+		}
+
+		if entry.Index == StatusUntracked || entry.Index == StatusIgnored || entry.Conflict != nil {
+			fmt.Printf("%s%c%c%s %s\n", ColorRed, entry.Index, entry.Worktree, ColorReset, path)
+			continue
+		}
+		fmt.Printf("%s%c%s%s%c%s %s\n", ColorGreen, entry.Index, ColorReset, ColorRed, entry.Worktree, ColorReset, path)
+	}
+}
+
+// PrintStatusPorcelainV1 prints the stable `--porcelain=v1` format.
+func PrintStatusPorcelainV1(statusInfo *StatusInfo, opts *StatusOptions) {
+	terminator := "\n"
+	if opts.NullTerminated {
+		terminator = "\x00"
+	}
+
+	if opts.Branch {
+		fmt.Printf("## %s%s", branchHeader(statusInfo), terminator)
+	}
+	for _, entry := range statusInfo.Entries {
+		switch {
+		case opts.NullTerminated && entry.Index == StatusRenamed:
+			fmt.Printf("%c%c %s\x00%s\x00", entry.Index, entry.Worktree, entry.Path, entry.OrigPath)
+		case opts.NullTerminated:
+			fmt.Printf("%c%c %s\x00", entry.Index, entry.Worktree, entry.Path)
+		case entry.Index == StatusRenamed:
+			fmt.Printf("%c%c %s -> %s\n", entry.Index, entry.Worktree, quotePath(entry.OrigPath), quotePath(entry.Path))
+		default:
+			fmt.Printf("%c%c %s\n", entry.Index, entry.Worktree, quotePath(entry.Path))
+		}
+	}
+}
+
+// PrintStatusPorcelainV2 prints the `--porcelain=v2` format, which also
+// carries file modes and object hashes.
+func PrintStatusPorcelainV2(statusInfo *StatusInfo, opts *StatusOptions) {
+	terminator := "\n"
+	pathSeparator := "\t"
+	if opts.NullTerminated {
+		terminator = "\x00"
+		pathSeparator = "\x00"
+	}
+	path := func(p string) string {
+		if opts.NullTerminated {
+			return p
+		}
+		return quotePath(p)
+	}
+
+	if opts.Branch {
+		oid := statusInfo.Head
+		if oid == "" {
+			oid = "(initial)"
+		}
+		head := statusInfo.Branch
+		if head == "" {
+			head = "(detached)"
+		}
+		fmt.Printf("# branch.oid %s%s", oid, terminator)
+		fmt.Printf("# branch.head %s%s", head, terminator)
+	}
+
+	for _, entry := range statusInfo.Entries {
+		switch entry.Index {
+		case StatusUntracked:
+			fmt.Printf("? %s%s", path(entry.Path), terminator)
+			continue
+		case StatusIgnored:
+			fmt.Printf("! %s%s", path(entry.Path), terminator)
+			continue
+		}
+
+		xy := porcelainV2Code(entry.Index) + porcelainV2Code(entry.Worktree)
+		if u := entry.Conflict; u != nil {
+			fmt.Printf("u %s N... %s %s %s %s %s %s %s %s%s", xy,
+				fileMode(u.Base != ""), fileMode(u.Ours != ""), fileMode(u.Theirs != ""), fileMode(true),
+				objectIDOrZero(u.Base), objectIDOrZero(u.Ours), objectIDOrZero(u.Theirs), path(entry.Path), terminator)
+			continue
+		}
+		modeHead := fileMode(entry.HeadHash != "")
+		modeIndex := fileMode(entry.IndexHash != "")
+		modeWorktree := fileMode(entry.IndexHash != "" && entry.Worktree != StatusDeleted)
+		fields := fmt.Sprintf("%s N... %s %s %s %s %s", xy, modeHead, modeIndex, modeWorktree,
+			objectIDOrZero(entry.HeadHash), objectIDOrZero(entry.IndexHash))
+
+		if entry.Index == StatusRenamed {
+			fmt.Printf("2 %s R100 %s%s%s%s", fields, path(entry.Path), pathSeparator, path(entry.OrigPath), terminator)
+			continue
+		}
+		fmt.Printf("1 %s %s%s", fields, path(entry.Path), terminator)
+	}
+}
+
+// branchHeader returns the branch line of the short and porcelain v1 formats.
+func branchHeader(statusInfo *StatusInfo) string {
+	if statusInfo.Branch == "" {
+		return "HEAD (no branch)"
+	}
+	if statusInfo.Head == "" {
+		return "No commits yet on " + statusInfo.Branch
+	}
+	return statusInfo.Branch
+}
+
+func porcelainV2Code(code StatusCode) string {
+	if code == StatusUnmodified {
+		return "."
+	}
+	return string(code)
+}
+
+func fileMode(exists bool) string {
+	if exists {
+		return "100644"
+	}
+	return "000000"
+}
+
+func objectIDOrZero(hash string) string {
+	if hash == "" {
+		return strings.Repeat("0", 40)
+	}
+	return hash
+}
+
+// quotePath wraps a path in double quotes with C-style escapes when it
+// contains characters that would confuse line-oriented parsers.
+func quotePath(path string) string {
+	needsQuoting := false
+	for i := 0; i < len(path); i++ {
+		if c := path[i]; c < 0x20 || c == '"' || c == '\\' || c >= 0x7f {
+			needsQuoting = true
+			break
+		}
+	}
+	if !needsQuoting {
+		return path
+	}
+
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for i := 0; i < len(path); i++ {
+		switch c := path[i]; c {
+		case '"', '\\':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case '\t':
+			sb.WriteString("\\t")
+		case '\n':
+			sb.WriteString("\\n")
+		default:
+			if c < 0x20 || c >= 0x7f {
+				fmt.Fprintf(&sb, "\\%03o", c)
+			} else {
+				sb.WriteByte(c)
+			}
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
@@ -0,0 +1,297 @@
+package gogit
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PushOptions controls Push.
+type PushOptions struct {
+	// Remote is the remote to push to; by default the current branch's
+	// upstream remote, or origin.
+	Remote string
+	// Refspecs are "[+]<src>[:<dst>]" values; ":<dst>" deletes dst. By
+	// default the current branch is pushed to the branch of the same name.
+	Refspecs []string
+	// Force allows updates that are not fast-forwards.
+	Force bool
+	// ForceWithLease allows them too, but only for refs whose remote value
+	// is still the one expected. Leases maps a remote ref to that value;
+	// refs without an entry are expected to match their tracking ref.
+	ForceWithLease bool
+	Leases         map[string]string
+	// SetUpstream makes each pushed branch the upstream of the local one.
+	SetUpstream bool
+}
+
+// Push copies local commits to a remote and updates its refs. Updates
+// that would lose commits on the remote are rejected unless forced.
+func Push(opts *PushOptions) error {
+	name := opts.Remote
+	if name == "" {
+		var err error
+		if name, err = defaultRemote(); err != nil {
+			return err
+		}
+	}
+	remote, err := GetRemote(name)
+	if err != nil {
+		return err
+	}
+	t, err := openTransport(remote.URL)
+	if err != nil {
+		return err
+	}
+
+	specs := opts.Refspecs
+	if len(specs) == 0 {
+		branch, err := CurrentBranch()
+		if err != nil {
+			return err
+		}
+		if branch == "" {
+			return fmt.Errorf("you are not currently on a branch; name the ref to push")
+		}
+		specs = []string{branch}
+	}
+	updates, err := planPush(specs, opts.Force)
+	if err != nil {
+		return err
+	}
+	ad, err := t.advertise(true)
+	if err != nil {
+		return err
+	}
+	for i := range updates {
+		updates[i].Old = ad.ref(updates[i].Ref)
+	}
+
+	// Decide on each update before touching the remote.
+	type outcome struct {
+		flag, summary, reason string
+		accepted              bool
+	}
+	outcomes := make([]outcome, len(updates))
+	for i, update := range updates {
+		out := &outcomes[i]
+		switch {
+		case update.New == update.Old:
+			out.flag, out.summary = "=", "[up to date]"
+			continue
+		case update.New == "":
+			out.flag, out.summary, out.accepted = "-", "[deleted]", true
+			if update.Old == "" {
+				out.flag, out.summary, out.reason, out.accepted = "!", "[rejected]", "remote ref does not exist", false
+			}
+			continue
+		case update.Old == "":
+			kind := "[new branch]"
+			if strings.HasPrefix(update.Ref, "refs/tags/") {
+				kind = "[new tag]"
+			}
+			out.flag, out.summary, out.accepted = "*", kind, true
+			continue
+		}
+
+		fastForward := false
+		if ObjectExists(update.Old) && !strings.HasPrefix(update.Ref, "refs/tags/") {
+			if fastForward, err = IsAncestor(update.Old, update.New); err != nil {
+				return err
+			}
+		}
+		if opts.ForceWithLease && !update.Force {
+			expected, ok := opts.Leases[update.Ref]
+			if !ok {
+				expected, err = ReadRef(remoteRefs(name) + strings.TrimPrefix(update.Ref, "refs/heads/"))
+				if err != nil {
+					return err
+				}
+			}
+			if update.Old != expected {
+				out.flag, out.summary, out.reason = "!", "[rejected]", "stale info"
+				continue
+			}
+			update.Force = true
+		}
+		switch {
+		case fastForward:
+			out.flag, out.summary, out.accepted = " ", abbrevHash(update.Old)+".."+abbrevHash(update.New), true
+		case update.Force:
+			out.flag, out.summary, out.reason, out.accepted = "+", abbrevHash(update.Old)+"..."+abbrevHash(update.New), "forced update", true
+		case strings.HasPrefix(update.Ref, "refs/tags/"):
+			out.flag, out.summary, out.reason = "!", "[rejected]", "already exists"
+		case !ObjectExists(update.Old):
+			out.flag, out.summary, out.reason = "!", "[rejected]", "fetch first"
+		default:
+			out.flag, out.summary, out.reason = "!", "[rejected]", "non-fast-forward"
+		}
+	}
+
+	// The remote applies the accepted updates, and may still refuse some,
+	// such as an update of the branch checked out there.
+	var accepted []refUpdate
+	for i, update := range updates {
+		if outcomes[i].accepted {
+			accepted = append(accepted, update)
+		}
+	}
+	if len(accepted) > 0 {
+		refused, err := t.push(accepted)
+		if err != nil {
+			return err
+		}
+		for i, update := range updates {
+			if reason, ok := refused[update.Ref]; ok && outcomes[i].accepted {
+				outcomes[i] = outcome{flag: "!", summary: "[remote rejected]", reason: reason}
+			}
+		}
+	}
+
+	// Report, and bring the tracking refs up to date.
+	changed, rejected := false, false
+	for i, update := range updates {
+		out := outcomes[i]
+		if out.flag == "=" {
+			continue
+		}
+		if !changed && !rejected {
+			fmt.Printf("To %s\n", remote.URL)
+		}
+		if update.New == "" {
+			printRefUpdate(out.flag, out.summary, shortRefName(update.Ref), "", out.reason)
+		} else {
+			printRefUpdate(out.flag, out.summary, shortRefName(update.Source), shortRefName(update.Ref), out.reason)
+		}
+		if !out.accepted {
+			rejected = true
+			continue
+		}
+		changed = true
+
+		branch, isBranch := strings.CutPrefix(update.Ref, "refs/heads/")
+		if !isBranch {
+			continue
+		}
+		tracking := remoteRefs(name) + branch
+		if update.New == "" {
+			if existing, err := ReadRef(tracking); err != nil {
+				return err
+			} else if existing != "" {
+				if err := DeleteRef(tracking); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if err := UpdateRef(tracking, update.New, "update by push"); err != nil {
+			return err
+		}
+	}
+	if !changed && !rejected {
+		fmt.Println("Everything up-to-date")
+	}
+
+	if opts.SetUpstream {
+		for i, update := range updates {
+			local, isLocal := strings.CutPrefix(update.Source, "refs/heads/")
+			if update.New == "" || !isLocal || outcomes[i].flag == "!" {
+				continue
+			}
+			if err := setUpstream(local, name, update.Ref); err != nil {
+				return err
+			}
+			fmt.Printf("branch '%s' set up to track '%s/%s'.\n", local, name, shortRefName(update.Ref))
+		}
+	}
+
+	if rejected {
+		for _, out := range outcomes {
+			switch out.reason {
+			case "branch is currently checked out", "deletion of the current branch prohibited":
+				fmt.Println("hint: A non-bare repository refuses to update its checked-out branch. Push")
+				fmt.Println("hint: to a bare repository, or set receive.denyCurrentBranch there to")
+				fmt.Println("hint: 'warn', 'ignore' or 'updateInstead'.")
+			case "non-fast-forward", "fetch first", "stale info":
+				fmt.Println("hint: Updates were rejected because the remote contains work that you do not")
+				fmt.Println("hint: have locally. Fetch it first, integrate it, and push again, or use")
+				fmt.Println("hint: --force-with-lease to overwrite it.")
+			default:
+				continue
+			}
+			break
+		}
+		return fmt.Errorf("failed to push some refs to '%s'", remote.URL)
+	}
+	return nil
+}
+
+// planPush resolves push refspecs into updates of remote refs. Old is
+// left for the caller to fill in from the remote.
+func planPush(specs []string, force bool) ([]refUpdate, error) {
+	var updates []refUpdate
+	for _, value := range specs {
+		spec := parseRefspec(value)
+		update := refUpdate{Force: spec.Force || force}
+
+		if spec.Src == "" {
+			if spec.Dst == "" {
+				return nil, fmt.Errorf("invalid refspec '%s'", value)
+			}
+			update.Ref = qualifyRemoteRef(spec.Dst, "refs/heads/")
+			updates = append(updates, update)
+			continue
+		}
+
+		source, err := localRefName(spec.Src)
+		if err != nil {
+			return nil, err
+		}
+		if update.New, err = ResolveRevision(spec.Src); err != nil {
+			return nil, fmt.Errorf("src refspec %s does not match any", spec.Src)
+		}
+		update.Source = source
+		if source == "" {
+			update.Source = spec.Src
+		}
+		switch {
+		case spec.Dst != "":
+			namespace := "refs/heads/"
+			if strings.HasPrefix(source, "refs/tags/") {
+				namespace = "refs/tags/"
+			}
+			update.Ref = qualifyRemoteRef(spec.Dst, namespace)
+		case source != "":
+			update.Ref = source
+		default:
+			return nil, fmt.Errorf("the destination of '%s' must be a full ref name", value)
+		}
+		updates = append(updates, update)
+	}
+	return updates, nil
+}
+
+// localRefName returns the full name of a local branch or tag, or "" if
+// name is not one.
+func localRefName(name string) (string, error) {
+	if strings.HasPrefix(name, "refs/") {
+		return name, nil
+	}
+	for _, candidate := range []string{"refs/heads/" + name, "refs/tags/" + name} {
+		hash, err := ReadRef(candidate)
+		if err != nil {
+			return "", err
+		}
+		if hash != "" {
+			return candidate, nil
+		}
+	}
+	return "", nil
+}
+
+// qualifyRemoteRef puts a short destination name into namespace.
+func qualifyRemoteRef(name, namespace string) string {
+	if strings.HasPrefix(name, "refs/") {
+		return name
+	}
+	return namespace + name
+}
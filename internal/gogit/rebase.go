@@ -0,0 +1,796 @@
+package gogit
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// RebaseOptions controls Rebase.
+type RebaseOptions struct {
+	// Upstream selects the commits to replay: those on HEAD that are not
+	// on Upstream.
+	Upstream string
+	// Onto is where the commits are replayed; Upstream by default.
+	Onto string
+	// Interactive lets the user edit the todo list first.
+	Interactive bool
+}
+
+// A rebase keeps its progress in .gogit/rebase-merge so that it can stop
+// and be resumed, even after a crash:
+//
+//	head-name       the branch being rebased, or "detached HEAD"
+//	orig-head       the commit HEAD pointed to before the rebase
+//	onto            the commit the todo list is replayed on
+//	git-rebase-todo the steps still to run
+//	done            the steps already run, the last one being the current
+//	interactive     present for rebase -i
+//	stopped-sha     the commit of a step stopped by a conflict
+//	stopped-action  the action of that step
+//	stopped-head    HEAD when the rebase stopped
+//	amend           HEAD after an "edit" step stopped
+//	message-squash  the message being built by a squash/fixup chain
+//	squash-edit     present when that chain contains a squash
+func rebaseDir() string {
+	return filepath.Join(RepoPath, "rebase-merge")
+}
+
+func rebaseFile(name string) string {
+	return filepath.Join(rebaseDir(), name)
+}
+
+// errRebasePaused is returned when an "edit" step stops the rebase on
+// purpose; the command still succeeds.
+var errRebasePaused = errors.New("rebase paused")
+
+// rebaseStep is one line of the todo list.
+type rebaseStep struct {
+	Action string
+	// Hash is the commit of every action but exec.
+	Hash string
+	// Command is the shell command of exec.
+	Command string
+}
+
+// rebaseActions maps todo commands and their abbreviations to actions.
+var rebaseActions = map[string]string{
+	"pick": "pick", "p": "pick",
+	"reword": "reword", "r": "reword",
+	"edit": "edit", "e": "edit",
+	"squash": "squash", "s": "squash",
+	"fixup": "fixup", "f": "fixup",
+	"exec": "exec", "x": "exec",
+	"drop": "drop", "d": "drop",
+}
+
+const rebaseTodoHelp = `
+# Commands:
+# p, pick <commit> = use commit
+# r, reword <commit> = use commit, but edit the commit message
+# e, edit <commit> = use commit, but stop for amending
+# s, squash <commit> = use commit, but meld into previous commit
+# f, fixup <commit> = like "squash", but discard this commit's log message
+# x, exec <command> = run command (the rest of the line) using shell
+# d, drop <commit> = remove commit
+#
+# These lines can be re-ordered; they are executed from top to bottom.
+#
+# If you remove a line here THAT COMMIT WILL BE LOST.
+#
+# However, if you remove everything, the rebase will be aborted.
+`
+
+// RebaseInProgress reports whether a rebase has stopped and waits for
+// --continue, --skip or --abort.
+func RebaseInProgress() bool {
+	_, err := os.Stat(rebaseDir())
+	return err == nil
+}
+
+// Rebase replays the commits of HEAD that are not on opts.Upstream on top
+// of opts.Onto, one by one, then moves the current branch to the result.
+func Rebase(opts *RebaseOptions) error {
+	if RebaseInProgress() {
+		return fmt.Errorf("a rebase is already in progress\nhint: try \"gogit rebase (--continue | --skip | --abort)\"")
+	}
+	if operation, _, err := currentOperation(); err != nil {
+		return err
+	} else if operation != "" {
+		return fmt.Errorf("a %s is in progress\nhint: finish it first with \"gogit %s --continue\" or \"--abort\"", operation, operation)
+	}
+	if err := requireCleanWorktree("rebase"); err != nil {
+		return err
+	}
+
+	headRef, head, err := ReadHead()
+	if err != nil {
+		return err
+	}
+	if head == "" {
+		return fmt.Errorf("cannot rebase: HEAD does not point to a commit yet")
+	}
+	upstream, err := resolveCommit(opts.Upstream)
+	if err != nil {
+		return err
+	}
+	onto := upstream
+	ontoName := opts.Upstream
+	if opts.Onto != "" {
+		if onto, err = resolveCommit(opts.Onto); err != nil {
+			return err
+		}
+		ontoName = opts.Onto
+	}
+
+	commits, err := commitsSince(head, upstream)
+	if err != nil {
+		return err
+	}
+	headName := headRef
+	if headName == "" {
+		headName = "detached HEAD"
+	}
+	if !opts.Interactive && onto == upstream {
+		if upToDate, err := IsAncestor(upstream, head); err != nil {
+			return err
+		} else if upToDate {
+			fmt.Printf("Current branch %s is up to date.\n", strings.TrimPrefix(headName, "refs/heads/"))
+			return nil
+		}
+	}
+
+	var steps []rebaseStep
+	for _, commit := range commits {
+		steps = append(steps, rebaseStep{Action: "pick", Hash: commit})
+	}
+	if opts.Interactive {
+		if steps, err = editTodo(steps, upstream, head, onto); err != nil {
+			return err
+		}
+		if len(steps) == 0 {
+			fmt.Println("Nothing to do")
+			return nil
+		}
+	}
+
+	if err := os.MkdirAll(rebaseDir(), 0755); err != nil {
+		return fmt.Errorf("error creating rebase state: %w", err)
+	}
+	state := map[string]string{"head-name": headName, "orig-head": head, "onto": onto, "done": ""}
+	if opts.Interactive {
+		state["interactive"] = ""
+	}
+	for name, value := range state {
+		if err := writeRebaseFile(name, value); err != nil {
+			return err
+		}
+	}
+	if err := writeRebaseTodo("git-rebase-todo", steps); err != nil {
+		return err
+	}
+
+	// Replay from a detached HEAD at onto; the branch only moves at the end.
+	headTree, err := commitTree(head)
+	if err != nil {
+		return err
+	}
+	ontoTree, err := commitTree(onto)
+	if err != nil {
+		return err
+	}
+	if err := checkoutTree(headTree, ontoTree, false, "rebase"); err != nil {
+		os.RemoveAll(rebaseDir())
+		return err
+	}
+	if err := DetachHead(onto, "rebase (start): checkout "+ontoName); err != nil {
+		return err
+	}
+	return runRebase()
+}
+
+// requireCleanWorktree fails when tracked files have staged or unstaged
+// changes.
+func requireCleanWorktree(action string) error {
+	statusInfo, err := GetStatus(false)
+	if err != nil {
+		return err
+	}
+	for _, entry := range statusInfo.Entries {
+		if entry.Index != StatusUntracked {
+			return fmt.Errorf("cannot %s: you have uncommitted changes\nPlease commit or stash them.", action)
+		}
+	}
+	return nil
+}
+
+// commitsSince returns the commits reachable from head but not from
+// upstream, oldest first.
+func commitsSince(head, upstream string) ([]string, error) {
+	excluded := make(map[string]bool)
+	for hash := upstream; hash != ""; {
+		excluded[hash] = true
+		commit, err := ReadCommit(hash)
+		if err != nil {
+			return nil, err
+		}
+		hash = commit.Parent
+	}
+
+	var commits []string
+	for hash := head; hash != "" && !excluded[hash]; {
+		commits = append(commits, hash)
+		commit, err := ReadCommit(hash)
+		if err != nil {
+			return nil, err
+		}
+		hash = commit.Parent
+	}
+	for i, j := 0, len(commits)-1; i < j; i, j = i+1, j-1 {
+		commits[i], commits[j] = commits[j], commits[i]
+	}
+	return commits, nil
+}
+
+// editTodo opens the todo list in the editor and parses the result.
+func editTodo(steps []rebaseStep, upstream, head, onto string) ([]rebaseStep, error) {
+	if err := os.MkdirAll(RepoPath, 0755); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(RepoPath, "REBASE_TODO")
+	defer os.Remove(path)
+
+	content, err := formatTodo(steps)
+	if err != nil {
+		return nil, err
+	}
+	content += fmt.Sprintf("\n# Rebase %s..%s onto %s (%d commands)\n", abbrevHash(upstream), abbrevHash(head), abbrevHash(onto), len(steps))
+	content += rebaseTodoHelp
+	edited, err := EditFile(path, content)
+	if err != nil {
+		return nil, err
+	}
+	steps, err = parseTodo(edited)
+	if err != nil {
+		return nil, err
+	}
+	for _, step := range steps {
+		if step.Action == "exec" || step.Action == "drop" {
+			continue
+		}
+		if step.Action == "squash" || step.Action == "fixup" {
+			return nil, fmt.Errorf("cannot '%s' without a previous commit", step.Action)
+		}
+		break
+	}
+	return steps, nil
+}
+
+// formatTodo renders steps the way the todo list shows them.
+func formatTodo(steps []rebaseStep) (string, error) {
+	var sb strings.Builder
+	for _, step := range steps {
+		if step.Action == "exec" {
+			fmt.Fprintf(&sb, "exec %s\n", step.Command)
+			continue
+		}
+		commit, err := ReadCommit(step.Hash)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&sb, "%s %s %s\n", step.Action, abbrevHash(step.Hash), subjectOf(commit.Message))
+	}
+	return sb.String(), nil
+}
+
+// parseTodo reads a todo list, skipping comments and blank lines and
+// resolving commits to full hashes.
+func parseTodo(content string) ([]rebaseStep, error) {
+	var steps []rebaseStep
+	for number, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		command, rest, _ := strings.Cut(line, " ")
+		action, ok := rebaseActions[command]
+		if !ok {
+			return nil, fmt.Errorf("invalid command '%s' on line %d of the todo list", command, number+1)
+		}
+		rest = strings.TrimSpace(rest)
+		if action == "exec" {
+			if rest == "" {
+				return nil, fmt.Errorf("missing command for exec on line %d of the todo list", number+1)
+			}
+			steps = append(steps, rebaseStep{Action: action, Command: rest})
+			continue
+		}
+		rev, _, _ := strings.Cut(rest, " ")
+		if rev == "" {
+			return nil, fmt.Errorf("missing commit for %s on line %d of the todo list", action, number+1)
+		}
+		hash, err := resolveCommit(rev)
+		if err != nil {
+			return nil, fmt.Errorf("line %d of the todo list: %w", number+1, err)
+		}
+		steps = append(steps, rebaseStep{Action: action, Hash: hash})
+	}
+	return steps, nil
+}
+
+func readRebaseFile(name string) (string, error) {
+	content, err := os.ReadFile(rebaseFile(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("error reading rebase state: %w", err)
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+func writeRebaseFile(name, value string) error {
+	if value != "" {
+		value += "\n"
+	}
+	if err := os.WriteFile(rebaseFile(name), []byte(value), 0644); err != nil {
+		return fmt.Errorf("error writing rebase state: %w", err)
+	}
+	return nil
+}
+
+func removeRebaseFiles(names ...string) error {
+	for _, name := range names {
+		if err := os.Remove(rebaseFile(name)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("error writing rebase state: %w", err)
+		}
+	}
+	return nil
+}
+
+func readRebaseTodo(name string) ([]rebaseStep, error) {
+	content, err := readRebaseFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return parseTodo(content)
+}
+
+func writeRebaseTodo(name string, steps []rebaseStep) error {
+	content, err := formatTodo(steps)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(rebaseFile(name), []byte(content), 0644); err != nil {
+		return fmt.Errorf("error writing rebase state: %w", err)
+	}
+	return nil
+}
+
+// runRebase runs the remaining steps of the todo list and finishes the
+// rebase when it is empty.
+func runRebase() error {
+	for {
+		todo, err := readRebaseTodo("git-rebase-todo")
+		if err != nil {
+			return err
+		}
+		if len(todo) == 0 {
+			return finishRebase()
+		}
+
+		// Move the step to "done" before running it, so that a crash
+		// never runs it twice.
+		step := todo[0]
+		done, err := readRebaseTodo("done")
+		if err != nil {
+			return err
+		}
+		if err := writeRebaseTodo("done", append(done, step)); err != nil {
+			return err
+		}
+		if err := writeRebaseTodo("git-rebase-todo", todo[1:]); err != nil {
+			return err
+		}
+
+		var next *rebaseStep
+		if len(todo) > 1 {
+			next = &todo[1]
+		}
+		if err := runRebaseStep(step, next); errors.Is(err, errRebasePaused) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// runRebaseStep runs one todo step. next is the step after it, if any.
+func runRebaseStep(step rebaseStep, next *rebaseStep) error {
+	switch step.Action {
+	case "drop":
+		return nil
+	case "exec":
+		fmt.Printf("Executing: %s\n", step.Command)
+		cmd := exec.Command("sh", "-c", step.Command)
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+		if err := cmd.Run(); err != nil {
+			return stopped("execution failed: %s\n"+
+				"You can fix the problem, and then run\n\n"+
+				"  gogit rebase --continue", step.Command)
+		}
+		return nil
+	}
+
+	commit, err := ReadCommit(step.Hash)
+	if err != nil {
+		return err
+	}
+	_, head, err := ReadHead()
+	if err != nil {
+		return err
+	}
+
+	// A pick whose parent is already HEAD needs no merge.
+	if step.Action == "pick" && commit.Parent == head {
+		headTree, err := commitTree(head)
+		if err != nil {
+			return err
+		}
+		tree, err := commitTree(commit.Hash)
+		if err != nil {
+			return err
+		}
+		if err := checkoutTree(headTree, tree, false, "rebase"); err != nil {
+			return err
+		}
+		return DetachHead(commit.Hash, "rebase (pick): "+subjectOf(commit.Message))
+	}
+
+	_, conflicts, err := mergeCommit(commit, false, "rebase")
+	if err != nil {
+		return err
+	}
+	if len(conflicts) > 0 {
+		stoppedState := map[string]string{"stopped-sha": commit.Hash, "stopped-action": step.Action, "stopped-head": head}
+		for name, value := range stoppedState {
+			if err := writeRebaseFile(name, value); err != nil {
+				return err
+			}
+		}
+		if err := os.WriteFile(mergeMsgPath(), []byte(conflictMessage(commit.Message, conflicts)), 0644); err != nil {
+			return fmt.Errorf("error writing %s: %w", mergeMsgPath(), err)
+		}
+		return stopped("could not apply %s... %s\n"+
+			"hint: Resolve all conflicts manually, mark them as resolved with\n"+
+			"hint: \"gogit add <conflicted_files>\", then run \"gogit rebase --continue\".\n"+
+			"hint: You can instead skip this commit: run \"gogit rebase --skip\".\n"+
+			"hint: To abort and get back to the state before \"gogit rebase\", run \"gogit rebase --abort\".",
+			abbrevHash(commit.Hash), subjectOf(commit.Message))
+	}
+	return commitRebaseStep(step, commit, next)
+}
+
+// commitRebaseStep records the merged index for a step: a new commit for
+// pick, reword and edit, or a rewrite of HEAD for squash and fixup.
+func commitRebaseStep(step rebaseStep, commit *Commit, next *rebaseStep) error {
+	_, head, err := ReadHead()
+	if err != nil {
+		return err
+	}
+	headTree, err := commitTree(head)
+	if err != nil {
+		return err
+	}
+	indexMap, err := ReadIndex()
+	if err != nil {
+		return err
+	}
+	subject := subjectOf(commit.Message)
+
+	if step.Action == "squash" || step.Action == "fixup" {
+		return squashIntoHead(step, commit, head, indexMap, next)
+	}
+
+	if maps.Equal(indexMap, headTree) {
+		fmt.Printf("dropping %s %s -- patch contents already upstream\n", abbrevHash(commit.Hash), subject)
+		return nil
+	}
+
+	message := commit.Message
+	if step.Action == "reword" {
+		edited, err := EditFile(rebaseFile("message"), message+"\n")
+		if err != nil {
+			return err
+		}
+		if message = CleanupMessage(edited, true); message == "" {
+			return fmt.Errorf("aborting commit due to empty commit message")
+		}
+	}
+	hash, err := recordCommit(indexMap, head, commit.Author, commit.Date, message,
+		fmt.Sprintf("rebase (%s): %s", step.Action, subjectOf(message)))
+	if err != nil {
+		return err
+	}
+
+	if step.Action == "edit" {
+		if err := writeRebaseFile("amend", hash); err != nil {
+			return err
+		}
+		fmt.Printf("Stopped at %s... %s\n"+
+			"You can amend the commit now, with\n\n"+
+			"  gogit commit --amend\n\n"+
+			"Once you are satisfied with your changes, run\n\n"+
+			"  gogit rebase --continue\n", abbrevHash(hash), subject)
+		return errRebasePaused
+	}
+	return nil
+}
+
+// squashIntoHead melds the merged index into HEAD. The message of a chain
+// of squash and fixup steps is built up in message-squash; squash adds
+// the commit's message, fixup drops it. At the end of a chain that
+// contains a squash, the combined message is opened in the editor.
+func squashIntoHead(step rebaseStep, commit *Commit, head string, indexMap map[string]string, next *rebaseStep) error {
+	previous, err := ReadCommit(head)
+	if err != nil {
+		return err
+	}
+	message, err := readRebaseFile("message-squash")
+	if err != nil {
+		return err
+	}
+	if message == "" {
+		message = previous.Message
+	}
+	if step.Action == "squash" {
+		message += "\n\n" + commit.Message
+		if err := writeRebaseFile("squash-edit", ""); err != nil {
+			return err
+		}
+	}
+
+	lastInChain := next == nil || (next.Action != "squash" && next.Action != "fixup")
+	if lastInChain {
+		if _, err := os.Stat(rebaseFile("squash-edit")); err == nil {
+			edited, err := EditFile(rebaseFile("message"), "# This is a combination of commits.\n"+message+"\n")
+			if err != nil {
+				return err
+			}
+			if message = CleanupMessage(edited, true); message == "" {
+				return fmt.Errorf("aborting commit due to empty commit message")
+			}
+		}
+		if err := removeRebaseFiles("message-squash", "squash-edit"); err != nil {
+			return err
+		}
+	} else if err := writeRebaseFile("message-squash", message); err != nil {
+		return err
+	}
+
+	_, err = recordCommit(indexMap, previous.Parent, previous.Author, previous.Date, message,
+		fmt.Sprintf("rebase (%s): %s", step.Action, subjectOf(message)))
+	return err
+}
+
+// finishRebase moves the rebased branch to the new HEAD and cleans up.
+func finishRebase() error {
+	headName, err := readRebaseFile("head-name")
+	if err != nil {
+		return err
+	}
+	onto, err := readRebaseFile("onto")
+	if err != nil {
+		return err
+	}
+	_, head, err := ReadHead()
+	if err != nil {
+		return err
+	}
+
+	if strings.HasPrefix(headName, "refs/") {
+		if err := UpdateRef(headName, head, fmt.Sprintf("rebase (finish): %s onto %s", headName, onto)); err != nil {
+			return err
+		}
+		if err := AttachHead(headName, "rebase (finish): returning to "+headName); err != nil {
+			return err
+		}
+	}
+	if err := os.RemoveAll(rebaseDir()); err != nil {
+		return fmt.Errorf("error removing rebase state: %w", err)
+	}
+	if err := clearPickState(); err != nil {
+		return err
+	}
+	fmt.Printf("Successfully rebased and updated %s.\n", headName)
+	return nil
+}
+
+// ContinueRebase records the step the rebase stopped at, once its
+// conflicts are resolved, and runs the rest of the todo list.
+func ContinueRebase() error {
+	if !RebaseInProgress() {
+		return fmt.Errorf("no rebase in progress")
+	}
+	unmerged, err := ReadUnmerged()
+	if err != nil {
+		return err
+	}
+	if len(unmerged) > 0 {
+		return errUnmerged("continuing")
+	}
+	_, head, err := ReadHead()
+	if err != nil {
+		return err
+	}
+
+	stoppedSha, err := readRebaseFile("stopped-sha")
+	if err != nil {
+		return err
+	}
+	amend, err := readRebaseFile("amend")
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case stoppedSha != "":
+		action, err := readRebaseFile("stopped-action")
+		if err != nil {
+			return err
+		}
+		stoppedHead, err := readRebaseFile("stopped-head")
+		if err != nil {
+			return err
+		}
+		if err := removeRebaseFiles("stopped-sha", "stopped-action", "stopped-head"); err != nil {
+			return err
+		}
+		if err := clearPickState(); err != nil {
+			return err
+		}
+		// If HEAD moved, the user already committed the resolution.
+		if head == stoppedHead {
+			commit, err := ReadCommit(stoppedSha)
+			if err != nil {
+				return err
+			}
+			todo, err := readRebaseTodo("git-rebase-todo")
+			if err != nil {
+				return err
+			}
+			var next *rebaseStep
+			if len(todo) > 0 {
+				next = &todo[0]
+			}
+			err = commitRebaseStep(rebaseStep{Action: action, Hash: stoppedSha}, commit, next)
+			if errors.Is(err, errRebasePaused) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+	case amend != "":
+		if err := removeRebaseFiles("amend"); err != nil {
+			return err
+		}
+		// Changes staged after an "edit" stop are folded into its commit.
+		if head == amend {
+			if err := amendHeadWithIndex(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := requireCleanWorktree("continue"); err != nil {
+		return err
+	}
+	return runRebase()
+}
+
+// amendHeadWithIndex rewrites HEAD with the index if they differ.
+func amendHeadWithIndex() error {
+	_, head, err := ReadHead()
+	if err != nil {
+		return err
+	}
+	commit, err := ReadCommit(head)
+	if err != nil {
+		return err
+	}
+	headTree, err := commitTree(head)
+	if err != nil {
+		return err
+	}
+	indexMap, err := ReadIndex()
+	if err != nil {
+		return err
+	}
+	if maps.Equal(indexMap, headTree) {
+		return nil
+	}
+	_, err = recordCommit(indexMap, commit.Parent, commit.Author, commit.Date, commit.Message,
+		"rebase (amend): "+subjectOf(commit.Message))
+	return err
+}
+
+// SkipRebase discards the step the rebase stopped at and runs the rest of
+// the todo list.
+func SkipRebase() error {
+	if !RebaseInProgress() {
+		return fmt.Errorf("no rebase in progress")
+	}
+	_, head, err := ReadHead()
+	if err != nil {
+		return err
+	}
+	if err := resetMerge(head); err != nil {
+		return err
+	}
+	if err := removeRebaseFiles("stopped-sha", "stopped-action", "stopped-head", "amend"); err != nil {
+		return err
+	}
+	if err := clearPickState(); err != nil {
+		return err
+	}
+	return runRebase()
+}
+
+// AbortRebase restores the branch, index and working tree to where they
+// were before the rebase started.
+func AbortRebase() error {
+	if !RebaseInProgress() {
+		return fmt.Errorf("no rebase in progress")
+	}
+	headName, err := readRebaseFile("head-name")
+	if err != nil {
+		return err
+	}
+	origHead, err := readRebaseFile("orig-head")
+	if err != nil {
+		return err
+	}
+	if origHead == "" {
+		return errors.New("corrupt rebase state: orig-head is missing")
+	}
+
+	if err := resetMerge(origHead); err != nil {
+		return err
+	}
+	if strings.HasPrefix(headName, "refs/") {
+		// The branch itself never moved during the rebase.
+		if err := AttachHead(headName, "rebase (abort): returning to "+headName); err != nil {
+			return err
+		}
+	} else if err := DetachHead(origHead, "rebase (abort): returning to "+origHead); err != nil {
+		return err
+	}
+	if err := clearPickState(); err != nil {
+		return err
+	}
+	return os.RemoveAll(rebaseDir())
+}
+
+// rebaseStatus describes a rebase in progress for `status`: the branch
+// being rebased, the commit it is replayed onto and the commit whose
+// conflicts stopped it, if any.
+func rebaseStatus() (string, string, string, error) {
+	headName, err := readRebaseFile("head-name")
+	if err != nil {
+		return "", "", "", err
+	}
+	onto, err := readRebaseFile("onto")
+	if err != nil {
+		return "", "", "", err
+	}
+	stoppedAt, err := readRebaseFile("stopped-sha")
+	if err != nil {
+		return "", "", "", err
+	}
+	return strings.TrimPrefix(headName, "refs/heads/"), onto, stoppedAt, nil
+}
@@ -0,0 +1,37 @@
+package gogit
+
+import (
+	"os"
+	"testing"
+)
+
+func TestRebaseAfterAddAll(t *testing.T) {
+	chdir(t, initTestRepo(t, t.TempDir(), "repo"))
+	if err := os.WriteFile("base.txt", []byte("base\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	commitAll(t, "base")
+	if err := CreateBranch("topic", "HEAD"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile("main.txt", []byte("main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	commitAll(t, "on main")
+	main := refIn(t, RepoPath, "refs/heads/main")
+
+	quietly(t, func() error { return Checkout("topic", &CheckoutOptions{}) })
+	if err := os.WriteFile("topic.txt", []byte("topic\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	commitAll(t, "on topic")
+	quietly(t, func() error { return Rebase(&RebaseOptions{Upstream: "main"}) })
+
+	commit, err := ReadCommit(refIn(t, RepoPath, "refs/heads/topic"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commit.Parent != main {
+		t.Fatalf("rebased topic has parent %s, want main %s", commit.Parent, main)
+	}
+}
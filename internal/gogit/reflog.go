@@ -0,0 +1,171 @@
+package gogit
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReflogEntry is one recorded update of a ref.
+type ReflogEntry struct {
+	Old      string
+	New      string
+	Identity string
+	Time     time.Time
+	Message  string
+}
+
+// reflogPath returns the log file of ref ("HEAD" or "refs/...").
+func reflogPath(ref string) string {
+	return filepath.Join(RepoPath, "logs", ref)
+}
+
+// appendReflog records that ref moved from oldHash to newHash. Lines use
+// Git's layout: "<old> <new> <identity> <unix time> <zone>\t<message>".
+func appendReflog(ref, oldHash, newHash, message string) error {
+	path := reflogPath(ref)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("error creating reflog directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("error opening reflog %s: %w", ref, err)
+	}
+	defer file.Close()
+
+	entry := ReflogEntry{Old: oldHash, New: newHash, Identity: authorName, Time: time.Now(), Message: message}
+	if _, err := file.WriteString(formatReflogEntry(entry)); err != nil {
+		return fmt.Errorf("error writing reflog %s: %w", ref, err)
+	}
+	return nil
+}
+
+func formatReflogEntry(entry ReflogEntry) string {
+	message := strings.ReplaceAll(strings.TrimSpace(entry.Message), "\n", " ")
+	return fmt.Sprintf("%s %s %s %d %s\t%s\n", hashOrZero(entry.Old), hashOrZero(entry.New),
+		entry.Identity, entry.Time.Unix(), entry.Time.Format("-0700"), message)
+}
+
+// writeReflog replaces the reflog of ref with entries, given newest first
+// as ReadReflog returns them.
+func writeReflog(ref string, entries []ReflogEntry) error {
+	var sb strings.Builder
+	for i := len(entries) - 1; i >= 0; i-- {
+		sb.WriteString(formatReflogEntry(entries[i]))
+	}
+	if err := os.WriteFile(reflogPath(ref), []byte(sb.String()), 0644); err != nil {
+		return fmt.Errorf("error writing reflog %s: %w", ref, err)
+	}
+	return nil
+}
+
+// ReadReflog returns the recorded updates of ref, newest first.
+func ReadReflog(ref string) ([]ReflogEntry, error) {
+	file, err := os.Open(reflogPath(ref))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading reflog %s: %w", ref, err)
+	}
+	defer file.Close()
+
+	var entries []ReflogEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		header, message, _ := strings.Cut(scanner.Text(), "\t")
+		fields := strings.Fields(header)
+		if len(fields) < 5 {
+			continue
+		}
+		entry := ReflogEntry{
+			Old:      strings.TrimPrefix(fields[0], zeroHash),
+			New:      strings.TrimPrefix(fields[1], zeroHash),
+			Identity: strings.Join(fields[2:len(fields)-2], " "),
+			Message:  message,
+		}
+		if seconds, err := strconv.ParseInt(fields[len(fields)-2], 10, 64); err == nil {
+			entry.Time = time.Unix(seconds, 0)
+			if zone, err := time.Parse("-0700", fields[len(fields)-1]); err == nil {
+				entry.Time = entry.Time.In(zone.Location())
+			}
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading reflog %s: %w", ref, err)
+	}
+
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return entries, nil
+}
+
+// reflogRef returns the ref whose log a name like "main" or "HEAD" in
+// "main@{2}" refers to. An empty name means HEAD.
+func reflogRef(name string) (string, error) {
+	if name == "" || name == "HEAD" || name == "@" {
+		return "HEAD", nil
+	}
+	for _, candidate := range []string{name, "refs/" + name, "refs/heads/" + name} {
+		if !strings.HasPrefix(candidate, "refs/") {
+			continue
+		}
+		if _, err := os.Stat(reflogPath(candidate)); err == nil {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("no reflog for '%s'", name)
+}
+
+// resolveReflog resolves "<ref>@{n}", the value ref had n updates ago.
+func resolveReflog(name string, n int) (string, error) {
+	ref, err := reflogRef(name)
+	if err != nil {
+		return "", err
+	}
+	entries, err := ReadReflog(ref)
+	if err != nil {
+		return "", err
+	}
+	if n >= len(entries) {
+		return "", fmt.Errorf("log for '%s' only has %d entries", ref, len(entries))
+	}
+	if entries[n].New == "" {
+		return "", fmt.Errorf("%s@{%d} does not point to a commit", name, n)
+	}
+	return entries[n].New, nil
+}
+
+// ReflogFor returns the reflog of a ref given by name ("HEAD", "main",
+// "refs/heads/main"), newest first.
+func ReflogFor(name string) ([]ReflogEntry, error) {
+	ref, err := reflogRef(name)
+	if err != nil {
+		return nil, err
+	}
+	return ReadReflog(ref)
+}
+
+// PrintReflog prints the reflog of ref, newest first, one
+// "<hash> <ref>@{n}: <message>" line per entry.
+func PrintReflog(name string) error {
+	ref, err := reflogRef(name)
+	if err != nil {
+		return err
+	}
+	entries, err := ReadReflog(ref)
+	if err != nil {
+		return err
+	}
+	shortName := strings.TrimPrefix(strings.TrimPrefix(ref, "refs/heads/"), "refs/")
+	for i, entry := range entries {
+		fmt.Printf("%s%s%s %s@{%d}: %s\n", ColorYellow, abbrevHash(entry.New), ColorReset, shortName, i, entry.Message)
+	}
+	return nil
+}
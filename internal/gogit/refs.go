@@ -0,0 +1,402 @@
+package gogit
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Ref is a named pointer to a commit.
+type Ref struct {
+	Name string
+	Hash string
+}
+
+// ReadRef returns the hash stored in a ref such as "refs/heads/main". A
+// missing or empty ref (an unborn branch) yields an empty hash.
+func ReadRef(name string) (string, error) {
+	content, err := os.ReadFile(filepath.Join(RepoPath, name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("error reading ref %s: %w", name, err)
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+// ErrRefMoved is returned by the verified ref updates when the ref no
+// longer holds the value the caller read from it.
+var ErrRefMoved = errors.New("ref was updated by another process")
+
+// UpdateRef points the ref name at hash, creating it if needed. A
+// non-empty message is recorded in the ref's reflog.
+func UpdateRef(name, hash, message string) error {
+	return updateRef(name, hash, message, nil)
+}
+
+// UpdateRefVerified is UpdateRef for a ref the caller expects to hold
+// oldHash ("" for none). The ref is read again while its lock is held, so
+// another process moving it in between yields ErrRefMoved instead of a
+// lost update.
+func UpdateRefVerified(name, oldHash, hash, message string) error {
+	return updateRef(name, hash, message, &oldHash)
+}
+
+func updateRef(name, hash, message string, expected *string) error {
+	lock, err := lockPath(filepath.Join(RepoPath, name))
+	if err != nil {
+		return fmt.Errorf("error updating ref %s: %w", name, err)
+	}
+	defer lock.rollback()
+	oldHash, err := ReadRef(name)
+	if err != nil {
+		return err
+	}
+	if expected != nil && oldHash != *expected {
+		return fmt.Errorf("error updating ref %s: %w", name, ErrRefMoved)
+	}
+	if _, err := lock.Write([]byte(hash + "\n")); err != nil {
+		return fmt.Errorf("error updating ref %s: %w", name, err)
+	}
+	if err := lock.commit(); err != nil {
+		return fmt.Errorf("error updating ref %s: %w", name, err)
+	}
+	if message != "" {
+		return appendReflog(name, oldHash, hash, message)
+	}
+	return nil
+}
+
+// DeleteRef removes the ref name and its reflog.
+func DeleteRef(name string) error {
+	if err := os.Remove(filepath.Join(RepoPath, name)); err != nil {
+		return fmt.Errorf("error deleting ref %s: %w", name, err)
+	}
+	return deleteReflog(name)
+}
+
+// DeleteRefVerified is DeleteRef for a ref the caller expects to hold
+// oldHash, which is checked under the ref's lock as in
+// UpdateRefVerified.
+func DeleteRefVerified(name, oldHash string) error {
+	lock, err := lockPath(filepath.Join(RepoPath, name))
+	if err != nil {
+		return fmt.Errorf("error deleting ref %s: %w", name, err)
+	}
+	defer lock.rollback()
+	current, err := ReadRef(name)
+	if err != nil {
+		return err
+	}
+	if current != oldHash {
+		return fmt.Errorf("error deleting ref %s: %w", name, ErrRefMoved)
+	}
+	return DeleteRef(name)
+}
+
+func deleteReflog(name string) error {
+	if err := os.Remove(reflogPath(name)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error deleting reflog of %s: %w", name, err)
+	}
+	return nil
+}
+
+// ListRefs returns the refs under prefix (for example "refs/heads/")
+// sorted by name. Unborn refs are skipped.
+func ListRefs(prefix string) ([]Ref, error) {
+	var refs []Ref
+	root := filepath.Join(RepoPath, prefix)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == root {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		// A lock is a ref being written, not a ref.
+		if d.IsDir() || strings.HasSuffix(path, ".lock") {
+			return nil
+		}
+
+		relativePath, err := filepath.Rel(RepoPath, path)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(relativePath)
+		hash, err := ReadRef(name)
+		if err != nil {
+			return err
+		}
+		if hash != "" {
+			refs = append(refs, Ref{Name: name, Hash: hash})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing refs under %s: %w", prefix, err)
+	}
+
+	sort.Slice(refs, func(i, j int) bool { return refs[i].Name < refs[j].Name })
+	return refs, nil
+}
+
+// CurrentBranch returns the short name of the branch HEAD points to, or
+// an empty string when HEAD is detached.
+func CurrentBranch() (string, error) {
+	ref, _, err := ReadHead()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(ref, "refs/heads/"), nil
+}
+
+// UpdateHead moves HEAD to hash: the current branch when HEAD is attached,
+// HEAD itself when it is detached. message is recorded in the reflogs of
+// HEAD and of the branch.
+func UpdateHead(hash, message string) error {
+	ref, oldHash, err := ReadHead()
+	if err != nil {
+		return err
+	}
+	if ref == "" {
+		return DetachHead(hash, message)
+	}
+	if err := UpdateRef(ref, hash, message); err != nil {
+		return err
+	}
+	return appendReflog("HEAD", oldHash, hash, message)
+}
+
+// updateHeadVerified is UpdateHead for a caller that read HEAD as oldRef
+// ("" when detached) at oldHash. If HEAD has since been switched, or has
+// moved, it returns ErrRefMoved and leaves everything as it is.
+func updateHeadVerified(oldRef, oldHash, hash, message string) error {
+	if oldRef != "" {
+		if ref, _, err := ReadHead(); err != nil {
+			return err
+		} else if ref != oldRef {
+			return fmt.Errorf("error updating HEAD: %w", ErrRefMoved)
+		}
+		if err := UpdateRefVerified(oldRef, oldHash, hash, message); err != nil {
+			return err
+		}
+		return appendReflog("HEAD", oldHash, hash, message)
+	}
+
+	lock, err := lockPath(HeadPath)
+	if err != nil {
+		return fmt.Errorf("error updating HEAD: %w", err)
+	}
+	defer lock.rollback()
+	if ref, head, err := ReadHead(); err != nil {
+		return err
+	} else if ref != "" || head != oldHash {
+		return fmt.Errorf("error updating HEAD: %w", ErrRefMoved)
+	}
+	if _, err := lock.Write([]byte(hash + "\n")); err != nil {
+		return fmt.Errorf("error updating HEAD: %w", err)
+	}
+	if err := lock.commit(); err != nil {
+		return fmt.Errorf("error updating HEAD: %w", err)
+	}
+	return appendReflog("HEAD", oldHash, hash, message)
+}
+
+// AttachHead makes HEAD a symbolic ref to ref, e.g. "refs/heads/main",
+// recording the move in HEAD's reflog.
+func AttachHead(ref, message string) error {
+	_, oldHash, err := ReadHead()
+	if err != nil {
+		return err
+	}
+	newHash, err := ReadRef(ref)
+	if err != nil {
+		return err
+	}
+	if err := writeFileLocked(HeadPath, []byte("ref: "+ref+"\n")); err != nil {
+		return fmt.Errorf("error updating HEAD: %w", err)
+	}
+	return appendReflog("HEAD", oldHash, newHash, message)
+}
+
+// DetachHead points HEAD directly at a commit, recording the move in
+// HEAD's reflog.
+func DetachHead(hash, message string) error {
+	_, oldHash, err := ReadHead()
+	if err != nil {
+		return err
+	}
+	if err := writeFileLocked(HeadPath, []byte(hash+"\n")); err != nil {
+		return fmt.Errorf("error updating HEAD: %w", err)
+	}
+	return appendReflog("HEAD", oldHash, hash, message)
+}
+
+// ResolveRevision turns a revision such as "HEAD", "main", "v1.0",
+// "a1b2c3d" or "HEAD~2^" into a full object hash.
+func ResolveRevision(rev string) (string, error) {
+	name := rev
+	suffix := ""
+	if i := strings.IndexAny(rev, "~^"); i >= 0 {
+		name, suffix = rev[:i], rev[i:]
+	}
+
+	hash, err := resolveName(name)
+	if err != nil {
+		return "", err
+	}
+
+	for suffix != "" {
+		op := suffix[0]
+		suffix = suffix[1:]
+		digits := 0
+		for digits < len(suffix) && suffix[digits] >= '0' && suffix[digits] <= '9' {
+			digits++
+		}
+		count := 1
+		if digits > 0 {
+			count, _ = strconv.Atoi(suffix[:digits])
+			suffix = suffix[digits:]
+		}
+
+		if op == '^' && count == 0 {
+			continue
+		}
+		if op == '^' && count > 1 {
+			commit, err := ReadCommit(hash)
+			if err != nil {
+				return "", err
+			}
+			if count > len(commit.Parents) {
+				return "", fmt.Errorf("revision '%s' has no parent %d", rev, count)
+			}
+			hash = commit.Parents[count-1]
+			continue
+		}
+		for range count {
+			commit, err := ReadCommit(hash)
+			if err != nil {
+				return "", err
+			}
+			if commit.Parent == "" {
+				return "", fmt.Errorf("revision '%s' goes past the first commit", rev)
+			}
+			hash = commit.Parent
+		}
+	}
+
+	return hash, nil
+}
+
+// resolveName resolves a revision without ~ and ^ suffixes.
+func resolveName(name string) (string, error) {
+	if base, selector, ok := strings.Cut(name, "@{"); ok && strings.HasSuffix(selector, "}") {
+		n, err := strconv.Atoi(strings.TrimSuffix(selector, "}"))
+		if err != nil || n < 0 {
+			return "", fmt.Errorf("unknown revision '%s'", name)
+		}
+		return resolveReflog(base, n)
+	}
+
+	if name == "HEAD" || name == "@" {
+		hash, err := GetBranchHash()
+		if err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+		if hash == "" {
+			return "", fmt.Errorf("HEAD does not point to a commit yet")
+		}
+		return hash, nil
+	}
+
+	if name == "FETCH_HEAD" {
+		hash, err := readFetchHead()
+		if err != nil {
+			return "", err
+		}
+		if hash == "" {
+			return "", fmt.Errorf("FETCH_HEAD does not point to a commit yet")
+		}
+		return hash, nil
+	}
+
+	if fullHashPattern.MatchString(name) {
+		return name, nil
+	}
+
+	for _, candidate := range []string{name, "refs/" + name, "refs/tags/" + name, "refs/heads/" + name, "refs/remotes/" + name} {
+		if !strings.HasPrefix(candidate, "refs/") {
+			continue
+		}
+		hash, err := ReadRef(candidate)
+		if err != nil {
+			return "", err
+		}
+		if hash != "" {
+			return hash, nil
+		}
+	}
+
+	if len(name) >= 4 && isHex(name) {
+		return expandShortHash(name)
+	}
+
+	return "", fmt.Errorf("unknown revision '%s'", name)
+}
+
+// expandShortHash finds the single object whose hash starts with prefix.
+func expandShortHash(prefix string) (string, error) {
+	entries, err := os.ReadDir(filepath.Join(ObjectsPath, prefix[:2]))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("unknown revision '%s'", prefix)
+		}
+		return "", err
+	}
+
+	var matches []string
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), prefix[2:]) {
+			matches = append(matches, prefix[:2]+entry.Name())
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("unknown revision '%s'", prefix)
+	case 1:
+		return matches[0], nil
+	}
+	return "", fmt.Errorf("short object ID %s is ambiguous", prefix)
+}
+
+func isHex(s string) bool {
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateRefName rejects branch and tag names Git would not accept.
+func ValidateRefName(name string) error {
+	switch {
+	case name == "", name == "HEAD", name == "@":
+		return fmt.Errorf("'%s' is not a valid name", name)
+	case strings.HasPrefix(name, "-"), strings.HasPrefix(name, "/"), strings.HasSuffix(name, "/"):
+		return fmt.Errorf("'%s' is not a valid name", name)
+	case strings.HasSuffix(name, ".lock"), strings.HasSuffix(name, "."):
+		return fmt.Errorf("'%s' is not a valid name", name)
+	case strings.Contains(name, ".."), strings.Contains(name, "//"), strings.Contains(name, "@{"):
+		return fmt.Errorf("'%s' is not a valid name", name)
+	case strings.ContainsAny(name, " ~^:?*[\\\t\n"):
+		return fmt.Errorf("'%s' is not a valid name", name)
+	}
+	return nil
+}
@@ -0,0 +1,301 @@
+package gogit
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Remote is a repository configured with `remote add`.
+type Remote struct {
+	Name string
+	URL  string
+}
+
+// remoteRefs is where the branches of remote name are tracked.
+func remoteRefs(name string) string {
+	return "refs/remotes/" + name + "/"
+}
+
+// defaultFetchRefspec maps every branch of the remote to a tracking ref.
+func defaultFetchRefspec(name string) string {
+	return "+refs/heads/*:" + remoteRefs(name) + "*"
+}
+
+// ListRemotes returns the configured remotes in config order.
+func ListRemotes() ([]Remote, error) {
+	entries, err := ListConfig()
+	if err != nil {
+		return nil, err
+	}
+	var remotes []Remote
+	index := make(map[string]int)
+	for _, entry := range entries {
+		rest, ok := strings.CutPrefix(entry.Key, "remote.")
+		if !ok || !strings.HasSuffix(rest, ".url") {
+			continue
+		}
+		name := strings.TrimSuffix(rest, ".url")
+		if i, seen := index[name]; seen {
+			remotes[i].URL = entry.Value
+			continue
+		}
+		index[name] = len(remotes)
+		remotes = append(remotes, Remote{Name: name, URL: entry.Value})
+	}
+	return remotes, nil
+}
+
+// GetRemote returns the remote called name.
+func GetRemote(name string) (*Remote, error) {
+	url, ok, err := GetConfig("remote." + name + ".url")
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("no such remote '%s'", name)
+	}
+	return &Remote{Name: name, URL: url}, nil
+}
+
+// AddRemote records a remote and the refspec its branches are fetched
+// with.
+func AddRemote(name, url string) error {
+	if err := ValidateRefName(name); err != nil {
+		return fmt.Errorf("'%s' is not a valid remote name", name)
+	}
+	if _, ok, err := GetConfig("remote." + name + ".url"); err != nil {
+		return err
+	} else if ok {
+		return fmt.Errorf("remote %s already exists", name)
+	}
+	if err := SetConfig("remote."+name+".url", url, false); err != nil {
+		return err
+	}
+	return SetConfig("remote."+name+".fetch", defaultFetchRefspec(name), false)
+}
+
+// RemoveRemote deletes a remote, its tracking refs and the upstream
+// settings of branches that pointed at it.
+func RemoveRemote(name string) error {
+	if _, err := GetRemote(name); err != nil {
+		return err
+	}
+	if err := RemoveConfigSection("remote."+name, false); err != nil {
+		return err
+	}
+
+	branches, err := ListBranches()
+	if err != nil {
+		return err
+	}
+	for _, branch := range branches {
+		remote, _, err := GetConfig("branch." + branch.Name + ".remote")
+		if err != nil {
+			return err
+		}
+		if remote == name {
+			if err := RemoveConfigSection("branch."+branch.Name, false); err != nil {
+				return err
+			}
+		}
+	}
+
+	refs, err := ListRefs(remoteRefs(name))
+	if err != nil {
+		return err
+	}
+	for _, ref := range refs {
+		if err := DeleteRef(ref.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PrintRemotes lists the remote names, with their URLs when verbose.
+func PrintRemotes(remotes []Remote, verbose bool) {
+	for _, remote := range remotes {
+		if !verbose {
+			fmt.Println(remote.Name)
+			continue
+		}
+		fmt.Printf("%s\t%s (fetch)\n", remote.Name, remote.URL)
+		fmt.Printf("%s\t%s (push)\n", remote.Name, remote.URL)
+	}
+}
+
+// defaultRemote returns the upstream remote of the current branch, or
+// "origin".
+func defaultRemote() (string, error) {
+	branch, err := CurrentBranch()
+	if err != nil {
+		return "", err
+	}
+	if branch != "" {
+		remote, ok, err := GetConfig("branch." + branch + ".remote")
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			return remote, nil
+		}
+	}
+	return "origin", nil
+}
+
+// remoteRepoDir finds the repository directory of a local URL: the
+// .gogit directory inside a working tree, or the directory itself when
+// it holds the repository files directly.
+func remoteRepoDir(url string) (string, error) {
+	path := strings.TrimPrefix(url, "file://")
+	candidates := []string{filepath.Join(path, ".gogit"), path}
+	for _, dir := range candidates {
+		if isRepoDir(dir) {
+			return dir, nil
+		}
+	}
+	return "", fmt.Errorf("'%s' does not appear to be a gogit repository", url)
+}
+
+// isRepoDir reports whether dir holds a repository's HEAD and objects.
+func isRepoDir(dir string) bool {
+	head, err := os.Stat(filepath.Join(dir, "HEAD"))
+	if err != nil || head.IsDir() {
+		return false
+	}
+	objects, err := os.Stat(filepath.Join(dir, "objects"))
+	return err == nil && objects.IsDir()
+}
+
+// refspec maps refs of one repository onto refs of another. Src and Dst
+// may both end in "*" to map a whole namespace.
+type refspec struct {
+	Force bool
+	Src   string
+	Dst   string
+}
+
+func parseRefspec(value string) refspec {
+	var spec refspec
+	value, spec.Force = strings.CutPrefix(value, "+")
+	spec.Src, spec.Dst, _ = strings.Cut(value, ":")
+	return spec
+}
+
+// match returns the destination of ref, or false if the spec does not
+// cover it.
+func (spec refspec) match(ref string) (string, bool) {
+	prefix, glob := strings.CutSuffix(spec.Src, "*")
+	if !glob {
+		return spec.Dst, ref == spec.Src
+	}
+	rest, ok := strings.CutPrefix(ref, prefix)
+	if !ok {
+		return "", false
+	}
+	return strings.TrimSuffix(spec.Dst, "*") + rest, true
+}
+
+// shortRefName drops the namespace of a branch, tag or tracking ref.
+func shortRefName(ref string) string {
+	for _, prefix := range []string{"refs/heads/", "refs/tags/", "refs/remotes/"} {
+		if short, ok := strings.CutPrefix(ref, prefix); ok {
+			return short
+		}
+	}
+	return ref
+}
+
+// missingObjects lists the objects reachable from tips, in the current
+// repository, that has reports the other side lacks. A commit the other
+// side has is assumed to come with its whole history.
+func missingObjects(tips []string, has func(hash string) bool) ([]string, error) {
+	var missing []string
+	seen := make(map[string]bool)
+	queue := append([]string(nil), tips...)
+	for len(queue) > 0 {
+		hash := queue[0]
+		queue = queue[1:]
+		if hash == "" || seen[hash] || has(hash) {
+			continue
+		}
+		seen[hash] = true
+		commit, err := ReadCommit(hash)
+		if err != nil {
+			return nil, err
+		}
+		missing = append(missing, hash)
+		queue = append(queue, commit.Parents...)
+
+		if seen[commit.Tree] || has(commit.Tree) {
+			continue
+		}
+		seen[commit.Tree] = true
+		missing = append(missing, commit.Tree)
+		tree, err := ReadTree(commit.Tree)
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range sortedKeys(tree) {
+			if hash := tree[path]; !seen[hash] && !has(hash) {
+				seen[hash] = true
+				missing = append(missing, hash)
+			}
+		}
+	}
+	return missing, nil
+}
+
+// objectFile returns where the object hash is stored in repository dir.
+func objectFile(dir, hash string) string {
+	return filepath.Join(dir, "objects", hash[:2], hash[2:])
+}
+
+// hasObjectIn returns a check for whether repository dir stores an
+// object.
+func hasObjectIn(dir string) func(hash string) bool {
+	return func(hash string) bool {
+		_, err := os.Stat(objectFile(dir, hash))
+		return err == nil
+	}
+}
+
+// transferObjects copies the objects reachable from tips that repository
+// to lacks out of repository from, and returns the ones it copied.
+func transferObjects(from, to string, tips []string) ([]string, error) {
+	var missing []string
+	err := withRepo(from, func() error {
+		var err error
+		missing, err = missingObjects(tips, hasObjectIn(to))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, hash := range missing {
+		data, err := os.ReadFile(objectFile(from, hash))
+		if err != nil {
+			return nil, fmt.Errorf("error reading object %s: %w", hash, err)
+		}
+		if err := writeFileSynced(objectFile(to, hash), data, 0644); err != nil {
+			return nil, fmt.Errorf("error writing object %s: %w", hash, err)
+		}
+	}
+	return missing, nil
+}
+
+// printRefUpdate prints one line of the fetch or push summary, such as
+// " * [new branch]      main       -> origin/main".
+func printRefUpdate(flag, summary, from, to, reason string) {
+	line := fmt.Sprintf(" %s %-17s %s", flag, summary, from)
+	if to != "" {
+		line += " -> " + to
+	}
+	if reason != "" {
+		line += " (" + reason + ")"
+	}
+	fmt.Println(line)
+}
@@ -0,0 +1,576 @@
+package gogit
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// A sequence of cherry-picks or reverts keeps its state in .gogit so that
+// it can stop on a conflict and be resumed:
+//
+//	sequencer/action   "cherry-pick" or "revert"
+//	sequencer/head     the commit HEAD pointed to before the sequence
+//	sequencer/todo     the steps still to apply, one "<action> <hash>" per line
+//	CHERRY_PICK_HEAD   the commit being applied when the sequence stopped
+//	REVERT_HEAD        (or the commit being reverted)
+//	MERGE_MSG          the message prepared for that commit
+//	UNMERGED           the paths with conflicts
+const (
+	pickAction   = "pick"
+	revertAction = "revert"
+)
+
+// sequencerStep is one commit to cherry-pick or revert.
+type sequencerStep struct {
+	Action string
+	Hash   string
+}
+
+// stoppedError is returned when the sequence waits for the user to
+// resolve the situation and run --continue, --skip or --abort.
+type stoppedError struct {
+	message string
+}
+
+func (e *stoppedError) Error() string {
+	return e.message
+}
+
+func stopped(format string, args ...any) error {
+	return &stoppedError{message: fmt.Sprintf(format, args...)}
+}
+
+func sequencerDir() string {
+	return filepath.Join(RepoPath, "sequencer")
+}
+
+func mergeMsgPath() string {
+	return filepath.Join(RepoPath, "MERGE_MSG")
+}
+
+// pickHeadPath returns the file naming the commit a stopped step was
+// applying.
+func pickHeadPath(action string) string {
+	if action == revertAction {
+		return filepath.Join(RepoPath, "REVERT_HEAD")
+	}
+	return filepath.Join(RepoPath, "CHERRY_PICK_HEAD")
+}
+
+// operationName returns the command behind an action.
+func operationName(action string) string {
+	if action == revertAction {
+		return "revert"
+	}
+	return "cherry-pick"
+}
+
+// CherryPick applies the changes introduced by each of revs on top of
+// HEAD, one new commit per revision.
+func CherryPick(revs []string) error {
+	return startSequence(pickAction, revs)
+}
+
+// Revert records, for each of revs, a new commit undoing its changes.
+func Revert(revs []string) error {
+	return startSequence(revertAction, revs)
+}
+
+// currentOperation returns the command of the sequence in progress, if
+// any, and the commit it stopped at.
+func currentOperation() (string, string, error) {
+	operation, err := os.ReadFile(filepath.Join(sequencerDir(), "action"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", "", nil
+		}
+		return "", "", fmt.Errorf("error reading sequencer state: %w", err)
+	}
+	name := strings.TrimSpace(string(operation))
+	action := pickAction
+	if name == "revert" {
+		action = revertAction
+	}
+	stoppedAt, err := os.ReadFile(pickHeadPath(action))
+	if err != nil && !os.IsNotExist(err) {
+		return "", "", fmt.Errorf("error reading sequencer state: %w", err)
+	}
+	return name, strings.TrimSpace(string(stoppedAt)), nil
+}
+
+func startSequence(action string, revs []string) error {
+	operation := operationName(action)
+	current, _, err := currentOperation()
+	if err != nil {
+		return err
+	}
+	if current != "" {
+		return fmt.Errorf("a %s is already in progress\nhint: try \"gogit %s (--continue | --skip | --abort)\"", current, current)
+	}
+	unmerged, err := ReadUnmerged()
+	if err != nil {
+		return err
+	}
+	if len(unmerged) > 0 {
+		return errUnmerged(operation)
+	}
+
+	var steps []sequencerStep
+	for _, rev := range revs {
+		hash, err := resolveCommit(rev)
+		if err != nil {
+			return err
+		}
+		steps = append(steps, sequencerStep{Action: action, Hash: hash})
+	}
+
+	_, head, err := ReadHead()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(sequencerDir(), 0755); err != nil {
+		return fmt.Errorf("error creating sequencer directory: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(sequencerDir(), "action"), []byte(operation+"\n"), 0644); err != nil {
+		return fmt.Errorf("error writing sequencer state: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(sequencerDir(), "head"), []byte(head+"\n"), 0644); err != nil {
+		return fmt.Errorf("error writing sequencer state: %w", err)
+	}
+	if err := writeTodo(steps); err != nil {
+		return err
+	}
+	return runSequence()
+}
+
+// runSequence applies the remaining steps until the todo list is empty or
+// a step stops.
+func runSequence() error {
+	for {
+		steps, err := readTodo()
+		if err != nil {
+			return err
+		}
+		if len(steps) == 0 {
+			return os.RemoveAll(sequencerDir())
+		}
+
+		err = applyStep(steps[0])
+		var stop *stoppedError
+		if errors.As(err, &stop) {
+			if err := writeTodo(steps[1:]); err != nil {
+				return err
+			}
+			return err
+		}
+		if err != nil {
+			// Nothing was changed by the failed step; if it was the first
+			// one, there is no sequence to resume either.
+			if untouched, _ := sequenceUntouched(); untouched {
+				os.RemoveAll(sequencerDir())
+			}
+			return err
+		}
+		if err := writeTodo(steps[1:]); err != nil {
+			return err
+		}
+	}
+}
+
+// sequenceUntouched reports whether HEAD still points where the sequence
+// started.
+func sequenceUntouched() (bool, error) {
+	orig, err := os.ReadFile(filepath.Join(sequencerDir(), "head"))
+	if err != nil {
+		return false, err
+	}
+	_, head, err := ReadHead()
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(string(orig)) == head, nil
+}
+
+func readTodo() ([]sequencerStep, error) {
+	content, err := os.ReadFile(filepath.Join(sequencerDir(), "todo"))
+	if err != nil {
+		return nil, fmt.Errorf("error reading sequencer state: %w", err)
+	}
+	var steps []sequencerStep
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		steps = append(steps, sequencerStep{Action: fields[0], Hash: fields[1]})
+	}
+	return steps, nil
+}
+
+// writeTodo saves the remaining steps, with each commit's subject as a
+// reminder for whoever reads the file.
+func writeTodo(steps []sequencerStep) error {
+	var sb strings.Builder
+	for _, step := range steps {
+		subject := ""
+		if commit, err := ReadCommit(step.Hash); err == nil {
+			subject, _ = splitMessage(commit.Message)
+		}
+		fmt.Fprintf(&sb, "%s %s %s\n", step.Action, step.Hash, subject)
+	}
+	if err := os.WriteFile(filepath.Join(sequencerDir(), "todo"), []byte(sb.String()), 0644); err != nil {
+		return fmt.Errorf("error writing sequencer state: %w", err)
+	}
+	return nil
+}
+
+// applyStep merges one commit's changes (or their reverse) into HEAD and
+// commits the result. On a conflict, or when the result changes nothing,
+// it leaves the prepared message and the step's commit in .gogit and
+// returns a stoppedError.
+func applyStep(step sequencerStep) error {
+	operation := operationName(step.Action)
+	commit, err := ReadCommit(step.Hash)
+	if err != nil {
+		return err
+	}
+	subject := subjectOf(commit.Message)
+
+	var message string
+	if step.Action == revertAction {
+		message = fmt.Sprintf("Revert \"%s\"\n\nThis reverts commit %s.", subject, commit.Hash)
+	} else {
+		message = fmt.Sprintf("%s\n\n(cherry picked from commit %s)", commit.Message, commit.Hash)
+	}
+
+	headRef, head, err := ReadHead()
+	if err != nil {
+		return err
+	}
+	headTree, conflicts, err := mergeCommit(commit, step.Action == revertAction, operation)
+	if err != nil {
+		return err
+	}
+
+	if len(conflicts) > 0 {
+		if err := writePickState(step, conflictMessage(message, conflicts)); err != nil {
+			return err
+		}
+		return stopped("could not apply %s... %s\n"+
+			"hint: after resolving the conflicts, mark the corrected paths\n"+
+			"hint: with 'gogit add <paths>' and run 'gogit %s --continue'.\n"+
+			"hint: You can instead skip this commit with 'gogit %s --skip'.\n"+
+			"hint: To abort and get back to the state before \"gogit %s\",\n"+
+			"hint: run 'gogit %s --abort'.",
+			abbrevHash(commit.Hash), subject, operation, operation, operation, operation)
+	}
+
+	indexMap, err := ReadIndex()
+	if err != nil {
+		return err
+	}
+	if maps.Equal(indexMap, headTree) {
+		if err := writePickState(step, message); err != nil {
+			return err
+		}
+		return emptyStepError(operation)
+	}
+
+	author, date := authorName, time.Now()
+	if step.Action == pickAction {
+		author, date = commit.Author, commit.Date
+	}
+	hash, err := recordCommit(indexMap, head, author, date, message, operation+": "+subjectOf(message))
+	if err != nil {
+		return err
+	}
+	printRecorded(headRef, hash, message)
+	return nil
+}
+
+// mergeCommit merges the changes commit introduced, or their reverse, into
+// the index and the working tree, printing a CONFLICT line per conflict.
+// The index must match HEAD. It returns HEAD's tree and the conflicts.
+func mergeCommit(commit *Commit, reverse bool, operation string) (map[string]string, []UnmergedPath, error) {
+	_, head, err := ReadHead()
+	if err != nil {
+		return nil, nil, err
+	}
+	headTree, err := commitTree(head)
+	if err != nil {
+		return nil, nil, err
+	}
+	indexMap, err := ReadIndex()
+	if err != nil {
+		return nil, nil, err
+	}
+	if !maps.Equal(indexMap, headTree) {
+		return nil, nil, fmt.Errorf("your local changes would be overwritten by %s\nhint: commit your changes or stash them to proceed", operation)
+	}
+
+	commitTreeMap, err := commitTree(commit.Hash)
+	if err != nil {
+		return nil, nil, err
+	}
+	parentTree, err := commitTree(commit.Parent)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	subject := subjectOf(commit.Message)
+	base, theirs := parentTree, commitTreeMap
+	theirsLabel := fmt.Sprintf("%s (%s)", abbrevHash(commit.Hash), subject)
+	if reverse {
+		base, theirs = commitTreeMap, parentTree
+		theirsLabel = "parent of " + theirsLabel
+	}
+
+	worktree, conflicts, err := mergeTrees(base, headTree, theirs, "HEAD", theirsLabel)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := applyMerge(headTree, worktree, conflicts, operation); err != nil {
+		return nil, nil, err
+	}
+	for _, conflict := range conflicts {
+		fmt.Printf("CONFLICT (%s): Merge conflict in %s\n", conflictKind(conflict), conflict.Path)
+	}
+	return headTree, conflicts, nil
+}
+
+// conflictMessage appends the list of conflicted paths to message as
+// comments, the way the prepared message of a stopped commit shows them.
+func conflictMessage(message string, conflicts []UnmergedPath) string {
+	var sb strings.Builder
+	sb.WriteString(message + "\n\n# Conflicts:\n")
+	for _, conflict := range conflicts {
+		fmt.Fprintf(&sb, "#\t%s\n", conflict.Path)
+	}
+	return sb.String()
+}
+
+// conflictKind names a conflict the way the CONFLICT lines do.
+func conflictKind(conflict UnmergedPath) string {
+	switch {
+	case conflict.Ours == "" || conflict.Theirs == "":
+		return "modify/delete"
+	case conflict.Base == "":
+		return "add/add"
+	}
+	return "content"
+}
+
+func emptyStepError(operation string) error {
+	return stopped("the previous %s is now empty, possibly due to conflict resolution\n"+
+		"hint: use 'gogit commit --allow-empty' to record it anyway,\n"+
+		"hint: or 'gogit %s --skip' to skip this commit", operation, operation)
+}
+
+// printRecorded prints the "[branch hash] subject" line for a new commit.
+func printRecorded(headRef, hash, message string) {
+	branch := strings.TrimPrefix(headRef, "refs/heads/")
+	if headRef == "" {
+		branch = "detached HEAD"
+	}
+	fmt.Printf("[%s %s] %s\n", branch, abbrevHash(hash), subjectOf(message))
+}
+
+// subjectOf returns the first line of a commit message.
+func subjectOf(message string) string {
+	subject, _ := splitMessage(message)
+	return subject
+}
+
+// writePickState remembers the step a sequence stopped at and the message
+// prepared for it.
+func writePickState(step sequencerStep, message string) error {
+	if err := os.WriteFile(pickHeadPath(step.Action), []byte(step.Hash+"\n"), 0644); err != nil {
+		return fmt.Errorf("error writing %s: %w", pickHeadPath(step.Action), err)
+	}
+	if err := os.WriteFile(mergeMsgPath(), []byte(message+"\n"), 0644); err != nil {
+		return fmt.Errorf("error writing %s: %w", mergeMsgPath(), err)
+	}
+	return nil
+}
+
+// clearPickState forgets the step a sequence stopped at.
+func clearPickState() error {
+	for _, path := range []string{pickHeadPath(pickAction), pickHeadPath(revertAction), mergeMsgPath()} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("error removing %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// inProgress checks that operation is the sequence in progress.
+func inProgress(operation string) error {
+	current, _, err := currentOperation()
+	if err != nil {
+		return err
+	}
+	if current != operation {
+		return fmt.Errorf("no %s in progress", operation)
+	}
+	return nil
+}
+
+// ContinueSequence commits the resolved step the sequence stopped at,
+// using the prepared message, and applies the remaining steps.
+// operation is "cherry-pick" or "revert".
+func ContinueSequence(operation string) error {
+	if err := inProgress(operation); err != nil {
+		return err
+	}
+	unmerged, err := ReadUnmerged()
+	if err != nil {
+		return err
+	}
+	if len(unmerged) > 0 {
+		return errUnmerged("committing")
+	}
+
+	_, stoppedAt, err := currentOperation()
+	if err != nil {
+		return err
+	}
+	// Without a stopped step the user already committed it by hand.
+	if stoppedAt != "" {
+		if err := commitStoppedStep(operation, stoppedAt); err != nil {
+			return err
+		}
+	}
+	return runSequence()
+}
+
+func commitStoppedStep(operation, stoppedAt string) error {
+	headRef, head, err := ReadHead()
+	if err != nil {
+		return err
+	}
+	headTree, err := commitTree(head)
+	if err != nil {
+		return err
+	}
+	indexMap, err := ReadIndex()
+	if err != nil {
+		return err
+	}
+	if maps.Equal(indexMap, headTree) {
+		return emptyStepError(operation)
+	}
+
+	content, err := os.ReadFile(mergeMsgPath())
+	if err != nil {
+		return fmt.Errorf("error reading %s: %w", mergeMsgPath(), err)
+	}
+	message := CleanupMessage(string(content), true)
+	if message == "" {
+		return fmt.Errorf("aborting commit due to empty commit message")
+	}
+
+	author, date := authorName, time.Now()
+	if operation == "cherry-pick" {
+		commit, err := ReadCommit(stoppedAt)
+		if err != nil {
+			return err
+		}
+		author, date = commit.Author, commit.Date
+	}
+	hash, err := recordCommit(indexMap, head, author, date, message, operation+": "+subjectOf(message))
+	if err != nil {
+		return err
+	}
+	printRecorded(headRef, hash, message)
+	return clearPickState()
+}
+
+// SkipSequence drops the step the sequence stopped at, discarding its
+// changes, and applies the remaining steps.
+func SkipSequence(operation string) error {
+	if err := inProgress(operation); err != nil {
+		return err
+	}
+	_, head, err := ReadHead()
+	if err != nil {
+		return err
+	}
+	if err := resetMerge(head); err != nil {
+		return err
+	}
+	if err := clearPickState(); err != nil {
+		return err
+	}
+	return runSequence()
+}
+
+// AbortSequence returns HEAD, the index and the working tree to where they
+// were before the sequence started.
+func AbortSequence(operation string) error {
+	if err := inProgress(operation); err != nil {
+		return err
+	}
+	content, err := os.ReadFile(filepath.Join(sequencerDir(), "head"))
+	if err != nil {
+		return fmt.Errorf("error reading sequencer state: %w", err)
+	}
+	orig := strings.TrimSpace(string(content))
+
+	if err := resetMerge(orig); err != nil {
+		return err
+	}
+	if orig != "" {
+		if err := UpdateHead(orig, "reset: moving to "+orig); err != nil {
+			return err
+		}
+	} else if headRef, _, err := ReadHead(); err == nil && headRef != "" {
+		// The sequence started on an unborn branch.
+		if err := DeleteRef(headRef); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	if err := clearPickState(); err != nil {
+		return err
+	}
+	return os.RemoveAll(sequencerDir())
+}
+
+// resetMerge discards conflicts and local changes, resetting the index
+// and the working tree to the tree of target.
+func resetMerge(target string) error {
+	_, head, err := ReadHead()
+	if err != nil {
+		return err
+	}
+	headTree, err := commitTree(head)
+	if err != nil {
+		return err
+	}
+	targetTree, err := commitTree(target)
+	if err != nil {
+		return err
+	}
+
+	// Files only the conflict brought in are not tracked anywhere.
+	unmerged, err := ReadUnmerged()
+	if err != nil {
+		return err
+	}
+	for _, u := range unmerged {
+		if targetTree[u.Path] == "" && headTree[u.Path] == "" {
+			if err := removeWorkdirFile(u.Path); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := checkoutTree(headTree, targetTree, true, "reset"); err != nil {
+		return err
+	}
+	return writeUnmerged(nil)
+}
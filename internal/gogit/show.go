@@ -0,0 +1,91 @@
+package gogit
+
+import (
+	"fmt"
+	"os"
+)
+
+// CommitChanges returns the files a commit changed relative to its parent.
+func CommitChanges(commit *Commit) ([]TreeChange, error) {
+	parentTree := make(map[string]string)
+	if commit.Parent != "" {
+		parent, err := ReadCommit(commit.Parent)
+		if err != nil {
+			return nil, err
+		}
+		if parentTree, err = ReadTree(parent.Tree); err != nil {
+			return nil, err
+		}
+	}
+
+	tree, err := ReadTree(commit.Tree)
+	if err != nil {
+		return nil, err
+	}
+	return DiffTrees(parentTree, tree), nil
+}
+
+// ShowObject prints the object named by rev. Commits are shown with their
+// metadata and the files they changed, trees as a listing and blobs as
+// their raw content.
+func ShowObject(rev string, opts *LogOptions) error {
+	hash, err := ResolveRevision(rev)
+	if err != nil {
+		return err
+	}
+	objectType, content, err := ReadObjectContent(hash)
+	if err != nil {
+		return err
+	}
+
+	switch objectType {
+	case ObjectTypeBlob:
+		_, err := os.Stdout.Write(content)
+		return err
+	case ObjectTypeTree:
+		fmt.Printf("tree %s\n\n", hash)
+		tree, err := parseTree(content)
+		if err != nil {
+			return err
+		}
+		for _, path := range sortedKeys(tree) {
+			fmt.Println(path)
+		}
+		return nil
+	}
+
+	commit, err := parseCommit(hash, content)
+	if err != nil {
+		return err
+	}
+	changes, err := CommitChanges(commit)
+	if err != nil {
+		return err
+	}
+
+	pf, err := parsePretty(opts.Format)
+	if err != nil {
+		return err
+	}
+	if _, err := FormatDate(commit.Date, opts.DateMode); err != nil {
+		return err
+	}
+	out := pf.render(commit, opts.DateMode)
+	if pf.preset == "" {
+		out += "\n"
+	}
+	fmt.Print(out)
+	PrintChanges(changes)
+	return nil
+}
+
+// PrintChanges prints one name-status line per changed file.
+func PrintChanges(changes []TreeChange) {
+	for _, change := range changes {
+		if change.Status == StatusRenamed {
+			fmt.Printf("R100\t%s\t%s\n", quotePath(change.OrigPath), quotePath(change.Path))
+			continue
+		}
+		fmt.Printf("%c\t%s\n", change.Status, quotePath(change.Path))
+	}
+}
@@ -0,0 +1,436 @@
+package gogit
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// stashRef holds the latest stash entry; older entries live in its reflog.
+const stashRef = "refs/stash"
+
+// A stash entry is a commit W whose tree is the saved working tree. Its
+// first parent is the commit HEAD pointed to (B) and its second parent a
+// commit I whose tree is the saved index, the same layout Git uses.
+
+// StashOptions controls StashPush.
+type StashOptions struct {
+	// Message describes the entry; by default it names HEAD's commit.
+	Message string
+	// Paths limits the stash to these files or directories.
+	Paths []string
+}
+
+// StashEntry is one saved stash.
+type StashEntry struct {
+	// Name is the "stash@{n}" selector of the entry.
+	Name    string
+	Hash    string
+	Message string
+}
+
+// matchPathspec reports whether path is one of specs, lies under one of
+// them, or matches one as a glob. No specs match everything.
+func matchPathspec(path string, specs []string) bool {
+	if len(specs) == 0 {
+		return true
+	}
+	for _, spec := range specs {
+		spec = strings.TrimSuffix(filepath.ToSlash(filepath.Clean(spec)), "/")
+		if spec == "." || path == spec || strings.HasPrefix(path, spec+"/") {
+			return true
+		}
+		if ok, _ := filepath.Match(spec, path); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// StashPush saves the changes to tracked files in the index and the
+// working tree as a new stash entry, then resets those files to HEAD.
+func StashPush(opts *StashOptions) error {
+	unmerged, err := ReadUnmerged()
+	if err != nil {
+		return err
+	}
+	if len(unmerged) > 0 {
+		return errUnmerged("stashing")
+	}
+	headRef, head, err := ReadHead()
+	if err != nil {
+		return err
+	}
+	if head == "" {
+		return fmt.Errorf("you do not have the initial commit yet")
+	}
+	headCommit, err := ReadCommit(head)
+	if err != nil {
+		return err
+	}
+	headTree, err := ReadTree(headCommit.Tree)
+	if err != nil {
+		return err
+	}
+	lock, err := lockIndex()
+	if err != nil {
+		return err
+	}
+	defer lock.rollback()
+	indexMap, err := ReadIndex()
+	if err != nil {
+		return err
+	}
+	workdirMap, err := BuildWorkdirMap()
+	if err != nil {
+		return fmt.Errorf("could not build the working directory map: %w", err)
+	}
+
+	// The saved index and working tree start from HEAD; only the selected
+	// tracked paths take their current state.
+	indexTree := make(map[string]string)
+	for path, hash := range headTree {
+		indexTree[path] = hash
+	}
+	var selected []string
+	changed := false
+	for _, path := range sortedKeys(unionKeys(headTree, indexMap)) {
+		if !matchPathspec(path, opts.Paths) {
+			continue
+		}
+		selected = append(selected, path)
+		if indexMap[path] != headTree[path] {
+			changed = true
+		}
+		if hash, ok := indexMap[path]; ok {
+			indexTree[path] = hash
+		} else {
+			delete(indexTree, path)
+		}
+	}
+	c, err := worktreeConverter()
+	if err != nil {
+		return err
+	}
+	defer c.close()
+	worktreeTree := make(map[string]string)
+	for path, hash := range indexTree {
+		worktreeTree[path] = hash
+	}
+	for _, path := range selected {
+		if _, tracked := indexMap[path]; !tracked {
+			continue
+		}
+		workdirHash, exists := workdirMap[path]
+		switch {
+		case !exists:
+			delete(worktreeTree, path)
+			changed = true
+		case workdirHash != indexMap[path]:
+			// The working tree blob may not be stored yet.
+			if err := processFile(c, path, worktreeTree); err != nil {
+				return err
+			}
+			changed = true
+		}
+	}
+	if !changed {
+		fmt.Println("No local changes to save")
+		return nil
+	}
+
+	branch := strings.TrimPrefix(headRef, "refs/heads/")
+	if headRef == "" {
+		branch = "(no branch)"
+	}
+	base := fmt.Sprintf("%s: %s %s", branch, abbrevHash(head), subjectOf(headCommit.Message))
+	message := "WIP on " + base
+	if opts.Message != "" {
+		message = fmt.Sprintf("On %s: %s", branch, opts.Message)
+	}
+
+	now := time.Now()
+	indexCommit, err := storeCommit(indexTree, []string{head}, authorName, now, "index on "+base)
+	if err != nil {
+		return err
+	}
+	stashCommit, err := storeCommit(worktreeTree, []string{head, indexCommit}, authorName, now, message)
+	if err != nil {
+		return err
+	}
+	if err := UpdateRef(stashRef, stashCommit, message); err != nil {
+		return err
+	}
+
+	// Reset the stashed paths to HEAD.
+	headConverter, err := treeConverter(headTree)
+	if err != nil {
+		return err
+	}
+	defer headConverter.close()
+	for _, path := range selected {
+		headHash, inHead := headTree[path]
+		if !inHead {
+			delete(indexMap, path)
+			if err := removeWorkdirFile(path); err != nil {
+				return err
+			}
+			continue
+		}
+		indexMap[path] = headHash
+		if workdirMap[path] != headHash {
+			if err := headConverter.writeFile(path, headHash); err != nil {
+				return err
+			}
+		}
+	}
+	if err := lock.commitIndex(indexMap); err != nil {
+		return err
+	}
+
+	fmt.Printf("Saved working directory and index state %s\n", message)
+	return nil
+}
+
+// unionKeys returns the set of keys present in any of the maps.
+func unionKeys(maps ...map[string]string) map[string]bool {
+	keys := make(map[string]bool)
+	for _, m := range maps {
+		for key := range m {
+			keys[key] = true
+		}
+	}
+	return keys
+}
+
+// ListStashes returns the stash entries, newest first.
+func ListStashes() ([]StashEntry, error) {
+	entries, err := ReadReflog(stashRef)
+	if err != nil {
+		return nil, err
+	}
+	var stashes []StashEntry
+	for i, entry := range entries {
+		stashes = append(stashes, StashEntry{
+			Name:    fmt.Sprintf("stash@{%d}", i),
+			Hash:    entry.New,
+			Message: entry.Message,
+		})
+	}
+	return stashes, nil
+}
+
+// PrintStashes prints one "stash@{n}: message" line per entry.
+func PrintStashes(stashes []StashEntry) {
+	for _, stash := range stashes {
+		fmt.Printf("%s%s%s: %s\n", ColorYellow, stash.Name, ColorReset, stash.Message)
+	}
+}
+
+// resolveStash finds the entry named by rev: "", "n", "stash@{n}" or
+// "refs/stash@{n}". It returns the entry's position and its commit.
+func resolveStash(rev string) (int, *Commit, error) {
+	selector := rev
+	if selector == "" {
+		selector = "0"
+	}
+	for _, prefix := range []string{"refs/stash@{", "stash@{"} {
+		if strings.HasPrefix(selector, prefix) && strings.HasSuffix(selector, "}") {
+			selector = strings.TrimSuffix(strings.TrimPrefix(selector, prefix), "}")
+			break
+		}
+	}
+	n, err := strconv.Atoi(selector)
+	if err != nil || n < 0 {
+		return 0, nil, fmt.Errorf("'%s' is not a stash reference", rev)
+	}
+
+	stashes, err := ListStashes()
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(stashes) == 0 {
+		return 0, nil, fmt.Errorf("no stash entries found")
+	}
+	if n >= len(stashes) {
+		return 0, nil, fmt.Errorf("stash@{%d} does not exist; there are only %d stash entries", n, len(stashes))
+	}
+	commit, err := ReadCommit(stashes[n].Hash)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(commit.Parents) < 2 {
+		return 0, nil, fmt.Errorf("'%s' is not a stash-like commit", rev)
+	}
+	return n, commit, nil
+}
+
+// StashChanges returns the files a stash entry changed relative to the
+// commit it was made on.
+func StashChanges(rev string) ([]TreeChange, error) {
+	_, commit, err := resolveStash(rev)
+	if err != nil {
+		return nil, err
+	}
+	return CommitChanges(commit)
+}
+
+// StashApply merges the changes saved in a stash entry into the working
+// tree. Files the stash added are staged; other changes are left
+// unstaged unless restoreIndex asks to restore the saved index as well.
+func StashApply(rev string, restoreIndex bool) error {
+	_, stash, err := resolveStash(rev)
+	if err != nil {
+		return err
+	}
+	unmerged, err := ReadUnmerged()
+	if err != nil {
+		return err
+	}
+	if len(unmerged) > 0 {
+		return errUnmerged("applying a stash")
+	}
+
+	_, head, err := ReadHead()
+	if err != nil {
+		return err
+	}
+	headTree, err := commitTree(head)
+	if err != nil {
+		return err
+	}
+	baseTree, err := commitTree(stash.Parents[0])
+	if err != nil {
+		return err
+	}
+	savedIndex, err := commitTree(stash.Parents[1])
+	if err != nil {
+		return err
+	}
+	savedWorktree, err := ReadTree(stash.Tree)
+	if err != nil {
+		return err
+	}
+	lock, err := lockIndex()
+	if err != nil {
+		return err
+	}
+	defer lock.rollback()
+	indexMap, err := ReadIndex()
+	if err != nil {
+		return err
+	}
+
+	// The saved index can only be restored where HEAD still matches the
+	// commit the stash was made on.
+	if restoreIndex {
+		for path := range unionKeys(baseTree, savedIndex) {
+			if baseTree[path] != savedIndex[path] && headTree[path] != baseTree[path] {
+				return fmt.Errorf("conflicts in index; try without --index")
+			}
+		}
+	}
+
+	worktree, conflicts, err := mergeTrees(baseTree, headTree, savedWorktree, "Updated upstream", "Stashed changes")
+	if err != nil {
+		return err
+	}
+	merged := maps.Clone(indexMap)
+	if err := mergeIntoIndex(merged, headTree, worktree, conflicts, "stash apply"); err != nil {
+		return err
+	}
+
+	// mergeIntoIndex staged the merged files. Put back the previous index
+	// entries, except for new files and, with restoreIndex, the paths the
+	// saved index changed.
+	for path, hash := range worktree {
+		switch {
+		case restoreIndex && savedIndex[path] != baseTree[path]:
+			if savedIndex[path] == "" {
+				delete(merged, path)
+			} else {
+				merged[path] = savedIndex[path]
+			}
+		case hash != "" && baseTree[path] == "" && headTree[path] == "":
+			// New files stay staged.
+		case indexMap[path] == "":
+			delete(merged, path)
+		default:
+			merged[path] = indexMap[path]
+		}
+	}
+	for _, conflict := range conflicts {
+		fmt.Printf("CONFLICT (%s): Merge conflict in %s\n", conflictKind(conflict), conflict.Path)
+		if conflict.Ours == "" {
+			delete(merged, conflict.Path)
+		} else {
+			merged[conflict.Path] = conflict.Ours
+		}
+	}
+	if err := lock.commitIndex(merged); err != nil {
+		return err
+	}
+	if err := writeUnmerged(conflicts); err != nil {
+		return err
+	}
+	if len(conflicts) > 0 {
+		return fmt.Errorf("conflicts in the stashed changes; resolve them and use \"gogit add\"")
+	}
+	return nil
+}
+
+// StashDrop removes a stash entry.
+func StashDrop(rev string) error {
+	n, commit, err := resolveStash(rev)
+	if err != nil {
+		return err
+	}
+	entries, err := ReadReflog(stashRef)
+	if err != nil {
+		return err
+	}
+	entries = append(entries[:n], entries[n+1:]...)
+
+	if len(entries) == 0 {
+		if err := DeleteRef(stashRef); err != nil {
+			return err
+		}
+	} else {
+		if err := writeReflog(stashRef, entries); err != nil {
+			return err
+		}
+		if err := UpdateRef(stashRef, entries[0].New, ""); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Dropped stash@{%d} (%s)\n", n, commit.Hash)
+	return nil
+}
+
+// StashPop applies a stash entry and drops it if it applied cleanly.
+func StashPop(rev string, restoreIndex bool) error {
+	if err := StashApply(rev, restoreIndex); err != nil {
+		if _, statErr := os.Stat(unmergedPath()); statErr == nil {
+			fmt.Println("The stash entry is kept in case you need it again.")
+		}
+		return err
+	}
+	return StashDrop(rev)
+}
+
+// StashBranch creates a branch at the commit a stash entry was made on,
+// switches to it and pops the entry there, restoring its index too.
+func StashBranch(name, rev string) error {
+	_, stash, err := resolveStash(rev)
+	if err != nil {
+		return err
+	}
+	if err := Checkout(stash.Parents[0], &CheckoutOptions{NewBranch: name}); err != nil {
+		return err
+	}
+	return StashPop(rev, true)
+}
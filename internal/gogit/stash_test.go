@@ -0,0 +1,41 @@
+package gogit
+
+import (
+	"os"
+	"testing"
+)
+
+// commitAll stages the whole working tree with "add ." and commits it.
+func commitAll(t testing.TB, message string) {
+	t.Helper()
+	quietly(t, func() error { return Add(".") })
+	quietly(t, func() error { return AddCommit(&CommitOptions{Message: message}) })
+}
+
+func TestStashPushPopAfterAddAll(t *testing.T) {
+	chdir(t, initTestRepo(t, t.TempDir(), "repo"))
+	if err := os.WriteFile("file.txt", []byte("one\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	commitAll(t, "first")
+
+	if err := os.WriteFile("file.txt", []byte("two\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	quietly(t, func() error { return StashPush(&StashOptions{}) })
+	if content, _ := os.ReadFile("file.txt"); string(content) != "one\n" {
+		t.Fatalf("file.txt after stash push = %q", content)
+	}
+	changes, err := StashChanges("stash@{0}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].Path != "file.txt" || changes[0].Status != StatusModified {
+		t.Fatalf("stash changes = %+v, want only file.txt modified", changes)
+	}
+
+	quietly(t, func() error { return StashPop("stash@{0}", false) })
+	if content, _ := os.ReadFile("file.txt"); string(content) != "two\n" {
+		t.Fatalf("file.txt after stash pop = %q", content)
+	}
+}
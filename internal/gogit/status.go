@@ -2,92 +2,235 @@ package gogit
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 )
 
-func StatusRepo() error {
+// StatusRepo computes the repository status and prints it in the format
+// selected by opts.
+func StatusRepo(opts *StatusOptions) error {
+	statusInfo, err := GetStatus(opts.Ignored)
+	if err != nil {
+		return err
+	}
+
+	switch opts.Format {
+	case StatusFormatShort:
+		PrintStatusShort(statusInfo, opts)
+	case StatusFormatPorcelainV1:
+		PrintStatusPorcelainV1(statusInfo, opts)
+	case StatusFormatPorcelainV2:
+		PrintStatusPorcelainV2(statusInfo, opts)
+	default:
+		PrintStatus(statusInfo)
+	}
+
+	return nil
+}
+
+// GetStatus compares HEAD, the index and the working tree and returns one
+// entry per changed path: tracked changes sorted by path, followed by
+// untracked files and, when includeIgnored is set, ignored files.
+func GetStatus(includeIgnored bool) (*StatusInfo, error) {
 	ignorePatterns, err := readGogitignore()
 	if err != nil {
-		return fmt.Errorf("error reading .gogitignore: %w", err)
+		return nil, fmt.Errorf("error reading .gogitignore: %w", err)
 	}
 
-	currentHash, err := GetBranchHash()
+	headRef, currentHash, err := ReadHead()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	var treeMap map[string]string
 	if currentHash != "" {
 		lastCommit, err := ReadCommit(currentHash)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		lastTreeHash := lastCommit.Tree
 		treeMap, err = ReadTree(lastTreeHash)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 
 	indexMap, err := ReadIndex()
 	if err != nil {
-		return err
+		return nil, err
 	}
 	statusInfo := &StatusInfo{
-		Branch:    "main",
-		Staged:    []string{},
-		Unstaged:  []string{},
-		Untracked: []string{},
+		Branch: strings.TrimPrefix(headRef, "refs/heads/"),
+		Head:   currentHash,
+	}
+	if RebaseInProgress() {
+		statusInfo.Operation = "rebase"
+		statusInfo.OperationBranch, statusInfo.OperationOnto, statusInfo.OperationHead, err = rebaseStatus()
+	} else {
+		statusInfo.Operation, statusInfo.OperationHead, err = currentOperation()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if statusInfo.Operation == "" && BisectInProgress() {
+		statusInfo.Operation = "bisect"
+		if statusInfo.OperationBranch, err = bisectStart(); err != nil {
+			return nil, err
+		}
+	}
+
+	entries := make(map[string]*StatusEntry)
+	entryFor := func(path string) *StatusEntry {
+		entry, ok := entries[path]
+		if !ok {
+			entry = &StatusEntry{
+				Path:      path,
+				Index:     StatusUnmodified,
+				Worktree:  StatusUnmodified,
+				HeadHash:  treeMap[path],
+				IndexHash: indexMap[path],
+			}
+			entries[path] = entry
+		}
+		return entry
 	}
 
+	// Index against HEAD.
 	for path, indexHash := range indexMap {
 		commitHash, existsInCommit := treeMap[path]
 		if !existsInCommit {
-			statusInfo.Staged = append(statusInfo.Staged, fmt.Sprintf("new file:   %s", path))
+			entryFor(path).Index = StatusAdded
 		} else if indexHash != commitHash {
-			statusInfo.Staged = append(statusInfo.Staged, fmt.Sprintf("modified:   %s", path))
+			entryFor(path).Index = StatusModified
 		}
 	}
 	for path := range treeMap {
 		if _, existsInIndex := indexMap[path]; !existsInIndex {
-			statusInfo.Staged = append(statusInfo.Staged, fmt.Sprintf("deleted:    %s", path))
+			entryFor(path).Index = StatusDeleted
 		}
 	}
+	detectRenames(entries)
+
+	// Conflicts override whatever the index says about the path.
+	unmerged, err := ReadUnmerged()
+	if err != nil {
+		return nil, err
+	}
+	for i := range unmerged {
+		entry := entryFor(unmerged[i].Path)
+		entry.Index, entry.Worktree = unmerged[i].Code()
+		entry.OrigPath = ""
+		entry.Conflict = &unmerged[i]
+	}
 
+	// Working tree against the index.
 	workdirMap, err := BuildWorkdirMap()
 	if err != nil {
-		return fmt.Errorf("could not build the working directory map: %w", err)
+		return nil, fmt.Errorf("could not build the working directory map: %w", err)
 	}
 
-	filteredWorkdirMap := make(map[string]string)
-	for path, hash := range workdirMap {
+	var untracked []string
+	for path, workdirHash := range workdirMap {
 		ignored, err := isIgnored(path, ignorePatterns)
 		if err != nil {
-			return fmt.Errorf("error checking ignore patterns for %s: %w", path, err)
+			return nil, fmt.Errorf("error checking ignore patterns for %s: %w", path, err)
 		}
-		if !ignored {
-			filteredWorkdirMap[path] = hash
+		if ignored {
+			continue
 		}
-	}
 
-	for path, workdirHash := range filteredWorkdirMap {
 		indexHash, existsInIndex := indexMap[path]
+		if entry, ok := entries[path]; ok && entry.Conflict != nil {
+			continue
+		}
 		if !existsInIndex {
-			// Case C: Untracked
-			statusInfo.Untracked = append(statusInfo.Untracked, path)
+			untracked = append(untracked, path)
 		} else if workdirHash != indexHash {
-			// Case D: Modified Unstaged
-			statusInfo.Unstaged = append(statusInfo.Unstaged, fmt.Sprintf("modified:   %s", path))
+			entryFor(path).Worktree = StatusModified
 		}
 	}
-	// Iterate over the index to find unstaged deletions
 	for path := range indexMap {
+		if entry, ok := entries[path]; ok && entry.Conflict != nil {
+			continue
+		}
 		if _, existsInWorkdir := workdirMap[path]; !existsInWorkdir {
-			statusInfo.Unstaged = append(statusInfo.Unstaged, fmt.Sprintf("deleted:    %s", path))
+			entryFor(path).Worktree = StatusDeleted
 		}
 	}
 
-	PrintStatus(statusInfo)
+	var paths []string
+	for path := range entries {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		statusInfo.Entries = append(statusInfo.Entries, *entries[path])
+	}
 
-	return nil
+	sort.Strings(untracked)
+	for _, path := range untracked {
+		statusInfo.Entries = append(statusInfo.Entries, StatusEntry{
+			Path:     path,
+			Index:    StatusUntracked,
+			Worktree: StatusUntracked,
+		})
+	}
+
+	if includeIgnored {
+		ignored, err := ListIgnored()
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range ignored {
+			if _, tracked := indexMap[path]; tracked {
+				continue
+			}
+			statusInfo.Entries = append(statusInfo.Entries, StatusEntry{
+				Path:     path,
+				Index:    StatusIgnored,
+				Worktree: StatusIgnored,
+			})
+		}
+	}
+
+	return statusInfo, nil
+}
+
+// detectRenames pairs staged deletions with staged additions of identical
+// content and merges each pair into a single renamed entry.
+func detectRenames(entries map[string]*StatusEntry) {
+	deletedByHash := make(map[string][]string)
+	for path, entry := range entries {
+		if entry.Index == StatusDeleted {
+			deletedByHash[entry.HeadHash] = append(deletedByHash[entry.HeadHash], path)
+		}
+	}
+	if len(deletedByHash) == 0 {
+		return
+	}
+
+	var added []string
+	for path, entry := range entries {
+		if entry.Index == StatusAdded {
+			added = append(added, path)
+		}
+	}
+	sort.Strings(added)
+
+	for _, path := range added {
+		entry := entries[path]
+		candidates := deletedByHash[entry.IndexHash]
+		if len(candidates) == 0 {
+			continue
+		}
+		sort.Strings(candidates)
+		origPath := candidates[0]
+		deletedByHash[entry.IndexHash] = candidates[1:]
+
+		entry.Index = StatusRenamed
+		entry.OrigPath = origPath
+		entry.HeadHash = entries[origPath].HeadHash
+		delete(entries, origPath)
+	}
 }
@@ -0,0 +1,69 @@
+package gogit
+
+import (
+	"fmt"
+)
+
+// ListTags returns the tags sorted by name.
+func ListTags() ([]Ref, error) {
+	refs, err := ListRefs("refs/tags/")
+	if err != nil {
+		return nil, err
+	}
+	for i := range refs {
+		refs[i].Name = refs[i].Name[len("refs/tags/"):]
+	}
+	return refs, nil
+}
+
+// CreateTag creates a lightweight tag pointing at rev, or at HEAD when rev
+// is empty. An existing tag is only replaced when force is set.
+func CreateTag(name, rev string, force bool) error {
+	if err := ValidateRefName(name); err != nil {
+		return err
+	}
+
+	refName := "refs/tags/" + name
+	existing, err := ReadRef(refName)
+	if err != nil {
+		return err
+	}
+	if existing != "" && !force {
+		return fmt.Errorf("tag '%s' already exists", name)
+	}
+
+	if rev == "" {
+		rev = "HEAD"
+	}
+	hash, err := ResolveRevision(rev)
+	if err != nil {
+		return err
+	}
+
+	return UpdateRef(refName, hash, "")
+}
+
+// DeleteTag removes a tag.
+func DeleteTag(name string) error {
+	refName := "refs/tags/" + name
+	hash, err := ReadRef(refName)
+	if err != nil {
+		return err
+	}
+	if hash == "" {
+		return fmt.Errorf("tag '%s' not found", name)
+	}
+
+	if err := DeleteRef(refName); err != nil {
+		return err
+	}
+	fmt.Printf("Deleted tag '%s' (was %s)\n", name, abbrevHash(hash))
+	return nil
+}
+
+// PrintTags lists tag names, one per line.
+func PrintTags(tags []Ref) {
+	for _, tag := range tags {
+		fmt.Println(tag.Name)
+	}
+}
@@ -0,0 +1,277 @@
+package gogit
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// advertisement is what a remote reports about its refs before a fetch or
+// push.
+type advertisement struct {
+	// Refs are the remote's branches and tags.
+	Refs []Ref
+	// Head is the commit its HEAD points to, and HeadRef the branch HEAD
+	// is attached to, if any.
+	Head    string
+	HeadRef string
+}
+
+// ref returns the value of a remote ref, or "" if it does not exist.
+func (ad *advertisement) ref(name string) string {
+	for _, ref := range ad.Refs {
+		if ref.Name == name {
+			return ref.Hash
+		}
+	}
+	return ""
+}
+
+// transport moves objects and refs between the current repository and
+// the repository behind a remote URL.
+type transport interface {
+	// advertise lists the remote's refs, as seen by a fetch or, with push,
+	// by a push.
+	advertise(push bool) (*advertisement, error)
+	// fetch copies the objects reachable from wants that are missing here.
+	fetch(wants []string) error
+	// fetchLFS copies the large objects pointers name.
+	fetchLFS(pointers []lfsPointer) error
+	// push copies the objects the updates need, and the large objects
+	// among them point to, and applies them on the remote. It returns the
+	// reason each refused update was refused.
+	push(updates []refUpdate) (map[string]string, error)
+}
+
+// openTransport returns the transport for a remote URL: smart HTTP for
+// http:// and https:// URLs, a bundle reader for bundle files, and direct
+// access for other local paths.
+func openTransport(url string) (transport, error) {
+	if isRemoteURL(url) {
+		return newHTTPTransport(url), nil
+	}
+	if path := strings.TrimPrefix(url, "file://"); isBundleFile(path) {
+		return &bundleTransport{file: path}, nil
+	}
+	dir, err := remoteRepoDir(url)
+	if err != nil {
+		return nil, err
+	}
+	if dir, err = filepath.Abs(dir); err != nil {
+		return nil, err
+	}
+	return &localTransport{dir: dir}, nil
+}
+
+// localTransport reaches a repository on the local filesystem.
+type localTransport struct {
+	dir string
+}
+
+func (t *localTransport) advertise(push bool) (*advertisement, error) {
+	ad := &advertisement{}
+	err := withRepo(t.dir, func() error {
+		var err error
+		if ad.Refs, err = listAdvertisedRefs(); err != nil {
+			return err
+		}
+		ad.HeadRef, ad.Head, err = ReadHead()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ad, nil
+}
+
+func (t *localTransport) fetch(wants []string) error {
+	_, err := transferObjects(t.dir, RepoPath, wants)
+	return err
+}
+
+func (t *localTransport) fetchLFS(pointers []lfsPointer) error {
+	return copyLFSObjects(t.dir, RepoPath, pointers)
+}
+
+func (t *localTransport) push(updates []refUpdate) (map[string]string, error) {
+	var tips []string
+	for _, update := range updates {
+		tips = append(tips, update.New)
+	}
+	copied, err := transferObjects(RepoPath, t.dir, tips)
+	if err != nil {
+		return nil, err
+	}
+	// Large objects go over before any ref points to them.
+	pointers, err := lfsPointersIn(copied)
+	if err != nil {
+		return nil, err
+	}
+	if err := copyLFSObjects(RepoPath, t.dir, pointers); err != nil {
+		return nil, err
+	}
+	var refused map[string]string
+	err = withRepo(t.dir, func() error {
+		var err error
+		refused, err = receiveUpdates(updates)
+		return err
+	})
+	return refused, err
+}
+
+// listAdvertisedRefs lists the branches and tags a repository offers.
+func listAdvertisedRefs() ([]Ref, error) {
+	branches, err := ListRefs("refs/heads/")
+	if err != nil {
+		return nil, err
+	}
+	tags, err := ListRefs("refs/tags/")
+	if err != nil {
+		return nil, err
+	}
+	return append(branches, tags...), nil
+}
+
+// checkTransferRef checks a ref name sent by the other side of a
+// transfer: only branches and tags with valid names may be read or
+// written, so that no name reaches outside the refs directory.
+func checkTransferRef(name string) error {
+	if !strings.HasPrefix(name, "refs/heads/") && !strings.HasPrefix(name, "refs/tags/") {
+		return fmt.Errorf("'%s' is not a branch or tag", name)
+	}
+	return ValidateRefName(name)
+}
+
+// receiveUpdates applies pushed ref updates to the current repository,
+// whose objects must already be in place. An update is refused when its
+// ref is not a branch or tag with a valid name, when the ref no longer
+// has the value the pusher saw, when its commit is missing,
+// or when it touches the branch checked out in a non-bare repository and
+// receive.denyCurrentBranch forbids that. The repository path must be
+// absolute so that its working tree can be found.
+func receiveUpdates(updates []refUpdate) (map[string]string, error) {
+	target, err := readPushTarget()
+	if err != nil {
+		return nil, err
+	}
+	refused := make(map[string]string)
+	for _, update := range updates {
+		if checkTransferRef(update.Ref) != nil {
+			refused[update.Ref] = "invalid ref name"
+			continue
+		}
+		current, err := ReadRef(update.Ref)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case current != update.Old:
+			refused[update.Ref] = "failed to update ref"
+			continue
+		case update.New != "" && !ObjectExists(update.New):
+			refused[update.Ref] = "missing necessary objects"
+			continue
+		}
+
+		if !target.bare && update.Ref == target.head {
+			switch {
+			case target.deny == "ignore" || target.deny == "false":
+			case target.deny == "warn":
+				fmt.Println("warning: updating the current branch")
+			case target.deny == "updateInstead" && update.New != "":
+				if err := target.updateWorktree(update.Old, update.New); err != nil {
+					refused[update.Ref] = err.Error()
+					continue
+				}
+			case update.New == "":
+				refused[update.Ref] = "deletion of the current branch prohibited"
+				continue
+			default:
+				refused[update.Ref] = "branch is currently checked out"
+				continue
+			}
+		}
+
+		// The ref is checked again under its lock, in case another push
+		// moved it since it was read above.
+		if update.New == "" {
+			err = DeleteRefVerified(update.Ref, update.Old)
+		} else {
+			err = UpdateRefVerified(update.Ref, update.Old, update.New, "push")
+		}
+		if errors.Is(err, ErrRefMoved) {
+			refused[update.Ref] = "failed to update ref"
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return refused, nil
+}
+
+// pushTarget describes the repository a push updates.
+type pushTarget struct {
+	bare bool
+	// worktree is the working tree of a non-bare target, and head the
+	// branch checked out there.
+	worktree string
+	head     string
+	// deny is its receive.denyCurrentBranch setting.
+	deny string
+}
+
+// readPushTarget inspects the current repository as the target of a push.
+func readPushTarget() (pushTarget, error) {
+	var target pushTarget
+	var err error
+	if target.bare, err = IsBareRepo(); err != nil {
+		return target, err
+	}
+	if target.bare {
+		return target, nil
+	}
+	target.worktree = filepath.Dir(RepoPath)
+	if target.head, _, err = ReadHead(); err != nil {
+		return target, err
+	}
+	target.deny, _, err = GetConfig("receive.denyCurrentBranch")
+	return target, err
+}
+
+// updateWorktree checks out new in the target's working tree, in place of
+// old, for receive.denyCurrentBranch=updateInstead. The working tree and
+// index must be clean.
+func (target pushTarget) updateWorktree(old, new string) error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	if err := os.Chdir(target.worktree); err != nil {
+		return err
+	}
+	defer os.Chdir(cwd)
+
+	return withRepo(".gogit", func() error {
+		statusInfo, err := GetStatus(false)
+		if err != nil {
+			return err
+		}
+		for _, entry := range statusInfo.Entries {
+			if entry.Index != StatusUntracked {
+				return fmt.Errorf("working directory has unstaged changes")
+			}
+		}
+		oldTree, err := commitTree(old)
+		if err != nil {
+			return err
+		}
+		newTree, err := commitTree(new)
+		if err != nil {
+			return err
+		}
+		return checkoutTree(oldTree, newTree, false, "push")
+	})
+}
@@ -2,27 +2,30 @@ package gogit
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"log"
-	"os"
+	"sort"
 	"strings"
 )
 
+// ReadTree reads a tree object and returns a map of path -> blob hash.
 func ReadTree(hash string) (map[string]string, error) {
-	treeMap := make(map[string]string)
-	treePath := fmt.Sprintf("%s/%s/%s", ObjectsPath, hash[:2], hash[2:])
-
-	treeFile, err := os.Open(treePath)
+	data, err := readObjectFile(hash)
 	if err != nil {
-		return treeMap, err
+		return make(map[string]string), err
 	}
+	return parseTree(data)
+}
 
-	defer treeFile.Close()
+// parseTree decodes the stored content of a tree object.
+func parseTree(data []byte) (map[string]string, error) {
+	treeMap := make(map[string]string)
 
-	// 3. Create a scanner to read the file line by line
-	scanner := bufio.NewScanner(treeFile)
+	// 3. Create a scanner to read the content line by line
+	scanner := bufio.NewScanner(bytes.NewReader(data))
 
-	// 4. Iterate over each line of the file
+	// 4. Iterate over each line of the content
 	for scanner.Scan() {
 		line := scanner.Text()
 
@@ -50,3 +53,47 @@ func ReadTree(hash string) (map[string]string, error) {
 
 	return treeMap, nil
 }
+
+// TreeChange is a file-level difference between two trees.
+type TreeChange struct {
+	Status StatusCode
+	Path   string
+	// OrigPath is the old path when Status is StatusRenamed.
+	OrigPath string
+	OldHash  string
+	NewHash  string
+}
+
+// DiffTrees compares two path -> blob hash maps and returns the changes
+// sorted by path. Deleted and added files with identical content are
+// reported as renames.
+func DiffTrees(oldTree, newTree map[string]string) []TreeChange {
+	entries := make(map[string]*StatusEntry)
+	for path, newHash := range newTree {
+		oldHash, exists := oldTree[path]
+		if !exists {
+			entries[path] = &StatusEntry{Path: path, Index: StatusAdded, IndexHash: newHash}
+		} else if oldHash != newHash {
+			entries[path] = &StatusEntry{Path: path, Index: StatusModified, HeadHash: oldHash, IndexHash: newHash}
+		}
+	}
+	for path, oldHash := range oldTree {
+		if _, exists := newTree[path]; !exists {
+			entries[path] = &StatusEntry{Path: path, Index: StatusDeleted, HeadHash: oldHash}
+		}
+	}
+	detectRenames(entries)
+
+	var changes []TreeChange
+	for _, entry := range entries {
+		changes = append(changes, TreeChange{
+			Status:   entry.Index,
+			Path:     entry.Path,
+			OrigPath: entry.OrigPath,
+			OldHash:  entry.HeadHash,
+			NewHash:  entry.IndexHash,
+		})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
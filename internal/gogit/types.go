@@ -4,17 +4,115 @@ import "time"
 
 // Commit represents a commit object.
 type Commit struct {
-	Hash    string
-	Tree    string
+	Hash string
+	Tree string
+	// Parent is the first parent, empty for a root commit; Parents lists
+	// them all.
 	Parent  string
+	Parents []string
 	Author  string
 	Date    time.Time
 	Message string
 }
 
+// CommitOptions controls how AddCommit builds a commit.
+type CommitOptions struct {
+	// Message is the text given with -m or -F. When amending, an empty
+	// Message reuses the message of the amended commit.
+	Message string
+	// Edit opens the editor on the message before committing.
+	Edit bool
+	// Amend replaces the current HEAD commit instead of adding a child to
+	// it; the new commit reuses HEAD's parent.
+	Amend bool
+	// AllowEmpty records the commit even if its tree matches its parent's.
+	AllowEmpty bool
+	// All stages modified and deleted tracked files before committing.
+	All bool
+	// NoVerify skips the pre-commit and commit-msg hooks.
+	NoVerify bool
+}
+
+// LogOptions controls how commits are rendered by LogRepo.
+type LogOptions struct {
+	// Format is a --pretty value: a preset name (oneline, short, medium,
+	// full, fuller) or a "format:"/"tformat:" placeholder template.
+	Format string
+	// DateMode is a --date value (default, relative, iso, iso-strict,
+	// rfc, short, unix, raw, local).
+	DateMode string
+}
+
+// StatusCode is a one-letter file state as shown by `status --short`.
+type StatusCode byte
+
+const (
+	StatusUnmodified StatusCode = ' '
+	StatusModified   StatusCode = 'M'
+	StatusAdded      StatusCode = 'A'
+	StatusDeleted    StatusCode = 'D'
+	StatusRenamed    StatusCode = 'R'
+	StatusUnmerged   StatusCode = 'U'
+	StatusUntracked  StatusCode = '?'
+	StatusIgnored    StatusCode = '!'
+)
+
+// StatusEntry describes a path whose content differs between HEAD, the
+// index and the working tree.
+type StatusEntry struct {
+	Path string
+	// OrigPath is the path in HEAD when Index is StatusRenamed.
+	OrigPath string
+	// Index compares the index with HEAD; Worktree compares the working
+	// tree with the index.
+	Index    StatusCode
+	Worktree StatusCode
+	// HeadHash and IndexHash are the blob hashes in HEAD and in the index,
+	// empty when the path is missing there.
+	HeadHash  string
+	IndexHash string
+	// Conflict is set for paths with unresolved merge conflicts; Index
+	// and Worktree then hold the two-letter conflict code.
+	Conflict *UnmergedPath
+}
+
+// StatusInfo is the state of the repository reported by `status`.
 type StatusInfo struct {
-	Branch    string
-	Staged    []string
-	Unstaged  []string
-	Untracked []string
+	// Branch is empty when HEAD is detached.
+	Branch string
+	// Head is the commit HEAD points to, empty before the first commit.
+	Head string
+	// Operation names the command waiting for the user ("cherry-pick",
+	// "revert", "rebase" or "bisect"), and OperationHead the commit whose
+	// conflicts stopped it.
+	Operation     string
+	OperationHead string
+	// OperationBranch and OperationOnto are the branch being rebased and
+	// the commit it is replayed onto. A bisection sets OperationBranch to
+	// the branch it started from.
+	OperationBranch string
+	OperationOnto   string
+	Entries         []StatusEntry
+}
+
+// StatusFormat selects how `status` prints a StatusInfo.
+type StatusFormat int
+
+const (
+	StatusFormatLong StatusFormat = iota
+	StatusFormatShort
+	StatusFormatPorcelainV1
+	StatusFormatPorcelainV2
+)
+
+// StatusOptions controls the output of StatusRepo.
+type StatusOptions struct {
+	Format StatusFormat
+	// Branch adds the branch header to the short and porcelain formats.
+	Branch bool
+	// NullTerminated ends porcelain entries with NUL instead of LF and
+	// prints paths unquoted.
+	NullTerminated bool
+	// Ignored also reports files excluded by .gogitignore.
+	Ignored bool
 }
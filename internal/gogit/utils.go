@@ -2,8 +2,6 @@ package gogit
 
 import (
 	"bufio"
-	"crypto/sha1"
-	"encoding/hex"
 	"fmt"
 	"io/fs"
 	"log"
@@ -13,41 +11,25 @@ import (
 	"strings"
 )
 
-func GetHeadRef() (map[string]string, error) {
-	headRef := make(map[string]string)
-	ref, err := os.Open(HeadPath)
+// ReadHead returns what HEAD points to: the branch ref (for example
+// "refs/heads/main") and its commit hash. When HEAD is detached the ref is
+// empty. The hash is empty on a branch without commits.
+func ReadHead() (string, string, error) {
+	content, err := os.ReadFile(HeadPath)
 	if err != nil {
-		return nil, err
+		return "", "", err
 	}
-	defer ref.Close()
-
-	// 3. Create a scanner to read the file line by line
-	scannerRef := bufio.NewScanner(ref)
-
-	// 4. Iterate over each line of the file
-	for scannerRef.Scan() {
-		line := scannerRef.Text() // Get the line as a string
-
-		// 5. Split the line into a slice of words
-		words := strings.Fields(line)
 
-		// 6. Check that there are at least two words
-		if len(words) < 2 {
-			log.Printf("Skipping line with incorrect format: %s", line)
-			continue // Go to the next line if the format is incorrect
+	line := strings.TrimSpace(string(content))
+	if ref, ok := strings.CutPrefix(line, "ref:"); ok {
+		ref = strings.TrimSpace(ref)
+		hash, err := ReadRef(ref)
+		if err != nil {
+			return "", "", err
 		}
-
-		key := words[0]
-		value := words[1]
-		headRef[key] = value
+		return ref, hash, nil
 	}
-
-	// 8. Check for errors during scanning
-	if err := scannerRef.Err(); err != nil {
-		return nil, fmt.Errorf("error scanning HEAD file: %w", err)
-	}
-
-	return headRef, nil
+	return "", line, nil
 }
 
 // readIndex reads the index file into a map.
@@ -79,6 +61,22 @@ func ReadIndex() (map[string]string, error) {
 
 // writeIndex writes the map of entries to the index file.
 func WriteIndex(indexEntries map[string]string) error {
+	lock, err := lockIndex()
+	if err != nil {
+		return err
+	}
+	defer lock.rollback()
+	return lock.commitIndex(indexEntries)
+}
+
+// lockIndex takes index.lock, for commands that read the index, change
+// it and write it back, so that no other process changes it in between.
+func lockIndex() (*lockFile, error) {
+	return lockPath(IndexPath)
+}
+
+// commitIndex writes the entries as the new index and releases the lock.
+func (l *lockFile) commitIndex(indexEntries map[string]string) error {
 	var lines []string
 	// For deterministic output, sort the file paths before writing.
 	var paths []string
@@ -96,42 +94,17 @@ func WriteIndex(indexEntries map[string]string) error {
 		output += "\n" // Add a final newline
 	}
 
-	if err := os.WriteFile(IndexPath, []byte(output), 0644); err != nil {
+	if _, err := l.Write([]byte(output)); err != nil {
 		return fmt.Errorf("error writing to index file %s: %w", IndexPath, err)
 	}
-	return nil
+	return l.commit()
 }
 
+// GetBranchHash returns the commit HEAD points to, or an empty string
+// before the first commit.
 func GetBranchHash() (string, error) {
-	headFile, err := os.Open(HeadPath)
-	if err != nil {
-		return "", err
-	}
-	defer headFile.Close()
-
-	var headRef string
-	headScanner := bufio.NewScanner(headFile)
-	for headScanner.Scan() {
-		line := headScanner.Text()
-
-		words := strings.Fields(line)
-		headRef = words[1]
-	}
-
-	branchRefPath := fmt.Sprintf("%s/%s", RepoPath, headRef)
-	branchHashFile, err := os.Open(branchRefPath)
-	if err != nil {
-		return "", err
-	}
-	defer branchHashFile.Close()
-
-	var currentHash string
-	brandHashScanner := bufio.NewScanner(branchHashFile)
-	for brandHashScanner.Scan() {
-		currentHash = brandHashScanner.Text()
-	}
-
-	return currentHash, nil
+	_, hash, err := ReadHead()
+	return hash, err
 }
 
 // BuildWorkdirMap walks the repoRoot and returns a map of relative path -> sha1hex.
@@ -148,144 +121,182 @@ func BuildWorkdirMap() (map[string]string, error) {
 		return nil, err
 	}
 
-	// 2. Start the recursive walk.
-	walkErr := filepath.WalkDir(repoRoot, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
+	// 2. Start the recursive walk; files are hashed on a pool of workers
+	// as it finds them.
+	walk := func(emit func(path string)) error {
+		return filepath.WalkDir(repoRoot, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
 
-		// Get the relative path to the repository root.
-		relativePath, err := filepath.Rel(repoRoot, path)
-		if err != nil {
-			return err
-		}
-		// Normalize to forward slashes for consistent comparison.
-		relativePath = filepath.ToSlash(relativePath)
+			// Get the relative path to the repository root.
+			relativePath, err := filepath.Rel(repoRoot, path)
+			if err != nil {
+				return err
+			}
+			// Normalize to forward slashes for consistent comparison.
+			relativePath = filepath.ToSlash(relativePath)
 
-		// Skip the root (".").
-		if relativePath == "." {
-			return nil
-		}
+			// Skip the root (".").
+			if relativePath == "." {
+				return nil
+			}
 
-		// Ignore the .gogitignore file itself.
-		if !d.IsDir() && filepath.Base(relativePath) == ".gogitignore" {
-			return nil
-		}
+			// .gogitignore is a file like any other: "add ." stages it, so
+			// the walk must see it too or it would always look deleted.
 
-		// Strict Filter: always ignore the .gogit directory.
-		if d.IsDir() && (relativePath == ".gogit" || strings.HasPrefix(relativePath, ".gogit/")) {
-			return filepath.SkipDir
-		}
+			// Strict Filter: always ignore the .gogit directory.
+			if d.IsDir() && (relativePath == ".gogit" || strings.HasPrefix(relativePath, ".gogit/")) {
+				return filepath.SkipDir
+			}
 
-		// Evaluate ignore rules (they are applied in order; '!' negations undo previous ignores).
-		isIgnored := false
-		name := d.Name() // name of the file/dir
-		isDir := d.IsDir()
+			isIgnored := matchIgnorePatterns(relativePath, d.Name(), d.IsDir(), ignorePatterns)
 
-		for _, rawPattern := range ignorePatterns {
-			if rawPattern == "" {
-				continue
-			}
-			pattern := filepath.ToSlash(strings.TrimSpace(rawPattern))
-
-			negated := false
-			if strings.HasPrefix(pattern, "!") {
-				negated = true
-				pattern = strings.TrimPrefix(pattern, "!")
-				pattern = strings.TrimSpace(pattern)
-				if pattern == "" {
-					// invalid "!" pattern -> ignore
-					continue
+			// If it's ignored -> if it's a dir, avoid entering; if it's a file, skip it.
+			if isIgnored {
+				if d.IsDir() {
+					return filepath.SkipDir
 				}
+				return nil
 			}
 
-			// If the pattern ends in "/" it points to directories.
-			patternDirOnly := strings.HasSuffix(pattern, "/")
-			if patternDirOnly {
-				pattern = strings.TrimSuffix(pattern, "/")
+			// If it's a valid directory, we do nothing (only files are hashed).
+			if d.IsDir() {
+				return nil
 			}
 
-			matched := false
+			// 3. Queue each valid file, by its relative path (without "./").
+			emit(relativePath)
+			return nil
+		})
+	}
 
-			// If the pattern contains a '/' we compare it against the full relative path.
-			if strings.Contains(pattern, "/") {
-				// if pattern starts with "/" we treat it as relative to the root: remove prefix if it exists
-				if strings.HasPrefix(pattern, "/") {
-					pattern = strings.TrimPrefix(pattern, "/")
-				}
-				// Match using filepath.Match against relativePath
-				if ok, matchErr := filepath.Match(pattern, relativePath); matchErr == nil && ok {
-					matched = true
-				} else if matchErr != nil {
-					// invalid pattern — we ignore it
-					continue
-				}
-			} else {
-				// does not contain '/', compare against the name of the file/dir
-				if ok, matchErr := filepath.Match(pattern, name); matchErr == nil && ok {
-					matched = true
-				} else if matchErr != nil {
-					continue
-				}
-			}
+	c, err := worktreeConverter()
+	if err != nil {
+		return nil, err
+	}
+	defer c.close()
+	results, err := hashInParallel(walk, c.hashFile)
+	if err != nil {
+		return nil, fmt.Errorf("error during the directory walk: %w", err)
+	}
+	for _, result := range results {
+		workdirMap[result.path] = result.hash
+	}
+	return workdirMap, nil
+}
 
-			// If the pattern is exclusive to directories, and this is not a dir -> no match
-			if matched && patternDirOnly && !isDir {
-				matched = false
+// matchIgnorePatterns evaluates .gogitignore rules against a path relative to
+// the repository root. Rules are applied in order and '!' negations undo
+// previous ignores, so the last matching rule wins.
+func matchIgnorePatterns(relativePath, name string, isDir bool, ignorePatterns []string) bool {
+	isIgnored := false
+	for _, rawPattern := range ignorePatterns {
+		if rawPattern == "" {
+			continue
+		}
+		pattern := filepath.ToSlash(strings.TrimSpace(rawPattern))
+
+		negated := false
+		if strings.HasPrefix(pattern, "!") {
+			negated = true
+			pattern = strings.TrimPrefix(pattern, "!")
+			pattern = strings.TrimSpace(pattern)
+			if pattern == "" {
+				// invalid "!" pattern -> ignore
+				continue
 			}
+		}
 
-			if matched {
-				if negated {
-					// A negation undoes the ignore state.
-					isIgnored = false
-				} else {
-					isIgnored = true
-				}
-				// We don't break; git processes all lines (last relevant match).
-			}
+		// If the pattern ends in "/" it points to directories.
+		patternDirOnly := strings.HasSuffix(pattern, "/")
+		if patternDirOnly {
+			pattern = strings.TrimSuffix(pattern, "/")
 		}
 
-		// If it's ignored -> if it's a dir, avoid entering; if it's a file, skip it.
-		if isIgnored {
-			if d.IsDir() {
-				return filepath.SkipDir
+		matched := false
+
+		// If the pattern contains a '/' we compare it against the full relative path.
+		if strings.Contains(pattern, "/") {
+			// if pattern starts with "/" we treat it as relative to the root: remove prefix if it exists
+			pattern = strings.TrimPrefix(pattern, "/")
+			// Match using filepath.Match against relativePath
+			if ok, matchErr := filepath.Match(pattern, relativePath); matchErr == nil && ok {
+				matched = true
+			} else if matchErr != nil {
+				// invalid pattern — we ignore it
+				continue
+			}
+		} else {
+			// does not contain '/', compare against the name of the file/dir
+			if ok, matchErr := filepath.Match(pattern, name); matchErr == nil && ok {
+				matched = true
+			} else if matchErr != nil {
+				continue
 			}
-			return nil
 		}
 
-		// If it's a valid directory, we do nothing (only files are hashed).
-		if d.IsDir() {
-			return nil
+		// If the pattern is exclusive to directories, and this is not a dir -> no match
+		if matched && patternDirOnly && !isDir {
+			matched = false
 		}
 
-		// 3. Process and hash each valid file.
-		content, err := os.ReadFile(path)
-		if err != nil {
-			return fmt.Errorf("could not read the file %s: %w", path, err)
+		if matched {
+			// A negation undoes the ignore state.
+			isIgnored = !negated
+			// We don't break; git processes all lines (last relevant match).
 		}
+	}
+	return isIgnored
+}
 
-		// Create a "blob <size>\0" header.
-		header := fmt.Sprintf("blob %d\x00", len(content))
-
-		// Concatenate and hash.
-		hasher := sha1.New()
-		_, _ = hasher.Write([]byte(header))
-		_, _ = hasher.Write(content)
-		hashBytes := hasher.Sum(nil)
+// ListIgnored walks the working tree and returns the paths excluded by
+// .gogitignore. Ignored directories are reported once, with a trailing '/'.
+func ListIgnored() ([]string, error) {
+	ignorePatterns, err := parseGitignore(".")
+	if err != nil {
+		return nil, err
+	}
+	extraPatterns, err := readGogitignore()
+	if err != nil {
+		return nil, fmt.Errorf("error reading .gogitignore: %w", err)
+	}
 
-		// Convert to hexadecimal and store.
-		hashHex := hex.EncodeToString(hashBytes)
-		// Save with the relative path (without "./").
-		workdirMap[relativePath] = hashHex
+	var ignored []string
+	walkErr := filepath.WalkDir(".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relativePath := filepath.ToSlash(path)
+		if relativePath == "." {
+			return nil
+		}
+		if d.IsDir() && relativePath == ".gogit" {
+			return filepath.SkipDir
+		}
 
+		matched := matchIgnorePatterns(relativePath, d.Name(), d.IsDir(), ignorePatterns)
+		if !matched && !d.IsDir() {
+			if matched, err = isIgnored(relativePath, extraPatterns); err != nil {
+				return err
+			}
+		}
+		if !matched {
+			return nil
+		}
+		if d.IsDir() {
+			ignored = append(ignored, relativePath+"/")
+			return filepath.SkipDir
+		}
+		ignored = append(ignored, relativePath)
 		return nil
 	})
-
 	if walkErr != nil {
 		return nil, fmt.Errorf("error during the directory walk: %w", walkErr)
 	}
 
-	return workdirMap, nil
+	sort.Strings(ignored)
+	return ignored, nil
 }
 
 // parseGitignore reads .gogitignore and returns the lines in order (including negations).
@@ -0,0 +1,75 @@
+package gogit
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// hashWorkers returns how many files are read and hashed at once: the
+// core.workers option, or one per CPU when it is unset or 0.
+func hashWorkers() (int, error) {
+	workers, err := GetConfigInt("core.workers", 0)
+	if err != nil {
+		return 0, err
+	}
+	if workers < 0 {
+		return 0, fmt.Errorf("core.workers must not be negative, got %d", workers)
+	}
+	if workers == 0 {
+		workers = runtime.NumCPU()
+	}
+	return workers, nil
+}
+
+// fileHash is the hash of one file found by a walk.
+type fileHash struct {
+	path string
+	hash string
+	err  error
+}
+
+// hashInParallel runs walk, which calls emit for each file to hash, while
+// a bounded pool of goroutines runs hash on the files emitted so far. The
+// results come back in the order the files were emitted, whatever order
+// they finished in, and the error returned is that of the first file in
+// that order to fail.
+func hashInParallel(walk func(emit func(path string)) error, hash func(path string) (string, error)) ([]*fileHash, error) {
+	workers, err := hashWorkers()
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make(chan *fileHash, workers*4)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				job.hash, job.err = hash(job.path)
+			}
+		}()
+	}
+
+	// Only this goroutine appends to results; each job is filled in by the
+	// one worker that takes it, and read after they have all finished.
+	var results []*fileHash
+	walkErr := walk(func(path string) {
+		job := &fileHash{path: path}
+		results = append(results, job)
+		jobs <- job
+	})
+	close(jobs)
+	wg.Wait()
+
+	if walkErr != nil {
+		return nil, walkErr
+	}
+	for _, result := range results {
+		if result.err != nil {
+			return nil, result.err
+		}
+	}
+	return results, nil
+}
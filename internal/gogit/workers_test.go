@@ -0,0 +1,132 @@
+package gogit
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+var benchFiles = flag.Int("bench.files", 10000, "number of files in the tree the hashing benchmarks generate")
+
+// generateTree writes files files, from a few bytes to a few kilobytes,
+// across 100 directories under dir. The content only depends on files.
+func generateTree(t testing.TB, dir string, files int) {
+	t.Helper()
+	random := rand.New(rand.NewSource(1))
+	for i := range files {
+		path := filepath.Join(dir, fmt.Sprintf("d%02d", i%100), fmt.Sprintf("f%06d.txt", i))
+		if i < 100 {
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+		}
+		var content bytes.Buffer
+		for line := range 1 + random.Intn(64) {
+			fmt.Fprintf(&content, "file %d line %d %f\n", i, line, random.Float64())
+		}
+		if err := os.WriteFile(path, content.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// quietly runs fn with standard output discarded, since add prints a
+// line per file.
+func quietly(t testing.TB, fn func() error) {
+	t.Helper()
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devNull.Close()
+	stdout := os.Stdout
+	os.Stdout = devNull
+	err = fn()
+	os.Stdout = stdout
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// setWorkers sets core.workers in the current repository.
+func setWorkers(t testing.TB, workers int) {
+	t.Helper()
+	if err := SetConfig("core.workers", strconv.Itoa(workers), false); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// benchmarkWorkers runs bench in a repository holding a generated tree,
+// once hashing one file at a time and once with the default pool of one
+// worker per CPU.
+func benchmarkWorkers(b *testing.B, bench func(b *testing.B)) {
+	dir := initTestRepo(b, b.TempDir(), "tree")
+	generateTree(b, dir, *benchFiles)
+	chdir(b, dir)
+	for _, workers := range []int{1, 0} {
+		name := "workers=" + strconv.Itoa(workers)
+		if workers == 0 {
+			name = "workers=default"
+		}
+		b.Run(name, func(b *testing.B) {
+			setWorkers(b, workers)
+			bench(b)
+		})
+	}
+}
+
+func BenchmarkAdd(b *testing.B) {
+	benchmarkWorkers(b, func(b *testing.B) {
+		for range b.N {
+			// Every run stores the objects anew into an empty index.
+			b.StopTimer()
+			if err := os.RemoveAll(ObjectsPath); err != nil {
+				b.Fatal(err)
+			}
+			if err := WriteIndex(map[string]string{}); err != nil {
+				b.Fatal(err)
+			}
+			b.StartTimer()
+			quietly(b, func() error { return Add(".") })
+		}
+	})
+}
+
+func BenchmarkStatus(b *testing.B) {
+	benchmarkWorkers(b, func(b *testing.B) {
+		b.StopTimer()
+		quietly(b, func() error { return Add(".") })
+		b.StartTimer()
+		for range b.N {
+			if _, err := GetStatus(false); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestAddSameIndexWithAnyWorkers(t *testing.T) {
+	root := t.TempDir()
+	var reference []byte
+	for _, workers := range []int{1, 2, 4, 0} {
+		dir := initTestRepo(t, root, "workers"+strconv.Itoa(workers))
+		generateTree(t, dir, 300)
+		chdir(t, dir)
+		setWorkers(t, workers)
+		quietly(t, func() error { return Add(".") })
+		index, err := os.ReadFile(IndexPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reference == nil {
+			reference = index
+		} else if !bytes.Equal(index, reference) {
+			t.Fatalf("the index written with %d workers differs from the one written with 1", workers)
+		}
+	}
+}